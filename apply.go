@@ -0,0 +1,93 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"fmt"
+	"sort"
+)
+
+// OpKind is the kind of an Op.
+type OpKind uint8
+
+const (
+	// OpPut inserts or updates the key, as with Txn.Insert.
+	OpPut OpKind = iota
+
+	// OpDelete deletes the key, as with Txn.Delete.
+	OpDelete
+
+	// OpDeletePrefix deletes every key that starts with the key, as with
+	// Txn.DeletePrefix.
+	OpDeletePrefix
+)
+
+// Op is an operation applied to a transaction by Txn.Apply. Val is only used
+// by OpPut.
+type Op[T any] struct {
+	Kind OpKind
+	Key  []byte
+	Val  T
+}
+
+// Apply applies the given operations to the transaction in order, so that
+// replicated logs of changes, such as the entries of a Raft FSM, can be applied
+// with a single call. Consecutive puts are inserted together as with
+// InsertMany. It stops at the first operation that fails, returning its error,
+// and the operations before it stay applied. If the transaction already has an
+// error, as returned by Err, nothing is applied and it's returned.
+func (t *Txn[T]) Apply(ops []Op[T]) error {
+	return t.apply(ops, false)
+}
+
+// ApplySorted is like Apply, but sorts each run of consecutive puts in the
+// order of the tree before inserting it, so that nodes along shared paths are
+// only copied once. The result is the same as for Apply, since the last put of
+// a key in a run still wins.
+func (t *Txn[T]) ApplySorted(ops []Op[T]) error {
+	return t.apply(ops, true)
+}
+
+func (t *Txn[T]) apply(ops []Op[T], sortPuts bool) error {
+	if t.err != nil {
+		return t.err
+	}
+	for i := 0; i < len(ops); i++ {
+		switch op := ops[i]; op.Kind {
+		case OpPut:
+			j := i + 1
+			for j < len(ops) && ops[j].Kind == OpPut {
+				j++
+			}
+			t.applyPuts(ops[i:j], sortPuts)
+			i = j - 1
+		case OpDelete:
+			t.Delete(op.Key)
+		case OpDeletePrefix:
+			t.DeletePrefix(op.Key)
+		default:
+			return fmt.Errorf("iradix: unknown op kind %d", op.Kind)
+		}
+		if t.err != nil {
+			return t.err
+		}
+	}
+	return nil
+}
+
+// applyPuts inserts a run of puts.
+func (t *Txn[T]) applyPuts(ops []Op[T], sortPuts bool) {
+	kvs := make([]KV[T], len(ops))
+	for i, op := range ops {
+		kvs[i] = KV[T]{Key: op.Key, Val: op.Val}
+	}
+	if sortPuts && len(kvs) > 1 {
+		s := sortedKVs[T]{kvs: kvs, paths: make([][]byte, len(kvs))}
+		for i, kv := range kvs {
+			s.paths[i] = t.conf.path(kv.Key)
+		}
+		sort.Stable(s)
+	}
+	t.InsertMany(kvs)
+}
@@ -0,0 +1,67 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTxn_Apply(t *testing.T) {
+	ops := []Op[int]{
+		{Kind: OpPut, Key: []byte("foo/b"), Val: 1},
+		{Kind: OpPut, Key: []byte("foo/a"), Val: 2},
+		{Kind: OpPut, Key: []byte("foo/b"), Val: 3},
+		{Kind: OpPut, Key: []byte("bar"), Val: 4},
+		{Kind: OpDelete, Key: []byte("foo/a")},
+		{Kind: OpPut, Key: []byte("zip/1"), Val: 5},
+		{Kind: OpPut, Key: []byte("zip/2"), Val: 6},
+		{Kind: OpDeletePrefix, Key: []byte("zip/")},
+		{Kind: OpPut, Key: []byte("zip/3"), Val: 7},
+	}
+	for _, sorted := range []bool{false, true} {
+		for _, base := range []*Tree[int]{New[int](), New[int]().BulkLoad([]KV[int]{{Key: []byte("a")}})} {
+			txn := base.Txn()
+			apply := txn.Apply
+			if sorted {
+				apply = txn.ApplySorted
+			}
+			if err := apply(ops); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			r := txn.Commit()
+			expect := []string{"bar", "foo/b", "zip/3"}
+			if base.Len() != 0 {
+				expect = append([]string{"a"}, expect...)
+			}
+			verifyTree(t, expect, r)
+			if v, _ := r.Get([]byte("foo/b")); v != 3 {
+				t.Fatalf("bad value: %d", v)
+			}
+			checkCounts(t, r.Root())
+		}
+	}
+
+	// Applying stops at the first failure.
+	txn := New[int](WithMemoryBudget(4, func(int) int { return 0 })).Txn()
+	err := txn.Apply([]Op[int]{
+		{Kind: OpPut, Key: []byte("ab"), Val: 1},
+		{Kind: OpPut, Key: []byte("cde"), Val: 2},
+		{Kind: OpDelete, Key: []byte("ab")},
+	})
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected budget error, got %v", err)
+	}
+	if _, ok := txn.Get([]byte("ab")); !ok {
+		t.Fatalf("expected later ops not to be applied")
+	}
+	if err := txn.Apply(nil); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected the error to be kept, got %v", err)
+	}
+
+	txn = New[int]().Txn()
+	if err := txn.Apply([]Op[int]{{Kind: 42, Key: []byte("a")}}); err == nil {
+		t.Fatalf("expected error for unknown op")
+	}
+}
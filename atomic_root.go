@@ -0,0 +1,68 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import "sync/atomic"
+
+// CommitIfUnchanged commits the transaction if base, the latest published
+// version of the tree, is the tree the transaction was started from, as told
+// by their versions. Otherwise another commit was published after the
+// transaction began, so ErrConflict is returned and the transaction isn't
+// committed, which lets it be rolled back, or reset and retried on the newer
+// tree.
+//
+// Checking and then publishing the new tree isn't atomic, so writers that
+// publish trees concurrently should do both with AtomicRoot.Commit.
+func (t *Txn[T]) CommitIfUnchanged(base *Tree[T]) (*Tree[T], error) {
+	if t.base == nil || base.version != t.base.version {
+		return nil, ErrConflict
+	}
+	return t.Commit(), nil
+}
+
+// AtomicRoot holds the latest published version of a tree, which any number of
+// goroutines can read and write without locks. Writers start transactions from
+// the tree returned by Load and publish them with Commit, which fails if
+// another writer published a tree in the meantime, in which case the write is
+// retried on the newer tree.
+type AtomicRoot[T any] struct {
+	v atomic.Value
+}
+
+// NewAtomicRoot returns an AtomicRoot holding the given tree.
+func NewAtomicRoot[T any](t *Tree[T]) *AtomicRoot[T] {
+	r := &AtomicRoot[T]{}
+	r.v.Store(t)
+	return r
+}
+
+// Load returns the latest published tree.
+func (r *AtomicRoot[T]) Load() *Tree[T] {
+	return r.v.Load().(*Tree[T])
+}
+
+// Store publishes the given tree unconditionally.
+func (r *AtomicRoot[T]) Store(t *Tree[T]) {
+	r.v.Store(t)
+}
+
+// Commit commits txn and publishes the result if the latest published tree is
+// still the one txn was started from, returning ErrConflict without committing
+// otherwise. The transaction is committed before the tree is swapped in, so if
+// another writer wins the race in between, the commit's OnBeforePublish hook
+// will have run for a tree that's never published, and the transaction can
+// only be used again by resetting it. Its notifications are only sent once the
+// tree has been published.
+func (r *AtomicRoot[T]) Commit(txn *Txn[T]) (*Tree[T], error) {
+	base := r.Load()
+	if txn.base == nil || base.version != txn.base.version {
+		return nil, ErrConflict
+	}
+	nt := txn.CommitOnly()
+	if !r.v.CompareAndSwap(base, nt) {
+		return nil, ErrConflict
+	}
+	txn.Notify()
+	return nt, nil
+}
@@ -0,0 +1,72 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestTxn_CommitIfUnchanged(t *testing.T) {
+	r := New[int]()
+	a, b := r.Txn(), r.Txn()
+	a.Insert([]byte("foo"), 1)
+	b.Insert([]byte("foo"), 2)
+
+	ra, err := a.CommitIfUnchanged(r)
+	if err != nil || ra.Version() <= r.Version() || ra.Generation() != 1 {
+		t.Fatalf("bad commit: %v", err)
+	}
+	if _, err := b.CommitIfUnchanged(ra); err != ErrConflict || b.committed {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+
+	// A commit started from the same tree also has the same generation, but
+	// its own version.
+	rb := b.Commit()
+	if rb.Generation() != ra.Generation() || rb.Version() <= ra.Version() {
+		t.Fatalf("bad versions")
+	}
+}
+
+func TestAtomicRoot(t *testing.T) {
+	root := NewAtomicRoot(New[int]())
+	const writers, writes = 8, 100
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	conflicts := 0
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			txn := root.Load().Txn()
+			for j := 0; j < writes; {
+				txn.Update([]byte("count"), func(old int, _ bool) (int, bool) { return old + 1, true })
+				if _, err := root.Commit(txn); err != nil {
+					if err != ErrConflict {
+						t.Errorf("err: %v", err)
+						return
+					}
+					mu.Lock()
+					conflicts++
+					mu.Unlock()
+				} else {
+					j++
+				}
+				txn.Reset(root.Load())
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := root.Load().Get([]byte("count")); v != writers*writes {
+		t.Fatalf("bad count %d with %d conflicts", v, conflicts)
+	}
+
+	// A transaction started from an older tree is rejected.
+	old := root.Load()
+	root.Store(New[int]())
+	if _, err := root.Commit(old.Txn()); err != ErrConflict {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// KeysBitmap returns a bitset of the width-byte suffixes that exist under the
+// given prefix. Every key made of the prefix followed by exactly width more
+// bytes sets the bit whose index is those bytes read as a big-endian integer,
+// so bit i is set in word i/64 at position i%64. Keys under the prefix with
+// shorter or longer suffixes are ignored. This is handy for keys that encode
+// fixed-size slots, to find which slots are taken without iterating over them.
+//
+// Only the subtrees that can hold keys of the right length are visited. The
+// width must be between 1 and 3, for bitsets of up to 2^24 bits; this panics
+// otherwise.
+func (n *Node[T]) KeysBitmap(prefix []byte, width int) []uint64 {
+	if width < 1 || width > 3 {
+		panic(fmt.Sprintf("iradix: invalid bitmap width %d", width))
+	}
+	bits := make([]uint64, (1<<(8*width))/64)
+
+	// Find the node holding the prefix, and the part of its prefix that's
+	// past the one we're given.
+	search := prefix
+	var rest []byte
+	for len(search) != 0 {
+		_, n = n.getEdge(search[0])
+		if n == nil {
+			return bits
+		}
+		if bytes.HasPrefix(search, n.prefix) {
+			search = search[len(n.prefix):]
+		} else if bytes.HasPrefix(n.prefix, search) {
+			rest = n.prefix[len(search):]
+			break
+		} else {
+			return bits
+		}
+	}
+	keysBitmap(n, rest, width, 0, bits)
+	return bits
+}
+
+// keysBitmap sets the bits of the keys under n, where seg is the part of n's
+// prefix that's in the suffix, rem is the number of suffix bytes not yet seen
+// and val the value of the ones seen so far.
+func keysBitmap[T any](n *Node[T], seg []byte, rem int, val uint32, bits []uint64) {
+	if len(seg) > rem {
+		return
+	}
+	for _, b := range seg {
+		val = val<<8 | uint32(b)
+	}
+	rem -= len(seg)
+	if rem == 0 {
+		if n.leaf != nil {
+			bits[val/64] |= 1 << (val % 64)
+		}
+		return
+	}
+	for _, e := range n.edges() {
+		keysBitmap(e.node, e.node.prefix, rem, val, bits)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNode_KeysBitmap(t *testing.T) {
+	r := New[int]()
+	for i, k := range []string{"slot", "slot/", "slot/\x00", "slot/\x05", "slot/\xff", "slot/\x05\x01", "slot/\x01\x02", "slots/\x07", "other/\x03"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	set := func(bits []uint64) []int {
+		var out []int
+		for i := 0; i < len(bits)*64; i++ {
+			if bits[i/64]&(1<<(i%64)) != 0 {
+				out = append(out, i)
+			}
+		}
+		return out
+	}
+
+	cases := []struct {
+		prefix string
+		width  int
+		expect []int
+	}{
+		{"slot/", 1, []int{0, 5, 255}},
+		{"slot/", 2, []int{0x0102, 0x0501}},
+		{"slot/", 3, nil},
+		{"slot", 2, []int{'/'<<8 | 0, '/'<<8 | 5, '/'<<8 | 255}},
+		{"slo", 1, []int{'t'}},
+		{"sl", 3, []int{'o'<<16 | 't'<<8 | '/'}},
+		{"", 1, nil},
+		{"missing", 1, nil},
+		{"slotx", 1, nil},
+	}
+	for _, c := range cases {
+		bits := r.Root().KeysBitmap([]byte(c.prefix), c.width)
+		if len(bits) != 1<<(8*c.width)/64 {
+			t.Fatalf("%q %d: bad len %d", c.prefix, c.width, len(bits))
+		}
+		if got := set(bits); !reflect.DeepEqual(got, c.expect) {
+			t.Fatalf("%q %d: got %v, want %v", c.prefix, c.width, got, c.expect)
+		}
+	}
+
+	// Compare with brute force on random keys.
+	r = New[int]()
+	for i := 0; i < 2000; i++ {
+		k := make([]byte, 3+rand.Intn(3))
+		copy(k, "ab")
+		for j := 2; j < len(k); j++ {
+			k[j] = byte(rand.Intn(8))
+		}
+		r, _, _ = r.Insert(k, i)
+	}
+	for width := 1; width <= 3; width++ {
+		expect := make([]uint64, 1<<(8*width)/64)
+		r.Root().WalkPrefix([]byte("ab"), func(k []byte, _ int) bool {
+			if s := k[2:]; len(s) == width {
+				v := 0
+				for _, b := range s {
+					v = v<<8 | int(b)
+				}
+				expect[v/64] |= 1 << (v % 64)
+			}
+			return false
+		})
+		if got := r.Root().KeysBitmap([]byte("ab"), width); !reflect.DeepEqual(got, expect) {
+			t.Fatalf("width %d: mismatch", width)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "width") {
+			t.Fatalf("expected panic, got %v", r)
+		}
+	}()
+	r.Root().KeysBitmap(nil, 4)
+}
@@ -0,0 +1,175 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import "bytes"
+
+// FirstInRange returns the smallest key under this node that is in the range
+// [start, end), with its value. A nil end means the range has no upper bound.
+// This only visits the nodes on the paths to the range boundaries, so it's much
+// cheaper than seeking an iterator to read a single element.
+func (n *Node[T]) FirstInRange(start, end []byte) ([]byte, T, bool) {
+	leaf, path := n.lowerBound(start, true, []byte{})
+	if leaf == nil || (end != nil && bytes.Compare(path, end) >= 0) {
+		var zero T
+		return nil, zero, false
+	}
+	return leaf.key, leaf.val, true
+}
+
+// LastInRange returns the largest key under this node that is in the range
+// [start, end), with its value. A nil end means the range has no upper bound.
+// Like FirstInRange, this only visits the nodes on the paths to the range
+// boundaries.
+func (n *Node[T]) LastInRange(start, end []byte) ([]byte, T, bool) {
+	var leaf *leafNode[T]
+	var path []byte
+	if end == nil {
+		leaf, path = maxLeaf(n, []byte{})
+	} else {
+		leaf, path = n.floor(end, false, []byte{})
+	}
+	if leaf == nil || bytes.Compare(path, start) < 0 {
+		var zero T
+		return nil, zero, false
+	}
+	return leaf.key, leaf.val, true
+}
+
+// Floor returns the largest key under this node that is less than or equal to
+// k, with its value. Like FirstInRange, this only visits the nodes on the path
+// to the key, and it doesn't allocate.
+func (n *Node[T]) Floor(k []byte) ([]byte, T, bool) {
+	leaf, _ := n.floor(k, true, nil)
+	if leaf == nil {
+		var zero T
+		return nil, zero, false
+	}
+	return leaf.key, leaf.val, true
+}
+
+// Ceiling returns the smallest key under this node that is greater than or
+// equal to k, with its value. Like Floor, it doesn't allocate.
+func (n *Node[T]) Ceiling(k []byte) ([]byte, T, bool) {
+	leaf, _ := n.lowerBound(k, true, nil)
+	if leaf == nil {
+		var zero T
+		return nil, zero, false
+	}
+	return leaf.key, leaf.val, true
+}
+
+// comparePrefix compares a node's prefix against the start of the search key,
+// treating a prefix that's longer than the search but equal to it for the
+// search's length as greater.
+func comparePrefix(prefix, search []byte) int {
+	if len(prefix) > len(search) {
+		return bytes.Compare(prefix, search)
+	}
+	return bytes.Compare(prefix, search[:len(prefix)])
+}
+
+// lowerBound returns the leaf with the smallest path under n that is greater
+// than search, or equal to it if inclusive is set, along with that path. The
+// search is relative to the end of n's prefix, and path is the path to n,
+// which the returned path is appended to. If path is nil, no path is built and
+// nil is returned for it, which avoids allocating when it isn't needed.
+func (n *Node[T]) lowerBound(search []byte, inclusive bool, path []byte) (*leafNode[T], []byte) {
+	if len(search) == 0 {
+		if inclusive && n.leaf != nil {
+			return n.leaf, path
+		}
+		if len(n.edges()) == 0 {
+			return nil, nil
+		}
+		e := n.edges()[0].node
+		return minLeaf(e, appendPath(path, e.prefix))
+	}
+
+	// This node's own leaf is a prefix of the search, so it's smaller. Try
+	// the children from the first one that could hold the bound.
+	for idx := n.searchEdges(search[0]); idx < len(n.edges()); idx++ {
+		child := n.edges()[idx].node
+		childPath := appendPath(path, child.prefix)
+		cmp := comparePrefix(child.prefix, search)
+		if cmp > 0 {
+			return minLeaf(child, childPath)
+		}
+		if cmp == 0 {
+			if leaf, p := child.lowerBound(search[len(child.prefix):], inclusive, childPath); leaf != nil {
+				return leaf, p
+			}
+		}
+	}
+	return nil, nil
+}
+
+// floor is the reverse of lowerBound, returning the leaf with the largest path
+// under n that is less than search, or equal to it if inclusive is set.
+func (n *Node[T]) floor(search []byte, inclusive bool, path []byte) (*leafNode[T], []byte) {
+	if len(search) == 0 {
+		if inclusive && n.leaf != nil {
+			return n.leaf, path
+		}
+		return nil, nil
+	}
+
+	// Try the children from the last one that could hold the bound, and then
+	// this node's own leaf, which is smaller than all of them.
+	idx := n.searchEdges(search[0])
+	if idx == len(n.edges()) || n.edges()[idx].label > search[0] {
+		idx--
+	}
+	for ; idx >= 0; idx-- {
+		child := n.edges()[idx].node
+		childPath := appendPath(path, child.prefix)
+		cmp := comparePrefix(child.prefix, search)
+		if cmp < 0 {
+			return maxLeaf(child, childPath)
+		}
+		if cmp == 0 {
+			if leaf, p := child.floor(search[len(child.prefix):], inclusive, childPath); leaf != nil {
+				return leaf, p
+			}
+		}
+	}
+	if n.leaf != nil {
+		return n.leaf, path
+	}
+	return nil, nil
+}
+
+// minLeaf returns the leaf with the smallest path under n, which has the given
+// path, and that leaf's path, which is nil if the given path is.
+func minLeaf[T any](n *Node[T], path []byte) (*leafNode[T], []byte) {
+	for n.leaf == nil {
+		if len(n.edges()) == 0 {
+			return nil, nil
+		}
+		n = n.edges()[0].node
+		path = appendPath(path, n.prefix)
+	}
+	return n.leaf, path
+}
+
+// maxLeaf is the reverse of minLeaf.
+func maxLeaf[T any](n *Node[T], path []byte) (*leafNode[T], []byte) {
+	for len(n.edges()) != 0 {
+		n = n.edges()[len(n.edges())-1].node
+		path = appendPath(path, n.prefix)
+	}
+	if n.leaf == nil {
+		return nil, nil
+	}
+	return n.leaf, path
+}
+
+// appendPath appends prefix to path, unless path is nil, which means the path
+// isn't needed.
+func appendPath(path, prefix []byte) []byte {
+	if path == nil {
+		return nil
+	}
+	return append(path, prefix...)
+}
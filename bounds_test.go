@@ -0,0 +1,133 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestNode_InRange(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randKey := func() []byte {
+		k := make([]byte, rng.Intn(5))
+		for i := range k {
+			k[i] = "abc"[rng.Intn(3)]
+		}
+		return k
+	}
+
+	for trial := 0; trial < 200; trial++ {
+		r := New[int]()
+		for i := 0; i < rng.Intn(30); i++ {
+			r, _, _ = r.Insert(randKey(), i)
+		}
+		var keys [][]byte
+		r.Root().Walk(func(k []byte, _ int) bool {
+			keys = append(keys, k)
+			return false
+		})
+		if !sort.SliceIsSorted(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 }) {
+			t.Fatalf("keys not sorted")
+		}
+
+		for q := 0; q < 50; q++ {
+			start, end := randKey(), randKey()
+			if rng.Intn(5) == 0 {
+				end = nil
+			}
+
+			var first, last []byte
+			for _, k := range keys {
+				if bytes.Compare(k, start) >= 0 && (end == nil || bytes.Compare(k, end) < 0) {
+					if first == nil {
+						first = k
+					}
+					last = k
+				}
+			}
+
+			k, v, ok := r.Root().FirstInRange(start, end)
+			if ok != (first != nil) || !bytes.Equal(k, first) {
+				t.Fatalf("first in [%q, %q): got %q %v, expected %q", start, end, k, ok, first)
+			}
+			if ok {
+				if expect, _ := r.Get(k); v != expect {
+					t.Fatalf("bad value: %v", v)
+				}
+			}
+			k, _, ok = r.Root().LastInRange(start, end)
+			if ok != (last != nil) || !bytes.Equal(k, last) {
+				t.Fatalf("last in [%q, %q): got %q %v, expected %q", start, end, k, ok, last)
+			}
+		}
+	}
+}
+
+func TestNode_FloorCeiling(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randKey := func() []byte {
+		k := make([]byte, rng.Intn(5))
+		for i := range k {
+			k[i] = "abc"[rng.Intn(3)]
+		}
+		return k
+	}
+
+	for trial := 0; trial < 200; trial++ {
+		r := New[int](WithoutDeleteMerge())
+		var keys [][]byte
+		for i := 0; i < rng.Intn(30); i++ {
+			r, _, _ = r.Insert(randKey(), i)
+		}
+		r, _, _ = r.Delete(randKey())
+		r.Root().Walk(func(k []byte, _ int) bool {
+			keys = append(keys, k)
+			return false
+		})
+
+		for q := 0; q < 50; q++ {
+			k := randKey()
+			var floor, ceiling []byte
+			for _, key := range keys {
+				if bytes.Compare(key, k) <= 0 {
+					floor = key
+				}
+				if ceiling == nil && bytes.Compare(key, k) >= 0 {
+					ceiling = key
+				}
+			}
+
+			got, v, ok := r.Root().Floor(k)
+			if ok != (floor != nil) || !bytes.Equal(got, floor) {
+				t.Fatalf("floor of %q: got %q %v, expected %q", k, got, ok, floor)
+			}
+			if expect, _ := r.Get(got); ok && v != expect {
+				t.Fatalf("bad value: %v", v)
+			}
+			got, v, ok = r.Ceiling(k)
+			if ok != (ceiling != nil) || !bytes.Equal(got, ceiling) {
+				t.Fatalf("ceiling of %q: got %q %v, expected %q", k, got, ok, ceiling)
+			}
+			if expect, _ := r.Get(got); ok && v != expect {
+				t.Fatalf("bad value: %v", v)
+			}
+		}
+	}
+
+	r := New[int]()
+	for _, k := range []string{"key/002", "key/004", "key/010"} {
+		r, _, _ = r.Insert([]byte(k), 0)
+	}
+	k := []byte("key/005")
+	allocs := testing.AllocsPerRun(10, func() {
+		r.Root().Floor(k)
+		r.Root().Ceiling(k)
+	})
+	if allocs != 0 {
+		t.Fatalf("expected no allocations, got %v", allocs)
+	}
+}
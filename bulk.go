@@ -0,0 +1,264 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"bytes"
+	"sort"
+)
+
+// KV is a key and its value, as passed to InsertMany.
+type KV[T any] struct {
+	Key []byte
+	Val T
+}
+
+// InsertMany inserts all the given keys and values, as if Insert was called
+// for each of them in order. If the transaction's tree is empty and the keys
+// are sorted in the order of the tree, the tree is built bottom-up instead,
+// creating every node exactly once, which is much faster for loading large
+// trees. Otherwise the keys are inserted one at a time, which is still faster
+// with sorted keys since nodes along shared paths are only copied once. If a
+// key appears more than once, the last value wins.
+func (t *Txn[T]) InsertMany(kvs []KV[T]) {
+	if !t.bulkLoad(kvs) {
+		for _, kv := range kvs {
+			t.Insert(kv.Key, kv.Val)
+		}
+	}
+}
+
+// BulkLoad returns a new tree with all the given keys and values inserted, as
+// with Txn.InsertMany. Loading sorted keys into an empty tree builds it
+// bottom-up.
+func (t *Tree[T]) BulkLoad(kvs []KV[T]) *Tree[T] {
+	txn := t.Txn()
+	txn.InsertMany(kvs)
+	return txn.Commit()
+}
+
+// NewFromMap returns a new tree, configured with the given options, holding
+// the keys and values of m. The keys are sorted first so that the tree is
+// built bottom-up, as with BulkLoad.
+func NewFromMap[T any](m map[string]T, opts ...Option) *Tree[T] {
+	t := New[T](opts...)
+	s := sortedKVs[T]{
+		kvs:   make([]KV[T], 0, len(m)),
+		paths: make([][]byte, 0, len(m)),
+	}
+	for k, v := range m {
+		key := []byte(k)
+		s.kvs = append(s.kvs, KV[T]{Key: key, Val: v})
+		s.paths = append(s.paths, t.conf.path(key))
+	}
+	sort.Sort(s)
+	return t.BulkLoad(s.kvs)
+}
+
+// NewFromSeq returns a new tree, configured with the given options, holding
+// the keys and values produced by seq, which calls yield for each of them in
+// turn and stops early if it returns false, in the style of a range-over-func
+// iterator. The entries are loaded in a single transaction, as with
+// Txn.InsertMany, and if they're sorted in the order of the tree the tree is
+// built bottom-up. If they aren't, the ones before the first out of order key
+// are built bottom-up and the rest are inserted one at a time. The keys are
+// retained as with Insert, so a producer that reuses its buffers must copy
+// them or the tree must be created WithCopyKeys.
+func NewFromSeq[T any](seq func(yield func(k []byte, v T) bool), opts ...Option) *Tree[T] {
+	l := streamLoader[T]{txn: New[T](opts...).Txn()}
+	seq(func(k []byte, v T) bool {
+		l.add(k, v)
+		return true
+	})
+	return l.commit()
+}
+
+// NewFromChannel is like NewFromSeq, but reads the keys and values from ch
+// until it's closed.
+func NewFromChannel[T any](ch <-chan KV[T], opts ...Option) *Tree[T] {
+	l := streamLoader[T]{txn: New[T](opts...).Txn()}
+	for kv := range ch {
+		l.add(kv.Key, kv.Val)
+	}
+	return l.commit()
+}
+
+// streamLoader loads a stream of entries into a transaction, buffering them
+// for a bulk load for as long as they're sorted.
+type streamLoader[T any] struct {
+	txn *Txn[T]
+	kvs []KV[T]
+
+	// last is the path of the last buffered entry.
+	last []byte
+
+	// unsorted is set once an entry was out of order, after which entries
+	// are inserted directly.
+	unsorted bool
+}
+
+func (l *streamLoader[T]) add(k []byte, v T) {
+	if !l.unsorted {
+		path := l.txn.conf.path(k)
+		if len(l.kvs) == 0 || bytes.Compare(l.last, path) <= 0 {
+			l.kvs = append(l.kvs, KV[T]{Key: k, Val: v})
+			l.last = path
+			return
+		}
+		l.flush()
+		l.unsorted = true
+	}
+	l.txn.Insert(k, v)
+}
+
+// flush loads the buffered entries.
+func (l *streamLoader[T]) flush() {
+	l.txn.InsertMany(l.kvs)
+	l.kvs, l.last = nil, nil
+}
+
+func (l *streamLoader[T]) commit() *Tree[T] {
+	l.flush()
+	return l.txn.Commit()
+}
+
+// sortedKVs sorts entries by their paths in the tree.
+type sortedKVs[T any] struct {
+	kvs   []KV[T]
+	paths [][]byte
+}
+
+func (s sortedKVs[T]) Len() int           { return len(s.kvs) }
+func (s sortedKVs[T]) Less(i, j int) bool { return bytes.Compare(s.paths[i], s.paths[j]) < 0 }
+func (s sortedKVs[T]) Swap(i, j int) {
+	s.kvs[i], s.kvs[j] = s.kvs[j], s.kvs[i]
+	s.paths[i], s.paths[j] = s.paths[j], s.paths[i]
+}
+
+// bulkLoad builds the tree from the given entries, returning false if that's
+// not possible because the tree isn't empty, the keys aren't sorted, or they
+// have to go through the checks of Insert.
+func (t *Txn[T]) bulkLoad(kvs []KV[T]) bool {
+	if len(kvs) == 0 || t.size != 0 || !t.checkUse("Insert") {
+		return false
+	}
+	paths := make([][]byte, len(kvs))
+	size := 0
+	for i, kv := range kvs {
+		if kv.Key == nil {
+			return false
+		}
+		paths[i] = t.conf.path(kv.Key)
+		if i > 0 && bytes.Compare(paths[i-1], paths[i]) > 0 {
+			return false
+		}
+		if t.conf.sizer != nil {
+			// Only the last of the entries for a key is kept.
+			if i > 0 && bytes.Equal(paths[i-1], paths[i]) {
+				size -= t.entrySize(kvs[i-1].Key, kvs[i-1].Val)
+			}
+			size += t.entrySize(kv.Key, kv.Val)
+		}
+	}
+	if t.conf.sizer != nil && size > t.conf.budget {
+		return false
+	}
+
+	b := bulkBuilder[T]{txn: t, kvs: kvs, paths: paths}
+	if t.conf.copyKeys || t.conf.transform != nil || t.conf.fold || t.conf.intern != nil || t.conf.stats != nil {
+		b.kvs = make([]KV[T], len(kvs))
+		for i, kv := range kvs {
+			kv.Key = t.conf.key(kv.Key)
+			if t.conf.transform != nil && t.conf.collate == nil {
+				paths[i] = kv.Key
+			}
+			if t.conf.copyKeys {
+				kv.Key = append(make([]byte, 0, len(kv.Key)), kv.Key...)
+				if t.conf.collate == nil {
+					paths[i] = kv.Key
+				}
+			}
+			if t.conf.intern != nil {
+				kv.Val = t.conf.intern(kv.Val)
+			}
+			if t.conf.stats != nil {
+				t.conf.stats.record(accessInsert, kv.Key)
+			}
+			b.kvs[i] = kv
+		}
+	}
+
+	if t.trackMutate {
+		t.trackChannel(t.root.mutateCh)
+	}
+	root := b.fill(Node[T]{}, 0, len(kvs), 0)
+	t.root = root
+	t.size = root.count()
+	t.bytes += size
+	if t.trackChanges {
+		var zero T
+		recursiveWalk(root, func(k []byte, v T) bool {
+			t.recordPut(k, zero, false, v)
+			return false
+		})
+	}
+	return true
+}
+
+// bulkBuilder builds a tree bottom-up from sorted entries.
+type bulkBuilder[T any] struct {
+	txn   *Txn[T]
+	kvs   []KV[T]
+	paths [][]byte
+}
+
+// node returns the node for the entries in [lo, hi), whose paths share their
+// first depth bytes, and whose parent's path ends at start.
+func (b *bulkBuilder[T]) node(lo, hi, start, depth int) *Node[T] {
+	first, last := b.paths[lo], b.paths[hi-1]
+	depth += longestPrefix(first[depth:], last[depth:])
+	n := b.fill(Node[T]{prefix: first[start:depth]}, lo, hi, depth)
+	if b.txn.aliasLeafPrefix(n) {
+		n.prefix = leafPrefix(n.leaf, len(n.prefix))
+	}
+	return n
+}
+
+// fill adds the leaf and children for the entries in [lo, hi) to n, whose path
+// is the first depth bytes of theirs, and allocates it.
+func (b *bulkBuilder[T]) fill(n Node[T], lo, hi, depth int) *Node[T] {
+	t := b.txn
+
+	// Sorting puts the entries whose path ends here first, and the last of
+	// them wins.
+	end := lo
+	for end < hi && len(b.paths[end]) == depth {
+		end++
+	}
+	if end > lo {
+		kv := b.kvs[end-1]
+		n.leaf = t.newLeaf(kv.Key, kv.Val, nil)
+		if t.conf.hash != nil {
+			t.hash += t.entryHash(kv.Key, kv.Val)
+		}
+		lo = end
+	}
+
+	var es edges[T]
+	count := n.count()
+	for lo < hi {
+		label := b.paths[lo][depth]
+		end := lo + 1
+		for end < hi && b.paths[end][depth] == label {
+			end++
+		}
+		child := b.node(lo, end, depth, depth+1)
+		es = append(es, edge[T]{label: label, node: child})
+		count += child.count()
+		lo = end
+	}
+	n.setEdges(es)
+	n.setCount(count)
+	return t.allocNode(n)
+}
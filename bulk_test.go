@@ -0,0 +1,236 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// nodeLayout describes the structure of the tree under n.
+func nodeLayout[T any](n *Node[T]) []string {
+	var out []string
+	var walk func(n *Node[T], depth int)
+	walk = func(n *Node[T], depth int) {
+		s := fmt.Sprintf("%d %q %d", depth, n.prefix, n.count())
+		if n.leaf != nil {
+			s += fmt.Sprintf(" %q=%v", n.leaf.key, n.leaf.val)
+		}
+		out = append(out, s)
+		for _, e := range n.edges() {
+			walk(e.node, depth+1)
+		}
+	}
+	walk(n, 0)
+	return out
+}
+
+func TestBulkLoad(t *testing.T) {
+	hash := func(v int) uint64 { return uint64(v) }
+	size := func(int) int { return 8 }
+	optsCases := [][]Option{
+		nil,
+		{WithContentHash(hash), WithMemoryBudget(1<<20, size), WithLeafMeta()},
+		{WithCollation(FoldCaseCollation()), WithCopyKeys()},
+	}
+	for i, opts := range optsCases {
+		var kvs []KV[int]
+		kvs = append(kvs, KV[int]{Key: []byte{}, Val: -1})
+		for j := 0; j < 1000; j++ {
+			k := fmt.Sprintf("%c/%d/%d", "aAbBc"[rand.Intn(5)], rand.Intn(50), rand.Intn(5))
+			kvs = append(kvs, KV[int]{Key: []byte(k), Val: j})
+		}
+		expect := New[int](opts...)
+		for _, kv := range kvs {
+			expect, _, _ = expect.Insert(kv.Key, kv.Val)
+		}
+
+		// Sort in the order of the tree, keeping duplicates in order so the
+		// last value wins.
+		sort.SliceStable(kvs, func(a, b int) bool {
+			return string(expect.CollateKey(kvs[a].Key)) < string(expect.CollateKey(kvs[b].Key))
+		})
+		r := New[int](opts...).BulkLoad(kvs)
+
+		if got, want := nodeLayout(r.Root()), nodeLayout(expect.Root()); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%d: layouts differ:\n%v\n%v", i, got, want)
+		}
+		if r.Len() != expect.Len() || r.Bytes() != expect.Bytes() {
+			t.Fatalf("%d: bad len %d or bytes %d", i, r.Len(), r.Bytes())
+		}
+		if h, ok := r.ContentHash(); ok {
+			if e, _ := expect.ContentHash(); h != e {
+				t.Fatalf("%d: bad hash", i)
+			}
+		}
+		checkCounts(t, r.Root())
+		checkLeafPrefix(t, r.Root())
+		if m, ok := r.GetMeta(kvs[0].Key); ok && m.Created != 1 {
+			t.Fatalf("%d: bad meta %v", i, m)
+		}
+	}
+}
+
+func TestInsertMany_Fallback(t *testing.T) {
+	kvs := []KV[int]{{[]byte("b"), 1}, {[]byte("a"), 2}, {[]byte("c"), 3}}
+
+	// Unsorted keys are inserted one at a time.
+	r := New[int]().BulkLoad(kvs)
+	verifyTree(t, []string{"a", "b", "c"}, r)
+
+	// So are keys loaded into a non-empty tree.
+	txn := r.Txn()
+	txn.TrackMutate(true)
+	ch, _, _ := txn.GetWatch([]byte("c"))
+	txn.InsertMany([]KV[int]{{[]byte("c"), 4}, {[]byte("d"), 5}})
+	r = txn.Commit()
+	verifyTree(t, []string{"a", "b", "c", "d"}, r)
+	if v, _ := r.Get([]byte("c")); v != 4 {
+		t.Fatalf("bad: %v", v)
+	}
+	select {
+	case <-ch:
+	default:
+		t.Fatalf("expected notification")
+	}
+
+	// Going over the budget records the error as Insert does.
+	r = New[int](WithMemoryBudget(4, func(int) int { return 1 }))
+	txn = r.Txn()
+	txn.InsertMany([]KV[int]{{[]byte("a"), 1}, {[]byte("b"), 2}, {[]byte("c"), 3}})
+	if txn.Err() != ErrBudgetExceeded {
+		t.Fatalf("bad: %v", txn.Err())
+	}
+	verifyTree(t, []string{"a", "b"}, txn.Commit())
+
+	// Nil keys are misuse.
+	txn = New[int](WithMisusePolicy(MisuseReturnError)).Txn()
+	txn.InsertMany([]KV[int]{{nil, 1}})
+	if txn.Err() == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func BenchmarkBulkLoad(b *testing.B) {
+	kvs := make([]KV[int], 100000)
+	for i := range kvs {
+		kvs[i] = KV[int]{Key: []byte(fmt.Sprintf("service/%06d", i)), Val: i}
+	}
+	b.Run("Insert", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			txn := New[int]().Txn()
+			for _, kv := range kvs {
+				txn.Insert(kv.Key, kv.Val)
+			}
+			txn.Commit()
+		}
+	})
+	b.Run("BulkLoad", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			New[int]().BulkLoad(kvs)
+		}
+	})
+}
+
+func TestNewFromMap(t *testing.T) {
+	m := map[string]int{"foo/bar": 1, "FOO": 2, "": 3, "foo/Baz": 4, "zip": 5}
+	for _, opts := range [][]Option{nil, {WithCollation(FoldCaseCollation())}} {
+		r := NewFromMap(m, opts...)
+		expect := New[int](opts...)
+		for k, v := range m {
+			expect, _, _ = expect.Insert([]byte(k), v)
+		}
+		if !reflect.DeepEqual(nodeLayout(r.Root()), nodeLayout(expect.Root())) {
+			t.Fatalf("bad layout:\n%v", nodeLayout(r.Root()))
+		}
+		if err := CheckOrdered(r); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if got := r.ToMap(); !reflect.DeepEqual(got, m) {
+			t.Fatalf("bad map: %v", got)
+		}
+	}
+
+	r := NewFromMap(m)
+	if got := r.ToMapPrefix([]byte("foo/")); !reflect.DeepEqual(got, map[string]int{"foo/bar": 1, "foo/Baz": 4}) {
+		t.Fatalf("bad map: %v", got)
+	}
+	if got := r.ToMapPrefix([]byte("x")); got == nil || len(got) != 0 {
+		t.Fatalf("bad map: %v", got)
+	}
+	if NewFromMap[int](nil).Len() != 0 {
+		t.Fatalf("expected empty tree")
+	}
+}
+
+func TestNewFromSeq(t *testing.T) {
+	keys := []string{"", "a", "a/b", "a/c", "b", "b"}
+	seq := func(keys []string) func(yield func([]byte, int) bool) {
+		return func(yield func([]byte, int) bool) {
+			for i, k := range keys {
+				if !yield([]byte(k), i) {
+					return
+				}
+			}
+		}
+	}
+	for _, order := range [][]string{keys, {"b", "a/c", "", "a/b", "a", "b"}, {"a", "a/b", "", "b", "a/c", "b"}} {
+		expect := New[int]()
+		for i, k := range order {
+			expect, _, _ = expect.Insert([]byte(k), i)
+		}
+		r := NewFromSeq(seq(order))
+		if !reflect.DeepEqual(nodeLayout(r.Root()), nodeLayout(expect.Root())) {
+			t.Fatalf("bad layout for %q:\n%v", order, nodeLayout(r.Root()))
+		}
+		if err := CheckOrdered(r); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		ch := make(chan KV[int])
+		go func() {
+			for i, k := range order {
+				ch <- KV[int]{Key: []byte(k), Val: i}
+			}
+			close(ch)
+		}()
+		r = NewFromChannel(ch)
+		if !reflect.DeepEqual(nodeLayout(r.Root()), nodeLayout(expect.Root())) {
+			t.Fatalf("bad layout for %q:\n%v", order, nodeLayout(r.Root()))
+		}
+	}
+
+	// The order is that of the collation.
+	r := NewFromSeq(seq([]string{"A", "b", "B/c"}), WithCollation(FoldCaseCollation()))
+	if r.Len() != 3 || r.Generation() != 1 {
+		t.Fatalf("bad tree: %d %d", r.Len(), r.Generation())
+	}
+	if err := CheckOrdered(r); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// All the constructors commit the loaded tree in the same way.
+	published := 0
+	hooks := WithCommitHooks(CommitHooks[int]{OnBeforePublish: func(*Tree[int]) { published++ }})
+	ch := make(chan KV[int], 1)
+	ch <- KV[int]{Key: []byte("a"), Val: 1}
+	close(ch)
+	for _, r := range []*Tree[int]{
+		NewFromMap(map[string]int{"a": 1}, hooks),
+		NewFromSeq(seq([]string{"a"}), hooks),
+		NewFromChannel(ch, hooks),
+	} {
+		if r.Len() != 1 || r.Generation() != 1 {
+			t.Fatalf("bad tree: %d %d", r.Len(), r.Generation())
+		}
+	}
+	if published != 3 {
+		t.Fatalf("bad hook calls: %d", published)
+	}
+}
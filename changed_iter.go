@@ -0,0 +1,105 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import "strings"
+
+// ChangedIterator is used to iterate over the keys whose leaves differ between
+// two versions of a tree. Since committed trees share all the nodes that were
+// not modified, whole subtrees that are identical in both versions are skipped
+// without being visited.
+type ChangedIterator[T any] struct {
+	old *rawIterator[T]
+	new *rawIterator[T]
+}
+
+// NewChangedIterator returns a ChangedIterator over the keys that were inserted,
+// updated or deleted between the old and new roots. Keys are returned in order,
+// but no distinction is made between the kinds of change; callers that only
+// need to re-validate whatever changed can look each key up in the new root.
+func NewChangedIterator[T any](old, new *Node[T]) *ChangedIterator[T] {
+	return &ChangedIterator[T]{
+		old: old.rawIterator(),
+		new: new.rawIterator(),
+	}
+}
+
+// ModifiedIterator returns a ChangedIterator over the keys that were inserted,
+// updated or deleted by the transaction so far, relative to the tree it was
+// started from, which can be used before committing to update indexes of the
+// changes. A key that was inserted and then deleted again isn't returned.
+// Since the transaction modifies the nodes it has already copied in place, the
+// iterator must not be used across further writes.
+func (t *Txn[T]) ModifiedIterator() *ChangedIterator[T] {
+	return NewChangedIterator(t.snap, t.root)
+}
+
+// Next returns the next changed key in order.
+func (i *ChangedIterator[T]) Next() ([]byte, bool) {
+	oldLeaf, newLeaf, ok := i.next()
+	if !ok {
+		return nil, false
+	}
+	if newLeaf != nil {
+		return newLeaf.key, true
+	}
+	return oldLeaf.key, true
+}
+
+// next advances both raw iterators until they reach a path where the leaves
+// differ, and returns the old and new leaves at that path, either of which may
+// be nil.
+func (i *ChangedIterator[T]) next() (*leafNode[T], *leafNode[T], bool) {
+	for {
+		oldElem, newElem := i.old.Front(), i.new.Front()
+		if oldElem == nil && newElem == nil {
+			return nil, nil, false
+		}
+
+		// Order the two positions by path, treating an exhausted iterator
+		// as being past the end of the other one.
+		var cmp int
+		switch {
+		case oldElem == nil:
+			cmp = 1
+		case newElem == nil:
+			cmp = -1
+		default:
+			cmp = strings.Compare(i.old.Path(), i.new.Path())
+		}
+
+		// This path only exists in the old version, so any leaf here was
+		// deleted.
+		if cmp < 0 {
+			i.old.Next()
+			if oldElem.leaf != nil {
+				return oldElem.leaf, nil, true
+			}
+			continue
+		}
+
+		// This path only exists in the new version, so any leaf here was
+		// inserted.
+		if cmp > 0 {
+			i.new.Next()
+			if newElem.leaf != nil {
+				return nil, newElem.leaf, true
+			}
+			continue
+		}
+
+		// If it's the same node then nothing under it changed.
+		if oldElem == newElem {
+			i.old.skip()
+			i.new.skip()
+			continue
+		}
+
+		i.old.Next()
+		i.new.Next()
+		if oldElem.leaf != newElem.leaf {
+			return oldElem.leaf, newElem.leaf, true
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestChangedIterator(t *testing.T) {
+	r := New[int]()
+	keys := []string{"", "a", "ab", "abc", "b", "foo", "foo/bar", "foo/baz", "zip"}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	txn := r.Txn()
+	txn.Insert([]byte("abd"), 100)     // insert
+	txn.Insert([]byte("foo/bar"), 101) // update
+	txn.Delete([]byte("b"))            // delete
+	txn.Insert([]byte("zz"), 102)      // insert
+	txn.Delete([]byte("zz"))           // insert + delete is no change
+	txn.DeletePrefix([]byte("zip"))    // delete
+	nr := txn.Commit()
+
+	collect := func(old, new *Tree[int]) []string {
+		var out []string
+		it := NewChangedIterator(old.Root(), new.Root())
+		for k, ok := it.Next(); ok; k, ok = it.Next() {
+			out = append(out, string(k))
+		}
+		return out
+	}
+
+	want := []string{"abd", "b", "foo/bar", "zip"}
+	if got := collect(r, nr); !slices.Equal(got, want) {
+		t.Fatalf("bad: got %v, want %v", got, want)
+	}
+
+	// The operation is symmetric in the keys it reports.
+	if got := collect(nr, r); !slices.Equal(got, want) {
+		t.Fatalf("bad: got %v, want %v", got, want)
+	}
+
+	// No changes between identical roots.
+	if got := collect(r, r); len(got) != 0 {
+		t.Fatalf("bad: %v", got)
+	}
+
+	// Everything changed relative to an empty tree.
+	if got := collect(New[int](), r); !slices.Equal(got, keys) {
+		t.Fatalf("bad: got %v, want %v", got, keys)
+	}
+}
+
+func TestTxn_ModifiedIterator(t *testing.T) {
+	r := New[int]()
+	for i, k := range []string{"a", "b", "c/1", "c/2"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	txn := r.Txn()
+	txn.Insert([]byte("c/3"), 10)
+	txn.Insert([]byte("a"), 11)
+	txn.Delete([]byte("c/1"))
+	txn.Insert([]byte("d"), 12)
+	txn.Delete([]byte("d"))
+	it := txn.ModifiedIterator()
+
+	var got []string
+	for k, ok := it.Next(); ok; k, ok = it.Next() {
+		got = append(got, string(k))
+	}
+	if want := []string{"a", "c/1", "c/3"}; !slices.Equal(got, want) {
+		t.Fatalf("bad: got %v, want %v", got, want)
+	}
+}
+
+func TestChangedIterator_Random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randKey := func() string {
+		b := make([]byte, rnd.Intn(6))
+		for i := range b {
+			b[i] = "abc"[rnd.Intn(3)]
+		}
+		return string(b)
+	}
+
+	r := New[int]()
+	for i := 0; i < 500; i++ {
+		r, _, _ = r.Insert([]byte(randKey()), i)
+	}
+
+	for round := 0; round < 50; round++ {
+		txn := r.Txn()
+		expect := make(map[string]struct{})
+		for i := 0; i < 10; i++ {
+			k := randKey()
+			if rnd.Intn(2) == 0 {
+				txn.Insert([]byte(k), round*100+i)
+			} else {
+				txn.Delete([]byte(k))
+			}
+		}
+		nr := txn.Commit()
+
+		// Brute force the set of keys that differ.
+		all := make(map[string]struct{})
+		for _, tree := range []*Tree[int]{r, nr} {
+			tree.Root().Walk(func(k []byte, _ int) bool {
+				all[string(k)] = struct{}{}
+				return false
+			})
+		}
+		for k := range all {
+			ov, ook := r.Get([]byte(k))
+			nv, nok := nr.Get([]byte(k))
+			if ook != nok || ov != nv {
+				expect[k] = struct{}{}
+			}
+		}
+
+		var got []string
+		it := NewChangedIterator(r.Root(), nr.Root())
+		for k, ok := it.Next(); ok; k, ok = it.Next() {
+			got = append(got, string(k))
+		}
+		if !sort.StringsAreSorted(got) {
+			t.Fatalf("not sorted: %v", got)
+		}
+		for _, k := range got {
+			delete(expect, k)
+		}
+		if len(expect) != 0 {
+			t.Fatalf("missing changes: %v", fmt.Sprint(expect))
+		}
+		r = nr
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ChangelogWriter writes the changes made by every commit of a tree to an
+// io.Writer, so that followers can replay them with a ChangelogReader to keep
+// a copy of the tree up to date. It's installed with WithCommitHooks using the
+// hooks returned by Hooks.
+//
+// Each commit is written as a record of the generation of the new tree, as
+// returned by Tree.Generation, and the Patch from the tree the transaction was
+// started from, in its binary encoding. The record is the generation as a
+// uvarint, then the length of the encoded patch as a uvarint, then the patch.
+// A patch holds the net changes to each key rather than every operation, so a
+// key that was written twice in a transaction appears once, and one that was
+// inserted and deleted again doesn't appear at all. Replaying the records in
+// order rebuilds the tree as long as each transaction was started from the
+// tree committed before it, as in a store with a single line of versions.
+type ChangelogWriter[T any] struct {
+	c Codec[T]
+
+	// mu serializes the records of concurrent commits and guards the
+	// fields below it.
+	mu  sync.Mutex
+	w   io.Writer
+	buf []byte
+	out []byte
+	err error
+}
+
+// NewChangelogWriter returns a ChangelogWriter that writes to w, encoding values
+// with c.
+func NewChangelogWriter[T any](w io.Writer, c Codec[T]) *ChangelogWriter[T] {
+	return &ChangelogWriter[T]{w: w, c: c}
+}
+
+// Hooks returns the commit hooks that write the changelog, to be given to
+// WithCommitHooks. The record of a commit is written once its watch channels
+// have been closed, on the committing goroutine.
+func (cw *ChangelogWriter[T]) Hooks() CommitHooks[T] {
+	return CommitHooks[T]{OnAfterPublish: cw.write}
+}
+
+// Err returns the first error from encoding or writing a record. The writer
+// stops once it has failed, since the records after a missing one can't be
+// replayed.
+func (cw *ChangelogWriter[T]) Err() error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	return cw.err
+}
+
+// write writes the record of the commit of new from old.
+func (cw *ChangelogWriter[T]) write(old, new *Tree[T]) {
+	p := new.Diff(old)
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if cw.err != nil {
+		return
+	}
+	enc, err := p.AppendBinary(cw.buf[:0], cw.c)
+	if err != nil {
+		cw.err = err
+		return
+	}
+	cw.buf = enc
+	out := appendUvarint(cw.out[:0], new.generation)
+	out = appendUvarint(out, uint64(len(enc)))
+	cw.out = append(out, enc...)
+	if _, err := cw.w.Write(cw.out); err != nil {
+		cw.err = err
+	}
+}
+
+// ChangelogReader reads the records written by a ChangelogWriter.
+type ChangelogReader[T any] struct {
+	r   *bufio.Reader
+	c   Codec[T]
+	buf bytes.Buffer
+}
+
+// NewChangelogReader returns a ChangelogReader that reads from r, decoding
+// values with c.
+func NewChangelogReader[T any](r io.Reader, c Codec[T]) *ChangelogReader[T] {
+	return &ChangelogReader[T]{r: bufio.NewReader(r), c: c}
+}
+
+// Next reads the next record, returning the generation of the tree it was
+// committed as and the patch that turns the previous tree into it, which can
+// be applied with Patch.Apply. It returns io.EOF once there are no more
+// records, and an error wrapping ErrInvalidEncoding if a record is truncated
+// or corrupt.
+func (cr *ChangelogReader[T]) Next() (uint64, Patch[T], error) {
+	gen, err := binary.ReadUvarint(cr.r)
+	if err != nil {
+		if err == io.EOF {
+			return 0, nil, io.EOF
+		}
+		return 0, nil, cr.truncated(err)
+	}
+	n, err := binary.ReadUvarint(cr.r)
+	if err != nil {
+		return 0, nil, cr.truncated(err)
+	}
+
+	// Copy rather than allocating the whole length up front, so a corrupt
+	// length fails at the end of the data instead.
+	cr.buf.Reset()
+	if _, err := io.CopyN(&cr.buf, cr.r, int64(n)); err != nil {
+		return 0, nil, cr.truncated(err)
+	}
+	p, err := DecodePatch(cr.buf.Bytes(), cr.c)
+	if err != nil {
+		return 0, nil, err
+	}
+	return gen, p, nil
+}
+
+// truncated returns the error for a record that ended early with err.
+func (cr *ChangelogReader[T]) truncated(err error) error {
+	if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: truncated changelog record", ErrInvalidEncoding)
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestChangelog(t *testing.T) {
+	var log bytes.Buffer
+	cw := NewChangelogWriter[string](&log, StringCodec{})
+	r := New[string](WithCommitHooks(cw.Hooks()))
+
+	r, _, _ = r.Insert([]byte("foo"), "1")
+	txn := r.Txn()
+	txn.Insert([]byte("bar"), "2")
+	txn.Insert([]byte("baz"), "3")
+	txn.Insert([]byte("foo"), "4")
+	txn.Insert([]byte("tmp"), "5")
+	txn.Delete([]byte("tmp"))
+	r = txn.Commit()
+	r, _ = r.DeletePrefix([]byte("ba"))
+	if err := cw.Err(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Replaying the log rebuilds the tree.
+	follower, gens := replayChangelog(t, log.Bytes())
+	if len(gens) != 3 || gens[2] != r.Generation() {
+		t.Fatalf("bad generations: %v", gens)
+	}
+	if !r.EqualFunc(follower, func(a, b string) bool { return a == b }) {
+		t.Fatalf("expected the follower to match")
+	}
+
+	// Trees built from a published tree aren't logged, since they aren't new
+	// versions of it.
+	log.Reset()
+	r = New[string](WithCommitHooks(cw.Hooks()))
+	r, _, _ = r.Insert([]byte("a"), "1")
+	r, _, _ = r.Insert([]byte("b"), "2")
+	filtered := r.Filter(func(k []byte, _ string) bool { return string(k) == "a" })
+	copied := filtered.DeepCopy(nil)
+	if log.Len() == 0 || filtered.Generation() != 2 || copied.Generation() != 2 {
+		t.Fatalf("bad generations: %d %d", filtered.Generation(), copied.Generation())
+	}
+	r, _, _ = r.Insert([]byte("c"), "3")
+	if err := cw.Err(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	follower, gens = replayChangelog(t, log.Bytes())
+	if len(gens) != 3 || gens[0] != 1 || gens[1] != 2 || gens[2] != 3 {
+		t.Fatalf("bad generations: %v", gens)
+	}
+	if !r.EqualFunc(follower, func(a, b string) bool { return a == b }) {
+		t.Fatalf("expected the follower to match, got %q", follower.Keys())
+	}
+
+	// Methods that write new contents to a published tree are logged.
+	log.Reset()
+	r = New[string](WithCommitHooks(cw.Hooks()))
+	r, _, _ = r.Insert([]byte("a"), "1")
+	r = r.BulkLoad([]KV[string]{{Key: []byte("b"), Val: "2"}})
+	r = NewOverlay(r).Insert([]byte("c"), "3").Compact()
+	other, _, _ := New[string]().Insert([]byte("d"), "4")
+	r = r.Merge(other, nil)
+	r, _, _ = r.Insert([]byte("e"), "5")
+	if err := cw.Err(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	follower, gens = replayChangelog(t, log.Bytes())
+	if len(gens) != 5 || gens[4] != 5 || r.Generation() != 5 {
+		t.Fatalf("bad generations: %v", gens)
+	}
+	if !r.EqualFunc(follower, func(a, b string) bool { return a == b }) {
+		t.Fatalf("expected the follower to match, got %q", follower.Keys())
+	}
+
+	// A truncated record is reported.
+	cr := NewChangelogReader[string](bytes.NewReader(log.Bytes()[:log.Len()-1]), StringCodec{})
+	var err error
+	for err == nil {
+		_, _, err = cr.Next()
+	}
+	if !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("expected encoding error, got %v", err)
+	}
+
+	// The writer stops at the first error.
+	cw = NewChangelogWriter[string](failWriter{}, StringCodec{})
+	r = New[string](WithCommitHooks(cw.Hooks()))
+	r, _, _ = r.Insert([]byte("foo"), "1")
+	if err := cw.Err(); err != errFailWriter {
+		t.Fatalf("expected write error, got %v", err)
+	}
+}
+
+var errFailWriter = errors.New("write failed")
+
+// failWriter is an io.Writer that always fails.
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) { return 0, errFailWriter }
+
+// replayChangelog applies the records of a changelog to an empty tree,
+// returning it and the generation of each record.
+func replayChangelog(t *testing.T, b []byte) (*Tree[string], []uint64) {
+	t.Helper()
+	cr := NewChangelogReader[string](bytes.NewReader(b), StringCodec{})
+	follower := New[string]()
+	var gens []uint64
+	for {
+		gen, p, err := cr.Next()
+		if err == io.EOF {
+			return follower, gens
+		}
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		gens = append(gens, gen)
+		txn := follower.Txn()
+		p.Apply(txn)
+		follower = txn.Commit()
+	}
+}
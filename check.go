@@ -0,0 +1,88 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// CheckOrdered validates the structure of a tree, returning an error wrapping
+// ErrInconsistent that describes the first problem found. It checks that
+// iterating the tree returns its keys in strictly increasing order, that every
+// key is stored at the path it spells, and that the number of keys agrees with
+// Len and the leaf counts kept in the nodes. This visits every node once
+// without allocating per key, so it's cheap enough to run against production
+// trees, for example after an operation that's suspected of corrupting them,
+// such as one whose keys may have been modified after they were inserted.
+func CheckOrdered[T any](t *Tree[T]) error {
+	c := orderChecker[T]{collate: t.conf.collate}
+	if err := c.check(t.root); err != nil {
+		return err
+	}
+	if c.leaves != t.size {
+		return fmt.Errorf("%w: tree has %d keys but Len is %d", ErrInconsistent, c.leaves, t.size)
+	}
+	return nil
+}
+
+// orderChecker holds the state of CheckOrdered.
+type orderChecker[T any] struct {
+	collate *[256]byte
+	prev    []byte
+	leaves  int
+
+	// path is the path of the node being checked, reused as the walk goes up
+	// and down the tree.
+	path []byte
+}
+
+// check checks the subtree under n.
+func (c *orderChecker[T]) check(n *Node[T]) error {
+	c.path = append(c.path, n.prefix...)
+	path := c.path
+	before := c.leaves
+	if n.leaf != nil {
+		if !c.matches(n.leaf.key, path) {
+			return fmt.Errorf("%w: key %q is stored at path %q", ErrInconsistent, n.leaf.key, path)
+		}
+		if c.leaves > 0 && bytes.Compare(c.prev, path) >= 0 {
+			return fmt.Errorf("%w: key %q is out of order", ErrInconsistent, n.leaf.key)
+		}
+		c.prev = append(c.prev[:0], path...)
+		c.leaves++
+	}
+	for i, e := range n.edges() {
+		if len(e.node.prefix) == 0 || e.node.prefix[0] != e.label {
+			return fmt.Errorf("%w: edge %q under %q doesn't match its node", ErrInconsistent, e.label, path)
+		}
+		if i > 0 && n.edges()[i-1].label >= e.label {
+			return fmt.Errorf("%w: edges under %q are out of order", ErrInconsistent, path)
+		}
+		if err := c.check(e.node); err != nil {
+			return err
+		}
+	}
+	c.path = c.path[:len(c.path)-len(n.prefix)]
+	if n.count() != c.leaves-before {
+		return fmt.Errorf("%w: node at %q counts %d keys but has %d", ErrInconsistent, path, n.count(), c.leaves-before)
+	}
+	return nil
+}
+
+// matches returns true if the key translates to the path.
+func (c *orderChecker[T]) matches(key, path []byte) bool {
+	if len(key) != len(path) {
+		return false
+	}
+	if c.collate == nil {
+		return bytes.Equal(key, path)
+	}
+	for i, b := range key {
+		if c.collate[b] != path[i] {
+			return false
+		}
+	}
+	return true
+}
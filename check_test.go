@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCheckOrdered(t *testing.T) {
+	build := func(opts ...Option) (*Tree[int], [][]byte) {
+		r := New[int](opts...)
+		var keys [][]byte
+		for _, k := range []string{"", "a", "ab", "abc", "b", "Ba", "foo/1", "foo/2"} {
+			keys = append(keys, []byte(k))
+			r, _, _ = r.Insert(keys[len(keys)-1], 0)
+		}
+		return r, keys
+	}
+
+	for _, opts := range [][]Option{nil, {WithCollation(FoldCaseCollation())}} {
+		r, _ := build(opts...)
+		if err := CheckOrdered(r); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if err := CheckOrdered(New[int]()); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		corrupt func(r *Tree[int], keys [][]byte)
+		expect  string
+	}{
+		// Node prefixes may alias the key too, so this can show up
+		// in different ways.
+		{"modified key", func(r *Tree[int], keys [][]byte) { keys[2][1] = 'x' }, ""},
+		{"moved key", func(r *Tree[int], keys [][]byte) { r.root.edges()[1].node.leaf.key = []byte("x") }, "is stored at path"},
+		{"bad len", func(r *Tree[int], keys [][]byte) { r.size++ }, "Len is"},
+		{"bad count", func(r *Tree[int], keys [][]byte) {
+			n := r.root.edges()[0].node
+			n.setCount(n.count() + 1)
+		}, "counts"},
+		{"bad edges", func(r *Tree[int], keys [][]byte) {
+			e := r.root.edges()
+			e[0], e[1] = e[1], e[0]
+		}, "edge"},
+	}
+	for _, c := range cases {
+		r, keys := build()
+		c.corrupt(r, keys)
+		err := CheckOrdered(r)
+		if !errors.Is(err, ErrInconsistent) || !strings.Contains(err.Error(), c.expect) {
+			t.Fatalf("%s: bad error %v", c.name, err)
+		}
+	}
+}
+
+func BenchmarkCheckOrdered(b *testing.B) {
+	r := New[int]()
+	for i := 0; i < 100000; i++ {
+		r, _, _ = r.Insert([]byte(fmt.Sprintf("key/%06d", i)), i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := CheckOrdered(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import "encoding/json"
+
+// Codec encodes values of type T to and from bytes for the binary encodings in
+// this package. Implementations must be able to decode everything they encode,
+// and should keep the encoding stable if the data is exchanged between
+// processes or stored.
+type Codec[T any] interface {
+	// AppendValue appends the encoding of v to b and returns the result.
+	AppendValue(b []byte, v T) ([]byte, error)
+
+	// DecodeValue decodes a value from the output of a call to AppendValue.
+	// The value must not retain b, which may be reused.
+	DecodeValue(b []byte) (T, error)
+}
+
+// BytesCodec is a Codec for byte slice values, which are stored as is.
+type BytesCodec struct{}
+
+// AppendValue implements Codec.
+func (BytesCodec) AppendValue(b []byte, v []byte) ([]byte, error) {
+	return append(b, v...), nil
+}
+
+// DecodeValue implements Codec.
+func (BytesCodec) DecodeValue(b []byte) ([]byte, error) {
+	return append([]byte{}, b...), nil
+}
+
+// StringCodec is a Codec for string values, which are stored as is.
+type StringCodec struct{}
+
+// AppendValue implements Codec.
+func (StringCodec) AppendValue(b []byte, v string) ([]byte, error) {
+	return append(b, v...), nil
+}
+
+// DecodeValue implements Codec.
+func (StringCodec) DecodeValue(b []byte) (string, error) {
+	return string(b), nil
+}
+
+// JSONCodec is a Codec that stores values with encoding/json, for any type
+// that it can round-trip.
+type JSONCodec[T any] struct{}
+
+// AppendValue implements Codec.
+func (JSONCodec[T]) AppendValue(b []byte, v T) ([]byte, error) {
+	enc, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, enc...), nil
+}
+
+// DecodeValue implements Codec.
+func (JSONCodec[T]) DecodeValue(b []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(b, &v)
+	return v, err
+}
@@ -0,0 +1,183 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+// WithCollation makes the tree order keys by the given collation rather than
+// by their raw byte values. Every byte b of a key is placed in the tree as
+// table[b], so keys are ordered by comparing their translated bytes. Keys are
+// still stored and returned exactly as they were inserted. The table must be a
+// permutation, mapping each byte to a different value, so that distinct keys
+// stay distinct; this panics otherwise.
+//
+// The methods on Tree and Txn, and iterators obtained from Tree.Iterator and
+// Tree.ReverseIterator, translate the keys they are given. Methods on Node work
+// on the translated keys as they're laid out in the tree, so keys given to them
+// must first be translated with Tree.CollateKey.
+func WithCollation(table [256]byte) Option {
+	var seen [256]bool
+	for _, b := range table {
+		if seen[b] {
+			panic("iradix: collation table is not a permutation")
+		}
+		seen[b] = true
+	}
+	return func(o *options) {
+		o.collate = &table
+	}
+}
+
+// FoldCaseCollation returns a collation table for WithCollation that orders
+// ASCII letters alphabetically regardless of case, with each uppercase letter
+// just before its lowercase form, so "Apple" < "apple" < "Banana" < "banana".
+// Other bytes keep their usual order, with the letters taking the place of the
+// uppercase ones.
+func FoldCaseCollation() [256]byte {
+	var table [256]byte
+	next := 0
+	for b := 0; b < 256; b++ {
+		switch {
+		case b >= 'a' && b <= 'z':
+			// Placed along with the uppercase letter below.
+		case b >= 'A' && b <= 'Z':
+			table[b] = byte(next)
+			table[b+'a'-'A'] = byte(next + 1)
+			next += 2
+		default:
+			table[b] = byte(next)
+			next++
+		}
+	}
+	return table
+}
+
+// WithKeyFold makes the keys of the tree case-insensitive for ASCII letters, as
+// for DNS names or HTTP header names. Keys are folded to lowercase when they're
+// inserted, so they're stored and returned in lowercase, and the keys given to
+// the methods on Tree and Txn and to the seek methods of the iterators from
+// Tree.Iterator and Tree.ReverseIterator are folded as well, so that "Foo" and
+// "FOO" are the same key as "foo". Bytes other than ASCII letters are left as
+// they are.
+//
+// Folding is done by the same translation as WithCollation, with which it can
+// be combined, so trees with folded keys can only be merged or compared with
+// other such trees, and Node methods need keys translated with
+// Tree.CollateKey. Patterns given to Tree.WalkGlob are folded too, but regular
+// expressions given to Tree.RegexpIterator must match the lowercase keys.
+func WithKeyFold() Option {
+	return func(o *options) {
+		o.fold = true
+	}
+}
+
+// KeyTransformer maps keys to a canonical form, such as a cleaned path or a
+// Unicode normalization form, for use with WithKeyTransformer.
+type KeyTransformer interface {
+	// TransformKey returns the canonical form of k. It must not modify k,
+	// though it may return it if it's already canonical, and transforming
+	// a canonical key must return it unchanged.
+	TransformKey(k []byte) []byte
+}
+
+// KeyTransformerFunc adapts a function to a KeyTransformer.
+type KeyTransformerFunc func(k []byte) []byte
+
+// TransformKey implements KeyTransformer.
+func (f KeyTransformerFunc) TransformKey(k []byte) []byte {
+	return f(k)
+}
+
+// WithKeyTransformer makes the tree store every key in the canonical form
+// given by tr, so that keys with the same canonical form are the same key.
+// Keys are transformed when they're inserted, so they're stored and returned in
+// their canonical form, and the keys and prefixes given to the methods on Tree
+// and Txn, to the seek methods of the iterators from Tree.Iterator and
+// Tree.ReverseIterator, and to the watch methods are transformed as well. This
+// generalizes WithKeyFold to transformations that can change the length of
+// keys, at the cost of a call to tr for every key.
+//
+// Since prefixes are transformed too, tr should map a prefix of a key to a
+// prefix of the key's canonical form for lookups by prefix to be meaningful.
+// Patterns given to WalkGlob and regular expressions are matched against the
+// canonical keys as they're given. Node methods need keys translated with
+// Tree.CollateKey, and trees can only be merged or compared with trees that
+// use the same transformation, which isn't checked.
+func WithKeyTransformer(tr KeyTransformer) Option {
+	return func(o *options) {
+		o.transform = tr
+	}
+}
+
+// transformKey returns k transformed by tr, or k itself if tr is nil.
+func transformKey(tr KeyTransformer, k []byte) []byte {
+	if tr == nil {
+		return k
+	}
+	return tr.TransformKey(k)
+}
+
+// foldCollation returns a table that maps ASCII uppercase letters to lowercase
+// and every other byte to itself.
+func foldCollation() [256]byte {
+	var table [256]byte
+	for b := range table {
+		table[b] = byte(b)
+		if b >= 'A' && b <= 'Z' {
+			table[b] += 'a' - 'A'
+		}
+	}
+	return table
+}
+
+// foldKey returns k with its ASCII uppercase letters folded to lowercase. It
+// returns k itself if there are none.
+func foldKey(k []byte) []byte {
+	i := 0
+	for i < len(k) && (k[i] < 'A' || k[i] > 'Z') {
+		i++
+	}
+	if i == len(k) {
+		return k
+	}
+	out := append(make([]byte, 0, len(k)), k...)
+	for ; i < len(out); i++ {
+		if out[i] >= 'A' && out[i] <= 'Z' {
+			out[i] += 'a' - 'A'
+		}
+	}
+	return out
+}
+
+// collateKey returns k translated through table, or k itself if table is nil.
+func collateKey(table *[256]byte, k []byte) []byte {
+	if table == nil {
+		return k
+	}
+	out := make([]byte, len(k))
+	for i, b := range k {
+		out[i] = table[b]
+	}
+	return out
+}
+
+// CollateKey returns k as it's laid out in the tree, for use with the methods
+// on Node. This returns k itself unless the tree was created with
+// WithCollation, WithKeyFold or WithKeyTransformer.
+func (t *Tree[T]) CollateKey(k []byte) []byte {
+	return t.conf.path(k)
+}
+
+// Iterator returns an iterator over the whole tree. Unlike the iterator from
+// Root().Iterator(), it translates the keys given to its seek methods if the
+// tree was created with WithCollation.
+func (t *Tree[T]) Iterator() *Iterator[T] {
+	return &Iterator[T]{node: t.root, collate: t.conf.collate, transform: t.conf.transform}
+}
+
+// ReverseIterator is like Iterator, but walks the tree backwards.
+func (t *Tree[T]) ReverseIterator() *ReverseIterator[T] {
+	ri := NewReverseIterator(t.root)
+	ri.i.collate = t.conf.collate
+	ri.i.transform = t.conf.transform
+	return ri
+}
@@ -0,0 +1,290 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestCollation(t *testing.T) {
+	r := New[int](WithCollation(FoldCaseCollation()))
+	keys := []string{"banana", "Apple", "apple", "Banana", "cherry", "APPLE", "[x]", "0"}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	if r.Len() != len(keys) {
+		t.Fatalf("bad len: %d", r.Len())
+	}
+	checkCounts(t, r.Root())
+
+	// Keys are returned exactly as they were inserted, in collation order.
+	expect := []string{"0", "APPLE", "Apple", "apple", "Banana", "banana", "cherry", "[x]"}
+	verifyTree(t, expect, r)
+
+	// Lookups and deletes take the original keys.
+	for i, k := range keys {
+		if v, ok := r.Get([]byte(k)); !ok || v != i {
+			t.Fatalf("%s: bad %v %v", k, v, ok)
+		}
+		if v, ok := r.GetString(k); !ok || v != i {
+			t.Fatalf("%s: bad %v %v", k, v, ok)
+		}
+	}
+	if _, ok := r.Get([]byte("aPPLE")); ok {
+		t.Fatalf("keys should stay distinct")
+	}
+	if _, ok := r.Root().Get([]byte("apple")); ok {
+		t.Fatalf("node methods should need collated keys")
+	}
+	if v, ok := r.Root().Get(r.CollateKey([]byte("apple"))); !ok || v != 2 {
+		t.Fatalf("bad %v %v", v, ok)
+	}
+
+	d, _, _ := r.Delete([]byte("Apple"))
+	verifyTree(t, []string{"0", "APPLE", "apple", "Banana", "banana", "cherry", "[x]"}, d)
+	d, _ = r.DeletePrefix([]byte("A"))
+	verifyTree(t, []string{"0", "apple", "Banana", "banana", "cherry", "[x]"}, d)
+	checkCounts(t, d.Root())
+
+	// Iterators from the tree translate seek keys.
+	collect := func(next func() ([]byte, int, bool)) []string {
+		var out []string
+		for k, _, ok := next(); ok; k, _, ok = next() {
+			out = append(out, string(k))
+		}
+		return out
+	}
+	it := r.Iterator()
+	it.SeekLowerBound([]byte("apple"))
+	if got := collect(it.Next); !reflect.DeepEqual(got, expect[3:]) {
+		t.Fatalf("bad: %v", got)
+	}
+	it = r.Iterator()
+	it.SeekLowerBound([]byte("B"))
+	if got := collect(it.Next); !reflect.DeepEqual(got, expect[4:]) {
+		t.Fatalf("bad: %v", got)
+	}
+	it = r.Iterator()
+	it.SeekPrefix([]byte("Ba"))
+	if got := collect(it.Next); !reflect.DeepEqual(got, []string{"Banana"}) {
+		t.Fatalf("bad: %v", got)
+	}
+
+	ri := r.ReverseIterator()
+	ri.SeekReverseLowerBound([]byte("apple"))
+	if got := collect(ri.Previous); !reflect.DeepEqual(got, []string{"apple", "Apple", "APPLE", "0"}) {
+		t.Fatalf("bad: %v", got)
+	}
+	ri = r.ReverseIterator()
+	ri.SeekReversePrefixLowerBound([]byte("A"), []byte("Apple"))
+	if got := collect(ri.Previous); !reflect.DeepEqual(got, []string{"Apple", "APPLE"}) {
+		t.Fatalf("bad: %v", got)
+	}
+
+	// Renames and reads of prefixes work on the original keys too.
+	n, _ := r.RenamePrefix([]byte("B"), []byte("c"))
+	verifyTree(t, []string{"0", "APPLE", "Apple", "apple", "banana", "canana", "cherry", "[x]"}, n)
+	var got []string
+	r.ReadMulti([][]byte{[]byte("b"), []byte("A")}, func(prefix []byte, it *Iterator[int]) {
+		got = append(got, collect(it.Next)...)
+	})
+	if !reflect.DeepEqual(got, []string{"banana", "APPLE", "Apple"}) {
+		t.Fatalf("bad: %v", got)
+	}
+}
+
+func TestCollation_NotPermutation(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected a panic")
+		}
+	}()
+	table := FoldCaseCollation()
+	table['a'] = table['A']
+	WithCollation(table)
+}
+
+func TestWithKeyFold(t *testing.T) {
+	r := New[int](WithKeyFold())
+	for i, k := range []string{"WWW.Example.COM", "www.example.com", "Mail.example.com", "example.COM"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	if r.Len() != 3 {
+		t.Fatalf("bad len: %d", r.Len())
+	}
+	if got := r.Keys(); !reflect.DeepEqual(got, [][]byte{[]byte("example.com"), []byte("mail.example.com"), []byte("www.example.com")}) {
+		t.Fatalf("bad keys: %q", got)
+	}
+	if v, ok := r.Get([]byte("WWW.EXAMPLE.com")); !ok || v != 1 {
+		t.Fatalf("bad value: %v %v", v, ok)
+	}
+	if k, v, ok := r.LongestPrefix([]byte("EXAMPLE.COM/path")); !ok || string(k) != "example.com" || v != 3 {
+		t.Fatalf("bad longest prefix: %q %v %v", k, v, ok)
+	}
+	var walked []string
+	r.WalkPrefix([]byte("MAIL."), func(k []byte, _ int) bool {
+		walked = append(walked, string(k))
+		return false
+	})
+	if !reflect.DeepEqual(walked, []string{"mail.example.com"}) {
+		t.Fatalf("bad walk: %q", walked)
+	}
+	walked = nil
+	r.WalkGlob([]byte("*.EXAMPLE.*"), func(k []byte, _ int) bool {
+		walked = append(walked, string(k))
+		return false
+	})
+	if !reflect.DeepEqual(walked, []string{"mail.example.com", "www.example.com"}) {
+		t.Fatalf("bad glob: %q", walked)
+	}
+	if r, _, _ = r.Delete([]byte("Mail.Example.Com")); r.Len() != 2 {
+		t.Fatalf("bad delete")
+	}
+	if err := CheckOrdered(r); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Keys that differ only in case are the same key when bulk loading, and
+	// the folding combines with a collation.
+	b := New[int](WithKeyFold(), WithCollation(FoldCaseCollation()), WithMemoryBudget(100, func(int) int { return 0 })).BulkLoad([]KV[int]{
+		{Key: []byte("A"), Val: 1},
+		{Key: []byte("a"), Val: 2},
+		{Key: []byte("b"), Val: 3},
+		{Key: []byte("B_"), Val: 4},
+	})
+	if got := b.Keys(); !reflect.DeepEqual(got, [][]byte{[]byte("a"), []byte("b"), []byte("b_")}) {
+		t.Fatalf("bad keys: %q", got)
+	}
+	if v, _ := b.Get([]byte("A")); v != 2 || b.Bytes() != 4 {
+		t.Fatalf("bad value %d or bytes %d", v, b.Bytes())
+	}
+	if err := CheckOrdered(b); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Trees derived from folded ones fold too.
+	o := NewOverlay(r).Insert([]byte("FTP.example.com"), 5)
+	if v, ok := o.Get([]byte("ftp.EXAMPLE.com")); !ok || v != 5 {
+		t.Fatalf("bad overlay value: %v %v", v, ok)
+	}
+	m := MapValues(r, func(_ []byte, v int) string { return "" })
+	if _, ok := m.Get([]byte("WWW.example.com")); !ok {
+		t.Fatalf("expected mapped tree to fold keys")
+	}
+}
+
+func TestWithKeyTransformer(t *testing.T) {
+	// Collapsing runs of slashes maps prefixes of keys to prefixes of their
+	// canonical forms.
+	calls := 0
+	squash := KeyTransformerFunc(func(k []byte) []byte {
+		calls++
+		if !bytes.Contains(k, []byte("//")) {
+			return k
+		}
+		var out []byte
+		for i, b := range k {
+			if b != '/' || i == 0 || k[i-1] != '/' {
+				out = append(out, b)
+			}
+		}
+		return out
+	})
+
+	r := New[int](WithKeyTransformer(squash), WithKeyFold())
+	txn := r.Txn()
+	txn.Insert([]byte("//a///B"), 1)
+	txn.Insert([]byte("/a/b"), 2)
+	txn.Insert([]byte("/a//c"), 3)
+	txn.Insert([]byte("/d"), 4)
+	r = txn.Commit()
+	if got := r.Keys(); !reflect.DeepEqual(got, [][]byte{[]byte("/a/b"), []byte("/a/c"), []byte("/d")}) {
+		t.Fatalf("bad keys: %q", got)
+	}
+	if v, ok := r.Get([]byte("/A//b")); !ok || v != 2 {
+		t.Fatalf("bad value: %v %v", v, ok)
+	}
+	if n := r.LenPrefix([]byte("//a//")); n != 2 {
+		t.Fatalf("bad count: %d", n)
+	}
+
+	it := r.Iterator()
+	it.SeekPrefix([]byte("/a//"))
+	var got []string
+	for k, _, ok := it.Next(); ok; k, _, ok = it.Next() {
+		got = append(got, string(k))
+	}
+	if !reflect.DeepEqual(got, []string{"/a/b", "/a/c"}) {
+		t.Fatalf("bad iteration: %q", got)
+	}
+
+	watch, _, ok := r.Root().GetWatch(r.CollateKey([]byte("//a//c")))
+	wi := r.WatchedPrefixIterator([]byte("//A/"))
+	if k, _, ok := wi.Next(); !ok || string(k) != "/a/b" {
+		t.Fatalf("bad watched key: %q", k)
+	}
+	txn = r.Txn()
+	txn.TrackMutate(true)
+	if _, ok := txn.Delete([]byte("/a////c")); !ok {
+		t.Fatalf("expected delete")
+	}
+	r = txn.Commit()
+	if !ok || !isClosedRecv(watch) {
+		t.Fatalf("expected watch to fire")
+	}
+
+	// Bulk loading transforms the keys too.
+	b := New[int](WithKeyTransformer(squash)).BulkLoad([]KV[int]{
+		{Key: []byte("/x//y"), Val: 1},
+		{Key: []byte("/x/y"), Val: 2},
+		{Key: []byte("/x/z"), Val: 3},
+	})
+	if got := b.Keys(); !reflect.DeepEqual(got, [][]byte{[]byte("/x/y"), []byte("/x/z")}) {
+		t.Fatalf("bad keys: %q", got)
+	}
+	if v, _ := b.Get([]byte("/x//y")); v != 2 {
+		t.Fatalf("bad value: %d", v)
+	}
+	if v, ok := b.GetString("/x//z"); !ok || v != 3 {
+		t.Fatalf("bad string value: %v %v", v, ok)
+	}
+
+	// Renaming a prefix replaces its transformed form. Where the renamed keys
+	// transform differently, the moved subtree is rebuilt.
+	rp := New[int](WithKeyTransformer(squash))
+	for i, k := range []string{"a/x", "a/y", "b"} {
+		rp, _, _ = rp.Insert([]byte(k), i)
+	}
+	for _, c := range []struct {
+		old, new string
+		expect   []string
+	}{
+		{"a//", "c", []string{"b", "cx", "cy"}},
+		{"a", "c/", []string{"b", "c/x", "c/y"}},
+		{"a/", "b//", []string{"b", "b/x", "b/y"}},
+	} {
+		nr, ok := rp.RenamePrefix([]byte(c.old), []byte(c.new))
+		if !ok {
+			t.Fatalf("%q -> %q: expected rename", c.old, c.new)
+		}
+		verifyTree(t, c.expect, nr)
+		if v, _ := nr.Get([]byte(c.expect[2])); v != 1 {
+			t.Fatalf("%q -> %q: bad value %d", c.old, c.new, v)
+		}
+		if err := CheckOrdered(nr); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if _, ok := rp.SwapPrefix([]byte("a//"), []byte("a/")); ok {
+		t.Fatalf("expected overlapping prefixes not to swap")
+	}
+	if err := CheckOrdered(b); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if calls == 0 {
+		t.Fatalf("transformer not called")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+// companion is a tree along with a companion index of its entries, which is
+// the part shared by IndexedTree, TokenIndexedTree and SuffixTree. Each of
+// them decides how the entries are keyed in the index.
+type companion[T any] struct {
+	tree  *Tree[T]
+	index *Tree[indexEntry[T]]
+}
+
+// indexEntry is the value of an entry in a companion index, which holds the
+// key in the form it's stored in the tree.
+type indexEntry[T any] struct {
+	key []byte
+	val T
+}
+
+// newCompanion returns an empty tree with the given options, along with an
+// empty index.
+func newCompanion[T any](opts []Option) companion[T] {
+	return companion[T]{
+		tree:  New[T](opts...),
+		index: New[indexEntry[T]](),
+	}
+}
+
+// Tree returns the tree of entries by their key.
+func (c *companion[T]) Tree() *Tree[T] {
+	return c.tree
+}
+
+// Len is used to return the number of elements in the tree.
+func (c *companion[T]) Len() int {
+	return c.tree.Len()
+}
+
+// Get is used to lookup a specific key, returning the value and if it was
+// found.
+func (c *companion[T]) Get(k []byte) (T, bool) {
+	return c.tree.Get(k)
+}
+
+// txn starts a new transaction on the tree and its index.
+func (c *companion[T]) txn() companionTxn[T] {
+	return companionTxn[T]{
+		tree:  c.tree.Txn(),
+		index: c.index.Txn(),
+	}
+}
+
+// companionTxn is a transaction on a companion.
+type companionTxn[T any] struct {
+	tree  *Txn[T]
+	index *Txn[indexEntry[T]]
+}
+
+// Txn returns the transaction on the tree of entries by their key, which can
+// be used for reads. Writing to it directly leaves the index out of date.
+func (t *companionTxn[T]) Txn() *Txn[T] {
+	return t.tree
+}
+
+// insert adds or updates a given key in the tree, and returns the key in the
+// form it's stored in, for the index, along with the previous value and a bool
+// indicating if any was set. The last bool is false if the insert was
+// rejected, in which case the index should be left alone.
+func (t *companionTxn[T]) insert(k []byte, v T) ([]byte, T, bool, bool) {
+	size := t.tree.size
+	old, ok := t.tree.Insert(k, v)
+	if !ok && t.tree.size == size {
+		// The insert was rejected, for example by a memory budget.
+		return nil, old, ok, false
+	}
+	k = t.tree.conf.key(k)
+	if t.tree.conf.copyKeys {
+		k = append(make([]byte, 0, len(k)), k...)
+	}
+	return k, old, ok, true
+}
+
+// delete deletes a given key from the tree, and returns the key in the form
+// it's stored in, for the index, along with the old value if any and a bool
+// indicating if the key was set.
+func (t *companionTxn[T]) delete(k []byte) ([]byte, T, bool) {
+	old, ok := t.tree.Delete(k)
+	if !ok {
+		return nil, old, ok
+	}
+	return t.tree.conf.key(k), old, ok
+}
+
+// commit finalizes the transaction and returns the new tree along with its
+// index.
+func (t *companionTxn[T]) commit() companion[T] {
+	return companion[T]{
+		tree:  t.tree.Commit(),
+		index: t.index.Commit(),
+	}
+}
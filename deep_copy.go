@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+// DeepCopy returns a copy of the tree that shares no memory with it, for
+// values such as pointers to mutable structs that make sharing between trees
+// unsafe. Every node, leaf, key and prefix is duplicated, and every value is
+// passed through copyVal, which should return a copy of it that shares nothing
+// with the original. If copyVal is nil, the values are copied by assignment.
+// The copy has the same options and shape as t, and its watch channels are
+// new, so closing those of t doesn't affect it. It's never allocated from a
+// TreePool, even if t was.
+func (t *Tree[T]) DeepCopy(copyVal func(T) T) *Tree[T] {
+	txn := t.emptyBase().Txn()
+	txn.root = txn.deepCopyNode(t.root, copyVal)
+	txn.size = t.size
+	txn.recount()
+	return txn.derive()
+}
+
+// deepCopyNode returns a copy of the subtree under n, as described by
+// DeepCopy.
+func (t *Txn[T]) deepCopyNode(n *Node[T], copyVal func(T) T) *Node[T] {
+	nn := t.allocNode(Node[T]{})
+	if n.leaf != nil {
+		l := leafNode[T]{
+			key: append([]byte{}, n.leaf.key...),
+			val: n.leaf.val,
+		}
+		if copyVal != nil {
+			l.val = copyVal(l.val)
+		}
+		if n.leaf.meta != nil {
+			meta := *n.leaf.meta
+			l.meta = &meta
+		}
+		nn.leaf = t.allocLeaf(l)
+	}
+	if len(n.edges()) != 0 {
+		nn.inner = &innerNode[T]{
+			edges: make(edges[T], len(n.edges())),
+			count: n.count(),
+		}
+		for i, e := range n.edges() {
+			nn.inner.edges[i] = edge[T]{
+				label: e.label,
+				node:  t.deepCopyNode(e.node, copyVal),
+			}
+		}
+	}
+	if t.aliasLeafPrefix(nn) {
+		nn.prefix = leafPrefix(nn.leaf, len(n.prefix))
+	} else if len(n.prefix) != 0 {
+		nn.prefix = append([]byte{}, n.prefix...)
+	}
+	return nn
+}
@@ -0,0 +1,66 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTree_DeepCopy(t *testing.T) {
+	type record struct{ n int }
+	r := New[*record](WithLeafMeta())
+	for i, k := range []string{"foo", "foo/bar", "foo/baz", "zip", ""} {
+		r, _, _ = r.Insert([]byte(k), &record{n: i})
+	}
+
+	c := r.DeepCopy(func(v *record) *record {
+		cp := *v
+		return &cp
+	})
+	if !reflect.DeepEqual(nodeLayout(c.Root()), nodeLayout(r.Root())) {
+		t.Fatalf("bad layout:\n%v", nodeLayout(c.Root()))
+	}
+	if err := CheckOrdered(c); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Nothing is shared, down to the bytes of the keys and prefixes.
+	var walk func(a, b *Node[*record])
+	walk = func(a, b *Node[*record]) {
+		if a == b || a.mutateCh == b.mutateCh {
+			t.Fatalf("node %q is shared", a.prefix)
+		}
+		if len(a.prefix) != 0 && &a.prefix[0] == &b.prefix[0] {
+			t.Fatalf("prefix %q is shared", a.prefix)
+		}
+		if a.leaf != nil {
+			if a.leaf == b.leaf || a.leaf.val == b.leaf.val || a.leaf.meta == b.leaf.meta {
+				t.Fatalf("leaf %q is shared", a.leaf.key)
+			}
+			if len(a.leaf.key) != 0 && &a.leaf.key[0] == &b.leaf.key[0] {
+				t.Fatalf("key %q is shared", a.leaf.key)
+			}
+			if *a.leaf.meta != *b.leaf.meta {
+				t.Fatalf("bad meta for %q", a.leaf.key)
+			}
+		}
+		for i := range a.edges() {
+			walk(a.edges()[i].node, b.edges()[i].node)
+		}
+	}
+	walk(r.Root(), c.Root())
+
+	v, _ := c.Get([]byte("foo/bar"))
+	v.n = 100
+	if orig, _ := r.Get([]byte("foo/bar")); orig.n != 1 {
+		t.Fatalf("value is shared")
+	}
+
+	// Without a copy function the values are assigned.
+	shallow := r.DeepCopy(nil)
+	if a, _ := shallow.Get([]byte("zip")); a != r.Root().edges()[1].node.leaf.val {
+		t.Fatalf("expected the value to be assigned")
+	}
+}
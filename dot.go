@@ -0,0 +1,93 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// DOTOptions controls how DumpDOT renders a tree.
+type DOTOptions struct {
+	// MaxDepth is the number of levels of nodes below the starting node that
+	// are drawn. Deeper subtrees are drawn as a single box with their number
+	// of keys. Zero means no limit.
+	MaxDepth int
+
+	// MaxKeys elides the subtrees below the starting node that hold more
+	// than this many keys in the same way, so the parts of a large tree
+	// around a bug can be drawn without the rest. Zero means no limit.
+	MaxKeys int
+
+	// Values includes the values of the leaves in the labels, formatted with
+	// fmt's %v verb.
+	Values bool
+}
+
+// DumpDOT writes the structure of the tree under this node to w as a Graphviz
+// DOT graph, which is useful when debugging the splitting and merging of
+// nodes. Every node is drawn with its prefix and the number of keys under it,
+// and nodes holding a leaf are drawn with a double border and the leaf's key.
+// Edges are labelled with the byte they're indexed by.
+func (n *Node[T]) DumpDOT(w io.Writer, opts DOTOptions) error {
+	d := dotWriter[T]{w: bufio.NewWriter(w), opts: opts}
+	d.printf("digraph iradix {\n\tnode [shape=box];\n")
+	d.node(n, 0)
+	d.printf("}\n")
+	if d.err != nil {
+		return d.err
+	}
+	return d.w.Flush()
+}
+
+// DumpDOT writes the structure of the tree to w as a Graphviz DOT graph. See
+// Node.DumpDOT.
+func (t *Tree[T]) DumpDOT(w io.Writer, opts DOTOptions) error {
+	return t.root.DumpDOT(w, opts)
+}
+
+// dotWriter writes the nodes of a DOT graph, recording the first error.
+type dotWriter[T any] struct {
+	w    *bufio.Writer
+	opts DOTOptions
+	next int
+	err  error
+}
+
+func (d *dotWriter[T]) printf(format string, args ...any) {
+	if d.err == nil {
+		_, d.err = fmt.Fprintf(d.w, format, args...)
+	}
+}
+
+// node writes n, at the given depth below the starting node, and the nodes
+// under it, returning its ID.
+func (d *dotWriter[T]) node(n *Node[T], depth int) int {
+	id := d.next
+	d.next++
+	if depth > 0 && ((d.opts.MaxDepth > 0 && depth > d.opts.MaxDepth) ||
+		(d.opts.MaxKeys > 0 && n.count() > d.opts.MaxKeys)) {
+		d.printf("\tn%d [label=%s, style=dashed];\n", id,
+			strconv.Quote(fmt.Sprintf("%q... (%d)", n.prefix, n.count())))
+		return id
+	}
+
+	label := fmt.Sprintf("%q (%d)", n.prefix, n.count())
+	attrs := ""
+	if n.leaf != nil {
+		label += fmt.Sprintf("\nkey %q", n.leaf.key)
+		if d.opts.Values {
+			label += fmt.Sprintf("\n%v", n.leaf.val)
+		}
+		attrs = ", peripheries=2"
+	}
+	d.printf("\tn%d [label=%s%s];\n", id, strconv.Quote(label), attrs)
+	for _, e := range n.edges() {
+		child := d.node(e.node, depth+1)
+		d.printf("\tn%d -> n%d [label=%s];\n", id, child, strconv.Quote(fmt.Sprintf("%q", []byte{e.label})))
+	}
+	return id
+}
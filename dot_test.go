@@ -0,0 +1,50 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDumpDOT(t *testing.T) {
+	r := New[int]()
+	for i, k := range []string{"foo", "foobar", "foobaz", "zip"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	var b strings.Builder
+	if err := r.DumpDOT(&b, DOTOptions{Values: true}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expect := `digraph iradix {
+	node [shape=box];
+	n0 [label="\"\" (4)"];
+	n1 [label="\"foo\" (3)\nkey \"foo\"\n0", peripheries=2];
+	n2 [label="\"ba\" (2)"];
+	n3 [label="\"r\" (1)\nkey \"foobar\"\n1", peripheries=2];
+	n2 -> n3 [label="\"r\""];
+	n4 [label="\"z\" (1)\nkey \"foobaz\"\n2", peripheries=2];
+	n2 -> n4 [label="\"z\""];
+	n1 -> n2 [label="\"b\""];
+	n0 -> n1 [label="\"f\""];
+	n5 [label="\"zip\" (1)\nkey \"zip\"\n3", peripheries=2];
+	n0 -> n5 [label="\"z\""];
+}
+`
+	if b.String() != expect {
+		t.Fatalf("bad graph:\n%s", b.String())
+	}
+
+	// Large and deep subtrees are elided.
+	for _, opts := range []DOTOptions{{MaxKeys: 2}, {MaxDepth: 1}} {
+		b.Reset()
+		if err := r.DumpDOT(&b, opts); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !strings.Contains(b.String(), `n1 [label="\"`) || strings.Contains(b.String(), "foobar") {
+			t.Fatalf("bad graph:\n%s", b.String())
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+// ContentHash returns the hash of the contents of the tree and true, or false
+// if the tree wasn't created with WithContentHash. Trees with the same keys and
+// values have the same hash, however they were built. The hash of a tree is the
+// sum of a hash of each key and value, so it's cheap to maintain but is not
+// suitable for use against adversarial input.
+func (t *Tree[T]) ContentHash() (uint64, bool) {
+	return t.hash, t.conf.hash != nil
+}
+
+// EqualFunc returns true if this tree and other have the same keys, with values
+// that are equal according to eq. Trees with different sizes, or different
+// content hashes if both were derived from the same New call with
+// WithContentHash, are unequal without looking any further. Otherwise subtrees
+// that are shared between the trees are skipped, and only the remaining values
+// are compared with eq. Trees with different collations lay out the same keys
+// differently, so for them every key is looked up in other instead.
+func (t *Tree[T]) EqualFunc(other *Tree[T], eq func(a, b T) bool) bool {
+	if t.size != other.size {
+		return false
+	}
+	if t.conf == other.conf && t.conf.hash != nil && t.hash != other.hash {
+		return false
+	}
+	if !sameCollation(t.conf.collate, other.conf.collate) {
+		equal := true
+		t.root.Walk(func(k []byte, v T) bool {
+			ov, ok := other.Get(k)
+			equal = ok && eq(v, ov)
+			return !equal
+		})
+		return equal
+	}
+
+	it := NewChangedIterator(t.root, other.root)
+	for {
+		a, b, ok := it.next()
+		if !ok {
+			return true
+		}
+		if a == nil || b == nil || !eq(a.val, b.val) {
+			return false
+		}
+	}
+}
+
+// entryHash returns the hash of an entry that's summed into the content hash.
+// The key and value are hashed separately and combined so that the same value
+// under different keys contributes differently.
+func (t *Txn[T]) entryHash(k []byte, v T) uint64 {
+	return hashEntry(k, t.conf.hash(v))
+}
+
+// hashEntry combines the hash of a key with the hash of its value, vh.
+func hashEntry(k []byte, vh uint64) uint64 {
+	// FNV-1a of the key.
+	h := uint64(14695981039346656037)
+	for _, c := range k {
+		h ^= uint64(c)
+		h *= 1099511628211
+	}
+	return mix64(h ^ mix64(vh))
+}
+
+// Hash returns the content hash of the tree, as maintained by WithContentHash,
+// so comparing the hashes of two trees is enough to tell that their contents
+// differ, such as on two replicas. This panics if the tree wasn't created with
+// WithContentHash; Root().Hash can be used with a value hasher instead.
+func (t *Tree[T]) Hash() uint64 {
+	if t.conf.hash == nil {
+		panic("iradix: Hash called on a tree without WithContentHash")
+	}
+	return t.hash
+}
+
+// Hash returns the hash of the keys and values under this node, using fn to
+// hash values. Equal values must have equal hashes, which could be a hash of
+// their serialized form. The hash only depends on the contents of the subtree,
+// not on how it's laid out or was built, and is the same as the hash that
+// WithContentHash with the same function maintains for a whole tree, so
+// Root().Hash(fn) equals Tree.Hash. Replicas whose hashes differ can find
+// where they diverge by comparing the hashes of the nodes under matching
+// prefixes. This visits every leaf under the node.
+func (n *Node[T]) Hash(fn func(T) uint64) uint64 {
+	var h uint64
+	recursiveWalk(n, func(k []byte, v T) bool {
+		h += hashEntry(k, fn(v))
+		return false
+	})
+	return h
+}
+
+// mix64 is the finalizer from SplitMix64, which spreads the bits of x so that
+// sums of hashes don't cancel out easily.
+func mix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
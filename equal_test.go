@@ -0,0 +1,180 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEqualFunc(t *testing.T) {
+	eq := func(a, b int) bool { return a == b }
+
+	base := New[int]()
+	for i := 0; i < 100; i++ {
+		base, _, _ = base.Insert([]byte(fmt.Sprintf("key/%02d", i)), i)
+	}
+
+	// Equal contents built separately, in a different order.
+	other := New[int]()
+	for i := 99; i >= 0; i-- {
+		other, _, _ = other.Insert([]byte(fmt.Sprintf("key/%02d", i)), i)
+	}
+	if !base.EqualFunc(other, eq) || !other.EqualFunc(base, eq) {
+		t.Fatalf("expected equal")
+	}
+	if !base.EqualFunc(base, eq) {
+		t.Fatalf("expected equal to itself")
+	}
+
+	// Derived trees with a changed value, a replaced key and a rewritten
+	// but equal value.
+	changed, _, _ := base.Insert([]byte("key/50"), -1)
+	if base.EqualFunc(changed, eq) {
+		t.Fatalf("expected unequal value")
+	}
+	replaced, _, _ := base.Delete([]byte("key/50"))
+	replaced, _, _ = replaced.Insert([]byte("key/5"), 50)
+	if base.EqualFunc(replaced, eq) {
+		t.Fatalf("expected unequal keys")
+	}
+	rewritten, _, _ := base.Insert([]byte("key/50"), 50)
+	if !base.EqualFunc(rewritten, eq) {
+		t.Fatalf("expected equal")
+	}
+
+	shorter, _, _ := base.Delete([]byte("key/00"))
+	if base.EqualFunc(shorter, eq) {
+		t.Fatalf("expected unequal sizes")
+	}
+
+	// The comparison function is only called for values that aren't shared.
+	calls := 0
+	base.EqualFunc(rewritten, func(a, b int) bool {
+		calls++
+		return a == b
+	})
+	if calls != 1 {
+		t.Fatalf("bad calls: %d", calls)
+	}
+
+	// Trees with different collations are compared by their keys.
+	folded := New[int](WithCollation(FoldCaseCollation()))
+	for _, k := range []string{"B", "a", "b"} {
+		folded, _, _ = folded.Insert([]byte(k), len(k))
+	}
+	plain := New[int]()
+	for _, k := range []string{"B", "a", "b"} {
+		plain, _, _ = plain.Insert([]byte(k), len(k))
+	}
+	if !folded.EqualFunc(plain, eq) || !plain.EqualFunc(folded, eq) {
+		t.Fatalf("expected equal")
+	}
+	plain, _, _ = plain.Insert([]byte("a"), 2)
+	if folded.EqualFunc(plain, eq) || plain.EqualFunc(folded, eq) {
+		t.Fatalf("expected unequal")
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	hash := func(v int) uint64 { return uint64(v) }
+	empty := New[int](WithContentHash(hash))
+	if h, ok := empty.ContentHash(); !ok || h != 0 {
+		t.Fatalf("bad: %v %v", h, ok)
+	}
+	if _, ok := New[int]().ContentHash(); ok {
+		t.Fatalf("expected no hash")
+	}
+
+	forward := empty
+	for i := 0; i < 50; i++ {
+		forward, _, _ = forward.Insert([]byte(fmt.Sprintf("key/%02d", i)), i)
+	}
+	backward := empty
+	for i := 49; i >= 0; i-- {
+		backward, _, _ = backward.Insert([]byte(fmt.Sprintf("key/%02d", i)), i)
+	}
+	fh, _ := forward.ContentHash()
+	bh, _ := backward.ContentHash()
+	if fh != bh || fh == 0 {
+		t.Fatalf("bad hashes: %x %x", fh, bh)
+	}
+
+	// Updating, deleting and deleting prefixes keep the hash in sync with
+	// the contents.
+	txn := forward.Txn()
+	txn.Insert([]byte("key/10"), 100)
+	txn.Insert([]byte("key/10"), 10)
+	txn.Insert([]byte("extra/1"), 1)
+	txn.Insert([]byte("extra/2"), 2)
+	txn.Delete([]byte("extra/1"))
+	txn.DeletePrefix([]byte("extra/"))
+	if h, _ := txn.Commit().ContentHash(); h != fh {
+		t.Fatalf("bad hash: %x %x", h, fh)
+	}
+
+	// Swapping values between keys changes the hash, so EqualFunc can bail
+	// out without comparing values.
+	swapped, _, _ := forward.Insert([]byte("key/01"), 2)
+	swapped, _, _ = swapped.Insert([]byte("key/02"), 1)
+	if h, _ := swapped.ContentHash(); h == fh {
+		t.Fatalf("expected different hash")
+	}
+	if forward.EqualFunc(swapped, func(a, b int) bool {
+		t.Fatalf("values compared")
+		return false
+	}) {
+		t.Fatalf("expected unequal")
+	}
+}
+
+func TestHash(t *testing.T) {
+	fn := func(v string) uint64 {
+		var h uint64
+		for _, c := range []byte(v) {
+			h = h*31 + uint64(c)
+		}
+		return h
+	}
+	keys := []string{"a", "svc/1", "svc/2", "svc/3", "z"}
+	build := func(opts ...Option) *Tree[string] {
+		r := New[string](append(opts, WithContentHash(fn))...)
+		txn := r.Txn()
+		for _, k := range keys {
+			txn.Insert([]byte(k), k)
+			txn.Insert([]byte(k+"/tmp/x"), k)
+		}
+		for _, k := range keys {
+			txn.Delete([]byte(k + "/tmp/x"))
+		}
+		return txn.Commit()
+	}
+
+	// The hash depends only on the contents, not the layout.
+	a, b := build(), build(WithoutDeleteMerge())
+	if a.Hash() != b.Hash() || a.Root().Hash(fn) != a.Hash() || b.Root().Hash(fn) != b.Hash() {
+		t.Fatalf("bad hashes")
+	}
+
+	// Subtrees under the same prefix can be compared to find differences.
+	c, _, _ := a.Insert([]byte("svc/2"), "changed")
+	if c.Hash() == a.Hash() {
+		t.Fatalf("expected different hashes")
+	}
+	_, as := a.Root().getEdge('s')
+	_, bs := b.Root().getEdge('s')
+	_, cs := c.Root().getEdge('s')
+	_, az := a.Root().getEdge('z')
+	_, cz := c.Root().getEdge('z')
+	if as.Hash(fn) != bs.Hash(fn) || as.Hash(fn) == cs.Hash(fn) || az.Hash(fn) != cz.Hash(fn) {
+		t.Fatalf("bad subtree hashes")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic")
+		}
+	}()
+	New[string]().Hash()
+}
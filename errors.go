@@ -0,0 +1,97 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrTxnCommitted is reported when a transaction is used after it has
+	// been committed.
+	ErrTxnCommitted = errors.New("transaction already committed")
+
+	// ErrTxnRolledBack is reported when a transaction is used after it has
+	// been rolled back.
+	ErrTxnRolledBack = errors.New("transaction rolled back")
+
+	// ErrNilKey is reported when a nil key is given to a transaction. An
+	// empty, non-nil key is always valid.
+	ErrNilKey = errors.New("nil key")
+
+	// ErrBudgetExceeded is reported when an insert is rejected because it
+	// would take the tree over the limit set with WithMemoryBudget.
+	ErrBudgetExceeded = errors.New("iradix: memory budget exceeded")
+
+	// ErrInvalidEncoding is returned when decoding data that is truncated,
+	// corrupt, or in an unsupported version of an encoding.
+	ErrInvalidEncoding = errors.New("iradix: invalid encoding")
+
+	// ErrNoCodec is returned by Tree.MarshalBinary and Tree.UnmarshalBinary
+	// when the tree has no Codec for its values, set with WithCodec.
+	ErrNoCodec = errors.New("iradix: no codec for values")
+
+	// ErrInconsistent is returned by CheckOrdered when a tree's structure
+	// doesn't agree with its contents.
+	ErrInconsistent = errors.New("iradix: tree is inconsistent")
+
+	// ErrInvalidated is returned by WatchedIterator.Err when the keys being
+	// iterated over were changed by a newer version of the tree.
+	ErrInvalidated = errors.New("iradix: iterator invalidated by a newer tree")
+
+	// ErrConflict is returned by Txn.CommitIfUnchanged and AtomicRoot.Commit
+	// when another tree was published after the transaction began.
+	ErrConflict = errors.New("iradix: tree changed since the transaction began")
+)
+
+// MisuseError describes an invalid use of a transaction. The Err field holds
+// one of the sentinel errors defined by this package so that callers can test
+// for it with errors.Is.
+type MisuseError struct {
+	// Op is the name of the operation that was attempted, such as "Insert".
+	Op string
+
+	// Err is the reason the operation was invalid.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *MisuseError) Error() string {
+	return fmt.Sprintf("iradix: %s: %v", e.Op, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *MisuseError) Unwrap() error {
+	return e.Err
+}
+
+// MisusePolicy controls how transactions react to invalid use, such as being
+// written to or committed again after Commit, or being given a nil key.
+type MisusePolicy int
+
+const (
+	// MisuseIgnore keeps the historical behavior where misuse is silently
+	// accepted: a committed transaction can continue to be written to and
+	// committed again, and a nil key is treated as the empty key. This is
+	// the default.
+	MisuseIgnore MisusePolicy = iota
+
+	// MisusePanic panics with a *MisuseError.
+	MisusePanic
+
+	// MisuseReturnError turns the invalid operation into a no-op and records
+	// a *MisuseError that can be retrieved with Txn.Err. Methods on Tree that
+	// use a transaction internally, like Tree.Insert, have no way to report
+	// the error, so they should be avoided with this policy.
+	MisuseReturnError
+)
+
+// WithMisusePolicy sets how transactions on the tree react to invalid use. See
+// MisusePolicy for details.
+func WithMisusePolicy(p MisusePolicy) Option {
+	return func(o *options) {
+		o.misuse = p
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMisusePolicy_Ignore(t *testing.T) {
+	r := New[int]()
+	txn := r.Txn()
+	txn.Insert(nil, 1)
+	r1 := txn.Commit()
+
+	// The historical behavior lets the transaction carry on after commit.
+	txn.Insert([]byte("foo"), 2)
+	r2 := txn.Commit()
+	if r1.Len() != 1 || r2.Len() != 2 {
+		t.Fatalf("bad: %d %d", r1.Len(), r2.Len())
+	}
+	if err := txn.Err(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestMisusePolicy_Panic(t *testing.T) {
+	r := New[int](WithMisusePolicy(MisusePanic))
+
+	expectPanic := func(want error, fn func()) {
+		t.Helper()
+		defer func() {
+			t.Helper()
+			err, ok := recover().(error)
+			if !ok || !errors.Is(err, want) {
+				t.Fatalf("expected panic with %v, got %v", want, err)
+			}
+		}()
+		fn()
+	}
+
+	txn := r.Txn()
+	expectPanic(ErrNilKey, func() { txn.Insert(nil, 1) })
+	expectPanic(ErrNilKey, func() { txn.DeletePrefix(nil) })
+	txn.Insert([]byte{}, 1)
+	txn.Commit()
+	expectPanic(ErrTxnCommitted, func() { txn.Insert([]byte("foo"), 1) })
+	expectPanic(ErrTxnCommitted, func() { txn.Delete([]byte("foo")) })
+	expectPanic(ErrTxnCommitted, func() { txn.Commit() })
+}
+
+func TestMisusePolicy_ReturnError(t *testing.T) {
+	r := New[int](WithMisusePolicy(MisuseReturnError))
+	r, _, _ = r.Insert([]byte("foo"), 1)
+
+	txn := r.Txn()
+	if _, ok := txn.Delete(nil); ok {
+		t.Fatalf("expected no-op")
+	}
+	var merr *MisuseError
+	if err := txn.Err(); !errors.As(err, &merr) || merr.Op != "Delete" || !errors.Is(err, ErrNilKey) {
+		t.Fatalf("bad err: %v", err)
+	}
+
+	txn.Insert([]byte("bar"), 2)
+	r1 := txn.Commit()
+	txn.Insert([]byte("baz"), 3)
+	txn.DeletePrefix([]byte(""))
+	r2 := txn.Commit()
+
+	// The writes after commit must have been rejected, and only the first
+	// error is retained.
+	if r1.Len() != 2 || r2.Len() != 2 {
+		t.Fatalf("bad: %d %d", r1.Len(), r2.Len())
+	}
+	if _, ok := r2.Get([]byte("baz")); ok {
+		t.Fatalf("write after commit was applied")
+	}
+	if !errors.Is(txn.Err(), ErrNilKey) {
+		t.Fatalf("bad err: %v", txn.Err())
+	}
+
+	txn = r2.Txn()
+	txn.Commit()
+	txn.Insert([]byte("baz"), 3)
+	if err := txn.Err(); !errors.Is(err, ErrTxnCommitted) || err.Error() != "iradix: Insert: transaction already committed" {
+		t.Fatalf("bad err: %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const (
+	// exportMagic starts every export.
+	exportMagic = "IRXS"
+
+	// exportVersion is the version of the export encoding.
+	exportVersion = 1
+
+	// exportCollated is the header flag set when the tree has a collation,
+	// whose table follows the flags.
+	exportCollated = 1 << 0
+
+	// exportLeaf is the node flag set when the node holds a leaf.
+	exportLeaf = 1 << 0
+)
+
+// Export encodes the structure of the tree, rather than just its keys and
+// values, using c to encode the values, so that Import can rebuild the same
+// nodes without splitting them again. The nodes map directly onto those of
+// other radix trees, since every node is a prefix, an optional value and a
+// list of children in order, so this is also meant as an interchange format
+// with other radix tree implementations.
+//
+// The encoding is the magic string "IRXS", a version byte, a flags byte, the
+// collation table if the tree has one, and a uvarint count of keys, followed
+// by the nodes in pre-order starting at the root. A node is a flags byte, its
+// prefix, then if it has a leaf its value, and finally a uvarint count of
+// children, which follow it. The key of a leaf is the concatenation of the
+// prefixes on the path to it, unless the tree has a collation, in which case
+// the key is written before the value since it differs from the path. Byte
+// strings are written as a uvarint length followed by that many bytes.
+func (t *Tree[T]) Export(c Codec[T]) ([]byte, error) {
+	b := append([]byte{}, exportMagic...)
+	b = append(b, exportVersion)
+	if t.conf.collate != nil {
+		b = append(b, exportCollated)
+		b = append(b, t.conf.collate[:]...)
+	} else {
+		b = append(b, 0)
+	}
+	b = appendUvarint(b, uint64(t.size))
+	return exportNode(b, t.root, c, t.conf.collate != nil)
+}
+
+// exportNode appends the encoding of n and the nodes under it to b.
+func exportNode[T any](b []byte, n *Node[T], c Codec[T], withKeys bool) ([]byte, error) {
+	var flags byte
+	if n.leaf != nil {
+		flags |= exportLeaf
+	}
+	b = append(b, flags)
+	b = appendUvarint(b, uint64(len(n.prefix)))
+	b = append(b, n.prefix...)
+	if n.leaf != nil {
+		if withKeys {
+			b = appendUvarint(b, uint64(len(n.leaf.key)))
+			b = append(b, n.leaf.key...)
+		}
+		var err error
+		if b, err = appendValue(b, n.leaf.val, c); err != nil {
+			return nil, err
+		}
+	}
+	b = appendUvarint(b, uint64(len(n.edges())))
+	for _, e := range n.edges() {
+		var err error
+		if b, err = exportNode(b, e.node, c, withKeys); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Import rebuilds a tree from the output of Tree.Export, using c to decode the
+// values, creating exactly the nodes that were exported. The tree is created
+// with the given options, which must have the same collation as the exported
+// tree. It returns an error wrapping ErrInvalidEncoding if b isn't a complete
+// export in a supported version or its nodes don't form a valid tree, and
+// ErrBudgetExceeded if the keys and values take the tree over its memory
+// budget.
+func Import[T any](b []byte, c Codec[T], opts ...Option) (*Tree[T], error) {
+	return importTree(b, c, New[T](opts...))
+}
+
+// importTree rebuilds a tree from the output of Tree.Export, as a transaction
+// on base, which must be empty.
+func importTree[T any](b []byte, c Codec[T], base *Tree[T]) (*Tree[T], error) {
+	d := &decoder{b: b}
+	if string(d.next(len(exportMagic))) != exportMagic {
+		return nil, fmt.Errorf("%w: not an export", ErrInvalidEncoding)
+	}
+	if v := d.next(1); len(v) == 1 && v[0] != exportVersion {
+		return nil, fmt.Errorf("%w: unsupported export version %d", ErrInvalidEncoding, v[0])
+	}
+	var collate *[256]byte
+	if flags := d.next(1); len(flags) == 1 && flags[0]&exportCollated != 0 {
+		if table := d.next(256); len(table) == 256 {
+			collate = (*[256]byte)(table)
+		}
+	}
+	size := d.uvarint()
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	txn := base.Txn()
+	if !sameCollation(collate, txn.conf.collate) {
+		return nil, fmt.Errorf("iradix: imported tree has a different collation")
+	}
+	im := importer[T]{txn: txn, d: d, codec: c}
+	root := im.node(nil, true)
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(d.b) != 0 {
+		return nil, fmt.Errorf("%w: trailing data after export", ErrInvalidEncoding)
+	}
+	if uint64(root.count()) != size {
+		return nil, fmt.Errorf("%w: export has %d keys, expected %d", ErrInvalidEncoding, root.count(), size)
+	}
+	txn.root = root
+	txn.size = root.count()
+	txn.recount()
+	if txn.conf.sizer != nil && txn.bytes > txn.conf.budget {
+		return nil, ErrBudgetExceeded
+	}
+	return txn.derive(), nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, encoding the tree with
+// Export using the codec set with WithCodec. Since the nodes are written with
+// their prefixes, shared prefixes are only stored once, and UnmarshalBinary
+// rebuilds the same nodes without splitting or copying any of them. It returns
+// ErrNoCodec if the tree has no codec.
+func (t *Tree[T]) MarshalBinary() ([]byte, error) {
+	c := t.conf.valueCodec()
+	if c == nil {
+		return nil, ErrNoCodec
+	}
+	return t.Export(c)
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, replacing the
+// contents of the tree with the output of MarshalBinary or Export. The tree
+// keeps the options it was created with by New, including its codec, and a
+// zero Tree gets the default options. The errors are those of Import, or
+// ErrNoCodec if the tree has no codec, and the tree is unchanged if one is
+// returned.
+func (t *Tree[T]) UnmarshalBinary(b []byte) error {
+	base := t.emptyBase()
+	c := base.conf.valueCodec()
+	if c == nil {
+		return ErrNoCodec
+	}
+	nt, err := importTree(b, c, base)
+	if err != nil {
+		return err
+	}
+	*t = *nt
+	return nil
+}
+
+// valueCodec returns the codec set with WithCodec, falling back to BytesCodec
+// or StringCodec for those value types, or nil if there's none.
+func (c *config[T]) valueCodec() Codec[T] {
+	if c.codec != nil {
+		return c.codec
+	}
+	if codec, ok := any(BytesCodec{}).(Codec[T]); ok {
+		return codec
+	}
+	if codec, ok := any(StringCodec{}).(Codec[T]); ok {
+		return codec
+	}
+	return nil
+}
+
+// importer rebuilds the nodes of an export.
+type importer[T any] struct {
+	txn   *Txn[T]
+	d     *decoder
+	codec Codec[T]
+}
+
+// node decodes the next node, whose parent's path is path, along with the
+// nodes under it. Errors are recorded in the decoder, and the returned node is
+// only valid if there were none. The root is the only node that may be empty
+// or have an empty prefix.
+func (im *importer[T]) node(path []byte, root bool) *Node[T] {
+	d, t := im.d, im.txn
+	var flags byte
+	if f := d.next(1); len(f) == 1 {
+		flags = f[0]
+	}
+	n := t.allocNode(Node[T]{prefix: append([]byte{}, d.bytes()...)})
+	if d.err != nil {
+		return n
+	}
+	if !root && len(n.prefix) == 0 {
+		d.err = fmt.Errorf("%w: empty prefix", ErrInvalidEncoding)
+		return n
+	}
+	path = append(path, n.prefix...)
+	if flags&exportLeaf != 0 {
+		key := path
+		if t.conf.collate != nil {
+			key = d.bytes()
+		}
+		key = append([]byte{}, key...)
+		v := decodeValue(d, im.codec)
+		if t.conf.intern != nil {
+			v = t.conf.intern(v)
+		}
+		n.leaf = t.newLeaf(key, v, nil)
+	}
+
+	edges := d.uvarint()
+	if d.err == nil && edges > 256 {
+		d.err = fmt.Errorf("%w: bad edge count", ErrInvalidEncoding)
+	}
+	for i := uint64(0); i < edges && d.err == nil; i++ {
+		child := im.node(path, false)
+		if d.err != nil {
+			break
+		}
+		es := n.edges()
+		if len(es) != 0 && es[len(es)-1].label >= child.prefix[0] {
+			d.err = fmt.Errorf("%w: children out of order", ErrInvalidEncoding)
+			break
+		}
+		n.setEdges(append(es, edge[T]{label: child.prefix[0], node: child}))
+		n.setCount(n.count() + child.count())
+	}
+	if d.err == nil && !root && n.count() == 0 {
+		d.err = fmt.Errorf("%w: empty node", ErrInvalidEncoding)
+	}
+	if d.err == nil && t.aliasLeafPrefix(n) && bytes.HasSuffix(n.leaf.key, n.prefix) {
+		n.prefix = leafPrefix(n.leaf, len(n.prefix))
+	}
+	return n
+}
@@ -0,0 +1,149 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	optsCases := [][]Option{
+		nil,
+		{WithoutDeleteMerge()},
+		{WithCollation(FoldCaseCollation())},
+	}
+	for i, opts := range optsCases {
+		rnd := rand.New(rand.NewSource(int64(i)))
+		r := New[string](opts...)
+		for j := 0; j < 300; j++ {
+			k := fmt.Sprintf("%c/%x", "aAbB"[rnd.Intn(4)], rnd.Intn(1000))
+			r, _, _ = r.Insert([]byte(k), k)
+		}
+		for j := 0; j < 100; j++ {
+			r, _, _ = r.Delete([]byte(fmt.Sprintf("a/%x", rnd.Intn(1000))))
+		}
+
+		b, err := r.Export(StringCodec{})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		out, err := Import[string](b, StringCodec{}, opts...)
+		if err != nil {
+			t.Fatalf("%d: err: %v", i, err)
+		}
+
+		// The nodes are rebuilt exactly, including ones that a delete
+		// without merging left behind.
+		if !reflect.DeepEqual(nodeLayout(out.Root()), nodeLayout(r.Root())) || out.Len() != r.Len() {
+			t.Fatalf("%d: bad import", i)
+		}
+		if err := CheckOrdered(out); err != nil {
+			t.Fatalf("%d: err: %v", i, err)
+		}
+	}
+}
+
+func TestExport_Encoding(t *testing.T) {
+	r := New[string]()
+	for _, k := range []string{"foo", "foobar", "zip"} {
+		r, _, _ = r.Insert([]byte(k), k)
+	}
+	b, err := r.Export(StringCodec{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The encoding is stable, so check it byte for byte.
+	expect := "IRXS\x01\x00\x03" +
+		"\x00\x00\x02" +
+		"\x01\x03foo\x03foo\x01" +
+		"\x01\x03bar\x06foobar\x00" +
+		"\x01\x03zip\x03zip\x00"
+	if string(b) != expect {
+		t.Fatalf("bad encoding: %q", b)
+	}
+
+	for _, bad := range []string{
+		"",
+		"IRXP\x01\x00\x00",
+		"IRXS\x02\x00\x00",
+		expect[:len(expect)-1],
+		expect + "\x00",
+		"IRXS\x01\x00\x01\x00\x00\x01\x00\x00\x00",
+		"IRXS\x01\x00\x02\x00\x00\x02\x01\x01b\x00\x00\x01\x01a\x00\x00",
+		"IRXS\x01\x00\x05" + expect[7:],
+	} {
+		if _, err := Import[string]([]byte(bad), StringCodec{}); !errors.Is(err, ErrInvalidEncoding) {
+			t.Fatalf("expected invalid encoding for %q: %v", bad, err)
+		}
+	}
+
+	if _, err := Import[string](b, StringCodec{}, WithCollation(FoldCaseCollation())); err == nil {
+		t.Fatalf("expected collation error")
+	}
+	size := func(s string) int { return len(s) }
+	if _, err := Import[string](b, StringCodec{}, WithMemoryBudget(10, size)); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected budget error: %v", err)
+	}
+}
+
+func TestTree_MarshalBinary(t *testing.T) {
+	r := New[int](WithCodec[int](JSONCodec[int]{}), WithCollation(FoldCaseCollation()))
+	for i, k := range []string{"foo/bar", "Foo", "", "foo/baz"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	b, err := r.MarshalBinary()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if export, _ := r.Export(JSONCodec[int]{}); !reflect.DeepEqual(b, export) {
+		t.Fatalf("bad encoding: %q", b)
+	}
+
+	// Unmarshaling into a tree uses its codec and options.
+	out := New[int](WithCodec[int](JSONCodec[int]{}), WithCollation(FoldCaseCollation()))
+	if err := out.UnmarshalBinary(b); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(nodeLayout(out.Root()), nodeLayout(r.Root())) {
+		t.Fatalf("bad layout:\n%v", nodeLayout(out.Root()))
+	}
+	if err := CheckOrdered(out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Without a codec, only trees of bytes or strings can be encoded.
+	var zero Tree[int]
+	if err := zero.UnmarshalBinary(b); !errors.Is(err, ErrNoCodec) {
+		t.Fatalf("expected no codec error, got %v", err)
+	}
+	if _, err := New[int]().MarshalBinary(); !errors.Is(err, ErrNoCodec) {
+		t.Fatalf("expected no codec error, got %v", err)
+	}
+	s := New[string]()
+	s, _, _ = s.Insert([]byte("foo"), "bar")
+	s, _, _ = s.Insert([]byte("foobar"), "baz")
+	if b, err = s.MarshalBinary(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var s2 Tree[string]
+	if err := s2.UnmarshalBinary(b); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if v, ok := s2.Get([]byte("foobar")); !ok || v != "baz" || s2.Len() != 2 {
+		t.Fatalf("bad value: %v %v", v, ok)
+	}
+
+	// A failed unmarshal leaves the tree as it was.
+	if err := s2.UnmarshalBinary(b[:len(b)-1]); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("expected invalid encoding, got %v", err)
+	}
+	if s2.Len() != 2 {
+		t.Fatalf("tree was changed")
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+// Filter returns a tree with only the keys of this tree for which pred returns
+// true. The result is built in a single transaction, and every subtree whose
+// keys all pass is shared with this tree rather than copied, so filtering out
+// a few keys only copies the nodes on the paths to them. The predicate is
+// called once for every key, in order.
+func (t *Tree[T]) Filter(pred func(k []byte, v T) bool) *Tree[T] {
+	txn := t.Txn()
+	root := txn.filter(t.root, pred)
+	if root == nil {
+		root = txn.allocNode(Node[T]{})
+	} else if len(root.prefix) != 0 {
+		// The root was collapsed with its only child.
+		root = txn.allocNode(Node[T]{
+			inner: &innerNode[T]{
+				edges: edges[T]{{label: root.prefix[0], node: root}},
+				count: root.count(),
+			},
+		})
+	}
+	txn.root = root
+	txn.size = root.count()
+	txn.recount()
+	return txn.derive()
+}
+
+// filter returns the subtree with the keys under n that pass pred, which is n
+// itself if they all do, or nil if none do. Like intersect, the result may have
+// a longer prefix than n, if it's left with a single child and no leaf.
+func (t *Txn[T]) filter(n *Node[T], pred func(k []byte, v T) bool) *Node[T] {
+	nn := Node[T]{prefix: n.prefix}
+	if n.leaf != nil && pred(n.leaf.key, n.leaf.val) {
+		nn.leaf = n.leaf
+	}
+	same := nn.leaf == n.leaf
+	var es edges[T]
+	count := nn.count()
+	for i, e := range n.edges() {
+		child := t.filter(e.node, pred)
+		if child == e.node {
+			if same {
+				continue
+			}
+		} else if same {
+			// Take the children that passed whole so far.
+			same = false
+			es = make(edges[T], 0, len(n.edges()))
+			for _, prev := range n.edges()[:i] {
+				es = append(es, prev)
+				count += prev.node.count()
+			}
+		}
+		if child != nil {
+			es = append(es, edge[T]{label: e.label, node: child})
+			count += child.count()
+		}
+	}
+
+	switch {
+	case same:
+		return n
+	case count == 0:
+		return nil
+	case nn.leaf == nil && len(es) == 1:
+		// Collapse the node with its only child.
+		child := es[0].node
+		nn = Node[T]{
+			prefix: concat(nn.prefix, child.prefix),
+			leaf:   child.leaf,
+		}
+		es, count = child.edges(), child.count()
+	}
+	nn.setEdges(es)
+	nn.setCount(count)
+	out := t.allocNode(nn)
+	if t.aliasLeafPrefix(out) {
+		out.prefix = leafPrefix(out.leaf, len(out.prefix))
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	hash := func(v int) uint64 { return uint64(v) }
+	r := New[int](WithContentHash(hash))
+	for i := 0; i < 1000; i++ {
+		r, _, _ = r.Insert([]byte(fmt.Sprintf("%c/%d", "abc"[rnd.Intn(3)], rnd.Intn(500))), i)
+	}
+
+	for _, mod := range []int{1, 2, 3, 7, 1000000} {
+		pred := func(k []byte, v int) bool { return v%mod == 0 }
+		got := r.Filter(pred)
+
+		expect := New[int](WithContentHash(hash))
+		var visited [][]byte
+		r.Root().Walk(func(k []byte, v int) bool {
+			visited = append(visited, k)
+			if pred(k, v) {
+				expect, _, _ = expect.Insert(k, v)
+			}
+			return false
+		})
+		if !got.EqualFunc(expect, func(a, b int) bool { return a == b }) || got.Len() != expect.Len() {
+			t.Fatalf("%d: bad filter", mod)
+		}
+		if got.Hash() != expect.Hash() || len(visited) != r.Len() {
+			t.Fatalf("%d: bad hash", mod)
+		}
+		if err := CheckOrdered(got); err != nil {
+			t.Fatalf("%d: err: %v", mod, err)
+		}
+		if !reflect.DeepEqual(nodeLayout(got.Canonicalize().Root()), nodeLayout(expect.Canonicalize().Root())) {
+			t.Fatalf("%d: bad layout", mod)
+		}
+	}
+
+	// Subtrees that pass whole are shared.
+	got := r.Filter(func(k []byte, _ int) bool { return k[0] != 'a' })
+	if got.Root().Child('b') != r.Root().Child('b') || got.Root().Child('a') != nil {
+		t.Fatalf("expected shared subtree")
+	}
+	if r.Filter(func([]byte, int) bool { return true }).Root() != r.Root() {
+		t.Fatalf("expected shared root")
+	}
+}
@@ -0,0 +1,340 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"go/token"
+	"io"
+	"math"
+	"strconv"
+)
+
+const (
+	// frozenMagic starts every frozen tree.
+	frozenMagic = "IRXF"
+
+	// frozenVersion is the version of the frozen tree encoding.
+	frozenVersion = 1
+
+	// frozenHeaderSize is the size of the fixed part of the header: the
+	// magic, version, flags, root offset and size.
+	frozenHeaderSize = len(frozenMagic) + 2 + 4 + 8
+
+	// frozenCollated is the header flag set when a collation table follows
+	// the fixed part of the header.
+	frozenCollated = 1 << 0
+
+	// frozenLeaf is the node flag set when the node holds a leaf.
+	frozenLeaf = 1 << 0
+)
+
+// Freeze encodes the tree into a flat, read-only form that can be queried in
+// place with OpenFrozen, without decoding it first, using c to encode the
+// values. This is meant for static lookup tables that are built once and
+// shipped inside binaries, either embedded from a file with go:embed or as Go
+// source generated with EncodeGo.
+//
+// The encoding is the magic string "IRXF", a version byte, a flags byte, the
+// offset of the root node as a little endian uint32 and the number of keys as
+// a little endian uint64, followed by the collation table if the tree has one.
+// Then come the nodes, each written after all of its children. A node is a
+// flags byte, its prefix, then if it has a leaf its key and value, and finally
+// a uvarint count of edges, the edge labels and the offsets of the children as
+// little endian uint32s. Byte strings are written as a uvarint length followed
+// by that many bytes. Freeze fails if the encoding would exceed 4GiB.
+func (t *Tree[T]) Freeze(c Codec[T]) ([]byte, error) {
+	if t.root == nil {
+		return nil, fmt.Errorf("iradix: can't freeze a released tree")
+	}
+	b := append([]byte{}, frozenMagic...)
+	b = append(b, frozenVersion, 0)
+	b = append(b, make([]byte, 12)...)
+	if t.conf.collate != nil {
+		b[len(frozenMagic)+1] |= frozenCollated
+		b = append(b, t.conf.collate[:]...)
+	}
+	b, root, err := freezeNode(b, t.root, c)
+	if err != nil {
+		return nil, err
+	}
+	binary.LittleEndian.PutUint32(b[len(frozenMagic)+2:], root)
+	binary.LittleEndian.PutUint64(b[len(frozenMagic)+6:], uint64(t.size))
+	return b, nil
+}
+
+// freezeNode appends the encoding of n and its children to b, and returns the
+// result along with the offset of n.
+func freezeNode[T any](b []byte, n *Node[T], c Codec[T]) ([]byte, uint32, error) {
+	var err error
+	offs := make([]uint32, len(n.edges()))
+	for i, e := range n.edges() {
+		if b, offs[i], err = freezeNode(b, e.node, c); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	off := len(b)
+	if uint64(off) > math.MaxUint32 {
+		return nil, 0, fmt.Errorf("iradix: frozen tree is too large")
+	}
+	var flags byte
+	if n.leaf != nil {
+		flags |= frozenLeaf
+	}
+	b = append(b, flags)
+	b = appendUvarint(b, uint64(len(n.prefix)))
+	b = append(b, n.prefix...)
+	if n.leaf != nil {
+		b = appendUvarint(b, uint64(len(n.leaf.key)))
+		b = append(b, n.leaf.key...)
+		if b, err = appendValue(b, n.leaf.val, c); err != nil {
+			return nil, 0, err
+		}
+	}
+	b = appendUvarint(b, uint64(len(n.edges())))
+	for _, e := range n.edges() {
+		b = append(b, e.label)
+	}
+	var buf [4]byte
+	for _, o := range offs {
+		binary.LittleEndian.PutUint32(buf[:], o)
+		b = append(b, buf[:]...)
+	}
+	return b, uint32(off), nil
+}
+
+// EncodeGo writes Go source to w that declares a variable with the given name
+// in package pkg, holding the frozen tree data returned by Freeze, so that the
+// tree can be compiled into a binary and opened with OpenFrozen.
+func EncodeGo(w io.Writer, pkg, name string, data []byte) error {
+	if !token.IsIdentifier(pkg) || !token.IsIdentifier(name) {
+		return fmt.Errorf("iradix: invalid Go identifier in %q.%q", pkg, name)
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by iradix.EncodeGo. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	fmt.Fprintf(&b, "// %s holds a frozen tree, to be opened with iradix.OpenFrozen.\n", name)
+	fmt.Fprintf(&b, "var %s = []byte(\"\"", name)
+	const lineLen = 64
+	for len(data) > 0 {
+		n := lineLen
+		if n > len(data) {
+			n = len(data)
+		}
+		b.WriteString(" +\n\t")
+		b.WriteString(strconv.Quote(string(data[:n])))
+		data = data[n:]
+	}
+	b.WriteString(")\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// Frozen is a read-only tree that reads the data written by Tree.Freeze in
+// place. Opening it only checks the header, and lookups and walks decode just
+// the nodes they visit, without allocating, except for the values they return.
+// Keys passed to callbacks point into the data and must not be modified. A
+// Frozen is safe for concurrent use.
+type Frozen[T any] struct {
+	data    []byte
+	codec   Codec[T]
+	root    uint32
+	size    int
+	collate *[256]byte
+}
+
+// OpenFrozen returns a Frozen reading the data written by Tree.Freeze, which
+// must not be modified afterwards, using c to decode values. It returns an
+// error wrapping ErrInvalidEncoding if the header isn't valid. The nodes are
+// not checked up front, so errors in them are reported by the methods that
+// reach them.
+func OpenFrozen[T any](data []byte, c Codec[T]) (*Frozen[T], error) {
+	if len(data) < frozenHeaderSize || string(data[:len(frozenMagic)]) != frozenMagic {
+		return nil, fmt.Errorf("%w: not a frozen tree", ErrInvalidEncoding)
+	}
+	if v := data[len(frozenMagic)]; v != frozenVersion {
+		return nil, fmt.Errorf("%w: unsupported frozen tree version %d", ErrInvalidEncoding, v)
+	}
+	f := &Frozen[T]{
+		data:  data,
+		codec: c,
+		root:  binary.LittleEndian.Uint32(data[len(frozenMagic)+2:]),
+	}
+	size := binary.LittleEndian.Uint64(data[len(frozenMagic)+6:])
+	if size > uint64(len(data)) {
+		return nil, fmt.Errorf("%w: bad frozen tree size", ErrInvalidEncoding)
+	}
+	f.size = int(size)
+	start := frozenHeaderSize
+	if data[len(frozenMagic)+1]&frozenCollated != 0 {
+		if len(data) < start+256 {
+			return nil, fmt.Errorf("%w: unexpected end of data", ErrInvalidEncoding)
+		}
+		f.collate = (*[256]byte)(data[start : start+256])
+		start += 256
+	}
+	if int(f.root) < start || int(f.root) >= len(data) {
+		return nil, fmt.Errorf("%w: bad frozen root offset", ErrInvalidEncoding)
+	}
+	return f, nil
+}
+
+// frozenNode is a node of a Frozen, with its fields pointing into the data.
+type frozenNode struct {
+	off    uint32
+	leaf   bool
+	prefix []byte
+	key    []byte
+	val    []byte
+	labels []byte
+	kids   []byte
+}
+
+// node decodes the node at offset off. Children must come before their parent,
+// which guarantees that walks over corrupt data end.
+func (f *Frozen[T]) node(off uint32) (frozenNode, error) {
+	n := frozenNode{off: off}
+	if int(off) >= len(f.data) {
+		return n, fmt.Errorf("%w: bad frozen node offset", ErrInvalidEncoding)
+	}
+	d := decoder{b: f.data[off:]}
+	if flags := d.next(1); len(flags) == 1 {
+		n.leaf = flags[0]&frozenLeaf != 0
+	}
+	n.prefix = d.bytes()
+	if n.leaf {
+		n.key = d.bytes()
+		n.val = d.bytes()
+	}
+	edges := d.uvarint()
+	if d.err == nil && edges > 256 {
+		return n, fmt.Errorf("%w: bad frozen edge count", ErrInvalidEncoding)
+	}
+	n.labels = d.next(int(edges))
+	n.kids = d.next(int(edges) * 4)
+	return n, d.err
+}
+
+// child returns the child of n at index i.
+func (f *Frozen[T]) child(n frozenNode, i int) (frozenNode, error) {
+	off := binary.LittleEndian.Uint32(n.kids[i*4:])
+	if off >= n.off {
+		return frozenNode{}, fmt.Errorf("%w: bad frozen child offset", ErrInvalidEncoding)
+	}
+	return f.node(off)
+}
+
+// edge returns the child of n under the given label, if there is one.
+func (f *Frozen[T]) edge(n frozenNode, label byte) (frozenNode, bool, error) {
+	i := bytes.IndexByte(n.labels, label)
+	if i < 0 {
+		return frozenNode{}, false, nil
+	}
+	c, err := f.child(n, i)
+	return c, err == nil, err
+}
+
+// Len returns the number of keys in the tree.
+func (f *Frozen[T]) Len() int {
+	return f.size
+}
+
+// Get returns the value stored under k, and whether there is one. The error
+// wraps ErrInvalidEncoding if the data is corrupt, or is the error returned by
+// the Codec.
+func (f *Frozen[T]) Get(k []byte) (T, bool, error) {
+	var zero T
+	search := collateKey(f.collate, k)
+	n, err := f.node(f.root)
+	for err == nil {
+		if len(search) == 0 {
+			if !n.leaf {
+				return zero, false, nil
+			}
+			v, err := f.codec.DecodeValue(n.val)
+			return v, err == nil, err
+		}
+		var ok bool
+		if n, ok, err = f.edge(n, search[0]); !ok {
+			break
+		}
+		if !bytes.HasPrefix(search, n.prefix) {
+			return zero, false, nil
+		}
+		search = search[len(n.prefix):]
+	}
+	return zero, false, err
+}
+
+// Walk calls fn for every key in the tree, in order, until fn returns true.
+// The error is from decoding the data or a value, as for Get.
+func (f *Frozen[T]) Walk(fn WalkFn[T]) error {
+	return f.WalkPrefix(nil, fn)
+}
+
+// WalkPrefix is like Walk, but only visits the keys with the given prefix.
+func (f *Frozen[T]) WalkPrefix(prefix []byte, fn WalkFn[T]) error {
+	search := collateKey(f.collate, prefix)
+	n, err := f.node(f.root)
+	for err == nil {
+		if len(search) == 0 {
+			_, err = f.walk(n, fn)
+			return err
+		}
+		var ok bool
+		if n, ok, err = f.edge(n, search[0]); !ok {
+			break
+		}
+		if bytes.HasPrefix(search, n.prefix) {
+			search = search[len(n.prefix):]
+		} else if bytes.HasPrefix(n.prefix, search) {
+			_, err = f.walk(n, fn)
+			return err
+		} else {
+			break
+		}
+	}
+	return err
+}
+
+// walk is like recursiveWalk for a Frozen. It returns true if the walk was
+// aborted.
+func (f *Frozen[T]) walk(n frozenNode, fn WalkFn[T]) (bool, error) {
+	if n.leaf {
+		v, err := f.codec.DecodeValue(n.val)
+		if err != nil {
+			return true, err
+		}
+		if fn(n.key, v) {
+			return true, nil
+		}
+	}
+	for i := range n.labels {
+		c, err := f.child(n, i)
+		if err != nil {
+			return true, err
+		}
+		if done, err := f.walk(c, fn); done {
+			return true, err
+		}
+	}
+	return false, nil
+}
+
+// Tree decodes the whole frozen tree into a new Tree, created with the given
+// options. The keys are copied, so the Tree doesn't retain the data.
+func (f *Frozen[T]) Tree(opts ...Option) (*Tree[T], error) {
+	kvs := make([]KV[T], 0, f.size)
+	err := f.Walk(func(k []byte, v T) bool {
+		kvs = append(kvs, KV[T]{Key: append([]byte{}, k...), Val: v})
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return New[T](opts...).BulkLoad(kvs), nil
+}
@@ -0,0 +1,155 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestFrozen(t *testing.T) {
+	r := New[string]()
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		k := fmt.Sprintf("%x/%d", rnd.Intn(64), rnd.Intn(100))
+		r, _, _ = r.Insert([]byte(k), k)
+	}
+	r, _, _ = r.Insert([]byte(""), "root")
+
+	data, err := r.Freeze(StringCodec{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f, err := OpenFrozen[string](data, StringCodec{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if f.Len() != r.Len() {
+		t.Fatalf("bad len: %d", f.Len())
+	}
+
+	// Every key is found, and misses aren't.
+	r.Root().Walk(func(k []byte, v string) bool {
+		if got, ok, err := f.Get(k); err != nil || !ok || got != v {
+			t.Fatalf("bad get %q: %q %v %v", k, got, ok, err)
+		}
+		return false
+	})
+	for _, k := range []string{"3", "3/", "zz", "0/1000"} {
+		if _, ok, err := f.Get([]byte(k)); err != nil || ok {
+			t.Fatalf("unexpected get %q: %v %v", k, ok, err)
+		}
+	}
+
+	// Walks match the tree.
+	for _, prefix := range []string{"", "1", "1f/", "1f/9", "nope"} {
+		var expect, got []string
+		r.Root().WalkPrefix([]byte(prefix), func(k []byte, _ string) bool {
+			expect = append(expect, string(k))
+			return false
+		})
+		if err := f.WalkPrefix([]byte(prefix), func(k []byte, _ string) bool {
+			got = append(got, string(k))
+			return false
+		}); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if strings.Join(got, ",") != strings.Join(expect, ",") {
+			t.Fatalf("bad walk of %q: %v", prefix, got)
+		}
+	}
+
+	thawed, err := f.Tree()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !thawed.EqualFunc(r, func(a, b string) bool { return a == b }) {
+		t.Fatalf("bad thawed tree")
+	}
+}
+
+func TestFrozen_Collation(t *testing.T) {
+	r := New[string](WithCollation(FoldCaseCollation()))
+	for _, k := range []string{"b", "B", "a", "A"} {
+		r, _, _ = r.Insert([]byte(k), k)
+	}
+	data, err := r.Freeze(StringCodec{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f, err := OpenFrozen[string](data, StringCodec{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if v, ok, _ := f.Get([]byte("B")); !ok || v != "B" {
+		t.Fatalf("bad get: %q %v", v, ok)
+	}
+	var keys []string
+	f.Walk(func(k []byte, _ string) bool {
+		keys = append(keys, string(k))
+		return false
+	})
+	if strings.Join(keys, ",") != "A,a,B,b" {
+		t.Fatalf("bad order: %v", keys)
+	}
+}
+
+func TestFrozen_Invalid(t *testing.T) {
+	r := New[string]()
+	r, _, _ = r.Insert([]byte("foo"), "bar")
+	r, _, _ = r.Insert([]byte("fob"), "baz")
+	data, err := r.Freeze(StringCodec{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for _, bad := range [][]byte{nil, []byte("IRXP\x01"), append([]byte("IRXF\x02"), data[5:]...)} {
+		if _, err := OpenFrozen[string](bad, StringCodec{}); !errors.Is(err, ErrInvalidEncoding) {
+			t.Fatalf("expected invalid encoding for %q: %v", bad, err)
+		}
+	}
+
+	// A truncated tree is reported when reading the nodes.
+	f, err := OpenFrozen[string](data[:len(data)-2], StringCodec{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, _, err := f.Get([]byte("foo")); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("expected invalid encoding: %v", err)
+	}
+	if err := f.Walk(func([]byte, string) bool { return false }); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("expected invalid encoding: %v", err)
+	}
+}
+
+func TestEncodeGo(t *testing.T) {
+	r := New[string]()
+	r, _, _ = r.Insert([]byte("foo"), "bar\x00\"")
+	data, err := r.Freeze(StringCodec{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var b bytes.Buffer
+	if err := EncodeGo(&b, "routes", "table", data); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "table.go", b.Bytes(), 0)
+	if err != nil {
+		t.Fatalf("err: %v\n%s", err, b.String())
+	}
+	if file.Name.Name != "routes" || file.Scope.Lookup("table") == nil {
+		t.Fatalf("bad source:\n%s", b.String())
+	}
+
+	if err := EncodeGo(&b, "routes", "not valid", data); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+// fuzzAlphabet is the set of bytes keys are made of in FuzzTree. It's small so
+// that random keys share prefixes often, which is where the tree does most of
+// its splitting and merging, and includes the extreme byte values.
+const fuzzAlphabet = "ab/\x00\xff"
+
+// FuzzTree runs a program of operations decoded from the input against a tree
+// and a map, checking that they agree after every step. Each operation is an
+// opcode byte followed by a key, which is a length byte and then up to three
+// bytes picked from fuzzAlphabet. Shrinking an input removes operations or key
+// bytes, so failures reduce to short programs.
+func FuzzTree(f *testing.F) {
+	f.Add([]byte("\x00\x01a\x00\x02ab\x01\x01a"))
+	f.Add([]byte("\x00\x03aab\x00\x03aba\x02\x01a\x03\x00"))
+	f.Add([]byte("\x00\x00\x00\x01\xff\x05\x02\x00\x04\x01a\x01\x00"))
+	f.Add([]byte("\x00\x03ab/\x00\x03ab\x00\x00\x02ab\x06\x01a\x03\x02ab"))
+
+	f.Fuzz(func(t *testing.T, program []byte) {
+		txn := New[int]().Txn()
+		model := make(map[string]int)
+		next := func() byte {
+			if len(program) == 0 {
+				return 0
+			}
+			b := program[0]
+			program = program[1:]
+			return b
+		}
+		nextKey := func() []byte {
+			k := make([]byte, int(next())%4)
+			for i := range k {
+				k[i] = fuzzAlphabet[int(next())%len(fuzzAlphabet)]
+			}
+			return k
+		}
+
+		for step := 0; len(program) > 0; step++ {
+			op, k := next()%8, nextKey()
+			switch op {
+			case 0, 1:
+				txn.Insert(k, step)
+				model[string(k)] = step
+			case 2:
+				_, ok := txn.Delete(k)
+				if _, expect := model[string(k)]; ok != expect {
+					t.Fatalf("step %d: bad delete of %q: %v", step, k, ok)
+				}
+				delete(model, string(k))
+			case 3:
+				txn.DeletePrefix(k)
+				for mk := range model {
+					if strings.HasPrefix(mk, string(k)) {
+						delete(model, mk)
+					}
+				}
+			case 4:
+				v, ok := txn.Get(k)
+				if expect, expectOK := model[string(k)]; ok != expectOK || v != expect {
+					t.Fatalf("step %d: bad get of %q: %v %v", step, k, v, ok)
+				}
+			case 5:
+				// Commit so that later writes copy nodes instead of
+				// modifying them in place.
+				txn = txn.Commit().Txn()
+			case 6:
+				var expect []string
+				for mk := range model {
+					if strings.HasPrefix(mk, string(k)) {
+						expect = append(expect, mk)
+					}
+				}
+				sort.Strings(expect)
+				it := txn.Root().Iterator()
+				it.SeekPrefix(k)
+				checkFuzzIterator(t, step, it, expect)
+			case 7:
+				var expect []string
+				for mk := range model {
+					if mk >= string(k) {
+						expect = append(expect, mk)
+					}
+				}
+				sort.Strings(expect)
+				it := txn.Root().Iterator()
+				it.SeekLowerBound(k)
+				checkFuzzIterator(t, step, it, expect)
+			}
+
+			if txn.size != len(model) || txn.Root().count() != len(model) {
+				t.Fatalf("step %d: bad len %d, expected %d", step, txn.size, len(model))
+			}
+		}
+
+		r := txn.Commit()
+		if err := CheckOrdered(r); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		var expect []string
+		for mk := range model {
+			expect = append(expect, mk)
+		}
+		sort.Strings(expect)
+		checkFuzzIterator(t, -1, r.Root().Iterator(), expect)
+	})
+}
+
+// checkFuzzIterator checks that the iterator returns exactly the expected keys.
+func checkFuzzIterator(t *testing.T, step int, it *Iterator[int], expect []string) {
+	t.Helper()
+	var got []string
+	for k, _, ok := it.Next(); ok; k, _, ok = it.Next() {
+		got = append(got, string(k))
+	}
+	if strings.Join(got, ",") != strings.Join(expect, ",") || len(got) != len(expect) {
+		t.Fatalf("step %d: got keys %q, expected %q", step, got, expect)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Group publishes a set of related trees together, such as a table and the
+// indexes over it, so that readers never see one of them updated without the
+// others. Every tree in the group is a Member, and a reader gets all of them at
+// once as a GroupSnapshot. Writes go through a GroupTxn, which holds a Txn for
+// each member it changes and publishes all of their results as the next
+// snapshot with a single atomic store.
+//
+// Only one GroupTxn can be open at a time; Txn blocks until the previous one
+// is committed or aborted. Reading snapshots never blocks. A Group is safe for
+// concurrent use.
+type Group struct {
+	// writer is held by the open GroupTxn, if there is one, and while
+	// adding members.
+	writer sync.Mutex
+
+	// cur holds the latest *GroupSnapshot.
+	cur atomic.Value
+}
+
+// NewGroup returns an empty Group. Trees are added to it with AddMember.
+func NewGroup() *Group {
+	g := &Group{}
+	g.cur.Store(&GroupSnapshot{changed: make(chan struct{})})
+	return g
+}
+
+// GroupSnapshot is a consistent view of every tree of a Group, as of one
+// commit. The trees are retrieved with Member.Tree.
+type GroupSnapshot struct {
+	trees      []any
+	generation uint64
+	changed    chan struct{}
+}
+
+// Generation returns the number of commits made to the group before this
+// snapshot was published, including additions of members.
+func (s *GroupSnapshot) Generation() uint64 {
+	return s.generation
+}
+
+// Changed returns a channel that is closed once a newer snapshot is published,
+// so a reader can watch the whole group with a single channel. It's closed
+// before the watch channels of any of the trees.
+func (s *GroupSnapshot) Changed() <-chan struct{} {
+	return s.changed
+}
+
+// Snapshot returns the latest snapshot of the group.
+func (g *Group) Snapshot() *GroupSnapshot {
+	return g.cur.Load().(*GroupSnapshot)
+}
+
+// publish makes the given trees the next snapshot of the group, which must be
+// called with the writer lock held.
+func (g *Group) publish(trees []any) *GroupSnapshot {
+	old := g.Snapshot()
+	s := &GroupSnapshot{
+		trees:      trees,
+		generation: old.generation + 1,
+		changed:    make(chan struct{}),
+	}
+	g.cur.Store(s)
+	close(old.changed)
+	return s
+}
+
+// Member is a typed handle on one of the trees of a Group.
+type Member[T any] struct {
+	g   *Group
+	idx int
+}
+
+// AddMember adds a tree to the group, publishing a new snapshot that includes
+// it, and returns its handle. This waits for any open GroupTxn to finish.
+func AddMember[T any](g *Group, t *Tree[T]) *Member[T] {
+	g.writer.Lock()
+	defer g.writer.Unlock()
+	old := g.Snapshot()
+	trees := append(old.trees[:len(old.trees):len(old.trees)], t)
+	g.publish(trees)
+	return &Member[T]{g: g, idx: len(trees) - 1}
+}
+
+// Tree returns the member's tree in the given snapshot of its group.
+func (m *Member[T]) Tree(s *GroupSnapshot) *Tree[T] {
+	return s.trees[m.idx].(*Tree[T])
+}
+
+// Txn returns the transaction that writes to the member's tree as part of the
+// given group transaction, starting it on first use. The transaction is
+// committed by GroupTxn.Commit, and must not be committed directly.
+func (m *Member[T]) Txn(gt *GroupTxn) *Txn[T] {
+	if m.g != gt.g {
+		panic("iradix: member used with a transaction of another group")
+	}
+	gt.checkOpen()
+	if e := gt.txns[m.idx]; e != nil {
+		return e.txn.(*Txn[T])
+	}
+	txn := m.Tree(gt.base).Txn()
+	txn.TrackMutate(gt.trackMutate)
+	gt.txns[m.idx] = &groupEntry{
+		txn:      txn,
+		commit:   func() any { return txn.CommitOnly() },
+		notify:   txn.Notify,
+		track:    txn.TrackMutate,
+		rollback: txn.Rollback,
+	}
+	return txn
+}
+
+// GroupTxn changes any number of the trees of a Group, and publishes them all
+// at once when it's committed.
+type GroupTxn struct {
+	g           *Group
+	base        *GroupSnapshot
+	txns        []*groupEntry
+	trackMutate bool
+	done        bool
+}
+
+// groupEntry holds the transaction of one member of a GroupTxn, with its
+// methods bound so the entries don't need to know the member's type.
+type groupEntry struct {
+	txn      any
+	commit   func() any
+	notify   func()
+	track    func(bool)
+	rollback func()
+}
+
+// Txn starts a transaction over the latest snapshot of the group, waiting for
+// the previous one to be committed or aborted. Every GroupTxn must be finished
+// with Commit or Abort, or the group can't be written to again.
+func (g *Group) Txn() *GroupTxn {
+	g.writer.Lock()
+	base := g.Snapshot()
+	return &GroupTxn{
+		g:    g,
+		base: base,
+		txns: make([]*groupEntry, len(base.trees)),
+	}
+}
+
+// TrackMutate enables mutation tracking for the transactions of every member,
+// so that Commit closes the watch channels of the changed parts of their
+// trees, as with Txn.TrackMutate.
+func (gt *GroupTxn) TrackMutate(track bool) {
+	gt.checkOpen()
+	gt.trackMutate = track
+	for _, e := range gt.txns {
+		if e != nil {
+			e.track(track)
+		}
+	}
+}
+
+// Commit commits the transactions of all the changed members, and publishes
+// their trees, along with the unchanged ones, as the next snapshot of the
+// group, which is returned. Readers see either none or all of the changes.
+// Once the snapshot is published, the snapshot's Changed channel and then, if
+// mutation tracking is enabled, the watch channels of the trees are closed.
+func (gt *GroupTxn) Commit() *GroupSnapshot {
+	gt.checkOpen()
+	gt.done = true
+	defer gt.g.writer.Unlock()
+
+	trees := append([]any{}, gt.base.trees...)
+	for i, e := range gt.txns {
+		if e != nil {
+			trees[i] = e.commit()
+		}
+	}
+	s := gt.g.publish(trees)
+	for _, e := range gt.txns {
+		if e != nil {
+			e.notify()
+		}
+	}
+	return s
+}
+
+// Abort discards the transaction without publishing anything, rolling back the
+// transactions of the members.
+func (gt *GroupTxn) Abort() {
+	gt.checkOpen()
+	gt.done = true
+	for _, e := range gt.txns {
+		if e != nil {
+			e.rollback()
+		}
+	}
+	gt.g.writer.Unlock()
+}
+
+// checkOpen panics if the transaction was already committed or aborted, since
+// the group's lock is no longer held.
+func (gt *GroupTxn) checkOpen() {
+	if gt.done {
+		panic("iradix: group transaction used after Commit or Abort")
+	}
+}
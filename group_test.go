@@ -0,0 +1,119 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	g := NewGroup()
+	data := AddMember(g, New[string]())
+	byValue := AddMember(g, New[[]byte]())
+
+	// A reader checks that every value it sees is indexed.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			s := g.Snapshot()
+			idx := byValue.Tree(s)
+			data.Tree(s).Root().Walk(func(k []byte, v string) bool {
+				if got, ok := idx.Get([]byte(v)); !ok || string(got) != string(k) {
+					t.Errorf("inconsistent snapshot %d at %q", s.Generation(), k)
+					return true
+				}
+				return false
+			})
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		before := g.Snapshot()
+		gt := g.Txn()
+		k, v := fmt.Sprintf("key/%d", i), fmt.Sprintf("val/%d", i)
+		data.Txn(gt).Insert([]byte(k), v)
+		byValue.Txn(gt).Insert([]byte(v), []byte(k))
+		s := gt.Commit()
+		if s != g.Snapshot() || s.Generation() != before.Generation()+1 {
+			t.Fatalf("bad snapshot")
+		}
+		select {
+		case <-before.Changed():
+		default:
+			t.Fatalf("expected change notification")
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if s := g.Snapshot(); data.Tree(s).Len() != 100 || byValue.Tree(s).Len() != 100 {
+		t.Fatalf("bad lens")
+	}
+}
+
+func TestGroupTxn_Notify(t *testing.T) {
+	g := NewGroup()
+	a := AddMember(g, New[int]())
+	b := AddMember(g, New[int]())
+
+	gt := g.Txn()
+	a.Txn(gt).Insert([]byte("x"), 1)
+	b.Txn(gt).Insert([]byte("y"), 1)
+	gt.Commit()
+
+	s := g.Snapshot()
+	watchA, _, _ := a.Tree(s).Root().GetWatch([]byte("x"))
+	watchB, _, _ := b.Tree(s).Root().GetWatch([]byte("y"))
+
+	// Aborting publishes nothing.
+	gt = g.Txn()
+	gt.TrackMutate(true)
+	a.Txn(gt).Delete([]byte("x"))
+	gt.Abort()
+	if g.Snapshot() != s || isClosedRecv(s.Changed()) {
+		t.Fatalf("abort published a snapshot")
+	}
+
+	// Only the changed member's watches fire.
+	gt = g.Txn()
+	gt.TrackMutate(true)
+	if a.Txn(gt) != a.Txn(gt) {
+		t.Fatalf("expected the same transaction")
+	}
+	a.Txn(gt).Insert([]byte("x"), 2)
+	gt.Commit()
+	if !isClosedRecv(watchA) || isClosedRecv(watchB) || !isClosedRecv(s.Changed()) {
+		t.Fatalf("bad notifications")
+	}
+	if v, _ := a.Tree(g.Snapshot()).Get([]byte("x")); v != 2 {
+		t.Fatalf("bad value: %d", v)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic")
+		}
+	}()
+	gt.Commit()
+}
+
+// isClosedRecv returns true if ch is closed.
+func isClosedRecv(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Handle pins a version of a tree for reading. Holding on to old versions of a
+// tree keeps all the nodes they don't share with newer versions alive, which
+// shows up as memory growth that's hard to track down. Handles make the
+// retention explicit: code that keeps a tree around takes a handle with Pin and
+// releases it once it's done, and with handle tracking turned on, the handles
+// that are still outstanding can be listed with where they were taken.
+type Handle[T any] struct {
+	tree  *Tree[T]
+	entry *handleEntry
+}
+
+// HandleInfo describes an outstanding handle.
+type HandleInfo struct {
+	// Generation is the generation of the pinned tree.
+	Generation uint64
+
+	// Pinned is when the handle was taken.
+	Pinned time.Time
+
+	// Stack is the stack trace of the goroutine that took the handle.
+	Stack string
+}
+
+// handleEntry is the record of a tracked handle.
+type handleEntry struct {
+	info HandleInfo
+}
+
+// handles holds the outstanding handles while tracking is on.
+var handles struct {
+	l       sync.Mutex
+	enabled bool
+	entries map[*handleEntry]struct{}
+}
+
+// TrackHandles turns handle tracking on or off. While it's on, every handle
+// taken with Pin records the stack trace of its caller until it's released,
+// which is costly, so this is meant for debugging. Turning tracking off
+// forgets the handles recorded so far.
+func TrackHandles(enabled bool) {
+	handles.l.Lock()
+	defer handles.l.Unlock()
+
+	handles.enabled = enabled
+	if enabled && handles.entries == nil {
+		handles.entries = make(map[*handleEntry]struct{})
+	} else if !enabled {
+		handles.entries = nil
+	}
+}
+
+// OutstandingHandles returns the tracked handles that haven't been released,
+// with the oldest generations first.
+func OutstandingHandles() []HandleInfo {
+	handles.l.Lock()
+	out := make([]HandleInfo, 0, len(handles.entries))
+	for e := range handles.entries {
+		out = append(out, e.info)
+	}
+	handles.l.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Generation != out[j].Generation {
+			return out[i].Generation < out[j].Generation
+		}
+		return out[i].Pinned.Before(out[j].Pinned)
+	})
+	return out
+}
+
+// Pin returns a handle on the tree, which must be released once the caller is
+// done reading it.
+func (t *Tree[T]) Pin() *Handle[T] {
+	h := &Handle[T]{tree: t}
+
+	handles.l.Lock()
+	defer handles.l.Unlock()
+
+	if handles.enabled {
+		buf := make([]byte, 4096)
+		buf = buf[:runtime.Stack(buf, false)]
+		h.entry = &handleEntry{info: HandleInfo{
+			Generation: t.generation,
+			Pinned:     time.Now(),
+			Stack:      string(buf),
+		}}
+		handles.entries[h.entry] = struct{}{}
+	}
+	return h
+}
+
+// Tree returns the pinned tree, or nil if the handle has been released.
+func (h *Handle[T]) Tree() *Tree[T] {
+	return h.tree
+}
+
+// Release releases the handle, so it stops retaining the tree. Releasing a
+// handle more than once has no effect.
+func (h *Handle[T]) Release() {
+	h.tree = nil
+	if h.entry == nil {
+		return
+	}
+
+	handles.l.Lock()
+	defer handles.l.Unlock()
+
+	if handles.entries != nil {
+		delete(handles.entries, h.entry)
+	}
+	h.entry = nil
+}
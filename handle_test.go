@@ -0,0 +1,54 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandle(t *testing.T) {
+	r := New[int]()
+
+	// Handles work without tracking, but aren't recorded.
+	h := r.Pin()
+	if h.Tree() != r {
+		t.Fatalf("bad tree")
+	}
+	h.Release()
+	if h.Tree() != nil || len(OutstandingHandles()) != 0 {
+		t.Fatalf("bad release")
+	}
+
+	TrackHandles(true)
+	defer TrackHandles(false)
+
+	old := r.Pin()
+	r, _, _ = r.Insert([]byte("foo"), 1)
+	r, _, _ = r.Insert([]byte("bar"), 2)
+	cur := r.Pin()
+	outstanding := OutstandingHandles()
+	if len(outstanding) != 2 {
+		t.Fatalf("bad: %v", outstanding)
+	}
+	if outstanding[0].Generation != 0 || outstanding[1].Generation != 2 {
+		t.Fatalf("bad generations: %v", outstanding)
+	}
+	if !strings.Contains(outstanding[0].Stack, "TestHandle") {
+		t.Fatalf("bad stack: %s", outstanding[0].Stack)
+	}
+
+	old.Release()
+	old.Release()
+	if outstanding = OutstandingHandles(); len(outstanding) != 1 || outstanding[0].Generation != 2 {
+		t.Fatalf("bad: %v", outstanding)
+	}
+
+	// Turning tracking off forgets the handles.
+	TrackHandles(false)
+	if len(OutstandingHandles()) != 0 {
+		t.Fatalf("expected no handles")
+	}
+	cur.Release()
+}
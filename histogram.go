@@ -0,0 +1,90 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+// SegmentStats describes the keys of a tree that share a leading segment.
+type SegmentStats struct {
+	// Segment is the shared leading part of the keys, up to and including
+	// the last separator, or a whole key if it has fewer separators than the
+	// requested depth.
+	Segment []byte
+
+	// Count is the number of keys in the segment.
+	Count int
+
+	// Bytes is the total size of the keys in the segment, plus the sizes of
+	// their values if the tree has a memory budget, as in Tree.Bytes.
+	Bytes int
+}
+
+// SegmentHistogram groups the keys of the tree by their leading segments, up to
+// and including the depth'th occurrence of sep, and returns the statistics of
+// every group in key order. For example, with sep '/' and depth 1 the keys
+// "a/1", "a/2" and "b" are grouped as "a/" and "b". This can be used to find
+// which namespaces dominate a tree shared by many users.
+//
+// Counts are taken from the subtree counts maintained by the tree, but sizes
+// need a walk of every segment's keys.
+func (t *Tree[T]) SegmentHistogram(sep byte, depth int) []SegmentStats {
+	if t.conf.collate != nil {
+		sep = t.conf.collate[sep]
+	}
+	var out []SegmentStats
+	segmentHistogram(t.root, sep, depth, 0, t.conf.sizer, &out)
+	return out
+}
+
+// segmentHistogram appends the stats of the segments under n, whose path
+// contains seen separators, to out.
+func segmentHistogram[T any](n *Node[T], sep byte, depth, seen int, sizer func(T) int, out *[]SegmentStats) {
+	// Find out if the segment ends within this node's prefix. The segment
+	// key is taken from a leaf, since paths may be collated.
+	end := -1
+	if seen >= depth {
+		end = 0
+	}
+	for i := 0; end < 0 && i < len(n.prefix); i++ {
+		if n.prefix[i] == sep {
+			if seen++; seen == depth {
+				end = i + 1
+			}
+		}
+	}
+	if end >= 0 {
+		leaf, path := minLeaf(n, []byte{})
+		if leaf == nil {
+			return
+		}
+		stats := SegmentStats{
+			Segment: leaf.key[:len(leaf.key)-len(path)-len(n.prefix)+end],
+			Count:   n.count(),
+		}
+		recursiveWalk(n, func(k []byte, v T) bool {
+			stats.Bytes += len(k)
+			if sizer != nil {
+				stats.Bytes += sizer(v)
+			}
+			return false
+		})
+		*out = append(*out, stats)
+		return
+	}
+
+	// The segment doesn't end at this node, so a leaf here gets one of its
+	// own.
+	if n.leaf != nil {
+		stats := SegmentStats{
+			Segment: n.leaf.key,
+			Count:   1,
+			Bytes:   len(n.leaf.key),
+		}
+		if sizer != nil {
+			stats.Bytes += sizer(n.leaf.val)
+		}
+		*out = append(*out, stats)
+	}
+	for _, e := range n.edges() {
+		segmentHistogram(e.node, sep, depth, seen, sizer, out)
+	}
+}
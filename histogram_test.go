@@ -0,0 +1,92 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSegmentHistogram(t *testing.T) {
+	r := New[string](WithMemoryBudget(1<<20, func(v string) int { return len(v) }))
+	txn := r.Txn()
+	for _, k := range []string{"a", "a/1", "a/2/x", "a/2/y", "ab/1", "b/", "b/c", "c"} {
+		txn.Insert([]byte(k), "v")
+	}
+	r = txn.Commit()
+
+	type stat struct {
+		Segment      string
+		Count, Bytes int
+	}
+	histogram := func(sep byte, depth int) []stat {
+		var out []stat
+		for _, s := range r.SegmentHistogram(sep, depth) {
+			out = append(out, stat{string(s.Segment), s.Count, s.Bytes})
+		}
+		return out
+	}
+
+	cases := []struct {
+		depth  int
+		expect []stat
+	}{
+		{0, []stat{{"", 8, 32}}},
+		{1, []stat{{"a", 1, 2}, {"a/", 3, 16}, {"ab/", 1, 5}, {"b/", 2, 7}, {"c", 1, 2}}},
+		{2, []stat{{"a", 1, 2}, {"a/1", 1, 4}, {"a/2/", 2, 12}, {"ab/1", 1, 5}, {"b/", 1, 3}, {"b/c", 1, 4}, {"c", 1, 2}}},
+	}
+	for _, c := range cases {
+		if got := histogram('/', c.depth); !reflect.DeepEqual(got, c.expect) {
+			t.Fatalf("depth %d: got %v, want %v", c.depth, got, c.expect)
+		}
+	}
+
+	if got := New[int]().SegmentHistogram('/', 1); got != nil {
+		t.Fatalf("bad: %v", got)
+	}
+}
+
+func TestSegmentHistogram_Random(t *testing.T) {
+	r := New[int]()
+	for i := 0; i < 2000; i++ {
+		k := make([]byte, 1+rand.Intn(8))
+		for j := range k {
+			k[j] = "ab/"[rand.Intn(3)]
+		}
+		r, _, _ = r.Insert(k, i)
+	}
+
+	for depth := 0; depth < 4; depth++ {
+		// Group keys by brute force, relying on the walk being in key order.
+		var expect []SegmentStats
+		r.Root().Walk(func(k []byte, _ int) bool {
+			seg, seen := k, 0
+			for i, b := range k {
+				if seen >= depth {
+					seg = k[:i]
+					break
+				}
+				if b == '/' {
+					seen++
+					seg = k[:i+1]
+				}
+			}
+			if seen < depth {
+				seg = k
+			}
+			if n := len(expect); n > 0 && bytes.Equal(expect[n-1].Segment, seg) {
+				expect[n-1].Count++
+				expect[n-1].Bytes += len(k)
+			} else {
+				expect = append(expect, SegmentStats{seg, 1, len(k)})
+			}
+			return false
+		})
+		if got := r.SegmentHistogram('/', depth); !reflect.DeepEqual(got, expect) {
+			t.Fatalf("depth %d: got %v, want %v", depth, got, expect)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import "bytes"
+
+// IndexedTree is a tree along with a secondary index that orders its entries
+// by a sort key taken from their values, such as a timestamp or a priority.
+// Both are updated together by an IndexedTxn, so the index is always in step
+// with the tree, and can be iterated over with ByValue and ReverseByValue.
+//
+// Entries are ordered in the index by their sort key and then their key. The
+// sort keys should have a fixed length, or otherwise be prefix free, like the
+// encodings of TimeKey and binary.BigEndian, so that a sort key followed by a
+// key can't be mistaken for another.
+type IndexedTree[T any] struct {
+	companion[T]
+	sortKey func(T) []byte
+}
+
+// NewIndexedTree returns an empty indexed tree, with values ordered in the
+// index by the keys returned by sortKey. The options apply to the tree.
+func NewIndexedTree[T any](sortKey func(T) []byte, opts ...Option) *IndexedTree[T] {
+	return &IndexedTree[T]{
+		companion: newCompanion[T](opts),
+		sortKey:   sortKey,
+	}
+}
+
+// ByValue returns an iterator over the entries in ascending order of their
+// sort keys.
+func (t *IndexedTree[T]) ByValue() *IndexIterator[T] {
+	return &IndexIterator[T]{it: t.index.Root().Iterator()}
+}
+
+// ReverseByValue returns an iterator over the entries in descending order of
+// their sort keys.
+func (t *IndexedTree[T]) ReverseByValue() *ReverseIndexIterator[T] {
+	return &ReverseIndexIterator[T]{it: t.index.Root().ReverseIterator()}
+}
+
+// Txn starts a new transaction that updates the tree and its index.
+func (t *IndexedTree[T]) Txn() *IndexedTxn[T] {
+	return &IndexedTxn[T]{
+		companionTxn: t.txn(),
+		sortKey:      t.sortKey,
+	}
+}
+
+// Insert is used to add or update a given key in a copy of the tree, as with
+// IndexedTxn.Insert.
+func (t *IndexedTree[T]) Insert(k []byte, v T) (*IndexedTree[T], T, bool) {
+	txn := t.Txn()
+	old, ok := txn.Insert(k, v)
+	return txn.Commit(), old, ok
+}
+
+// Delete is used to delete a given key from a copy of the tree, as with
+// IndexedTxn.Delete.
+func (t *IndexedTree[T]) Delete(k []byte) (*IndexedTree[T], T, bool) {
+	txn := t.Txn()
+	old, ok := txn.Delete(k)
+	return txn.Commit(), old, ok
+}
+
+// IndexedTxn is a transaction on an IndexedTree.
+type IndexedTxn[T any] struct {
+	companionTxn[T]
+	sortKey func(T) []byte
+}
+
+// Insert is used to add or update a given key, and moves it to its place in
+// the index. The return provides the previous value and a bool indicating if
+// any was set.
+func (t *IndexedTxn[T]) Insert(k []byte, v T) (T, bool) {
+	k, old, ok, applied := t.insert(k, v)
+	if !applied {
+		return old, ok
+	}
+	if ok {
+		t.index.Delete(indexKey(t.sortKey(old), k))
+	}
+	t.index.Insert(indexKey(t.sortKey(v), k), indexEntry[T]{key: k, val: v})
+	return old, ok
+}
+
+// Delete is used to delete a given key, along with its index entry. Returns
+// the old value if any, and a bool indicating if the key was set.
+func (t *IndexedTxn[T]) Delete(k []byte) (T, bool) {
+	k, old, ok := t.delete(k)
+	if ok {
+		t.index.Delete(indexKey(t.sortKey(old), k))
+	}
+	return old, ok
+}
+
+// Commit is used to finalize the transaction and return the new tree along
+// with its index.
+func (t *IndexedTxn[T]) Commit() *IndexedTree[T] {
+	return &IndexedTree[T]{
+		companion: t.commit(),
+		sortKey:   t.sortKey,
+	}
+}
+
+// indexKey returns the key of an entry in the index.
+func indexKey(sortKey, k []byte) []byte {
+	ik := make([]byte, 0, len(sortKey)+len(k))
+	return append(append(ik, sortKey...), k...)
+}
+
+// IndexIterator iterates over the entries of an IndexedTree in ascending order
+// of their sort keys.
+type IndexIterator[T any] struct {
+	it *Iterator[indexEntry[T]]
+}
+
+// SeekLowerBound seeks the iterator to the first entry whose sort key is
+// greater than or equal to the given one.
+func (i *IndexIterator[T]) SeekLowerBound(sortKey []byte) {
+	i.it.SeekLowerBound(sortKey)
+}
+
+// SeekPrefix seeks the iterator to the entries whose sort key starts with the
+// given prefix.
+func (i *IndexIterator[T]) SeekPrefix(prefix []byte) {
+	i.it.SeekPrefix(prefix)
+}
+
+// Next returns the key and value of the next entry.
+func (i *IndexIterator[T]) Next() ([]byte, T, bool) {
+	_, e, ok := i.it.Next()
+	return e.key, e.val, ok
+}
+
+// ReverseIndexIterator iterates over the entries of an IndexedTree in
+// descending order of their sort keys.
+type ReverseIndexIterator[T any] struct {
+	it *ReverseIterator[indexEntry[T]]
+
+	// skip is an index key to skip if it's the first one returned.
+	skip []byte
+}
+
+// SeekReverseLowerBound seeks the iterator to the entries whose sort key is
+// less than or equal to the given one.
+func (i *ReverseIndexIterator[T]) SeekReverseLowerBound(sortKey []byte) {
+	// Seek to the smallest key after all those starting with the sort key,
+	// which is skipped if it's there.
+	i.skip = nil
+	for n := len(sortKey); n > 0; n-- {
+		if sortKey[n-1] != 0xff {
+			i.skip = append(append([]byte{}, sortKey[:n-1]...), sortKey[n-1]+1)
+			break
+		}
+	}
+	if i.skip != nil {
+		i.it.SeekReverseLowerBound(i.skip)
+	}
+}
+
+// Previous returns the key and value of the previous entry.
+func (i *ReverseIndexIterator[T]) Previous() ([]byte, T, bool) {
+	ik, e, ok := i.it.Previous()
+	if i.skip != nil {
+		if ok && bytes.Equal(ik, i.skip) {
+			ik, e, ok = i.it.Previous()
+		}
+		i.skip = nil
+	}
+	return e.key, e.val, ok
+}
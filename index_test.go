@@ -0,0 +1,115 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestIndexedTree(t *testing.T) {
+	type job struct {
+		pri  uint16
+		name string
+	}
+	pri := func(p uint16) []byte {
+		b := make([]byte, 2)
+		binary.BigEndian.PutUint16(b, p)
+		return b
+	}
+	sortKey := func(j job) []byte {
+		return pri(j.pri)
+	}
+
+	r := NewIndexedTree(sortKey)
+	txn := r.Txn()
+	txn.Insert([]byte("a"), job{3, "a"})
+	txn.Insert([]byte("b"), job{1, "b"})
+	txn.Insert([]byte("c"), job{2, "c"})
+	txn.Insert([]byte("d"), job{2, "d"})
+	txn.Insert([]byte("e"), job{0xffff, "e"})
+	txn.Delete([]byte("missing"))
+	r = txn.Commit()
+
+	// Updates move entries in the index, and deletes remove them.
+	r2, old, ok := r.Insert([]byte("b"), job{4, "b"})
+	if !ok || old.pri != 1 {
+		t.Fatalf("bad: %v %v", old, ok)
+	}
+	r2, _, _ = r2.Delete([]byte("d"))
+
+	forward := func(r *IndexedTree[job], seek []byte) []string {
+		var out []string
+		it := r.ByValue()
+		if seek != nil {
+			it.SeekLowerBound(seek)
+		}
+		for k, _, ok := it.Next(); ok; k, _, ok = it.Next() {
+			out = append(out, string(k))
+		}
+		return out
+	}
+	reverse := func(r *IndexedTree[job], seek []byte) []string {
+		var out []string
+		it := r.ReverseByValue()
+		if seek != nil {
+			it.SeekReverseLowerBound(seek)
+		}
+		for k, _, ok := it.Previous(); ok; k, _, ok = it.Previous() {
+			out = append(out, string(k))
+		}
+		return out
+	}
+
+	cases := []struct {
+		got, want []string
+	}{
+		{forward(r, nil), []string{"b", "c", "d", "a", "e"}},
+		{forward(r, pri(2)), []string{"c", "d", "a", "e"}},
+		{forward(r2, nil), []string{"c", "a", "b", "e"}},
+		{reverse(r, nil), []string{"e", "a", "d", "c", "b"}},
+		{reverse(r, pri(2)), []string{"d", "c", "b"}},
+		{reverse(r, []byte{0xff, 0xff}), []string{"e", "a", "d", "c", "b"}},
+		{reverse(r2, pri(3)), []string{"a", "c"}},
+		{reverse(r, pri(0)), nil},
+	}
+	for i, c := range cases {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Fatalf("%d: got %v, want %v", i, c.got, c.want)
+		}
+	}
+	if r.Len() != 5 || r2.Len() != 4 || r2.index.Len() != 4 {
+		t.Fatalf("bad lens")
+	}
+	if v, ok := r2.Get([]byte("b")); !ok || v.pri != 4 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+
+	// Rejected inserts leave the index alone.
+	r = NewIndexedTree(sortKey, WithMemoryBudget(2, func(job) int { return 0 }))
+	txn = r.Txn()
+	txn.Insert([]byte("a"), job{1, "a"})
+	txn.Insert([]byte("bcd"), job{2, "bcd"})
+	if txn.Txn().Err() != ErrBudgetExceeded {
+		t.Fatalf("bad: %v", txn.Txn().Err())
+	}
+	if r = txn.Commit(); !reflect.DeepEqual(forward(r, nil), []string{"a"}) {
+		t.Fatalf("bad: %v", forward(r, nil))
+	}
+
+	// The index holds keys in the form they're stored in the tree.
+	r = NewIndexedTree(sortKey, WithKeyFold())
+	txn = r.Txn()
+	txn.Insert([]byte("A"), job{2, "A"})
+	txn.Insert([]byte("a"), job{1, "a"})
+	txn.Insert([]byte("B"), job{3, "B"})
+	r = txn.Commit()
+	if got := forward(r, nil); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("bad: %v", got)
+	}
+	if r, _, _ = r.Delete([]byte("b")); !reflect.DeepEqual(forward(r, nil), []string{"a"}) || r.index.Len() != 1 {
+		t.Fatalf("bad: %v", forward(r, nil))
+	}
+}
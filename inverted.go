@@ -0,0 +1,124 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+// TokenIndexedTree is a tree along with an inverted index from tokens taken
+// from its values back to their keys, such as the words in a description or
+// the tags of a service. Both are updated together by a TokenIndexedTxn, so
+// Lookup can find the entries whose value has a token without walking the
+// tree.
+type TokenIndexedTree[T any] struct {
+	companion[T]
+	tokenize func(T) [][]byte
+}
+
+// NewTokenIndexedTree returns an empty tree indexed by the tokens returned by
+// tokenize for each value. A value may have any number of tokens, including
+// repeated ones. The options apply to the tree.
+func NewTokenIndexedTree[T any](tokenize func(T) [][]byte, opts ...Option) *TokenIndexedTree[T] {
+	return &TokenIndexedTree[T]{
+		companion: newCompanion[T](opts),
+		tokenize:  tokenize,
+	}
+}
+
+// Lookup returns an iterator over the entries whose value has the given
+// token, in key order.
+func (t *TokenIndexedTree[T]) Lookup(token []byte) *TokenIterator[T] {
+	it := t.index.Root().Iterator()
+	it.SeekPrefix(tokenKey(token, nil))
+	return &TokenIterator[T]{it: it}
+}
+
+// Txn starts a new transaction that updates the tree and its index.
+func (t *TokenIndexedTree[T]) Txn() *TokenIndexedTxn[T] {
+	return &TokenIndexedTxn[T]{
+		companionTxn: t.txn(),
+		tokenize:     t.tokenize,
+	}
+}
+
+// Insert is used to add or update a given key in a copy of the tree, as with
+// TokenIndexedTxn.Insert.
+func (t *TokenIndexedTree[T]) Insert(k []byte, v T) (*TokenIndexedTree[T], T, bool) {
+	txn := t.Txn()
+	old, ok := txn.Insert(k, v)
+	return txn.Commit(), old, ok
+}
+
+// Delete is used to delete a given key from a copy of the tree, as with
+// TokenIndexedTxn.Delete.
+func (t *TokenIndexedTree[T]) Delete(k []byte) (*TokenIndexedTree[T], T, bool) {
+	txn := t.Txn()
+	old, ok := txn.Delete(k)
+	return txn.Commit(), old, ok
+}
+
+// TokenIndexedTxn is a transaction on a TokenIndexedTree.
+type TokenIndexedTxn[T any] struct {
+	companionTxn[T]
+	tokenize func(T) [][]byte
+}
+
+// Insert is used to add or update a given key, and replaces the index entries
+// for its old value's tokens with ones for the new value's. The return
+// provides the previous value and a bool indicating if any was set.
+func (t *TokenIndexedTxn[T]) Insert(k []byte, v T) (T, bool) {
+	k, old, ok, applied := t.insert(k, v)
+	if !applied {
+		return old, ok
+	}
+	if ok {
+		t.unindex(k, old)
+	}
+	for _, token := range t.tokenize(v) {
+		t.index.Insert(tokenKey(token, k), indexEntry[T]{key: k, val: v})
+	}
+	return old, ok
+}
+
+// Delete is used to delete a given key, along with its index entries. Returns
+// the old value if any, and a bool indicating if the key was set.
+func (t *TokenIndexedTxn[T]) Delete(k []byte) (T, bool) {
+	k, old, ok := t.delete(k)
+	if ok {
+		t.unindex(k, old)
+	}
+	return old, ok
+}
+
+// unindex removes the index entries of a key with the given value, where the
+// key is in the form it's stored in the tree.
+func (t *TokenIndexedTxn[T]) unindex(k []byte, v T) {
+	for _, token := range t.tokenize(v) {
+		t.index.Delete(tokenKey(token, k))
+	}
+}
+
+// Commit is used to finalize the transaction and return the new tree along
+// with its index.
+func (t *TokenIndexedTxn[T]) Commit() *TokenIndexedTree[T] {
+	return &TokenIndexedTree[T]{
+		companion: t.commit(),
+		tokenize:  t.tokenize,
+	}
+}
+
+// tokenKey returns the key of an entry in the inverted index. The token is
+// prefixed with its length so that it can't run into the key.
+func tokenKey(token, k []byte) []byte {
+	ik := appendUvarint(make([]byte, 0, 2+len(token)+len(k)), uint64(len(token)))
+	return append(append(ik, token...), k...)
+}
+
+// TokenIterator iterates over the entries found by TokenIndexedTree.Lookup.
+type TokenIterator[T any] struct {
+	it *Iterator[indexEntry[T]]
+}
+
+// Next returns the key and value of the next entry.
+func (i *TokenIterator[T]) Next() ([]byte, T, bool) {
+	_, e, ok := i.it.Next()
+	return e.key, e.val, ok
+}
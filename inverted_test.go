@@ -0,0 +1,77 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenIndexedTree(t *testing.T) {
+	words := func(v string) [][]byte {
+		var out [][]byte
+		for _, w := range strings.Fields(v) {
+			out = append(out, []byte(w))
+		}
+		return out
+	}
+	lookup := func(r *TokenIndexedTree[string], token string) []string {
+		var out []string
+		it := r.Lookup([]byte(token))
+		for k, v, ok := it.Next(); ok; k, v, ok = it.Next() {
+			out = append(out, string(k)+"="+v)
+		}
+		return out
+	}
+
+	r := NewTokenIndexedTree(words)
+	txn := r.Txn()
+	txn.Insert([]byte("web"), "http tls tls")
+	txn.Insert([]byte("db"), "sql tls")
+	txn.Insert([]byte("cache"), "http")
+	txn.Insert([]byte("h"), "ttp")
+	r = txn.Commit()
+
+	r2, _, _ := r.Insert([]byte("web"), "grpc")
+	r2, _, _ = r2.Delete([]byte("db"))
+
+	cases := []struct {
+		got, want []string
+	}{
+		{lookup(r, "tls"), []string{"db=sql tls", "web=http tls tls"}},
+		{lookup(r, "http"), []string{"cache=http", "web=http tls tls"}},
+		{lookup(r, "ttp"), []string{"h=ttp"}},
+		{lookup(r, "h"), nil},
+		{lookup(r, "grpc"), nil},
+		{lookup(r2, "tls"), nil},
+		{lookup(r2, "http"), []string{"cache=http"}},
+		{lookup(r2, "grpc"), []string{"web=grpc"}},
+	}
+	for i, c := range cases {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Fatalf("%d: got %v, want %v", i, c.got, c.want)
+		}
+	}
+	if r.Len() != 4 || r2.Len() != 3 || r2.index.Len() != 3 {
+		t.Fatalf("bad lens %d %d %d", r.Len(), r2.Len(), r2.index.Len())
+	}
+
+	// The index holds keys in the form they're stored in the tree.
+	r = NewTokenIndexedTree(words, WithKeyFold())
+	txn = r.Txn()
+	txn.Insert([]byte("Web"), "http")
+	txn.Insert([]byte("WEB"), "grpc tls")
+	txn.Insert([]byte("DB"), "tls")
+	r = txn.Commit()
+	if got := lookup(r, "tls"); !reflect.DeepEqual(got, []string{"db=tls", "web=grpc tls"}) {
+		t.Fatalf("bad: %v", got)
+	}
+	if got := lookup(r, "http"); got != nil {
+		t.Fatalf("bad: %v", got)
+	}
+	if r, _, _ = r.Delete([]byte("Db")); !reflect.DeepEqual(lookup(r, "tls"), []string{"web=grpc tls"}) || r.index.Len() != 2 {
+		t.Fatalf("bad: %v", lookup(r, "tls"))
+	}
+}
@@ -5,7 +5,9 @@ package iradix
 
 import (
 	"bytes"
+	"context"
 	"strings"
+	"sync/atomic"
 
 	"github.com/hashicorp/golang-lru/v2/simplelru"
 )
@@ -19,6 +21,11 @@ const (
 	// to set the max size of the mutation notify maps since those should
 	// also be bounded in a similar way.
 	defaultModifiedCache = 8192
+
+	// trackSmallSize is the number of mutation channels a transaction can
+	// track before it needs to allocate a map for them. This covers writes
+	// to a single key in all but very deep trees.
+	trackSmallSize = 16
 )
 
 // Tree implements an immutable radix tree. This can be treated as a
@@ -29,23 +36,136 @@ const (
 type Tree[T any] struct {
 	root *Node[T]
 	size int
+
+	// bytes is the approximate size of the keys and values in the tree,
+	// only tracked if a memory budget is set.
+	bytes int
+
+	// hash is the content hash of the tree, only tracked if a hash function
+	// is set.
+	hash uint64
+
+	// generation is the commit sequence number of this tree. It starts at
+	// zero for a new tree and is incremented each time a transaction based
+	// on this tree is committed.
+	generation uint64
+
+	// version identifies this tree among all the trees created by the
+	// process, as returned by Version.
+	version uint64
+
+	// conf is the configuration given to New, shared by all derived trees.
+	conf *config[T]
+
+	// arena is the arena nodes are allocated from, if the tree was obtained
+	// from a TreePool.
+	arena *arena[T]
 }
 
-// New returns an empty Tree
-func New[T any]() *Tree[T] {
+// New returns an empty Tree, configured with the given options.
+func New[T any](opts ...Option) *Tree[T] {
 	t := &Tree[T]{
 		root: &Node[T]{
 			mutateCh: make(chan struct{}),
 		},
+		conf:    newConfig[T](opts),
+		version: nextVersion(),
 	}
 	return t
 }
 
+// versions is the last version given to a tree.
+var versions uint64
+
+// nextVersion returns the version for a new tree.
+func nextVersion() uint64 {
+	return atomic.AddUint64(&versions, 1)
+}
+
+// emptyBase returns an empty tree with the configuration of t, or the default
+// one if t is a zero Tree, to rebuild the contents of t from. Its generation is
+// that of t, so the rebuilt tree follows on from it.
+func (t *Tree[T]) emptyBase() *Tree[T] {
+	conf := t.conf
+	if conf == nil {
+		conf = newConfig[T](nil)
+	}
+	return &Tree[T]{
+		root:       &Node[T]{mutateCh: make(chan struct{})},
+		generation: t.generation,
+		version:    nextVersion(),
+		conf:       conf,
+	}
+}
+
 // Len is used to return the number of elements in the tree
 func (t *Tree[T]) Len() int {
 	return t.size
 }
 
+// LenPrefix returns the number of keys in the tree with the given prefix, in
+// time proportional to the depth of the prefix rather than to the number of
+// keys.
+func (t *Tree[T]) LenPrefix(prefix []byte) int {
+	return t.root.CountPrefix(t.conf.path(prefix))
+}
+
+// ListPrefix lists the keys with the given prefix like a directory, returning
+// the keys without the delimiter after the prefix and the common prefixes of
+// the others, in the order of the tree. See Node.ListPrefix.
+func (t *Tree[T]) ListPrefix(prefix []byte, delimiter byte) (entries []KV[T], commonPrefixes [][]byte) {
+	if t.conf.collate != nil {
+		delimiter = t.conf.collate[delimiter]
+	}
+	return t.root.ListPrefix(t.conf.path(prefix), delimiter)
+}
+
+// WalkGlob walks the keys in the tree that match the given pattern, in order.
+// See Node.WalkGlob.
+func (t *Tree[T]) WalkGlob(pattern []byte, fn WalkFn[T]) {
+	if t.conf.fold {
+		pattern = foldKey(pattern)
+	}
+	walkGlob(t.root, t.conf.path(globPrefix(pattern)), pattern, fn)
+}
+
+// Select returns the idx-th smallest key in the tree, counting from zero, with
+// its value, or false if idx is out of range. See Node.Select.
+func (t *Tree[T]) Select(idx int) ([]byte, T, bool) {
+	return t.root.Select(idx)
+}
+
+// Rank returns the number of keys in the tree that are smaller than k, and
+// whether k itself is present. See Node.Rank.
+func (t *Tree[T]) Rank(k []byte) (int, bool) {
+	return t.root.Rank(t.conf.path(k))
+}
+
+// Bytes returns the approximate number of bytes retained by the keys and values
+// in the tree, as counted for WithMemoryBudget. This is always zero if no budget
+// is set.
+func (t *Tree[T]) Bytes() int {
+	return t.bytes
+}
+
+// Generation returns the commit sequence number of the tree. A new tree has
+// generation zero, and every tree returned by committing a transaction has a
+// generation one greater than the tree the transaction was started from. Copies
+// of a tree, such as from DeepCopy or Filter, keep its generation. This can be
+// used to order and deduplicate roots derived from a common ancestor.
+func (t *Tree[T]) Generation() uint64 {
+	return t.generation
+}
+
+// Version returns a number that identifies the tree among all the trees
+// created by the process. Versions increase monotonically, so a tree committed
+// after another has a greater version, even if both were started from the
+// same tree and so have the same generation. It's used by
+// Txn.CommitIfUnchanged to detect conflicting commits.
+func (t *Tree[T]) Version() uint64 {
+	return t.version
+}
+
 // Txn is a transaction on the tree. This transaction is applied
 // atomically and returns a new tree when committed. A transaction
 // is not thread safe, and should only be used by a single goroutine.
@@ -61,11 +181,40 @@ type Txn[T any] struct {
 	// transaction.
 	size int
 
+	// bytes tracks the approximate size of the keys and values in the tree
+	// as it is modified during the transaction, if a budget is set.
+	bytes int
+
+	// hash tracks the content hash of the tree as it is modified during the
+	// transaction, if a hash function is set.
+	hash uint64
+
+	// generation is the generation of the tree this transaction was started
+	// from. The committed tree will be stamped with the next generation.
+	generation uint64
+
+	// conf is the configuration of the tree this transaction was started
+	// from.
+	conf *config[T]
+
+	// base is the tree this transaction was started from, and published is
+	// the tree it committed, until the OnAfterPublish hook has been called.
+	// result is the tree returned by the first commit, which is returned
+	// again by any later commit that the misuse policy rejects.
+	base      *Tree[T]
+	published *Tree[T]
+	result    *Tree[T]
+
+	// arena is the arena of the tree this transaction was started from, if
+	// any.
+	arena *arena[T]
+
 	// writable is a cache of writable nodes that have been created during
 	// the course of the transaction. This allows us to re-use the same
 	// nodes for further writes and avoid unnecessary copies of nodes that
 	// have never been exposed outside the transaction. This will only hold
-	// up to defaultModifiedCache number of entries.
+	// up to defaultModifiedCache number of entries, unless another size was
+	// set with WithWritableCacheSize.
 	writable *simplelru.LRU[*Node[T], any]
 
 	// trackChannels is used to hold channels that need to be notified to
@@ -74,21 +223,118 @@ type Txn[T any] struct {
 	// trackOverflow flag, which will cause us to use a more expensive
 	// algorithm to perform the notifications. Mutation tracking is only
 	// performed if trackMutate is true.
+	//
+	// The first trackSmallSize channels are held in trackSmall, which
+	// avoids allocating the map for transactions that only touch a few
+	// nodes. They are moved into the map once it's full.
 	trackChannels map[chan struct{}]struct{}
+	trackSmall    [trackSmallSize]chan struct{}
+	trackNumSmall int
 	trackOverflow bool
 	trackMutate   bool
+
+	// trackPrefix is the path set by TrackMutatePrefix, if any, in which
+	// case no channels are tracked and the notifications are found by
+	// comparing the subtrees under it instead.
+	trackPrefix []byte
+
+	// mutateFn is the function set by TrackMutateFunc, and mutateIter the
+	// iterator over the changed keys it's being called with, if a
+	// notification is under way.
+	mutateFn   func(key []byte)
+	mutateIter *ChangedIterator[T]
+
+	// notifyResumed is set if a notification after the tracking state
+	// overflowed was stopped by NotifyCtx before it was done.
+	notifyResumed bool
+
+	// changes holds the changes made by the transaction, in order, if
+	// trackChanges is true.
+	changes      []Change[T]
+	trackChanges bool
+
+	// walkStack is reused by trackChannelsAndCount to walk subtrees without
+	// recursion.
+	walkStack []*Node[T]
+
+	// committed is set once the transaction has been committed, so that
+	// further use can be handled according to the misuse policy.
+	committed bool
+
+	// rolledBack is set once the transaction has been rolled back, after
+	// which it can't be used at all.
+	rolledBack bool
+
+	// err holds the first error recorded by the transaction, either misuse
+	// under the MisuseReturnError policy or ErrBudgetExceeded.
+	err error
+
+	// reused is set once the transaction has been Reset, after which the
+	// cache of writable nodes and the map of tracked channels are emptied
+	// and kept in spareWritable and spareTrack when it's done with them,
+	// so the next use doesn't have to allocate them again.
+	reused        bool
+	spareWritable *simplelru.LRU[*Node[T], any]
+	spareTrack    map[chan struct{}]struct{}
 }
 
 // Txn starts a new transaction that can be used to mutate the tree
 func (t *Tree[T]) Txn() *Txn[T] {
-	txn := &Txn[T]{
-		root: t.root,
-		snap: t.root,
-		size: t.size,
-	}
+	txn := &Txn[T]{}
+	txn.bind(t)
 	return txn
 }
 
+// bind starts the transaction on the given tree.
+func (t *Txn[T]) bind(tree *Tree[T]) {
+	t.root = tree.root
+	t.snap = tree.root
+	t.size = tree.size
+	t.bytes = tree.bytes
+	t.hash = tree.hash
+	t.generation = tree.generation
+	t.conf = tree.conf
+	t.base = tree
+	t.arena = tree.arena
+}
+
+// Reset starts the transaction over on the given tree, as if it had been
+// returned by tree.Txn, whether or not it was committed or rolled back. Writers
+// that make many small commits can reuse a transaction this way to avoid the
+// garbage from allocating each one, and once it has been reset it also keeps
+// its cache of writable nodes and map of tracked channels, emptied, between
+// commits. Settings such as TrackMutate are cleared. The transaction must not
+// be reset until any notifications for its last commit have been sent.
+func (t *Txn[T]) Reset(tree *Tree[T]) {
+	writable, track := t.spareWritable, t.spareTrack
+	if t.writable != nil {
+		t.writable.Purge()
+		writable = t.writable
+	}
+	if writable != nil && t.conf != tree.conf {
+		// The cache was sized for another tree.
+		writable = nil
+	}
+	walkStack := t.walkStack
+	*t = Txn[T]{
+		reused:        true,
+		spareWritable: writable,
+		spareTrack:    track,
+		walkStack:     walkStack,
+	}
+	t.bind(tree)
+}
+
+// releaseWritable drops the cache of writable nodes, keeping it for reuse if
+// the transaction has been reset.
+func (t *Txn[T]) releaseWritable() {
+	if t.reused && t.writable != nil {
+		t.writable.Purge()
+		t.spareWritable = t.writable
+	}
+	t.writable = nil
+}
+
 // Clone makes an independent copy of the transaction. The new transaction
 // does not track any nodes and has TrackMutate turned off. The cloned transaction will contain any uncommitted writes in the original transaction but further mutations to either will be independent and result in different radix trees on Commit. A cloned transaction may be passed to another goroutine and mutated there independently however each transaction may only be mutated in a single thread.
 func (t *Txn[T]) Clone() *Txn[T] {
@@ -96,9 +342,15 @@ func (t *Txn[T]) Clone() *Txn[T] {
 	t.writable = nil
 
 	txn := &Txn[T]{
-		root: t.root,
-		snap: t.snap,
-		size: t.size,
+		root:       t.root,
+		snap:       t.snap,
+		size:       t.size,
+		bytes:      t.bytes,
+		hash:       t.hash,
+		generation: t.generation,
+		conf:       t.conf,
+		base:       t.base,
+		arena:      t.arena,
 	}
 	return txn
 }
@@ -108,6 +360,172 @@ func (t *Txn[T]) Clone() *Txn[T] {
 // the transaction is committed.
 func (t *Txn[T]) TrackMutate(track bool) {
 	t.trackMutate = track
+	t.trackPrefix = nil
+}
+
+// TrackMutateFunc sets a function to call with each key that was inserted,
+// updated or deleted by the transaction when it's notified, instead of
+// watching channels, which suits integrations with event buses that would
+// otherwise have to hold a channel for every node they watch. The keys are
+// found by comparing the trees, which only visits the subtrees that changed,
+// and are passed in order. This is independent of TrackMutate, and a nil fn
+// removes the function.
+func (t *Txn[T]) TrackMutateFunc(fn func(key []byte)) {
+	t.mutateFn = fn
+}
+
+// TrackMutatePrefix turns on mutation tracking for only the keys under the
+// given prefix, so that transactions that touch the whole tree don't have to
+// keep track of the channels for all the nodes they modify when the watchers
+// only care about one part of it. No channels are tracked as the transaction
+// is written, and instead the subtrees under the prefix are compared when it's
+// notified, skipping the nodes the trees share. Only the watches for the nodes
+// and leaves under the prefix are notified, so notably watches on the nodes
+// above it, such as the root, don't fire. Calling TrackMutate removes the
+// prefix.
+func (t *Txn[T]) TrackMutatePrefix(prefix []byte) {
+	t.trackMutate = true
+	t.trackPrefix = append([]byte{}, t.conf.path(prefix)...)
+}
+
+// TrackChanges can be used to toggle if the changes made by the transaction
+// are recorded, so that they can be retrieved with Changes, for example to
+// build an audit log or trigger side effects after committing. Only the
+// changes made while it's enabled are recorded.
+func (t *Txn[T]) TrackChanges(track bool) {
+	t.trackChanges = track
+}
+
+// Changes returns the changes recorded by the transaction since TrackChanges
+// was enabled, in the order they were made. It can be called before or after
+// the transaction is committed. Writes that remove a range of keys, such as
+// DeletePrefix, record a change for each key removed, in order. The slice is
+// appended to by further writes and must not be modified.
+func (t *Txn[T]) Changes() []Change[T] {
+	return t.changes
+}
+
+// recordPut records the insert of a key, if changes are tracked.
+func (t *Txn[T]) recordPut(k []byte, old T, hadOld bool, v T) {
+	if !t.trackChanges {
+		return
+	}
+	c := Change[T]{Type: ChangeAdded, Key: k, New: v}
+	if hadOld {
+		c.Type, c.Old = ChangeUpdated, old
+	}
+	t.changes = append(t.changes, c)
+}
+
+// recordDelete records the delete of a key, if changes are tracked.
+func (t *Txn[T]) recordDelete(k []byte, old T) {
+	if t.trackChanges {
+		t.changes = append(t.changes, Change[T]{Type: ChangeDeleted, Key: k, Old: old})
+	}
+}
+
+// recordDeletes records the deletion of every key under n, if changes are
+// tracked.
+func (t *Txn[T]) recordDeletes(n *Node[T]) {
+	if !t.trackChanges {
+		return
+	}
+	recursiveWalk(n, func(k []byte, v T) bool {
+		t.recordDelete(k, v)
+		return false
+	})
+}
+
+// Rollback abandons the transaction, discarding its writes along with its
+// cache of writable nodes, recorded changes and the watch channels it was
+// tracking, so they can be garbage collected right away even if the
+// transaction itself is still referenced. Reads from the transaction
+// afterwards see the tree it was started from. Any further write or commit is
+// misuse, which panics under the MisuseIgnore and MisusePanic policies, and is
+// recorded as an error wrapping ErrTxnRolledBack under MisuseReturnError.
+// Rolling back a transaction that was already committed or rolled back does
+// nothing, so it's safe to defer.
+func (t *Txn[T]) Rollback() {
+	if t.committed || t.rolledBack {
+		return
+	}
+	t.rolledBack = true
+	t.root = t.snap
+	t.size = t.base.size
+	t.bytes = t.base.bytes
+	t.hash = t.base.hash
+	t.writable = nil
+	t.trackChannels = nil
+	t.resetTrackSmall()
+	t.trackOverflow = false
+	t.notifyResumed = false
+	t.mutateFn, t.mutateIter = nil, nil
+	t.walkStack = nil
+	t.changes = nil
+}
+
+// Err returns the first error recorded by the transaction, or nil if there
+// wasn't one. Errors are recorded for misuse on trees using the
+// MisuseReturnError policy, and for inserts that would exceed the limit set
+// with WithMemoryBudget. In either case the operation that failed was not
+// applied.
+func (t *Txn[T]) Err() error {
+	return t.err
+}
+
+// Bytes returns the approximate number of bytes retained by the keys and values
+// in the transaction's tree, as counted for WithMemoryBudget.
+func (t *Txn[T]) Bytes() int {
+	return t.bytes
+}
+
+// entrySize returns the size of an entry counted against the memory budget.
+func (t *Txn[T]) entrySize(k []byte, v T) int {
+	return len(k) + t.conf.sizer(v)
+}
+
+// checkUse checks that the transaction can still be used for the given
+// operation, returning false if the operation should not proceed.
+func (t *Txn[T]) checkUse(op string) bool {
+	if t.rolledBack {
+		// There's no historical behavior to keep for rolled back
+		// transactions, so they're never silently used.
+		if t.conf.misuse == MisuseIgnore {
+			panic(&MisuseError{Op: op, Err: ErrTxnRolledBack})
+		}
+		return t.misuse(op, ErrTxnRolledBack)
+	}
+	if t.committed {
+		return t.misuse(op, ErrTxnCommitted)
+	}
+	return true
+}
+
+// checkKey is like checkUse but also validates the key given to the operation.
+func (t *Txn[T]) checkKey(op string, k []byte) bool {
+	if !t.checkUse(op) {
+		return false
+	}
+	if k == nil {
+		return t.misuse(op, ErrNilKey)
+	}
+	return true
+}
+
+// misuse applies the misuse policy to an invalid operation, returning false
+// if the operation should not proceed.
+func (t *Txn[T]) misuse(op string, err error) bool {
+	switch t.conf.misuse {
+	case MisusePanic:
+		panic(&MisuseError{Op: op, Err: err})
+	case MisuseReturnError:
+		if t.err == nil {
+			t.err = &MisuseError{Op: op, Err: err}
+		}
+		return false
+	default:
+		return true
+	}
 }
 
 // trackChannel safely attempts to track the given mutation channel, setting the
@@ -115,8 +533,9 @@ func (t *Txn[T]) TrackMutate(track bool) {
 // state that will accumulate during a transaction and we have a slower algorithm
 // to switch to if we overflow.
 func (t *Txn[T]) trackChannel(ch chan struct{}) {
-	// In overflow, make sure we don't store any more objects.
-	if t.trackOverflow {
+	// In overflow, make sure we don't store any more objects. With a
+	// prefix, none are stored at all.
+	if t.trackOverflow || t.trackPrefix != nil {
 		return
 	}
 
@@ -130,26 +549,57 @@ func (t *Txn[T]) trackChannel(ch chan struct{}) {
 		// safe to do this since we have already overflowed and will be using
 		// the slow notify algorithm.
 		t.trackChannels = nil
+		t.resetTrackSmall()
 		return
 	}
 
-	// Create the map on the fly when we need it.
+	// Use the inline array until it fills up. Channels can be tracked more
+	// than once, but must only be closed once, so check for duplicates.
 	if t.trackChannels == nil {
-		t.trackChannels = make(map[chan struct{}]struct{})
+		for _, tracked := range t.trackSmall[:t.trackNumSmall] {
+			if tracked == ch {
+				return
+			}
+		}
+		if t.trackNumSmall < trackSmallSize {
+			t.trackSmall[t.trackNumSmall] = ch
+			t.trackNumSmall++
+			return
+		}
+
+		// Create the map on the fly when we need it.
+		t.trackChannels = t.spareTrack
+		t.spareTrack = nil
+		if t.trackChannels == nil {
+			t.trackChannels = make(map[chan struct{}]struct{})
+		}
+		for _, tracked := range t.trackSmall {
+			t.trackChannels[tracked] = struct{}{}
+		}
+		t.resetTrackSmall()
 	}
 
 	// Otherwise we are good to track it.
 	t.trackChannels[ch] = struct{}{}
 }
 
+// resetTrackSmall clears the inline array of tracked channels.
+func (t *Txn[T]) resetTrackSmall() {
+	t.trackSmall = [trackSmallSize]chan struct{}{}
+	t.trackNumSmall = 0
+}
+
 // writeNode returns a node to be modified, if the current node has already been
 // modified during the course of the transaction, it is used in-place. Set
 // forLeafUpdate to true if you are getting a write node to update the leaf,
 // which will set leaf mutation tracking appropriately as well.
 func (t *Txn[T]) writeNode(n *Node[T], forLeafUpdate bool) *Node[T] {
 	// Ensure the writable set exists.
+	if t.writable == nil && t.spareWritable != nil {
+		t.writable, t.spareWritable = t.spareWritable, nil
+	}
 	if t.writable == nil {
-		lru, err := simplelru.NewLRU[*Node[T], any](defaultModifiedCache, nil)
+		lru, err := simplelru.NewLRU[*Node[T], any](t.conf.writableCacheSize(), nil)
 		if err != nil {
 			panic(err)
 		}
@@ -182,17 +632,19 @@ func (t *Txn[T]) writeNode(n *Node[T], forLeafUpdate bool) *Node[T] {
 	// safe to replace this leaf with another after you get your node for
 	// writing. You MUST replace it, because the channel associated with
 	// this leaf will be closed when this transaction is committed.
-	nc := &Node[T]{
-		mutateCh: make(chan struct{}),
-		leaf:     n.leaf,
-	}
-	if n.prefix != nil {
+	nc := t.allocNode(Node[T]{leaf: n.leaf})
+	if t.aliasLeafPrefix(n) {
+		nc.prefix = leafPrefix(n.leaf, len(n.prefix))
+	} else if n.prefix != nil {
 		nc.prefix = make([]byte, len(n.prefix))
 		copy(nc.prefix, n.prefix)
 	}
-	if len(n.edges) != 0 {
-		nc.edges = make([]edge[T], len(n.edges))
-		copy(nc.edges, n.edges)
+	if n.inner != nil {
+		nc.inner = &innerNode[T]{count: n.inner.count}
+		if len(n.inner.edges) != 0 {
+			nc.inner.edges = make([]edge[T], len(n.inner.edges))
+			copy(nc.inner.edges, n.inner.edges)
+		}
 	}
 
 	// Mark this node as writable.
@@ -200,51 +652,105 @@ func (t *Txn[T]) writeNode(n *Node[T], forLeafUpdate bool) *Node[T] {
 	return nc
 }
 
+// aliasLeafPrefix returns true if n is a leaf-only node, so its prefix can be
+// stored as part of its leaf's key. That's not possible with a collation since
+// the path through the tree isn't made of the same bytes as the key.
+func (t *Txn[T]) aliasLeafPrefix(n *Node[T]) bool {
+	return n.isLeafOnly() && t.conf.collate == nil
+}
+
+// newLeaf returns a new leaf for the given key and value. If the leaf replaces
+// an existing one, old should be set so that any metadata can be carried over.
+func (t *Txn[T]) newLeaf(k []byte, v T, old *leafNode[T]) *leafNode[T] {
+	leaf := t.allocLeaf(leafNode[T]{
+		key: k,
+		val: v,
+	})
+	if t.conf.leafMeta {
+		gen := t.generation + 1
+		leaf.meta = &LeafMeta{Created: gen, Modified: gen}
+		if old != nil && old.meta != nil {
+			leaf.meta.Created = old.meta.Created
+		}
+	}
+	return leaf
+}
+
 // Visit all the nodes in the tree under n, and add their mutateChannels to the transaction
 // Returns the size of the subtree visited
+//
+// The walk uses an explicit stack rather than recursion so that deep subtrees
+// are safe to delete. Once mutation tracking overflows there's no point in
+// visiting the rest of the nodes, so their leaf counts are used instead.
 func (t *Txn[T]) trackChannelsAndCount(n *Node[T]) int {
-	// Count only leaf nodes
-	leaves := 0
-	if n.leaf != nil {
-		leaves = 1
+	if !t.trackMutate || t.trackOverflow || t.trackPrefix != nil {
+		return n.count()
 	}
-	// Mark this node as being mutated.
-	if t.trackMutate {
+
+	leaves := 0
+	stack := append(t.walkStack, n)
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if t.trackOverflow {
+			leaves += n.count()
+			continue
+		}
+
+		// Mark this node and its leaf as being mutated.
 		t.trackChannel(n.mutateCh)
-	}
+		if n.leaf != nil {
+			leaves++
+			t.trackChannel(n.leaf.mutateCh)
+		}
 
-	// Mark its leaf as being mutated, if appropriate.
-	if t.trackMutate && n.leaf != nil {
-		t.trackChannel(n.leaf.mutateCh)
+		// Visit the children
+		for _, e := range n.edges() {
+			stack = append(stack, e.node)
+		}
 	}
 
-	// Recurse on the children
-	for _, e := range n.edges {
-		leaves += t.trackChannelsAndCount(e.node)
+	// Keep the stack for next time, but don't hold on to any of the nodes.
+	stack = stack[:cap(stack)]
+	for i := range stack {
+		stack[i] = nil
 	}
+	t.walkStack = stack[:0]
 	return leaves
 }
 
+// canMerge returns true if n may be collapsed with its only child after a
+// delete. The root is never merged, and no node is when WithoutDeleteMerge is
+// set.
+func (t *Txn[T]) canMerge(n *Node[T]) bool {
+	return n != t.root && !t.conf.noMerge
+}
+
 // mergeChild is called to collapse the given node with its child. This is only
 // called when the given node is not a leaf and has a single edge.
 func (t *Txn[T]) mergeChild(n *Node[T]) {
 	// Mark the child node as being mutated since we are about to abandon
 	// it. We don't need to mark the leaf since we are retaining it if it
 	// is there.
-	e := n.edges[0]
+	e := n.edges()[0]
 	child := e.node
 	if t.trackMutate {
 		t.trackChannel(child.mutateCh)
 	}
 
 	// Merge the nodes.
-	n.prefix = concat(n.prefix, child.prefix)
-	n.leaf = child.leaf
-	if len(child.edges) != 0 {
-		n.edges = make([]edge[T], len(child.edges))
-		copy(n.edges, child.edges)
+	n.setLeaf(child.leaf)
+	if t.aliasLeafPrefix(child) {
+		n.prefix = leafPrefix(child.leaf, len(n.prefix)+len(child.prefix))
 	} else {
-		n.edges = nil
+		n.prefix = concat(n.prefix, child.prefix)
+	}
+	if len(child.edges()) != 0 {
+		es := make([]edge[T], len(child.edges()))
+		copy(es, child.edges())
+		n.setEdges(es)
+	} else {
+		n.setEdges(nil)
 	}
 }
 
@@ -261,11 +767,15 @@ func (t *Txn[T]) insert(n *Node[T], k, search []byte, v T) (*Node[T], T, bool) {
 			didUpdate = true
 		}
 
+		oldLeaf := n.leaf
 		nc := t.writeNode(n, true)
-		nc.leaf = &leafNode[T]{
-			mutateCh: make(chan struct{}),
-			key:      k,
-			val:      v,
+		nc.setLeaf(t.newLeaf(k, v, oldLeaf))
+		if t.aliasLeafPrefix(nc) {
+			// Point the prefix at the new key so the old one can be freed.
+			nc.prefix = leafPrefix(nc.leaf, len(nc.prefix))
+		}
+		if !didUpdate {
+			nc.setCount(nc.count() + 1)
 		}
 		return nc, oldVal, didUpdate
 	}
@@ -277,18 +787,14 @@ func (t *Txn[T]) insert(n *Node[T], k, search []byte, v T) (*Node[T], T, bool) {
 	if child == nil {
 		e := edge[T]{
 			label: search[0],
-			node: &Node[T]{
-				mutateCh: make(chan struct{}),
-				leaf: &leafNode[T]{
-					mutateCh: make(chan struct{}),
-					key:      k,
-					val:      v,
-				},
+			node: t.allocNode(Node[T]{
+				leaf:   t.newLeaf(k, v, nil),
 				prefix: search,
-			},
+			}),
 		}
 		nc := t.writeNode(n, false)
 		nc.addEdge(e)
+		nc.setCount(nc.count() + 1)
 		return nc, zero, false
 	}
 
@@ -299,7 +805,10 @@ func (t *Txn[T]) insert(n *Node[T], k, search []byte, v T) (*Node[T], T, bool) {
 		newChild, oldVal, didUpdate := t.insert(child, k, search, v)
 		if newChild != nil {
 			nc := t.writeNode(n, false)
-			nc.edges[idx].node = newChild
+			nc.edges()[idx].node = newChild
+			if !didUpdate {
+				nc.setCount(nc.count() + 1)
+			}
 			return nc, oldVal, didUpdate
 		}
 		return nil, oldVal, didUpdate
@@ -307,10 +816,10 @@ func (t *Txn[T]) insert(n *Node[T], k, search []byte, v T) (*Node[T], T, bool) {
 
 	// Split the node
 	nc := t.writeNode(n, false)
-	splitNode := &Node[T]{
-		mutateCh: make(chan struct{}),
-		prefix:   search[:commonPrefix],
-	}
+	nc.setCount(nc.count() + 1)
+	splitNode := t.allocNode(Node[T]{
+		prefix: search[:commonPrefix],
+	})
 	nc.replaceEdge(edge[T]{
 		label: search[0],
 		node:  splitNode,
@@ -323,29 +832,25 @@ func (t *Txn[T]) insert(n *Node[T], k, search []byte, v T) (*Node[T], T, bool) {
 		node:  modChild,
 	})
 	modChild.prefix = modChild.prefix[commonPrefix:]
+	splitNode.setCount(child.count() + 1)
 
 	// Create a new leaf node
-	leaf := &leafNode[T]{
-		mutateCh: make(chan struct{}),
-		key:      k,
-		val:      v,
-	}
+	leaf := t.newLeaf(k, v, nil)
 
 	// If the new key is a subset, add to to this node
 	search = search[commonPrefix:]
 	if len(search) == 0 {
-		splitNode.leaf = leaf
+		splitNode.setLeaf(leaf)
 		return nc, zero, false
 	}
 
 	// Create a new edge for the node
 	splitNode.addEdge(edge[T]{
 		label: search[0],
-		node: &Node[T]{
-			mutateCh: make(chan struct{}),
-			leaf:     leaf,
-			prefix:   search,
-		},
+		node: t.allocNode(Node[T]{
+			leaf:   leaf,
+			prefix: search,
+		}),
 	})
 	return nc, zero, false
 }
@@ -365,10 +870,11 @@ func (t *Txn[T]) delete(n *Node[T], search []byte) (*Node[T], *leafNode[T]) {
 
 		// Remove the leaf node
 		nc := t.writeNode(n, true)
-		nc.leaf = nil
+		nc.setLeaf(nil)
+		nc.setCount(nc.count() - 1)
 
 		// Check if this node should be merged
-		if n != t.root && len(nc.edges) == 1 {
+		if t.canMerge(n) && len(nc.edges()) == 1 {
 			t.mergeChild(nc)
 		}
 		return nc, oldLeaf
@@ -393,15 +899,16 @@ func (t *Txn[T]) delete(n *Node[T], search []byte) (*Node[T], *leafNode[T]) {
 	// the !nc.isLeaf() check in the logic just below. This is pretty subtle,
 	// so be careful if you change any of the logic here.
 	nc := t.writeNode(n, false)
+	nc.setCount(nc.count() - 1)
 
 	// Delete the edge if the node has no edges
-	if newChild.leaf == nil && len(newChild.edges) == 0 {
+	if newChild.leaf == nil && len(newChild.edges()) == 0 {
 		nc.delEdge(label)
-		if n != t.root && len(nc.edges) == 1 && !nc.isLeaf() {
+		if t.canMerge(n) && len(nc.edges()) == 1 && !nc.isLeaf() {
 			t.mergeChild(nc)
 		}
 	} else {
-		nc.edges[idx].node = newChild
+		nc.edges()[idx].node = newChild
 	}
 	return nc, leaf
 }
@@ -410,12 +917,13 @@ func (t *Txn[T]) delete(n *Node[T], search []byte) (*Node[T], *leafNode[T]) {
 func (t *Txn[T]) deletePrefix(n *Node[T], search []byte) (*Node[T], int) {
 	// Check for key exhaustion
 	if len(search) == 0 {
+		// Visit the subtree before getting the node for writing, since if
+		// it's already writable it will be modified in place below.
+		numDeletions := t.trackChannelsAndCount(n)
 		nc := t.writeNode(n, true)
-		if n.isLeaf() {
-			nc.leaf = nil
-		}
-		nc.edges = nil
-		return nc, t.trackChannelsAndCount(n)
+		nc.leaf = nil
+		nc.inner = nil
+		return nc, numDeletions
 	}
 
 	// Look for an edge
@@ -443,42 +951,227 @@ func (t *Txn[T]) deletePrefix(n *Node[T], search []byte) (*Node[T], int) {
 	// so be careful if you change any of the logic here.
 
 	nc := t.writeNode(n, false)
+	nc.setCount(nc.count() - numDeletions)
 
 	// Delete the edge if the node has no edges
-	if newChild.leaf == nil && len(newChild.edges) == 0 {
+	if newChild.leaf == nil && len(newChild.edges()) == 0 {
 		nc.delEdge(label)
-		if n != t.root && len(nc.edges) == 1 && !nc.isLeaf() {
+		if t.canMerge(n) && len(nc.edges()) == 1 && !nc.isLeaf() {
 			t.mergeChild(nc)
 		}
 	} else {
-		nc.edges[idx].node = newChild
+		nc.edges()[idx].node = newChild
 	}
 	return nc, numDeletions
 }
 
 // Insert is used to add or update a given key. The return provides
 // the previous value and a bool indicating if any was set.
+//
+// The tree keeps a reference to k rather than a copy, unless the tree was
+// created with WithCopyKeys, so the caller must not modify k afterwards.
+// Doing so would silently corrupt the order of the tree.
 func (t *Txn[T]) Insert(k []byte, v T) (T, bool) {
-	newRoot, oldVal, didUpdate := t.insert(t.root, k, k, v)
+	if !t.checkKey("Insert", k) {
+		var zero T
+		return zero, false
+	}
+	k = t.conf.key(k)
+	if t.conf.copyKeys {
+		k = append(make([]byte, 0, len(k)), k...)
+	}
+	if t.conf.stats != nil {
+		t.conf.stats.record(accessInsert, k)
+	}
+	if t.conf.intern != nil {
+		v = t.conf.intern(v)
+	}
+	path := collateKey(t.conf.collate, k)
+	// Look up any existing value once for the per-entry accounting.
+	var old T
+	var hadOld bool
+	if t.conf.sizer != nil || t.conf.hash != nil {
+		old, hadOld = t.root.Get(path)
+	}
+	var delta int
+	if t.conf.sizer != nil {
+		delta = t.entrySize(k, v)
+		if hadOld {
+			delta -= t.entrySize(k, old)
+		}
+		if delta > 0 && t.bytes+delta > t.conf.budget {
+			if t.err == nil {
+				t.err = ErrBudgetExceeded
+			}
+			var zero T
+			return zero, false
+		}
+	}
+	newRoot, oldVal, didUpdate := t.insert(t.root, k, path, v)
 	if newRoot != nil {
 		t.root = newRoot
 	}
 	if !didUpdate {
 		t.size++
 	}
+	t.bytes += delta
+	if t.conf.hash != nil {
+		if hadOld {
+			t.hash -= t.entryHash(k, old)
+		}
+		t.hash += t.entryHash(k, v)
+	}
+	t.recordPut(k, oldVal, didUpdate, v)
 	return oldVal, didUpdate
 }
 
+// Update reads, transforms and writes the value of a given key in a single
+// pass over the tree. fn is called with the current value and whether the key
+// is set, and returns the new value and whether to write it. The return
+// indicates if a write occurred, which it doesn't if fn declines it or the
+// write would go over the memory budget.
+//
+// As with Insert, the tree keeps a reference to k rather than a copy unless
+// the tree was created with WithCopyKeys.
+func (t *Txn[T]) Update(k []byte, fn func(old T, exists bool) (T, bool)) bool {
+	if !t.checkKey("Update", k) {
+		return false
+	}
+	k = t.conf.key(k)
+	if t.conf.copyKeys {
+		k = append(make([]byte, 0, len(k)), k...)
+	}
+	if t.conf.stats != nil {
+		t.conf.stats.record(accessInsert, k)
+	}
+	u := updater[T]{fn: fn}
+	newRoot := t.update(t.root, k, collateKey(t.conf.collate, k), &u)
+	if !u.wrote {
+		return false
+	}
+	if newRoot != nil {
+		t.root = newRoot
+	}
+	if !u.exists {
+		t.size++
+	}
+	t.bytes += u.delta
+	if t.conf.hash != nil {
+		if u.exists {
+			t.hash -= t.entryHash(k, u.old)
+		}
+		t.hash += t.entryHash(k, u.val)
+	}
+	t.recordPut(k, u.old, u.exists, u.val)
+	return true
+}
+
+// CompareAndSwap sets the value of a given key to new if it's set to a value
+// that eq reports is equal to expected, returning whether it was swapped. The
+// key isn't added if it isn't set.
+func (t *Txn[T]) CompareAndSwap(k []byte, expected, new T, eq func(a, b T) bool) bool {
+	return t.Update(k, func(old T, exists bool) (T, bool) {
+		if !exists || !eq(old, expected) {
+			return old, false
+		}
+		return new, true
+	})
+}
+
+// CompareAndDelete deletes a given key if it's set to a value that eq reports
+// is equal to expected, returning whether it was deleted.
+func (t *Txn[T]) CompareAndDelete(k []byte, expected T, eq func(a, b T) bool) bool {
+	if !t.checkKey("CompareAndDelete", k) {
+		return false
+	}
+	if old, ok := t.root.Get(t.conf.path(k)); !ok || !eq(old, expected) {
+		return false
+	}
+	_, ok := t.Delete(k)
+	return ok
+}
+
+// updater holds the state of an Update as it goes down the tree.
+type updater[T any] struct {
+	fn     func(old T, exists bool) (T, bool)
+	old    T
+	val    T
+	exists bool
+	wrote  bool
+	delta  int
+}
+
+// update does a recursive update, returning nil if nothing was written. It
+// follows the edges that fully match the search key, and at the node where
+// the key either is or would be added calls the update function and inserts
+// its value from there, so the path is only walked once.
+func (t *Txn[T]) update(n *Node[T], k, search []byte, u *updater[T]) *Node[T] {
+	if len(search) != 0 {
+		idx, child := n.getEdge(search[0])
+		if child != nil && bytes.HasPrefix(search, child.prefix) {
+			newChild := t.update(child, k, search[len(child.prefix):], u)
+			if newChild == nil {
+				return nil
+			}
+			nc := t.writeNode(n, false)
+			nc.edges()[idx].node = newChild
+			if !u.exists {
+				nc.setCount(nc.count() + 1)
+			}
+			return nc
+		}
+	}
+
+	if len(search) == 0 && n.isLeaf() {
+		u.old, u.exists = n.leaf.val, true
+	}
+	v, ok := u.fn(u.old, u.exists)
+	if !ok {
+		return nil
+	}
+	if t.conf.intern != nil {
+		v = t.conf.intern(v)
+	}
+	if t.conf.sizer != nil {
+		u.delta = t.entrySize(k, v)
+		if u.exists {
+			u.delta -= t.entrySize(k, u.old)
+		}
+		if u.delta > 0 && t.bytes+u.delta > t.conf.budget {
+			if t.err == nil {
+				t.err = ErrBudgetExceeded
+			}
+			return nil
+		}
+	}
+	u.val, u.wrote = v, true
+	nc, _, _ := t.insert(n, k, search, v)
+	return nc
+}
+
 // Delete is used to delete a given key. Returns the old value if any,
 // and a bool indicating if the key was set.
 func (t *Txn[T]) Delete(k []byte) (T, bool) {
 	var zero T
-	newRoot, leaf := t.delete(t.root, k)
+	if !t.checkKey("Delete", k) {
+		return zero, false
+	}
+	if t.conf.stats != nil {
+		t.conf.stats.record(accessDelete, t.conf.key(k))
+	}
+	newRoot, leaf := t.delete(t.root, t.conf.path(k))
 	if newRoot != nil {
 		t.root = newRoot
 	}
 	if leaf != nil {
 		t.size--
+		if t.conf.sizer != nil {
+			t.bytes -= t.entrySize(leaf.key, leaf.val)
+		}
+		if t.conf.hash != nil {
+			t.hash -= t.entryHash(leaf.key, leaf.val)
+		}
+		t.recordDelete(leaf.key, leaf.val)
 		return leaf.val, true
 	}
 	return zero, false
@@ -487,16 +1180,396 @@ func (t *Txn[T]) Delete(k []byte) (T, bool) {
 // DeletePrefix is used to delete an entire subtree that matches the prefix
 // This will delete all nodes under that prefix
 func (t *Txn[T]) DeletePrefix(prefix []byte) bool {
+	if !t.checkKey("DeletePrefix", prefix) {
+		return false
+	}
+	prefix = t.conf.path(prefix)
+	var deleted int
+	var deletedHash uint64
+	var changes []Change[T]
+	if t.conf.sizer != nil || t.conf.hash != nil || t.trackChanges {
+		t.root.WalkPrefix(prefix, func(k []byte, v T) bool {
+			if t.conf.sizer != nil {
+				deleted += t.entrySize(k, v)
+			}
+			if t.conf.hash != nil {
+				deletedHash += t.entryHash(k, v)
+			}
+			if t.trackChanges {
+				changes = append(changes, Change[T]{Type: ChangeDeleted, Key: k, Old: v})
+			}
+			return false
+		})
+	}
 	newRoot, numDeletions := t.deletePrefix(t.root, prefix)
 	if newRoot != nil {
 		t.root = newRoot
 		t.size = t.size - numDeletions
+		t.bytes -= deleted
+		t.hash -= deletedHash
+		t.changes = append(t.changes, changes...)
 		return true
 	}
 	return false
 
 }
 
+// DeleteRange deletes all the keys in the range [start, end), where a nil end
+// means the range has no upper bound, and returns the number of keys deleted.
+// Subtrees that lie entirely within the range are removed whole, so only the
+// nodes along the paths to the range boundaries are visited, unless mutation
+// tracking, a memory budget or content hashing needs every deleted key.
+func (t *Txn[T]) DeleteRange(start, end []byte) int {
+	if !t.checkUse("DeleteRange") {
+		return 0
+	}
+	start = t.conf.path(start)
+	if end != nil {
+		end = t.conf.path(end)
+		if bytes.Compare(start, end) >= 0 {
+			return 0
+		}
+	}
+	newRoot, numDeletions := t.deleteRange(t.root, nil, start, end)
+	if newRoot != nil {
+		t.root = newRoot
+		t.size -= numDeletions
+	}
+	return numDeletions
+}
+
+// deleteRange deletes the keys under n in the range [start, end), where path
+// is the path to n including its prefix. It returns nil if nothing under n was
+// in the range.
+func (t *Txn[T]) deleteRange(n *Node[T], path, start, end []byte) (*Node[T], int) {
+	// Every key under n has path as a prefix, so the whole subtree is
+	// either outside the range, inside it, or straddles one of its ends.
+	below := end == nil || (bytes.Compare(path, end) < 0 && !bytes.HasPrefix(end, path))
+	if bytes.Compare(path, start) >= 0 && below {
+		// Visit the subtree before getting the node for writing, since if
+		// it's already writable it will be modified in place below.
+		t.unaccount(n)
+		t.recordDeletes(n)
+		numDeletions := t.trackChannelsAndCount(n)
+		nc := t.writeNode(n, true)
+		nc.leaf = nil
+		nc.inner = nil
+		return nc, numDeletions
+	}
+	if (end != nil && bytes.Compare(path, end) >= 0) ||
+		(bytes.Compare(path, start) < 0 && !bytes.HasPrefix(start, path)) {
+		return nil, 0
+	}
+
+	// Otherwise the subtree straddles an end of the range. The leaf, if
+	// there is one, is below the end, and in the range unless it's below
+	// the start.
+	var changes []edge[T]
+	numDeletions := 0
+	delLeaf := n.leaf != nil && bytes.Compare(path, start) >= 0
+	if delLeaf {
+		numDeletions++
+		t.recordDelete(n.leaf.key, n.leaf.val)
+	}
+	for _, e := range n.edges() {
+		newChild, deleted := t.deleteRange(e.node, append(path, e.node.prefix...), start, end)
+		if newChild != nil {
+			changes = append(changes, edge[T]{label: e.label, node: newChild})
+			numDeletions += deleted
+		}
+	}
+	if len(changes) == 0 && !delLeaf {
+		return nil, 0
+	}
+
+	// Copy this node. As for delete, mergeChild only adds a leaf if there
+	// isn't one.
+	nc := t.writeNode(n, delLeaf)
+	if delLeaf {
+		if t.conf.sizer != nil {
+			t.bytes -= t.entrySize(n.leaf.key, n.leaf.val)
+		}
+		if t.conf.hash != nil {
+			t.hash -= t.entryHash(n.leaf.key, n.leaf.val)
+		}
+		nc.setLeaf(nil)
+	}
+	nc.setCount(nc.count() - numDeletions)
+	for _, e := range changes {
+		if e.node.leaf == nil && len(e.node.edges()) == 0 {
+			nc.delEdge(e.label)
+		} else {
+			nc.replaceEdge(e)
+		}
+	}
+	if t.canMerge(n) && len(nc.edges()) == 1 && !nc.isLeaf() {
+		t.mergeChild(nc)
+	}
+	return nc, numDeletions
+}
+
+// unaccount removes the keys under n from the size and content hash of the
+// tree, if they're tracked.
+func (t *Txn[T]) unaccount(n *Node[T]) {
+	if t.conf.sizer == nil && t.conf.hash == nil {
+		return
+	}
+	recursiveWalk(n, func(k []byte, v T) bool {
+		if t.conf.sizer != nil {
+			t.bytes -= t.entrySize(k, v)
+		}
+		if t.conf.hash != nil {
+			t.hash -= t.entryHash(k, v)
+		}
+		return false
+	})
+}
+
+// RenamePrefix moves every key that starts with oldPrefix to the same key with
+// oldPrefix replaced by newPrefix, keeping its value. Any existing keys with the
+// new names are overwritten. Returns true if any keys were moved.
+//
+// The subtree under oldPrefix is detached and re-rooted under newPrefix with
+// the same shape, so only the nodes along the two paths are split or merged,
+// and no keys are looked up or inserted one at a time. Since every leaf holds
+// its full key, the nodes of the subtree are still copied to rename their
+// leaves. If the tree's KeyTransformer doesn't store the renamed keys as the
+// new prefix followed by the rest of the old keys, the subtree can't keep its
+// shape, and its renamed keys are inserted into a new one instead.
+func (t *Txn[T]) RenamePrefix(oldPrefix, newPrefix []byte) bool {
+	if !t.checkKey("RenamePrefix", oldPrefix) || !t.checkKey("RenamePrefix", newPrefix) {
+		return false
+	}
+	graft, ok := t.renamedSubtree(oldPrefix, newPrefix)
+	if !ok || graft == nil {
+		return false
+	}
+	if t.conf.sizer != nil && !t.fitsRenamed(graft, t.conf.path(oldPrefix)) {
+		return false
+	}
+	t.DeletePrefix(oldPrefix)
+	t.Merge(graft, nil)
+	return true
+}
+
+// Move moves the value of oldKey to newKey, overwriting any existing value of
+// newKey. Returns true if oldKey was set. With mutation tracking, the watches
+// on oldKey fire as for Delete and those on newKey as for Insert. Since both
+// happen within the transaction, the nodes along the parts of the paths that
+// the keys share are only copied once.
+func (t *Txn[T]) Move(oldKey, newKey []byte) bool {
+	if !t.checkKey("Move", oldKey) || !t.checkKey("Move", newKey) {
+		return false
+	}
+	if bytes.Equal(t.conf.path(oldKey), t.conf.path(newKey)) {
+		_, ok := t.root.Get(t.conf.path(oldKey))
+		return ok
+	}
+	if t.conf.sizer != nil && !t.fitsMove(oldKey, newKey) {
+		return false
+	}
+	v, ok := t.Delete(oldKey)
+	if ok {
+		t.Insert(newKey, v)
+	}
+	return ok
+}
+
+// fitsMove checks that moving oldKey to newKey keeps the tree within its
+// memory budget, recording ErrBudgetExceeded if it doesn't, so that the key
+// isn't lost by failing to insert it once it's been deleted.
+func (t *Txn[T]) fitsMove(oldKey, newKey []byte) bool {
+	v, ok := t.root.Get(t.conf.path(oldKey))
+	if !ok {
+		return true
+	}
+	k := t.conf.key(newKey)
+	delta := t.entrySize(k, v) - t.entrySize(t.conf.key(oldKey), v)
+	if cur, ok := t.root.Get(t.conf.path(newKey)); ok {
+		delta -= t.entrySize(k, cur)
+	}
+	if delta > 0 && t.bytes+delta > t.conf.budget {
+		if t.err == nil {
+			t.err = ErrBudgetExceeded
+		}
+		return false
+	}
+	return true
+}
+
+// SwapPrefix exchanges the subtrees under prefixA and prefixB, so that every key
+// that started with prefixA starts with prefixB instead, and the other way
+// around. Since this happens within the transaction, readers of the committed
+// trees never see a state where only one of the subtrees has moved. Returns
+// true if any keys were moved. Nothing is done if one prefix is a prefix of the
+// other, once they're transformed and collated, since the subtrees would
+// overlap, in which case false is returned. The subtrees are re-rooted as for
+// RenamePrefix.
+func (t *Txn[T]) SwapPrefix(prefixA, prefixB []byte) bool {
+	if !t.checkKey("SwapPrefix", prefixA) || !t.checkKey("SwapPrefix", prefixB) {
+		return false
+	}
+	pathA, pathB := t.conf.path(prefixA), t.conf.path(prefixB)
+	if bytes.HasPrefix(pathA, pathB) || bytes.HasPrefix(pathB, pathA) {
+		return false
+	}
+	a, ok := t.renamedSubtree(prefixA, prefixB)
+	if !ok {
+		return false
+	}
+	b, ok := t.renamedSubtree(prefixB, prefixA)
+	if !ok {
+		return false
+	}
+	graft := a
+	switch {
+	case a == nil && b == nil:
+		return false
+	case a == nil:
+		graft = b
+	case b != nil:
+		// The renamed subtrees don't overlap, so merging them only joins
+		// their paths.
+		txn := a.Txn()
+		txn.Merge(b, nil)
+		graft = txn.derive()
+	}
+	if t.conf.sizer != nil && !t.fitsRenamed(graft, pathA, pathB) {
+		return false
+	}
+	t.DeletePrefix(prefixA)
+	t.DeletePrefix(prefixB)
+	t.Merge(graft, nil)
+	return true
+}
+
+// renamedSubtree returns a tree holding the keys under oldPrefix renamed to
+// start with newPrefix, or nil if there are none, for RenamePrefix and
+// SwapPrefix to merge into the transaction's tree. The nodes under oldPrefix
+// are copied with the same shape when the keys allow it, and otherwise the
+// renamed keys are inserted into the new tree. It returns false, recording the
+// error, if the renamed keys don't fit in the memory budget.
+func (t *Txn[T]) renamedSubtree(oldPrefix, newPrefix []byte) (*Tree[T], bool) {
+	oldPath := t.conf.path(oldPrefix)
+	n, start := t.root.findPrefix(oldPath)
+	if n == nil || n.count() == 0 {
+		return nil, true
+	}
+
+	txn := (&Tree[T]{generation: t.generation, conf: t.conf}).emptyBase().Txn()
+	r := renamer[T]{
+		txn:    txn,
+		cut:    len(oldPath),
+		prefix: newPrefix,
+		key:    t.conf.key(newPrefix),
+	}
+	path := concat(t.conf.path(newPrefix), n.prefix[len(oldPath)-start:])
+	if sub, ok := r.node(n, path); ok {
+		if len(path) == 0 {
+			txn.root = sub
+		} else {
+			txn.root = txn.allocNode(Node[T]{
+				inner: &innerNode[T]{
+					edges: edges[T]{{label: path[0], node: sub}},
+					count: sub.count(),
+				},
+			})
+		}
+		txn.size = sub.count()
+		txn.recount()
+		return txn.derive(), true
+	}
+
+	// The renamed keys are stored differently, so the subtree is rebuilt.
+	n.Walk(func(k []byte, v T) bool {
+		txn.Insert(concat(newPrefix, k[len(oldPath):]), v)
+		return txn.err != nil
+	})
+	if txn.err != nil {
+		if t.err == nil {
+			t.err = txn.err
+		}
+		return nil, false
+	}
+	return txn.derive(), true
+}
+
+// renamer copies subtrees for renamedSubtree, replacing the first cut bytes of
+// every key with key, the stored form of prefix.
+type renamer[T any] struct {
+	txn    *Txn[T]
+	cut    int
+	prefix []byte
+	key    []byte
+}
+
+// node returns a copy of the subtree under n with the given prefix and renamed
+// keys, or false if the tree's KeyTransformer would store one of the renamed
+// keys as something other than key followed by the rest of the old key.
+func (r *renamer[T]) node(n *Node[T], prefix []byte) (*Node[T], bool) {
+	nn := Node[T]{prefix: prefix}
+	if n.leaf != nil {
+		rest := n.leaf.key[r.cut:]
+		k := concat(r.key, rest)
+		if r.txn.conf.transform != nil && !bytes.Equal(r.txn.conf.key(concat(r.prefix, rest)), k) {
+			return nil, false
+		}
+		nn.leaf = r.txn.newLeaf(k, n.leaf.val, nil)
+	}
+	if len(n.edges()) != 0 {
+		nn.inner = &innerNode[T]{
+			edges: make(edges[T], len(n.edges())),
+			count: n.count(),
+		}
+		for i, e := range n.edges() {
+			child, ok := r.node(e.node, e.node.prefix)
+			if !ok {
+				return nil, false
+			}
+			nn.inner.edges[i] = edge[T]{label: e.label, node: child}
+		}
+	}
+	out := r.txn.allocNode(nn)
+	if r.txn.aliasLeafPrefix(out) {
+		out.prefix = leafPrefix(out.leaf, len(out.prefix))
+	}
+	return out, true
+}
+
+// fitsRenamed checks that replacing the keys under the given paths with the
+// keys of graft keeps the tree within its memory budget, counting the existing
+// keys that graft overwrites, and records ErrBudgetExceeded if it doesn't, so
+// that no keys are lost by failing to add them once the old ones are deleted.
+func (t *Txn[T]) fitsRenamed(graft *Tree[T], paths ...[]byte) bool {
+	delta := graft.bytes
+	for _, p := range paths {
+		t.root.WalkPrefix(p, func(k []byte, v T) bool {
+			delta -= t.entrySize(k, v)
+			return false
+		})
+	}
+	graft.root.Walk(func(k []byte, _ T) bool {
+		path := collateKey(t.conf.collate, k)
+		for _, p := range paths {
+			if bytes.HasPrefix(path, p) {
+				// This key is deleted anyway.
+				return false
+			}
+		}
+		if cur, ok := t.root.Get(path); ok {
+			delta -= t.entrySize(k, cur)
+		}
+		return false
+	})
+	if delta > 0 && t.bytes+delta > t.conf.budget {
+		if t.err == nil {
+			t.err = ErrBudgetExceeded
+		}
+		return false
+	}
+	return true
+}
+
 // Root returns the current root of the radix tree within this
 // transaction. The root is not safe across insert and delete operations,
 // but can be used to read the current state during a transaction.
@@ -507,44 +1580,136 @@ func (t *Txn[T]) Root() *Node[T] {
 // Get is used to lookup a specific key, returning
 // the value and if it was found
 func (t *Txn[T]) Get(k []byte) (T, bool) {
-	return t.root.Get(k)
+	if t.conf.stats != nil {
+		t.conf.stats.record(accessGet, t.conf.key(k))
+	}
+	return t.root.Get(t.conf.path(k))
+}
+
+// GetMeta is used to lookup the metadata of a specific key, returning the
+// metadata and if it was found. Leaves written during this transaction report
+// the generation the transaction will be committed as.
+func (t *Txn[T]) GetMeta(k []byte) (LeafMeta, bool) {
+	return t.root.GetMeta(t.conf.path(k))
 }
 
 // GetWatch is used to lookup a specific key, returning
 // the watch channel, value and if it was found
 func (t *Txn[T]) GetWatch(k []byte) (<-chan struct{}, T, bool) {
-	return t.root.GetWatch(k)
+	return t.root.GetWatch(t.conf.path(k))
 }
 
 // Commit is used to finalize the transaction and return a new tree. If mutation
-// tracking is turned on then notifications will also be issued.
+// tracking is turned on then notifications will also be issued. Any hooks set
+// with WithCommitHooks are run around the notifications.
 func (t *Txn[T]) Commit() *Tree[T] {
-	nt := t.CommitOnly()
-	if t.trackMutate {
-		t.Notify()
-	}
+	nt := t.commitOnly("Commit")
+	t.Notify()
 	return nt
 }
 
+// CommitWith is like Commit, but first calls validate with the root of the
+// tree that would be committed, so that constraints on the contents of the
+// tree can be checked in one place rather than by every writer. If validate
+// returns an error, the transaction isn't committed and nothing is published
+// or notified, and the error is returned. The transaction can then still be
+// written to and committed, or rolled back.
+func (t *Txn[T]) CommitWith(validate func(newRoot *Node[T]) error) (*Tree[T], error) {
+	if !t.rolledBack && !t.committed {
+		if err := validate(t.root); err != nil {
+			return nil, err
+		}
+	}
+	nt := t.commitOnly("CommitWith")
+	t.Notify()
+	return nt, nil
+}
+
 // CommitOnly is used to finalize the transaction and return a new tree, but
 // does not issue any notifications until Notify is called.
 func (t *Txn[T]) CommitOnly() *Tree[T] {
-	nt := &Tree[T]{t.root, t.size}
-	t.writable = nil
+	return t.commitOnly("CommitOnly")
+}
+
+// commitOnly implements CommitOnly, reporting misuse against the given
+// operation name. If the misuse policy rejects the commit, a tree is still
+// returned so callers get a usable result, but nothing new is committed: it's
+// the tree from the previous commit, or the base tree if the transaction was
+// rolled back, and the hooks aren't run.
+func (t *Txn[T]) commitOnly(op string) *Tree[T] {
+	first := !t.committed
+	if !t.checkUse(op) {
+		if t.result != nil {
+			return t.result
+		}
+		return t.base
+	}
+	nt := t.finish(t.generation + 1)
+	if first {
+		t.result = nt
+		if fn := t.conf.hooks.OnBeforePublish; fn != nil {
+			fn(nt)
+		}
+		if t.conf.hooks.OnAfterPublish != nil {
+			t.published = nt
+		}
+	}
 	return nt
 }
 
+// derive finalizes a transaction that builds a tree derived from another one,
+// such as by Filter or DeepCopy, and returns the tree. It isn't a new version
+// of the tree the transaction was started from, so unlike Commit it keeps that
+// tree's generation and doesn't run the hooks. These transactions never track
+// mutations, so there's nothing to notify.
+func (t *Txn[T]) derive() *Tree[T] {
+	return t.finish(t.generation)
+}
+
+// finish marks the transaction as committed and returns its tree, stamped
+// with the given generation.
+func (t *Txn[T]) finish(generation uint64) *Tree[T] {
+	t.committed = true
+	nt := &Tree[T]{
+		root:       t.root,
+		size:       t.size,
+		bytes:      t.bytes,
+		hash:       t.hash,
+		generation: generation,
+		version:    nextVersion(),
+		conf:       t.conf,
+		arena:      t.arena,
+	}
+	t.releaseWritable()
+	return nt
+}
+
+// afterPublish calls the OnAfterPublish hook for the committed tree, if it
+// hasn't been called yet.
+func (t *Txn[T]) afterPublish() {
+	if nt := t.published; nt != nil {
+		t.published = nil
+		t.conf.hooks.OnAfterPublish(t.base, nt)
+	}
+}
+
 // slowNotify does a complete comparison of the before and after trees in order
 // to trigger notifications. This doesn't require any additional state but it
 // is very expensive to compute.
 func (t *Txn[T]) slowNotify() {
+	t.slowNotifyWith(&notifier{ctx: context.Background()})
+}
+
+// slowNotifyWith is slowNotify closing the channels with the given notifier,
+// returning false if it was stopped by the notifier's context.
+func (t *Txn[T]) slowNotifyWith(nf *notifier) bool {
 	snapIter := t.snap.rawIterator()
 	rootIter := t.root.rawIterator()
 	for snapIter.Front() != nil || rootIter.Front() != nil {
 		// If we've exhausted the nodes in the old snapshot, we know
 		// there's nothing remaining to notify.
 		if snapIter.Front() == nil {
-			return
+			return true
 		}
 		snapElem := snapIter.Front()
 
@@ -553,9 +1718,11 @@ func (t *Txn[T]) slowNotify() {
 		// know from the loop condition there's something in the old
 		// snapshot.
 		if rootIter.Front() == nil {
-			close(snapElem.mutateCh)
-			if snapElem.isLeaf() {
-				close(snapElem.leaf.mutateCh)
+			if !nf.close(snapElem.mutateCh) {
+				return false
+			}
+			if snapElem.isLeaf() && !nf.close(snapElem.leaf.mutateCh) {
+				return false
 			}
 			snapIter.Next()
 			continue
@@ -568,9 +1735,11 @@ func (t *Txn[T]) slowNotify() {
 		// If the snapshot is behind the root, then we must have deleted
 		// this node during the transaction.
 		if cmp < 0 {
-			close(snapElem.mutateCh)
-			if snapElem.isLeaf() {
-				close(snapElem.leaf.mutateCh)
+			if !nf.close(snapElem.mutateCh) {
+				return false
+			}
+			if snapElem.isLeaf() && !nf.close(snapElem.leaf.mutateCh) {
+				return false
 			}
 			snapIter.Next()
 			continue
@@ -587,42 +1756,241 @@ func (t *Txn[T]) slowNotify() {
 		// node and possibly the leaf.
 		rootElem := rootIter.Front()
 		if snapElem != rootElem {
-			close(snapElem.mutateCh)
-			if snapElem.leaf != nil && (snapElem.leaf != rootElem.leaf) {
-				close(snapElem.leaf.mutateCh)
+			if !nf.close(snapElem.mutateCh) {
+				return false
+			}
+			if snapElem.leaf != nil && (snapElem.leaf != rootElem.leaf) && !nf.close(snapElem.leaf.mutateCh) {
+				return false
 			}
 		}
 		snapIter.Next()
 		rootIter.Next()
 	}
+	return true
+}
+
+// prefixNotify notifies the watches on the nodes and leaves under the prefix
+// set by TrackMutatePrefix, returning false if it was stopped by the
+// notifier's context.
+func (t *Txn[T]) prefixNotify(nf *notifier) bool {
+	n, path := t.snap, []byte{}
+	for search := t.trackPrefix; len(search) > 0; {
+		_, child := n.getEdge(search[0])
+		switch {
+		case child == nil:
+			return true
+		case bytes.HasPrefix(search, child.prefix):
+			search = search[len(child.prefix):]
+		case bytes.HasPrefix(child.prefix, search):
+			search = nil
+		default:
+			return true
+		}
+		path = append(path, child.prefix...)
+		n = child
+	}
+	return diffNotify(nf, n, t.root, path)
+}
+
+// nodeAt returns the node under n whose path, relative to n, is exactly path,
+// or nil if there isn't one.
+func nodeAt[T any](n *Node[T], path []byte) *Node[T] {
+	for n != nil && len(path) > 0 {
+		_, child := n.getEdge(path[0])
+		if child == nil || !bytes.HasPrefix(path, child.prefix) {
+			return nil
+		}
+		path = path[len(child.prefix):]
+		n = child
+	}
+	return n
+}
+
+// diffNotify notifies the watches on the nodes and leaves under old that were
+// replaced or removed in the new tree, in the same way as slowNotify, where the
+// path of old is the path of anchor in the new tree followed by rest. Subtrees
+// that are the same in both trees are skipped. It returns false if it was
+// stopped by the notifier's context.
+func diffNotify[T any](nf *notifier, old, anchor *Node[T], rest []byte) bool {
+	cur := nodeAt(anchor, rest)
+	if old == cur {
+		return true
+	}
+	if !nf.close(old.mutateCh) {
+		return false
+	}
+	if old.leaf != nil && (cur == nil || cur.leaf != old.leaf) && !nf.close(old.leaf.mutateCh) {
+		return false
+	}
+	if cur != nil {
+		// The children's paths are relative to this node.
+		anchor, rest = cur, nil
+	}
+	for _, e := range old.edges() {
+		path := e.node.prefix
+		if len(rest) != 0 {
+			path = concat(rest, path)
+		}
+		if !diffNotify(nf, e.node, anchor, path) {
+			return false
+		}
+	}
+	return true
+}
+
+// notifier closes the channels for a notification, stopping once its context
+// is done.
+type notifier struct {
+	ctx  context.Context
+	sent int
+	err  error
+
+	// resumed is set if an earlier notification was stopped part way
+	// through a full tree compare, which will find channels it already
+	// closed.
+	resumed bool
+}
+
+// stopped returns true if the context is done, checking it every
+// ctxCheckInterval notifications.
+func (nf *notifier) stopped() bool {
+	if nf.sent%ctxCheckInterval == 0 {
+		nf.err = nf.ctx.Err()
+	}
+	return nf.err != nil
+}
+
+// close closes ch, returning false instead if the context is done.
+func (nf *notifier) close(ch chan struct{}) bool {
+	if nf.stopped() {
+		return false
+	}
+	if nf.resumed {
+		select {
+		case <-ch:
+			return true
+		default:
+		}
+	}
+	close(ch)
+	nf.sent++
+	return true
 }
 
 // Notify is used along with TrackMutate to trigger notifications. This must
 // only be done once a transaction is committed via CommitOnly, and it is called
-// automatically by Commit.
+// automatically by Commit. The first call after a commit also runs the
+// OnAfterPublish hook set with WithCommitHooks, once the notifications are
+// done.
 func (t *Txn[T]) Notify() {
-	if !t.trackMutate {
+	t.notify(&notifier{ctx: context.Background()})
+}
+
+// NotifyCtx is like Notify, but checks ctx every few hundred channels and stops
+// closing them if it's done, returning the number of channels it closed, and
+// calls to the function set by TrackMutateFunc it made, along with ctx.Err().
+// This bounds the time spent notifying after mutation tracking overflowed,
+// which requires a full comparison of the trees. The remaining notifications
+// are sent by the next call to Notify or NotifyCtx, and the OnAfterPublish hook
+// only runs once all of them have been.
+func (t *Txn[T]) NotifyCtx(ctx context.Context) (int, error) {
+	nf := notifier{ctx: ctx}
+	t.notify(&nf)
+	return nf.sent, nf.err
+}
+
+func (t *Txn[T]) notify(nf *notifier) {
+	if t.trackMutate && !t.notifyChannels(nf) {
 		return
 	}
+	if t.mutateFn != nil && !t.notifyFunc(nf) {
+		return
+	}
+	t.afterPublish()
+}
+
+// notifyChannels closes the tracked channels, returning false if it was
+// stopped by the notifier's context.
+func (t *Txn[T]) notifyChannels(nf *notifier) bool {
+	if t.trackPrefix != nil {
+		// The watches may have been notified by an earlier stopped
+		// notification, or from channels tracked before the prefix was
+		// set.
+		nf.resumed = true
+	}
 
 	// If we've overflowed the tracking state we can't use it in any way and
 	// need to do a full tree compare.
 	if t.trackOverflow {
-		t.slowNotify()
+		nf.resumed = nf.resumed || t.notifyResumed
+		if !t.slowNotifyWith(nf) {
+			t.notifyResumed = true
+			return false
+		}
+		t.notifyResumed = false
 	} else {
+		// Forget the channels as they're closed, so that a notification
+		// that's stopped can be picked up again.
+		for t.trackNumSmall > 0 {
+			if !nf.close(t.trackSmall[t.trackNumSmall-1]) {
+				return false
+			}
+			t.trackNumSmall--
+			t.trackSmall[t.trackNumSmall] = nil
+		}
 		for ch := range t.trackChannels {
-			close(ch)
+			if !nf.close(ch) {
+				return false
+			}
+			delete(t.trackChannels, ch)
 		}
 	}
+	if t.trackPrefix != nil && !t.prefixNotify(nf) {
+		return false
+	}
 
 	// Clean up the tracking state so that a re-notify is safe (will trigger
-	// the else clause above which will be a no-op).
+	// the else clause above which will be a no-op). The map was emptied as
+	// the channels were closed.
+	if t.reused && t.trackChannels != nil {
+		t.spareTrack = t.trackChannels
+	}
 	t.trackChannels = nil
+	t.resetTrackSmall()
 	t.trackOverflow = false
+	return true
+}
+
+// notifyFunc calls the function set by TrackMutateFunc with each changed key,
+// returning false if it was stopped by the notifier's context. The function is
+// removed once it's been called for all of them, so it's only called once per
+// key.
+func (t *Txn[T]) notifyFunc(nf *notifier) bool {
+	if t.mutateIter == nil {
+		t.mutateIter = NewChangedIterator(t.snap, t.root)
+	}
+	for {
+		if nf.stopped() {
+			return false
+		}
+		oldLeaf, newLeaf, ok := t.mutateIter.next()
+		if !ok {
+			break
+		}
+		if newLeaf != nil {
+			t.mutateFn(newLeaf.key)
+		} else {
+			t.mutateFn(oldLeaf.key)
+		}
+		nf.sent++
+	}
+	t.mutateFn, t.mutateIter = nil, nil
+	return true
 }
 
 // Insert is used to add or update a given key. The return provides
 // the new tree, previous value and a bool indicating if any was set.
+// As with Txn.Insert, k must not be modified afterwards.
 func (t *Tree[T]) Insert(k []byte, v T) (*Tree[T], T, bool) {
 	txn := t.Txn()
 	old, ok := txn.Insert(k, v)
@@ -645,16 +2013,177 @@ func (t *Tree[T]) DeletePrefix(k []byte) (*Tree[T], bool) {
 	return txn.Commit(), ok
 }
 
+// DeleteRange is used to delete all the keys in the range [start, end), where a
+// nil end means the range has no upper bound. Returns the new tree, and the
+// number of keys deleted.
+func (t *Tree[T]) DeleteRange(start, end []byte) (*Tree[T], int) {
+	txn := t.Txn()
+	n := txn.DeleteRange(start, end)
+	return txn.Commit(), n
+}
+
+// SwapPrefix is used to exchange the subtrees under prefixA and prefixB. Returns
+// the new tree, and a bool indicating if any keys were moved.
+func (t *Tree[T]) SwapPrefix(prefixA, prefixB []byte) (*Tree[T], bool) {
+	txn := t.Txn()
+	ok := txn.SwapPrefix(prefixA, prefixB)
+	return txn.Commit(), ok
+}
+
+// RenamePrefix is used to move every key under oldPrefix to newPrefix. Returns
+// the new tree, and a bool indicating if any keys were moved.
+func (t *Tree[T]) RenamePrefix(oldPrefix, newPrefix []byte) (*Tree[T], bool) {
+	txn := t.Txn()
+	ok := txn.RenamePrefix(oldPrefix, newPrefix)
+	return txn.Commit(), ok
+}
+
+// Move is used to move the value of oldKey to newKey. Returns the new tree,
+// and a bool indicating if oldKey was set.
+func (t *Tree[T]) Move(oldKey, newKey []byte) (*Tree[T], bool) {
+	txn := t.Txn()
+	ok := txn.Move(oldKey, newKey)
+	return txn.Commit(), ok
+}
+
 // Root returns the root node of the tree which can be used for richer
 // query operations.
 func (t *Tree[T]) Root() *Node[T] {
 	return t.root
 }
 
+// LongestCommonPrefix returns the longest prefix shared by all the keys in the
+// tree, or nil if it's empty. See Node.LongestCommonPrefix.
+func (t *Tree[T]) LongestCommonPrefix() []byte {
+	return t.root.LongestCommonPrefix()
+}
+
+// Keys returns all the keys in the tree in order, in a single allocation. The
+// keys are the ones stored in the tree, so they must not be modified.
+func (t *Tree[T]) Keys() [][]byte {
+	return t.KeysPrefix(nil)
+}
+
+// KeysPrefix is like Keys, but only returns the keys with the given prefix.
+func (t *Tree[T]) KeysPrefix(prefix []byte) [][]byte {
+	n := t.root.prefixNode(t.conf.path(prefix))
+	if n == nil || n.count() == 0 {
+		return nil
+	}
+	out := make([][]byte, 0, n.count())
+	recursiveWalk(n, func(k []byte, _ T) bool {
+		out = append(out, k)
+		return false
+	})
+	return out
+}
+
+// Values returns the values of all the keys in the tree, in the order of their
+// keys, in a single allocation.
+func (t *Tree[T]) Values() []T {
+	return t.ValuesPrefix(nil)
+}
+
+// ValuesPrefix is like Values, but only returns the values of the keys with
+// the given prefix.
+func (t *Tree[T]) ValuesPrefix(prefix []byte) []T {
+	n := t.root.prefixNode(t.conf.path(prefix))
+	if n == nil || n.count() == 0 {
+		return nil
+	}
+	out := make([]T, 0, n.count())
+	recursiveWalk(n, func(_ []byte, v T) bool {
+		out = append(out, v)
+		return false
+	})
+	return out
+}
+
+// ToMap returns a map of all the keys in the tree to their values.
+func (t *Tree[T]) ToMap() map[string]T {
+	return t.ToMapPrefix(nil)
+}
+
+// ToMapPrefix is like ToMap, but only includes the keys with the given prefix.
+func (t *Tree[T]) ToMapPrefix(prefix []byte) map[string]T {
+	n := t.root.prefixNode(t.conf.path(prefix))
+	if n == nil {
+		return map[string]T{}
+	}
+	out := make(map[string]T, n.count())
+	recursiveWalk(n, func(k []byte, v T) bool {
+		out[string(k)] = v
+		return false
+	})
+	return out
+}
+
+// ReadMulti calls fn once for each of the given prefixes, in order, with an
+// iterator seeked to that prefix. All the iterators come from the same root,
+// so the reads are consistent with each other even if the caller is swapping
+// in newer versions of the tree concurrently.
+func (t *Tree[T]) ReadMulti(prefixes [][]byte, fn func(prefix []byte, it *Iterator[T])) {
+	root := t.root
+	for _, prefix := range prefixes {
+		it := &Iterator[T]{node: root, collate: t.conf.collate, transform: t.conf.transform}
+		it.SeekPrefix(prefix)
+		fn(prefix, it)
+	}
+}
+
 // Get is used to lookup a specific key, returning
 // the value and if it was found
 func (t *Tree[T]) Get(k []byte) (T, bool) {
-	return t.root.Get(k)
+	if t.conf.stats != nil {
+		t.conf.stats.record(accessGet, t.conf.key(k))
+	}
+	return t.root.Get(t.conf.path(k))
+}
+
+// GetString is like Get, but takes the key as a string without converting it
+// to a byte slice, unless the tree transforms, collates or samples its keys.
+func (t *Tree[T]) GetString(k string) (T, bool) {
+	if t.conf.collate != nil || t.conf.transform != nil || t.conf.stats != nil {
+		return t.Get([]byte(k))
+	}
+	return t.root.GetString(k)
+}
+
+// GetMeta is used to lookup the metadata of a specific key, returning the
+// metadata and if it was found. Metadata is only recorded for trees created
+// with the WithLeafMeta option.
+func (t *Tree[T]) GetMeta(k []byte) (LeafMeta, bool) {
+	return t.root.GetMeta(t.conf.path(k))
+}
+
+// LongestPrefix is like Get, but instead of an exact match, it returns the
+// longest key in the tree that's a prefix of k. See Node.LongestPrefix.
+func (t *Tree[T]) LongestPrefix(k []byte) ([]byte, T, bool) {
+	return t.root.LongestPrefix(t.conf.path(k))
+}
+
+// GetPath returns every key in the tree that's a prefix of k, including k
+// itself, with their values, in order from the shortest. See Node.GetPath.
+func (t *Tree[T]) GetPath(k []byte) []KV[T] {
+	return t.root.GetPath(t.conf.path(k))
+}
+
+// Floor returns the largest key in the tree that is less than or equal to k,
+// with its value. See Node.Floor.
+func (t *Tree[T]) Floor(k []byte) ([]byte, T, bool) {
+	return t.root.Floor(t.conf.path(k))
+}
+
+// Ceiling returns the smallest key in the tree that is greater than or equal
+// to k, with its value. See Node.Ceiling.
+func (t *Tree[T]) Ceiling(k []byte) ([]byte, T, bool) {
+	return t.root.Ceiling(t.conf.path(k))
+}
+
+// WalkPrefix walks the keys in the tree with the given prefix, in order. See
+// Node.WalkPrefix.
+func (t *Tree[T]) WalkPrefix(prefix []byte, fn WalkFn[T]) {
+	t.root.WalkPrefix(t.conf.path(prefix), fn)
 }
 
 // longestPrefix finds the length of the shared prefix
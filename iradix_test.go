@@ -4,10 +4,13 @@
 package iradix
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 	"testing/quick"
 
@@ -17,8 +20,13 @@ import (
 
 func CopyTree[T any](t *Tree[T]) *Tree[T] {
 	nt := &Tree[T]{
-		root: CopyNode(t.root),
-		size: t.size,
+		root:       CopyNode(t.root),
+		size:       t.size,
+		bytes:      t.bytes,
+		hash:       t.hash,
+		generation: t.generation,
+		version:    t.version,
+		conf:       t.conf,
 	}
 	return nt
 }
@@ -35,11 +43,14 @@ func CopyNode[T any](n *Node[T]) *Node[T] {
 	if n.leaf != nil {
 		nn.leaf = CopyLeaf(n.leaf)
 	}
-	if len(n.edges) != 0 {
-		nn.edges = make([]edge[T], len(n.edges))
-		for idx, ed := range n.edges {
-			nn.edges[idx].label = ed.label
-			nn.edges[idx].node = CopyNode(ed.node)
+	if n.inner != nil {
+		nn.inner = &innerNode[T]{count: n.inner.count}
+		if len(n.inner.edges) != 0 {
+			nn.inner.edges = make([]edge[T], len(n.inner.edges))
+			for idx, ed := range n.inner.edges {
+				nn.inner.edges[idx].label = ed.label
+				nn.inner.edges[idx].node = CopyNode(ed.node)
+			}
 		}
 	}
 	return nn
@@ -50,6 +61,7 @@ func CopyLeaf[T any](l *leafNode[T]) *leafNode[T] {
 		mutateCh: l.mutateCh,
 		key:      l.key,
 		val:      l.val,
+		meta:     l.meta,
 	}
 	return ll
 }
@@ -482,6 +494,81 @@ func TestLongestPrefix(t *testing.T) {
 		if string(m) != test.out {
 			t.Fatalf("mis-match: %v %v", m, test)
 		}
+
+		ms, _, ok := root.LongestPrefixString(test.inp)
+		if !ok || ms != test.out {
+			t.Fatalf("mis-match: %q %v %v", ms, ok, test)
+		}
+	}
+
+	// Without the empty key there may be no match.
+	r, _, _ = r.Delete([]byte(""))
+	if m, _, ok := r.Root().LongestPrefixString("fo"); ok || m != "" {
+		t.Fatalf("bad: %q %v", m, ok)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		r.Root().LongestPrefixString("foobarbazzap")
+	})
+	if allocs != 0 {
+		t.Fatalf("expected no allocations, got %v", allocs)
+	}
+}
+
+func TestLongestPrefix_Bounded(t *testing.T) {
+	r := New[int]()
+	for i, k := range []string{"svc/", "svc/web/", "svc/web/1", "svc/web/1/x"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	root := r.Root()
+
+	k := []byte("svc/web/1/x/y")
+	for _, c := range []struct {
+		max int
+		out string
+	}{
+		{-1, ""}, {3, ""}, {4, "svc/"}, {8, "svc/web/"}, {10, "svc/web/1"}, {100, "svc/web/1/x"},
+	} {
+		m, _, ok := root.LongestPrefixWithin(k, c.max)
+		if ok != (c.out != "") || string(m) != c.out {
+			t.Fatalf("%d: got %q %v", c.max, m, ok)
+		}
+	}
+	for _, c := range []struct {
+		segments int
+		out      string
+	}{
+		{0, ""}, {1, "svc/"}, {2, "svc/web/"}, {3, "svc/web/1"}, {4, "svc/web/1/x"}, {10, "svc/web/1/x"},
+	} {
+		m, _, ok := root.LongestPrefixSegments(k, '/', c.segments)
+		if ok != (c.out != "") || string(m) != c.out {
+			t.Fatalf("%d: got %q %v", c.segments, m, ok)
+		}
+	}
+}
+
+func TestGetString(t *testing.T) {
+	r := New[int]()
+	keys := []string{"", "foo", "foobar", "foozip", "zap"}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	for i, k := range keys {
+		if v, ok := r.GetString(k); !ok || v != i {
+			t.Fatalf("bad: %q %v %v", k, v, ok)
+		}
+	}
+	for _, k := range []string{"f", "fo", "foob", "foobarbaz", "za", "zz"} {
+		if _, ok := r.GetString(k); ok {
+			t.Fatalf("unexpected match: %q", k)
+		}
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		r.GetString("foobar")
+	})
+	if allocs != 0 {
+		t.Fatalf("expected no allocations, got %v", allocs)
 	}
 }
 
@@ -1873,3 +1960,1498 @@ func TestClone(t *testing.T) {
 		t.Fatalf("bad baz in t2")
 	}
 }
+
+func TestGeneration(t *testing.T) {
+	r := New[int]()
+	if g := r.Generation(); g != 0 {
+		t.Fatalf("expected generation 0, got %d", g)
+	}
+
+	r, _, _ = r.Insert([]byte("foo"), 1)
+	r, _, _ = r.Insert([]byte("bar"), 2)
+	if g := r.Generation(); g != 2 {
+		t.Fatalf("expected generation 2, got %d", g)
+	}
+
+	// A transaction with several writes only advances the generation once.
+	txn := r.Txn()
+	txn.Insert([]byte("baz"), 3)
+	txn.Delete([]byte("foo"))
+	r2 := txn.Commit()
+	if g := r2.Generation(); g != 3 {
+		t.Fatalf("expected generation 3, got %d", g)
+	}
+
+	// Clones inherit the generation of the transaction they came from.
+	txn = r2.Txn()
+	clone := txn.Clone()
+	clone.Insert([]byte("zip"), 4)
+	if g := clone.Commit().Generation(); g != 4 {
+		t.Fatalf("expected generation 4, got %d", g)
+	}
+
+	// The original tree is unaffected.
+	if g := r.Generation(); g != 2 {
+		t.Fatalf("expected generation 2, got %d", g)
+	}
+}
+
+func TestLeafMeta(t *testing.T) {
+	r := New[int](WithLeafMeta())
+
+	r, _, _ = r.Insert([]byte("foo"), 1) // generation 1
+	r, _, _ = r.Insert([]byte("bar"), 2) // generation 2
+	r, _, _ = r.Insert([]byte("foo"), 3) // generation 3
+
+	txn := r.Txn()
+	txn.Insert([]byte("baz"), 4)
+	if meta, ok := txn.GetMeta([]byte("baz")); !ok || meta != (LeafMeta{4, 4}) {
+		t.Fatalf("bad: %v %v", meta, ok)
+	}
+	r = txn.Commit()
+
+	cases := map[string]LeafMeta{
+		"foo": {Created: 1, Modified: 3},
+		"bar": {Created: 2, Modified: 2},
+		"baz": {Created: 4, Modified: 4},
+	}
+	for k, want := range cases {
+		got, ok := r.GetMeta([]byte(k))
+		if !ok {
+			t.Fatalf("missing meta for %q", k)
+		}
+		if got != want {
+			t.Fatalf("bad meta for %q: got %v, want %v", k, got, want)
+		}
+	}
+	if _, ok := r.GetMeta([]byte("nope")); ok {
+		t.Fatalf("unexpected meta for missing key")
+	}
+
+	// Trees without the option don't record anything.
+	plain := New[int]()
+	plain, _, _ = plain.Insert([]byte("foo"), 1)
+	if _, ok := plain.GetMeta([]byte("foo")); ok {
+		t.Fatalf("unexpected meta without WithLeafMeta")
+	}
+}
+
+func TestIntern(t *testing.T) {
+	type config struct {
+		name string
+	}
+	canonical := make(map[config]*config)
+	var calls int
+	intern := func(c *config) *config {
+		calls++
+		if existing, ok := canonical[*c]; ok {
+			return existing
+		}
+		canonical[*c] = c
+		return c
+	}
+
+	r := New[*config](WithIntern(intern))
+	txn := r.Txn()
+	for i := 0; i < 100; i++ {
+		txn.Insert([]byte(fmt.Sprintf("key-%03d", i)), &config{name: fmt.Sprintf("cfg-%d", i%3)})
+	}
+	r = txn.Commit()
+
+	if calls != 100 {
+		t.Fatalf("expected 100 calls, got %d", calls)
+	}
+	if len(canonical) != 3 {
+		t.Fatalf("expected 3 distinct values, got %d", len(canonical))
+	}
+	a, _ := r.Get([]byte("key-000"))
+	b, _ := r.Get([]byte("key-003"))
+	if a != b {
+		t.Fatalf("expected interned values to share a pointer")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected a panic for a mismatched intern func")
+		}
+	}()
+	New[int](WithIntern(func(s string) string { return s }))
+}
+
+// checkCounts verifies the leaf counts of every node under n and returns the
+// number of leaves found.
+func checkCounts[T any](t *testing.T, n *Node[T]) int {
+	t.Helper()
+	leaves := 0
+	if n.leaf != nil {
+		leaves++
+	}
+	for _, e := range n.edges() {
+		leaves += checkCounts(t, e.node)
+	}
+	if n.count() != leaves {
+		t.Fatalf("bad count for node %q: got %d, want %d", n.prefix, n.count(), leaves)
+	}
+	if n.inner != nil && len(n.inner.edges) == 0 {
+		t.Fatalf("node %q has no children but isn't compact", n.prefix)
+	}
+	return leaves
+}
+
+func TestLeafCounts(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	randKey := func() []byte {
+		b := make([]byte, rnd.Intn(6))
+		for i := range b {
+			b[i] = "abcd"[rnd.Intn(4)]
+		}
+		return b
+	}
+
+	r := New[int]()
+	for i := 0; i < 2000; i++ {
+		txn := r.Txn()
+		for j := 0; j < 5; j++ {
+			switch rnd.Intn(10) {
+			case 0:
+				txn.DeletePrefix(randKey())
+			case 1, 2, 3:
+				txn.Delete(randKey())
+			default:
+				txn.Insert(randKey(), i)
+			}
+		}
+		r = txn.Commit()
+		if got := checkCounts(t, r.Root()); got != r.Len() {
+			t.Fatalf("leaf count %d doesn't match size %d", got, r.Len())
+		}
+	}
+}
+
+func TestDeletePrefix_WritableNode(t *testing.T) {
+	r := New[int]()
+	for _, k := range []string{"a", "ab", "b"} {
+		r, _, _ = r.Insert([]byte(k), 0)
+	}
+	watches := make(map[string]<-chan struct{})
+	for _, k := range []string{"a", "ab", "b"} {
+		watches[k], _, _ = r.Root().GetWatch([]byte(k))
+	}
+
+	// Make the root writable in this transaction before deleting everything
+	// under it.
+	txn := r.Txn()
+	txn.TrackMutate(true)
+	txn.Insert([]byte("c"), 0)
+	if !txn.DeletePrefix(nil) {
+		t.Fatalf("expected a deletion")
+	}
+	r = txn.Commit()
+	if r.Len() != 0 {
+		t.Fatalf("bad len: %d", r.Len())
+	}
+	for k, ch := range watches {
+		select {
+		case <-ch:
+		default:
+			t.Fatalf("expected %q to be notified", k)
+		}
+	}
+}
+
+func TestReadMulti(t *testing.T) {
+	r := New[int]()
+	for i, k := range []string{"a/1", "a/2", "b/1", "c/1", "c/2", "c/3"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	got := make(map[string][]string)
+	prefixes := [][]byte{[]byte("a/"), []byte("c/"), []byte("d/")}
+	r.ReadMulti(prefixes, func(prefix []byte, it *Iterator[int]) {
+		// Writing to the tree while reading must not affect the results.
+		r, _, _ = r.Insert([]byte("c/4"), 0)
+
+		for k, _, ok := it.Next(); ok; k, _, ok = it.Next() {
+			got[string(prefix)] = append(got[string(prefix)], string(k))
+		}
+	})
+
+	want := map[string][]string{
+		"a/": {"a/1", "a/2"},
+		"c/": {"c/1", "c/2", "c/3"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+// checkLeafPrefix fails the test if the prefix of the leaf-only node n doesn't
+// share memory with the end of its leaf's key.
+func checkLeafPrefix[T any](t *testing.T, n *Node[T]) {
+	t.Helper()
+	if len(n.prefix) == 0 {
+		return
+	}
+	key := n.leaf.key
+	if &n.prefix[len(n.prefix)-1] != &key[len(key)-1] {
+		t.Fatalf("prefix %q of leaf %q is not stored in its key", n.prefix, key)
+	}
+}
+
+func TestLeafOnlyPrefix(t *testing.T) {
+	check := func(r *Tree[int]) {
+		t.Helper()
+		var walk func(n *Node[int])
+		walk = func(n *Node[int]) {
+			if n.isLeafOnly() {
+				checkLeafPrefix(t, n)
+			}
+			for _, e := range n.edges() {
+				walk(e.node)
+			}
+		}
+		walk(r.Root())
+	}
+
+	r := New[int]()
+	for _, k := range []string{"foo", "foobar", "foobaz", "zip", "zipper"} {
+		r, _, _ = r.Insert([]byte(k), 0)
+	}
+	check(r)
+
+	// Updating a leaf moves the prefix over to the new key.
+	r, _, _ = r.Insert([]byte("foobar"), 1)
+	check(r)
+
+	// Deleting "foo" merges its node with the "ba" node below it, and then
+	// deleting "foobaz" merges that with the leaf-only "r" node.
+	r, _, _ = r.Delete([]byte("foo"))
+	check(r)
+	r, _, _ = r.Delete([]byte("foobaz"))
+	check(r)
+	r, _, _ = r.Delete([]byte("zip"))
+	check(r)
+
+	// Copies made by a transaction also share the key.
+	txn := r.Txn()
+	txn.Insert([]byte("zipped"), 2)
+	r = txn.Commit()
+	check(r)
+	verifyTree(t, []string{"foobar", "zipped", "zipper"}, r)
+}
+
+func TestMemoryBudget(t *testing.T) {
+	r := New[string](WithMemoryBudget(20, func(v string) int { return len(v) }))
+
+	txn := r.Txn()
+	txn.Insert([]byte("foo"), "abc")
+	txn.Insert([]byte("bar"), "abcd")
+	if txn.Bytes() != 13 {
+		t.Fatalf("bad bytes: %d", txn.Bytes())
+	}
+
+	// This would take the total to 21.
+	if _, ok := txn.Insert([]byte("baz"), "abcde"); ok {
+		t.Fatalf("should not update")
+	}
+	if err := txn.Err(); err != ErrBudgetExceeded {
+		t.Fatalf("bad err: %v", err)
+	}
+	if _, ok := txn.Get([]byte("baz")); ok {
+		t.Fatalf("insert should not be applied")
+	}
+	if txn.Bytes() != 13 {
+		t.Fatalf("bad bytes: %d", txn.Bytes())
+	}
+
+	// Growing an existing entry within the budget is fine, as is shrinking
+	// one.
+	txn.Insert([]byte("foo"), "abcdefghi")
+	if txn.Bytes() != 19 {
+		t.Fatalf("bad bytes: %d", txn.Bytes())
+	}
+	txn.Insert([]byte("foo"), "a")
+	if txn.Bytes() != 11 {
+		t.Fatalf("bad bytes: %d", txn.Bytes())
+	}
+	r = txn.Commit()
+	if r.Bytes() != 11 || r.Len() != 2 {
+		t.Fatalf("bad tree: %d bytes, %d keys", r.Bytes(), r.Len())
+	}
+
+	// Deletes free up space.
+	txn = r.Txn()
+	txn.Delete([]byte("bar"))
+	if txn.Bytes() != 4 {
+		t.Fatalf("bad bytes: %d", txn.Bytes())
+	}
+	txn.Insert([]byte("foo/a"), "aa")
+	txn.Insert([]byte("foo/b"), "bb")
+	if txn.Err() != nil || txn.Bytes() != 18 {
+		t.Fatalf("bad: %v %d", txn.Err(), txn.Bytes())
+	}
+	txn.DeletePrefix([]byte("foo/"))
+	if txn.Bytes() != 4 {
+		t.Fatalf("bad bytes: %d", txn.Bytes())
+	}
+
+	// Trees without a budget don't track anything.
+	u, _, _ := New[string]().Insert([]byte("foo"), "bar")
+	if u.Bytes() != 0 {
+		t.Fatalf("bad bytes: %d", u.Bytes())
+	}
+}
+
+func TestDeletePrefix_Deep(t *testing.T) {
+	// Every key in the chain is a prefix of the next, so that part of the
+	// tree is as deep as it is large. The wide part makes sure tracking
+	// overflows part way through the walk.
+	r := New[int]()
+	txn := r.Txn()
+	key := []byte("x")
+	for i := 0; i < 2000; i++ {
+		key = append(key, 'a'+byte(i%26))
+		txn.Insert(append([]byte(nil), key...), i)
+	}
+	for i := 0; i < 10000; i++ {
+		txn.Insert([]byte(fmt.Sprintf("x/%05d", i)), i)
+	}
+	txn.Insert([]byte("y"), 0)
+	r = txn.Commit()
+
+	for _, track := range []bool{false, true} {
+		leafCh, _, _ := r.Root().GetWatch(key)
+		otherCh, _, _ := r.Root().GetWatch([]byte("y"))
+
+		txn := r.Txn()
+		txn.TrackMutate(track)
+		if !txn.DeletePrefix([]byte("x")) {
+			t.Fatalf("expected delete")
+		}
+		if cap(txn.walkStack) > 0 && txn.walkStack[:cap(txn.walkStack)][0] != nil {
+			t.Fatalf("walk stack retains nodes")
+		}
+		if nt := txn.Commit(); nt.Len() != 1 {
+			t.Fatalf("bad len: %d", nt.Len())
+		}
+
+		select {
+		case <-leafCh:
+			if !track {
+				t.Fatalf("unexpected notification")
+			}
+		default:
+			if track {
+				t.Fatalf("expected notification")
+			}
+		}
+		select {
+		case <-otherCh:
+			t.Fatalf("unrelated key notified")
+		default:
+		}
+	}
+}
+
+func TestTrackChannel_Small(t *testing.T) {
+	txn := New[int]().Txn()
+	txn.TrackMutate(true)
+
+	var chs []chan struct{}
+	for i := 0; i < trackSmallSize+4; i++ {
+		ch := make(chan struct{})
+		chs = append(chs, ch)
+
+		// Duplicates must only be tracked once, before and after
+		// moving to the map.
+		txn.trackChannel(ch)
+		txn.trackChannel(ch)
+
+		if i < trackSmallSize {
+			if txn.trackChannels != nil || txn.trackNumSmall != i+1 {
+				t.Fatalf("%d: expected inline tracking", i)
+			}
+		} else if len(txn.trackChannels) != i+1 || txn.trackNumSmall != 0 {
+			t.Fatalf("%d: expected map tracking", i)
+		}
+	}
+
+	txn.Notify()
+	for i, ch := range chs {
+		select {
+		case <-ch:
+		default:
+			t.Fatalf("%d: not notified", i)
+		}
+	}
+	if txn.trackChannels != nil || txn.trackNumSmall != 0 {
+		t.Fatalf("tracking state not reset")
+	}
+
+	// A repeated notify is a no-op.
+	txn.Notify()
+}
+
+func BenchmarkTrackedCommit_SingleKey(b *testing.B) {
+	r := New[int]()
+	for i := 0; i < 1000; i++ {
+		r, _, _ = r.Insert([]byte(fmt.Sprintf("key/%04d", i)), i)
+	}
+	key := []byte("key/0500")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		txn := r.Txn()
+		txn.TrackMutate(true)
+		txn.Insert(key, i)
+		r = txn.Commit()
+	}
+}
+
+func TestRenamePrefix(t *testing.T) {
+	r := New[int]()
+	for i, k := range []string{"a", "tenant/a/1", "tenant/a/2", "tenant/a", "tenant/ab", "tenant/b/1", "z"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	cases := []struct {
+		old, new string
+		ok       bool
+		expect   []string
+	}{
+		{"tenant/a/", "tenant/c/", true, []string{"a", "tenant/a", "tenant/ab", "tenant/b/1", "tenant/c/1", "tenant/c/2", "z"}},
+		{"tenant/a", "t/", true, []string{"a", "t/", "t//1", "t//2", "t/b", "tenant/b/1", "z"}},
+		{"tenant/a/", "tenant/a/x/", true, []string{"a", "tenant/a", "tenant/a/x/1", "tenant/a/x/2", "tenant/ab", "tenant/b/1", "z"}},
+		{"tenant/a/", "tenant/b/", true, []string{"a", "tenant/a", "tenant/ab", "tenant/b/1", "tenant/b/2", "z"}},
+		{"", "x", true, []string{"xa", "xtenant/a", "xtenant/a/1", "xtenant/a/2", "xtenant/ab", "xtenant/b/1", "xz"}},
+		{"missing/", "other/", false, []string{"a", "tenant/a", "tenant/a/1", "tenant/a/2", "tenant/ab", "tenant/b/1", "z"}},
+	}
+	for _, c := range cases {
+		nr, ok := r.RenamePrefix([]byte(c.old), []byte(c.new))
+		if ok != c.ok {
+			t.Fatalf("%q -> %q: bad ok %v", c.old, c.new, ok)
+		}
+		verifyTree(t, c.expect, nr)
+		if nr.Len() != len(c.expect) {
+			t.Fatalf("%q -> %q: bad len %d", c.old, c.new, nr.Len())
+		}
+		checkCounts(t, nr.Root())
+	}
+
+	// Values move with their keys, and overwrite existing ones.
+	nr, _ := r.RenamePrefix([]byte("tenant/a/"), []byte("tenant/b/"))
+	if v, _ := nr.Get([]byte("tenant/b/1")); v != 1 {
+		t.Fatalf("bad: %v", v)
+	}
+	if v, _ := nr.Get([]byte("tenant/b/2")); v != 2 {
+		t.Fatalf("bad: %v", v)
+	}
+
+	// The subtrees off the two paths are shared with the old tree.
+	nr, _ = r.RenamePrefix([]byte("tenant/a/"), []byte("other/"))
+	if nr.Root().Child('z') != r.Root().Child('z') {
+		t.Fatalf("expected untouched subtree to be shared")
+	}
+
+	// A rename that doesn't fit in the budget keeps the old keys.
+	r2 := New[int](WithMemoryBudget(10, func(int) int { return 0 }))
+	r2, _, _ = r2.Insert([]byte("a/1"), 1)
+	r2, _, _ = r2.Insert([]byte("a/2"), 2)
+	r2, _, _ = r2.Insert([]byte("b"), 3)
+	txn := r2.Txn()
+	if txn.RenamePrefix([]byte("a/"), []byte("abc/")) || !errors.Is(txn.Err(), ErrBudgetExceeded) {
+		t.Fatalf("expected the budget to be enforced")
+	}
+	verifyTree(t, []string{"a/1", "a/2", "b"}, txn.Commit())
+
+	// Keys that are overwritten, or deleted as part of the rename, free
+	// their space.
+	txn = r2.Txn()
+	if !txn.RenamePrefix([]byte("a/"), []byte("ab/")) || txn.Err() != nil || txn.Bytes() != 9 {
+		t.Fatalf("bad rename: %v %d", txn.Err(), txn.Bytes())
+	}
+	r2, _, _ = r2.Insert([]byte("c/1"), 4)
+	txn = r2.Txn()
+	if !txn.RenamePrefix([]byte("c/"), []byte("a/")) || txn.Err() != nil || txn.Bytes() != 7 {
+		t.Fatalf("bad rename: %v %d", txn.Err(), txn.Bytes())
+	}
+}
+
+func TestRenamePrefix_Random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randKey := func(n int) string {
+		b := make([]byte, rnd.Intn(n+1))
+		for i := range b {
+			b[i] = "ab/"[rnd.Intn(3)]
+		}
+		return string(b)
+	}
+	sizer := func(v int) int { return v % 7 }
+	hasher := func(v int) uint64 { return uint64(v) * 0x9e3779b97f4a7c15 }
+	opts := []Option{WithMemoryBudget(1<<30, sizer), WithContentHash(hasher)}
+
+	for i := 0; i < 500; i++ {
+		model := make(map[string]int)
+		r := New[int](opts...)
+		txn := r.Txn()
+		for j := rnd.Intn(20); j > 0; j-- {
+			k := randKey(5)
+			txn.Insert([]byte(k), j)
+			model[k] = j
+		}
+		r = txn.Commit()
+
+		a, b := randKey(3), randKey(3)
+		moved := make(map[string]int)
+		txn = r.Txn()
+		swap := i%2 == 1
+		var ok bool
+		if swap {
+			ok = txn.SwapPrefix([]byte(a), []byte(b))
+			if !strings.HasPrefix(a, b) && !strings.HasPrefix(b, a) {
+				for k, v := range model {
+					if strings.HasPrefix(k, a) {
+						moved[b+k[len(a):]] = v
+						delete(model, k)
+					} else if strings.HasPrefix(k, b) {
+						moved[a+k[len(b):]] = v
+						delete(model, k)
+					}
+				}
+			}
+		} else {
+			ok = txn.RenamePrefix([]byte(a), []byte(b))
+			for k, v := range model {
+				if strings.HasPrefix(k, a) {
+					moved[b+k[len(a):]] = v
+					delete(model, k)
+				}
+			}
+		}
+		for k, v := range moved {
+			model[k] = v
+		}
+		if ok != (len(moved) != 0) {
+			t.Fatalf("%q %q: bad ok %v", a, b, ok)
+		}
+		nr := txn.Commit()
+
+		var expect []string
+		expected := New[int](opts...).Txn()
+		for k, v := range model {
+			expect = append(expect, k)
+			expected.Insert([]byte(k), v)
+		}
+		sort.Strings(expect)
+		if len(expect) == 0 {
+			expect = nil
+		}
+		verifyTree(t, expect, nr)
+		checkCounts(t, nr.Root())
+		if err := CheckOrdered(nr); err != nil {
+			t.Fatalf("%q %q: %v", a, b, err)
+		}
+		want := expected.Commit()
+		if nr.Len() != want.Len() || nr.Bytes() != want.Bytes() || nr.Hash() != want.Hash() {
+			t.Fatalf("%q %q: bad accounting", a, b)
+		}
+		if !nr.EqualFunc(want, func(x, y int) bool { return x == y }) {
+			t.Fatalf("%q %q: bad values", a, b)
+		}
+	}
+}
+
+func TestTxn_Move(t *testing.T) {
+	r := New[int]()
+	for i, k := range []string{"foo", "foo/bar", "foo/baz", "zip"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	oldWatch, _, _ := r.Root().GetWatch([]byte("foo/bar"))
+	newWatch, _, _ := r.Root().GetWatch([]byte("zip"))
+	otherWatch, _, _ := r.Root().GetWatch([]byte("foo"))
+
+	txn := r.Txn()
+	txn.TrackMutate(true)
+	if !txn.Move([]byte("foo/bar"), []byte("zip")) {
+		t.Fatalf("expected move")
+	}
+	if txn.Move([]byte("missing"), []byte("foo/qux")) {
+		t.Fatalf("expected missing key not to move")
+	}
+	if !txn.Move([]byte("foo"), []byte("foo")) || txn.Move([]byte("bar"), []byte("bar")) {
+		t.Fatalf("bad move to the same key")
+	}
+	nr := txn.Commit()
+	verifyTree(t, []string{"foo", "foo/baz", "zip"}, nr)
+	if v, _ := nr.Get([]byte("zip")); v != 1 {
+		t.Fatalf("bad value: %d", v)
+	}
+	checkCounts(t, nr.Root())
+	if !isClosedRecv(oldWatch) || !isClosedRecv(newWatch) {
+		t.Fatalf("expected watches to fire")
+	}
+	if isClosedRecv(otherWatch) {
+		t.Fatalf("expected other watch not to fire")
+	}
+
+	nr, ok := nr.Move([]byte("zip"), []byte("a"))
+	if !ok {
+		t.Fatalf("expected move")
+	}
+	verifyTree(t, []string{"a", "foo", "foo/baz"}, nr)
+
+	// A move that doesn't fit in the budget keeps the old key.
+	r2 := New[int](WithMemoryBudget(3, func(int) int { return 0 }))
+	txn = r2.Txn()
+	txn.Insert([]byte("ab"), 1)
+	if txn.Move([]byte("ab"), []byte("abcd")) || !errors.Is(txn.Err(), ErrBudgetExceeded) {
+		t.Fatalf("expected the budget to be enforced")
+	}
+	if v, ok := txn.Get([]byte("ab")); !ok || v != 1 {
+		t.Fatalf("expected old key to be kept")
+	}
+	if !txn.Move([]byte("ab"), []byte("c")) || txn.Bytes() != 1 {
+		t.Fatalf("bad move: %d", txn.Bytes())
+	}
+}
+
+func TestSwapPrefix(t *testing.T) {
+	r := New[string]()
+	for _, k := range []string{"blue/a", "blue/b", "green/a", "green/c", "live"} {
+		r, _, _ = r.Insert([]byte(k), k)
+	}
+	watch, _, _ := r.Root().GetWatch([]byte("blue/a"))
+	other, _, _ := r.Root().GetWatch([]byte("live"))
+
+	txn := r.Txn()
+	txn.TrackMutate(true)
+	if !txn.SwapPrefix([]byte("blue/"), []byte("green/")) {
+		t.Fatalf("expected swap")
+	}
+	nr := txn.Commit()
+	verifyTree(t, []string{"blue/a", "blue/c", "green/a", "green/b", "live"}, nr)
+	checkCounts(t, nr.Root())
+	for k, v := range map[string]string{"blue/c": "green/c", "green/b": "blue/b", "blue/a": "green/a"} {
+		if got, _ := nr.Get([]byte(k)); got != v {
+			t.Fatalf("%s: bad value %q", k, got)
+		}
+	}
+	select {
+	case <-watch:
+	default:
+		t.Fatalf("expected notification")
+	}
+	select {
+	case <-other:
+		t.Fatalf("unexpected notification")
+	default:
+	}
+
+	// Swapping with an empty subtree is a move.
+	nr, ok := r.SwapPrefix([]byte("blue/"), []byte("red/"))
+	if !ok {
+		t.Fatalf("expected swap")
+	}
+	verifyTree(t, []string{"green/a", "green/c", "live", "red/a", "red/b"}, nr)
+
+	// Overlapping and empty prefixes do nothing.
+	for _, c := range [][2]string{{"blue/", "blue/a"}, {"", "live"}, {"red/", "yellow/"}} {
+		if nr, ok := r.SwapPrefix([]byte(c[0]), []byte(c[1])); ok || nr.Len() != r.Len() {
+			t.Fatalf("%q: unexpected swap", c)
+		}
+	}
+
+	// Prefixes overlap if they do once they're folded.
+	r2 := New[string](WithKeyFold())
+	for _, k := range []string{"a/x", "b/y"} {
+		r2, _, _ = r2.Insert([]byte(k), k)
+	}
+	if nr, ok := r2.SwapPrefix([]byte("A/"), []byte("a/")); ok || nr.Len() != 2 {
+		t.Fatalf("unexpected swap")
+	}
+	nr, _ = r2.SwapPrefix([]byte("A/"), []byte("B/"))
+	verifyTree(t, []string{"a/y", "b/x"}, nr)
+}
+
+func TestWithoutDeleteMerge(t *testing.T) {
+	keys := []string{"foo", "foobar", "foobaz"}
+	for _, noMerge := range []bool{false, true} {
+		var opts []Option
+		if noMerge {
+			opts = append(opts, WithoutDeleteMerge())
+		}
+		r := New[int](opts...)
+		for i, k := range keys {
+			r, _, _ = r.Insert([]byte(k), i)
+		}
+		watch := r.Root().Child('f').Child('b').Child('z').mutateCh
+
+		txn := r.Txn()
+		txn.TrackMutate(true)
+		txn.Delete([]byte("foobar"))
+		r = txn.Commit()
+		verifyTree(t, []string{"foo", "foobaz"}, r)
+		checkCounts(t, r.Root())
+
+		// The "ba" node is only kept without merging.
+		foo := r.Root().Child('f')
+		if n := foo.Child('b'); noMerge != (n != nil && string(n.Prefix()) == "ba" && n.Fanout() == 1) {
+			t.Fatalf("noMerge %v: bad node %q", noMerge, n.Prefix())
+		}
+
+		// Without merging, the node holding the other leaf isn't modified by
+		// either the delete or re-inserting the key.
+		txn = r.Txn()
+		txn.TrackMutate(true)
+		txn.Insert([]byte("foobar"), 3)
+		r = txn.Commit()
+		verifyTree(t, keys, r)
+		checkCounts(t, r.Root())
+		select {
+		case <-watch:
+			if noMerge {
+				t.Fatalf("unexpected notification")
+			}
+		default:
+			if !noMerge {
+				t.Fatalf("expected notification")
+			}
+		}
+
+		// Deleting everything below the node still removes it.
+		r, _ = r.DeletePrefix([]byte("fooba"))
+		verifyTree(t, []string{"foo"}, r)
+		if foo := r.Root().Child('f'); foo.Fanout() != 0 {
+			t.Fatalf("bad fanout %d", foo.Fanout())
+		}
+	}
+}
+
+func BenchmarkDelete_Churn(b *testing.B) {
+	for _, noMerge := range []bool{false, true} {
+		b.Run(fmt.Sprintf("noMerge=%v", noMerge), func(b *testing.B) {
+			var opts []Option
+			if noMerge {
+				opts = append(opts, WithoutDeleteMerge())
+			}
+			r := New[int](opts...)
+			for i := 0; i < 1000; i++ {
+				r, _, _ = r.Insert([]byte(fmt.Sprintf("key/%03d/a", i)), i)
+				r, _, _ = r.Insert([]byte(fmt.Sprintf("key/%03d/b", i)), i)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				k := []byte(fmt.Sprintf("key/%03d/a", i%1000))
+				txn := r.Txn()
+				txn.TrackMutate(true)
+				txn.Delete(k)
+				txn.Insert(k, i)
+				r = txn.Commit()
+			}
+		})
+	}
+}
+
+func TestWithCopyKeys(t *testing.T) {
+	buf := []byte("key/a")
+	r := New[int](WithCopyKeys())
+	r, _, _ = r.Insert(buf, 1)
+	buf[4] = 'c'
+	r, _, _ = r.Insert(buf, 2)
+	buf[4] = 'b'
+	r, _, _ = r.Insert(buf, 3)
+	buf[0] = 'x'
+
+	verifyTree(t, []string{"key/a", "key/b", "key/c"}, r)
+	for k, v := range map[string]int{"key/a": 1, "key/b": 3, "key/c": 2} {
+		if got, ok := r.Get([]byte(k)); !ok || got != v {
+			t.Fatalf("%s: got %v %v", k, got, ok)
+		}
+	}
+
+	// The empty key is copied too.
+	r, _, _ = r.Insert([]byte{}, 4)
+	if v, ok := r.Get(nil); !ok || v != 4 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+}
+
+func TestDeleteRange(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	alphabet := "ab/"
+	randKey := func() string {
+		b := make([]byte, rnd.Intn(5))
+		for i := range b {
+			b[i] = alphabet[rnd.Intn(len(alphabet))]
+		}
+		return string(b)
+	}
+	size := func(v int) int { return 8 }
+	hash := func(v int) uint64 { return uint64(v) }
+
+	for i := 0; i < 500; i++ {
+		var opts []Option
+		if i%2 == 1 {
+			opts = append(opts, WithoutDeleteMerge())
+		}
+		r := New[int](append(opts, WithMemoryBudget(1<<20, size), WithContentHash(hash))...)
+		expect := make(map[string]int)
+		for j := 0; j < 40; j++ {
+			k := randKey()
+			r, _, _ = r.Insert([]byte(k), j)
+			expect[k] = j
+		}
+
+		start, end := []byte(randKey()), []byte(randKey())
+		if i%10 == 0 {
+			end = nil
+		}
+		deleted := 0
+		for k := range expect {
+			if k >= string(start) && (end == nil || k < string(end)) {
+				delete(expect, k)
+				deleted++
+			}
+		}
+
+		got, n := r.DeleteRange(start, end)
+		if n != deleted || got.Len() != len(expect) || got.Root().count() != len(expect) {
+			t.Fatalf("bad delete of [%q, %q): %d %d", start, end, n, got.Len())
+		}
+		for k, v := range expect {
+			if out, ok := got.Get([]byte(k)); !ok || out != v {
+				t.Fatalf("missing %q after delete of [%q, %q)", k, start, end)
+			}
+		}
+		if err := CheckOrdered(got); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if got.Bytes() != 8*len(expect)+keyBytes(expect) ||
+			got.Hash() != got.Root().Hash(hash) {
+			t.Fatalf("bad accounting after delete of [%q, %q)", start, end)
+		}
+	}
+}
+
+// keyBytes returns the total length of the keys of m.
+func keyBytes(m map[string]int) int {
+	n := 0
+	for k := range m {
+		n += len(k)
+	}
+	return n
+}
+
+func TestTrackMutate_DeleteRange(t *testing.T) {
+	r := New[int]()
+	keys := []string{"a", "b", "b/1", "b/2", "c/1", "c/2", "d"}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	watches := make(map[string]<-chan struct{})
+	for _, k := range keys {
+		watches[k], _, _ = r.Root().GetWatch([]byte(k))
+	}
+
+	txn := r.Txn()
+	txn.TrackMutate(true)
+	if n := txn.DeleteRange([]byte("b/1"), []byte("c/2")); n != 3 {
+		t.Fatalf("bad count: %d", n)
+	}
+	if hasAnyClosedMutateCh(r) {
+		t.Fatalf("Transaction was not committed, no channel should have been closed")
+	}
+	txn.Commit()
+
+	for _, k := range keys {
+		fired := false
+		select {
+		case <-watches[k]:
+			fired = true
+		default:
+		}
+		if expect := k == "b/1" || k == "b/2" || k == "c/1"; fired != expect {
+			t.Fatalf("bad watch for %q: %v", k, fired)
+		}
+	}
+}
+
+func TestTree_KeysValues(t *testing.T) {
+	r := New[int]()
+	for i, k := range []string{"b", "a/2", "a/1", "c"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	keys := func(ks [][]byte) []string {
+		var out []string
+		for _, k := range ks {
+			out = append(out, string(k))
+		}
+		return out
+	}
+	if got := keys(r.Keys()); !reflect.DeepEqual(got, []string{"a/1", "a/2", "b", "c"}) {
+		t.Fatalf("bad keys: %v", got)
+	}
+	if got := r.Values(); !reflect.DeepEqual(got, []int{2, 1, 0, 3}) {
+		t.Fatalf("bad values: %v", got)
+	}
+	if got := keys(r.KeysPrefix([]byte("a"))); !reflect.DeepEqual(got, []string{"a/1", "a/2"}) {
+		t.Fatalf("bad keys: %v", got)
+	}
+	if got := r.ValuesPrefix([]byte("a/")); !reflect.DeepEqual(got, []int{2, 1}) {
+		t.Fatalf("bad values: %v", got)
+	}
+	if r.KeysPrefix([]byte("x")) != nil || r.ValuesPrefix([]byte("x")) != nil || New[int]().Keys() != nil {
+		t.Fatalf("expected no keys")
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		r.Keys()
+		r.Values()
+	})
+	if allocs != 2 {
+		t.Fatalf("expected one allocation each, got %v", allocs)
+	}
+}
+
+func TestWithCommitHooks(t *testing.T) {
+	var events []string
+	var trees []*Tree[int]
+	hooks := CommitHooks[int]{
+		OnBeforePublish: func(nt *Tree[int]) {
+			events = append(events, fmt.Sprintf("before %d", nt.Generation()))
+			trees = append(trees, nt)
+		},
+		OnAfterPublish: func(old, nt *Tree[int]) {
+			events = append(events, fmt.Sprintf("after %d->%d", old.Generation(), nt.Generation()))
+			if nt != trees[len(trees)-1] {
+				t.Fatalf("bad tree")
+			}
+		},
+	}
+	r := New[int](WithCommitHooks(hooks))
+	r, _, _ = r.Insert([]byte("a"), 1)
+
+	// The after hook runs once the watches have fired.
+	watch, _, _ := r.Root().GetWatch([]byte("a"))
+	txn := r.Txn()
+	txn.TrackMutate(true)
+	txn.Insert([]byte("a"), 2)
+	txn.CommitOnly()
+	if len(events) != 3 {
+		t.Fatalf("bad events: %v", events)
+	}
+	txn.Notify()
+	txn.Notify()
+	select {
+	case <-watch:
+	default:
+		t.Fatalf("expected watch to fire")
+	}
+
+	// Committing again doesn't run the hooks again.
+	txn.Commit()
+
+	expect := []string{"before 1", "after 0->1", "before 2", "after 1->2"}
+	if !reflect.DeepEqual(events, expect) {
+		t.Fatalf("bad events: %v", events)
+	}
+}
+
+func TestTxn_Rollback(t *testing.T) {
+	r := New[int]()
+	r, _, _ = r.Insert([]byte("foo"), 1)
+	watch, _, _ := r.Root().GetWatch([]byte("foo"))
+
+	txn := r.Txn()
+	txn.TrackMutate(true)
+	txn.Insert([]byte("foo"), 2)
+	txn.Insert([]byte("bar"), 3)
+	txn.Rollback()
+	if v, _ := txn.Get([]byte("foo")); v != 1 || txn.Root() != r.Root() {
+		t.Fatalf("expected the writes to be discarded")
+	}
+	if txn.writable != nil || txn.trackChannels != nil || txn.trackNumSmall != 0 {
+		t.Fatalf("expected the transaction's state to be released")
+	}
+	txn.Notify()
+	if isClosedRecv(watch) {
+		t.Fatalf("expected the watch not to fire")
+	}
+	txn.Rollback()
+
+	// Further use panics, even with the default policy.
+	func() {
+		defer func() {
+			err, _ := recover().(error)
+			if !errors.Is(err, ErrTxnRolledBack) {
+				t.Fatalf("expected rollback error, got %v", err)
+			}
+		}()
+		txn.Insert([]byte("baz"), 4)
+	}()
+
+	// With MisuseReturnError, it's recorded instead.
+	r2 := New[int](WithMisusePolicy(MisuseReturnError))
+	txn = r2.Txn()
+	txn.Insert([]byte("foo"), 1)
+	txn.Rollback()
+	if _, ok := txn.Insert([]byte("bar"), 2); ok || !errors.Is(txn.Err(), ErrTxnRolledBack) {
+		t.Fatalf("expected rollback error, got %v", txn.Err())
+	}
+	if tree := txn.Commit(); tree != nil && tree.Len() != 0 {
+		t.Fatalf("expected commit to be rejected")
+	}
+
+	// A rejected commit returns the base tree without running the hooks.
+	hooked := 0
+	r4 := New[int](WithMisusePolicy(MisuseReturnError), WithCommitHooks(CommitHooks[int]{
+		OnBeforePublish: func(*Tree[int]) { hooked++ },
+		OnAfterPublish:  func(_, _ *Tree[int]) { hooked++ },
+	}))
+	txn = r4.Txn()
+	txn.Insert([]byte("foo"), 1)
+	txn.Rollback()
+	if tree := txn.Commit(); tree != r4 || tree.Generation() != 0 || hooked != 0 {
+		t.Fatalf("expected commit to be rejected")
+	}
+	if !errors.Is(txn.Err(), ErrTxnRolledBack) {
+		t.Fatalf("bad err: %v", txn.Err())
+	}
+
+	// Rolling back after a commit does nothing.
+	txn = r.Txn()
+	txn.Insert([]byte("bar"), 3)
+	r3 := txn.Commit()
+	txn.Rollback()
+	if _, ok := r3.Get([]byte("bar")); !ok || txn.rolledBack {
+		t.Fatalf("expected rollback to be ignored")
+	}
+
+	// Aborting a group transaction rolls back the members.
+	g := NewGroup()
+	m := AddMember(g, r)
+	gt := g.Txn()
+	mt := m.Txn(gt)
+	mt.Insert([]byte("baz"), 4)
+	gt.Abort()
+	if !mt.rolledBack {
+		t.Fatalf("expected member to be rolled back")
+	}
+}
+
+func TestTxn_Update(t *testing.T) {
+	incr := func(old int, exists bool) (int, bool) { return old + 1, true }
+
+	txn := New[int](WithContentHash(func(v int) uint64 { return uint64(v) })).Txn()
+	for _, k := range []string{"foo", "foo", "foobar", "fo", "foo", "", "zip"} {
+		if !txn.Update([]byte(k), incr) {
+			t.Fatalf("expected a write for %q", k)
+		}
+	}
+	r := txn.Commit()
+	expect := map[string]int{"": 1, "fo": 1, "foo": 3, "foobar": 1, "zip": 1}
+	if r.Len() != len(expect) || r.Root().count() != len(expect) {
+		t.Fatalf("bad len: %d", r.Len())
+	}
+	for k, e := range expect {
+		if v, ok := r.Get([]byte(k)); !ok || v != e {
+			t.Fatalf("bad value for %q: %v %v", k, v, ok)
+		}
+	}
+	if err := CheckOrdered(r); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	other := New[int](WithContentHash(func(v int) uint64 { return uint64(v) }))
+	for k, v := range expect {
+		other, _, _ = other.Insert([]byte(k), v)
+	}
+	if r.Hash() != other.Hash() {
+		t.Fatalf("bad hash")
+	}
+
+	// Declining the write leaves the tree and its nodes alone.
+	txn = r.Txn()
+	var seen []bool
+	skip := func(old int, exists bool) (int, bool) {
+		seen = append(seen, exists)
+		return 0, false
+	}
+	if txn.Update([]byte("foo"), skip) || txn.Update([]byte("foob"), skip) {
+		t.Fatalf("expected no write")
+	}
+	if txn.Root() != r.Root() || len(seen) != 2 || !seen[0] || seen[1] {
+		t.Fatalf("bad update: %v", seen)
+	}
+
+	// Updates that go over the budget aren't written.
+	r2 := New[int](WithMemoryBudget(8, func(v int) int { return v }))
+	txn = r2.Txn()
+	txn.Insert([]byte("a"), 4)
+	if txn.Update([]byte("a"), func(old int, exists bool) (int, bool) { return old + 4, true }) {
+		t.Fatalf("expected the budget to be enforced")
+	}
+	if v, _ := txn.Get([]byte("a")); v != 4 || txn.Bytes() != 5 || !errors.Is(txn.Err(), ErrBudgetExceeded) {
+		t.Fatalf("bad state: %d %d %v", v, txn.Bytes(), txn.Err())
+	}
+}
+
+func TestTxn_CompareAndSwap(t *testing.T) {
+	eq := func(a, b int) bool { return a == b }
+	txn := New[int]().Txn()
+	txn.Insert([]byte("foo"), 1)
+
+	if txn.CompareAndSwap([]byte("foo"), 2, 3, eq) {
+		t.Fatalf("expected swap to fail")
+	}
+	if txn.CompareAndSwap([]byte("bar"), 0, 3, eq) || txn.size != 1 {
+		t.Fatalf("expected missing key not to be added")
+	}
+	if !txn.CompareAndSwap([]byte("foo"), 1, 3, eq) {
+		t.Fatalf("expected swap")
+	}
+	if v, _ := txn.Get([]byte("foo")); v != 3 {
+		t.Fatalf("bad value: %d", v)
+	}
+
+	if txn.CompareAndDelete([]byte("foo"), 1, eq) || txn.CompareAndDelete([]byte("bar"), 0, eq) {
+		t.Fatalf("expected delete to fail")
+	}
+	if !txn.CompareAndDelete([]byte("foo"), 3, eq) {
+		t.Fatalf("expected delete")
+	}
+	if r := txn.Commit(); r.Len() != 0 {
+		t.Fatalf("bad len: %d", r.Len())
+	}
+}
+
+func TestTxn_Changes(t *testing.T) {
+	r := New[int]()
+	for i, k := range []string{"a", "b/1", "b/2", "c/1", "c/2", "d"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	txn := r.Txn()
+	txn.Insert([]byte("untracked"), 0)
+	txn.TrackChanges(true)
+	txn.Insert([]byte("a"), 10)
+	txn.Insert([]byte("e"), 11)
+	txn.Delete([]byte("d"))
+	txn.Delete([]byte("missing"))
+	txn.DeletePrefix([]byte("b/"))
+	txn.DeleteRange([]byte("c/2"), []byte("c/3"))
+	txn.Update([]byte("c/1"), func(old int, _ bool) (int, bool) { return old + 1, true })
+	txn.Merge(New[int]().BulkLoad([]KV[int]{{Key: []byte("a"), Val: 12}}), nil)
+	txn.Commit()
+
+	expect := []Change[int]{
+		{Type: ChangeUpdated, Key: []byte("a"), Old: 0, New: 10},
+		{Type: ChangeAdded, Key: []byte("e"), New: 11},
+		{Type: ChangeDeleted, Key: []byte("d"), Old: 5},
+		{Type: ChangeDeleted, Key: []byte("b/1"), Old: 1},
+		{Type: ChangeDeleted, Key: []byte("b/2"), Old: 2},
+		{Type: ChangeDeleted, Key: []byte("c/2"), Old: 4},
+		{Type: ChangeUpdated, Key: []byte("c/1"), Old: 3, New: 4},
+		{Type: ChangeUpdated, Key: []byte("a"), Old: 10, New: 12},
+	}
+	if got := txn.Changes(); !reflect.DeepEqual(got, expect) {
+		t.Fatalf("bad changes:\n%v\n%v", got, expect)
+	}
+
+	// Bulk loads record every key.
+	txn = New[int]().Txn()
+	txn.TrackChanges(true)
+	txn.InsertMany([]KV[int]{{Key: []byte("a"), Val: 1}, {Key: []byte("b"), Val: 2}})
+	if got := txn.Changes(); len(got) != 2 || got[1].Type != ChangeAdded || string(got[1].Key) != "b" {
+		t.Fatalf("bad changes: %v", got)
+	}
+	txn.Rollback()
+	if txn.Changes() != nil {
+		t.Fatalf("expected changes to be discarded")
+	}
+}
+
+// stopAfterCtx is a context whose Err starts returning context.Canceled after
+// it has been called a given number of times.
+type stopAfterCtx struct {
+	context.Context
+	calls int
+}
+
+func (c *stopAfterCtx) Err() error {
+	if c.calls == 0 {
+		return context.Canceled
+	}
+	c.calls--
+	return nil
+}
+
+func TestTxn_NotifyCtx(t *testing.T) {
+	for _, n := range []int{ctxCheckInterval, 2 * defaultModifiedCache} {
+		r := New[int]()
+		for i := 0; i < n; i++ {
+			r, _, _ = r.Insert([]byte(fmt.Sprintf("%05d", i)), i)
+		}
+		var watches []<-chan struct{}
+		for i := 0; i < n; i++ {
+			ch, _, _ := r.Root().GetWatch([]byte(fmt.Sprintf("%05d", i)))
+			watches = append(watches, ch)
+		}
+		published := 0
+		r.conf.hooks.OnAfterPublish = func(_, _ *Tree[int]) { published++ }
+
+		txn := r.Txn()
+		txn.TrackMutate(true)
+		txn.DeletePrefix(nil)
+		txn.CommitOnly()
+		if txn.trackOverflow != (n > defaultModifiedCache) {
+			t.Fatalf("bad overflow: %v", txn.trackOverflow)
+		}
+
+		// A done context stops the notifications, and the rest are sent
+		// later.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if closed, err := txn.NotifyCtx(ctx); closed != 0 || !errors.Is(err, context.Canceled) {
+			t.Fatalf("bad notify: %d %v", closed, err)
+		}
+		closed, err := txn.NotifyCtx(&stopAfterCtx{Context: context.Background(), calls: 1})
+		if closed != ctxCheckInterval || !errors.Is(err, context.Canceled) {
+			t.Fatalf("bad notify: %d %v", closed, err)
+		}
+		if published != 0 {
+			t.Fatalf("expected the hook not to run yet")
+		}
+		rest, err := txn.NotifyCtx(context.Background())
+		if err != nil || closed+rest < n {
+			t.Fatalf("bad notify: %d %v", rest, err)
+		}
+		for i, ch := range watches {
+			if !isClosedRecv(ch) {
+				t.Fatalf("expected watch %d to fire", i)
+			}
+		}
+		if published != 1 {
+			t.Fatalf("expected the hook to run once, got %d", published)
+		}
+		txn.Notify()
+	}
+}
+
+func TestTxn_TrackMutatePrefix(t *testing.T) {
+	type watched struct {
+		path     string
+		node     *Node[int]
+		nodeCh   chan struct{}
+		leafCh   chan struct{}
+		leafNode *leafNode[int]
+	}
+	var collect func(n *Node[int], path string, out []watched) []watched
+	collect = func(n *Node[int], path string, out []watched) []watched {
+		path += string(n.prefix)
+		w := watched{path: path, node: n, nodeCh: n.mutateCh}
+		if n.leaf != nil {
+			w.leafCh, w.leafNode = n.leaf.mutateCh, n.leaf
+		}
+		out = append(out, w)
+		for _, e := range n.edges() {
+			out = collect(e.node, path, out)
+		}
+		return out
+	}
+
+	keys := []string{"a", "n", "ns", "ns/", "ns/a", "ns/a/1", "ns/b", "nt", "z"}
+	for i := 0; i < 200; i++ {
+		r := New[int]()
+		for _, k := range keys {
+			if rand.Intn(2) == 0 {
+				r, _, _ = r.Insert([]byte(k), 0)
+			}
+		}
+		all := collect(r.Root(), "", nil)
+
+		txn := r.Txn()
+		txn.TrackMutatePrefix([]byte("ns/"))
+		for j := 0; j < 4; j++ {
+			k := []byte(keys[rand.Intn(len(keys))])
+			switch rand.Intn(3) {
+			case 0:
+				txn.Insert(k, j)
+			case 1:
+				txn.Delete(k)
+			default:
+				txn.DeletePrefix(k)
+			}
+		}
+		if txn.trackNumSmall != 0 || txn.trackChannels != nil {
+			t.Fatalf("expected no channels to be tracked")
+		}
+		nr := txn.Commit()
+
+		// Watches under the prefix fire as they would for a full
+		// comparison of the trees, and the others don't.
+		for _, w := range all {
+			cur := nodeAt(nr.Root(), []byte(w.path))
+			under := strings.HasPrefix(w.path, "ns/")
+			if expect := under && cur != w.node; isClosed(w.nodeCh) != expect {
+				t.Fatalf("bad node watch for %q: expected %v", w.path, expect)
+			}
+			if w.leafCh == nil {
+				continue
+			}
+			if expect := under && (cur == nil || cur.leaf != w.leafNode); isClosed(w.leafCh) != expect {
+				t.Fatalf("bad leaf watch for %q: expected %v", w.path, expect)
+			}
+		}
+		txn.Notify()
+	}
+}
+
+func TestTxn_TrackMutateFunc(t *testing.T) {
+	r := New[int]()
+	for i, k := range []string{"a", "b/1", "b/2", "c"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	var got []string
+	fn := func(k []byte) { got = append(got, string(k)) }
+
+	txn := r.Txn()
+	txn.TrackMutateFunc(fn)
+	txn.Insert([]byte("c"), 10)
+	txn.Insert([]byte("b/3"), 11)
+	txn.DeletePrefix([]byte("a"))
+	txn.CommitOnly()
+	if got != nil {
+		t.Fatalf("expected no calls before notifying")
+	}
+	txn.Notify()
+	txn.Notify()
+	if expect := []string{"a", "b/3", "c"}; !reflect.DeepEqual(got, expect) {
+		t.Fatalf("bad keys: %q", got)
+	}
+
+	// Notifying with a done context makes no calls until it's resumed.
+	got = nil
+	txn = r.Txn()
+	txn.TrackMutateFunc(fn)
+	txn.Delete([]byte("b/1"))
+	txn.CommitOnly()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if n, err := txn.NotifyCtx(ctx); n != 0 || err == nil || got != nil {
+		t.Fatalf("bad notify: %d %v %q", n, err, got)
+	}
+	if n, err := txn.NotifyCtx(context.Background()); n != 1 || err != nil || len(got) != 1 {
+		t.Fatalf("bad notify: %d %v %q", n, err, got)
+	}
+}
+
+func TestTxn_CommitWith(t *testing.T) {
+	r := New[int]()
+	r, _, _ = r.Insert([]byte("foo"), 1)
+	watch, _, _ := r.Root().GetWatch([]byte("foo"))
+	published := 0
+	r.conf.hooks.OnBeforePublish = func(*Tree[int]) { published++ }
+
+	errTooBig := errors.New("too big")
+	validate := func(root *Node[int]) error {
+		if root.count() > 2 {
+			return errTooBig
+		}
+		return nil
+	}
+
+	txn := r.Txn()
+	txn.TrackMutate(true)
+	txn.Insert([]byte("foo"), 2)
+	txn.Insert([]byte("bar"), 3)
+	txn.Insert([]byte("baz"), 4)
+	if nr, err := txn.CommitWith(validate); nr != nil || err != errTooBig {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if txn.committed || published != 0 || isClosedRecv(watch) {
+		t.Fatalf("expected nothing to be committed")
+	}
+
+	// Fixing the transaction lets it commit.
+	txn.Delete([]byte("baz"))
+	nr, err := txn.CommitWith(validate)
+	if err != nil || nr.Len() != 2 {
+		t.Fatalf("bad commit: %v", err)
+	}
+	if published != 1 || !isClosedRecv(watch) {
+		t.Fatalf("expected the commit to be published and notified")
+	}
+}
+
+func TestWithWritableCacheSize(t *testing.T) {
+	for _, size := range []int{0, 2, 1 << 16} {
+		r := New[int](WithWritableCacheSize(size))
+		txn := r.Txn()
+		for i := 0; i < 1000; i++ {
+			txn.Insert([]byte(fmt.Sprintf("%03d", i)), i)
+		}
+		expect := size
+		if size == 0 {
+			expect = defaultModifiedCache
+		}
+		if txn.writable.Len() > expect || (size != 2 && txn.writable.Len() <= 2) {
+			t.Fatalf("size %d: bad cache length %d", size, txn.writable.Len())
+		}
+		r = txn.Commit()
+		if r.Len() != 1000 {
+			t.Fatalf("bad len %d", r.Len())
+		}
+		checkCounts(t, r.Root())
+
+		// The size is inherited by derived trees.
+		mapped := MapValues(r, func(_ []byte, v int) string { return "" })
+		if mapped.conf.writableCacheSize() != r.conf.writableCacheSize() {
+			t.Fatalf("expected the cache size to be inherited")
+		}
+	}
+}
+
+func TestTxn_Reset(t *testing.T) {
+	r := New[int]()
+	txn := r.Txn()
+	txn.Insert([]byte("foo"), 1)
+	txn.Reset(r)
+	if _, ok := txn.Get([]byte("foo")); ok || txn.committed {
+		t.Fatalf("expected a fresh transaction")
+	}
+
+	var watches []<-chan struct{}
+	for i := 0; i < 10; i++ {
+		txn.TrackMutate(true)
+		for j := 0; j < 2*trackSmallSize; j++ {
+			txn.Insert([]byte(fmt.Sprintf("%d", j)), i)
+		}
+		nr := txn.Commit()
+		for _, ch := range watches {
+			if !isClosedRecv(ch) {
+				t.Fatalf("expected watch to fire")
+			}
+		}
+		watches = watches[:0]
+		for j := 0; j < 2*trackSmallSize; j++ {
+			ch, _, _ := nr.Root().GetWatch([]byte(fmt.Sprintf("%d", j)))
+			watches = append(watches, ch)
+		}
+		if nr.Len() != 2*trackSmallSize {
+			t.Fatalf("bad len %d", nr.Len())
+		}
+		if txn.spareWritable == nil || txn.spareWritable.Len() != 0 || (i > 0 && (txn.spareTrack == nil || len(txn.spareTrack) != 0)) {
+			t.Fatalf("expected the emptied state to be kept")
+		}
+		txn.Reset(nr)
+		if txn.trackMutate || txn.base != nr {
+			t.Fatalf("expected the settings to be cleared")
+		}
+	}
+	if err := CheckOrdered(txn.Commit()); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package iradixtest provides helpers for writing tests against code that uses
+// iradix trees, such as random tree generators for property-based tests with
+// testing/quick.
+package iradixtest
+
+import (
+	"math/rand"
+	"reflect"
+	"testing/quick"
+
+	iradix "github.com/hashicorp/go-immutable-radix/v2"
+)
+
+// Config controls the shape of randomly generated trees.
+type Config struct {
+	// Alphabet is the set of bytes that keys are made from. Small alphabets
+	// make keys share prefixes more often, which produces deeper trees with
+	// more splits.
+	Alphabet string
+
+	// MaxKeyLen is the maximum length of a generated key. Keys are between
+	// zero and MaxKeyLen bytes long, so the empty key may be generated.
+	MaxKeyLen int
+
+	// MaxKeys is the maximum number of keys inserted into a generated tree.
+	// The size hint given by testing/quick also bounds the number of keys.
+	MaxKeys int
+}
+
+// DefaultConfig is the Config used by the Tree and Key generators. It uses a
+// short alphabet of readable letters and short keys, which makes failures easy
+// to read and provokes interesting tree shapes.
+var DefaultConfig = Config{
+	Alphabet:  "abcdefg",
+	MaxKeyLen: 8,
+	MaxKeys:   100,
+}
+
+// GenerateKey returns a random key using the given config.
+func GenerateKey(rand *rand.Rand, c Config) []byte {
+	b := make([]byte, rand.Intn(c.MaxKeyLen+1))
+	for i := range b {
+		b[i] = c.Alphabet[rand.Intn(len(c.Alphabet))]
+	}
+	return b
+}
+
+// GenerateTree returns a random tree using the given config. The size is the
+// hint given to quick.Generator implementations and bounds the number of keys
+// along with c.MaxKeys. Values are generated with quick.Value, falling back to
+// the zero value for types it doesn't support, such as interfaces.
+func GenerateTree[T any](rand *rand.Rand, size int, c Config) *iradix.Tree[T] {
+	max := c.MaxKeys
+	if size < max {
+		max = size
+	}
+
+	var zero T
+	typ := reflect.TypeOf(&zero).Elem()
+
+	txn := iradix.New[T]().Txn()
+	for i := rand.Intn(max + 1); i > 0; i-- {
+		v := zero
+		if rv, ok := quick.Value(typ, rand); ok {
+			v = rv.Interface().(T)
+		}
+		txn.Insert(GenerateKey(rand, c), v)
+	}
+	return txn.Commit()
+}
+
+// Values returns a function that can be used as the Values field of a
+// quick.Config to generate the arguments of the property function f using the
+// given config. Arguments of type *iradix.Tree[T] or Tree[T] get a random tree,
+// and arguments of type Key get a random key; all other arguments are generated
+// with quick.Value.
+func Values[T any](c Config, f any) func([]reflect.Value, *rand.Rand) {
+	treeType := reflect.TypeOf((*iradix.Tree[T])(nil))
+	wrapType := reflect.TypeOf(Tree[T]{})
+	keyType := reflect.TypeOf(Key(nil))
+	fnType := reflect.TypeOf(f)
+	return func(args []reflect.Value, rand *rand.Rand) {
+		for i := range args {
+			switch typ := fnType.In(i); typ {
+			case treeType:
+				args[i] = reflect.ValueOf(GenerateTree[T](rand, c.MaxKeys, c))
+			case wrapType:
+				args[i] = reflect.ValueOf(Tree[T]{GenerateTree[T](rand, c.MaxKeys, c)})
+			case keyType:
+				args[i] = reflect.ValueOf(Key(GenerateKey(rand, c)))
+			default:
+				v, ok := quick.Value(typ, rand)
+				if !ok {
+					v = reflect.Zero(typ)
+				}
+				args[i] = v
+			}
+		}
+	}
+}
+
+// Tree wraps a tree so that it can be generated by testing/quick using the
+// DefaultConfig. Use Values for control over the generated trees.
+type Tree[T any] struct {
+	*iradix.Tree[T]
+}
+
+// Generate implements quick.Generator.
+func (Tree[T]) Generate(rand *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(Tree[T]{GenerateTree[T](rand, size, DefaultConfig)})
+}
+
+// Key is a key that can be generated by testing/quick using the DefaultConfig.
+type Key []byte
+
+// Generate implements quick.Generator.
+func (Key) Generate(rand *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(Key(GenerateKey(rand, DefaultConfig)))
+}
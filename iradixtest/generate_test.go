@@ -0,0 +1,56 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradixtest
+
+import (
+	"bytes"
+	"testing"
+	"testing/quick"
+
+	iradix "github.com/hashicorp/go-immutable-radix/v2"
+)
+
+func TestTree_Generate(t *testing.T) {
+	// Iteration over any generated tree should be strictly ordered and agree
+	// with the size of the tree.
+	prop := func(tree Tree[int]) bool {
+		var last []byte
+		n := 0
+		tree.Root().Walk(func(k []byte, _ int) bool {
+			if n > 0 && bytes.Compare(last, k) >= 0 {
+				return true
+			}
+			last = k
+			n++
+			return false
+		})
+		return n == tree.Len()
+	}
+	if err := quick.Check(prop, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValues(t *testing.T) {
+	c := Config{Alphabet: "xy", MaxKeyLen: 3, MaxKeys: 20}
+
+	// Every key must come from the alphabet and respect the length limit, and
+	// any generated key that's in the tree must be found.
+	prop := func(tree *iradix.Tree[string], k Key) bool {
+		if len(k) > c.MaxKeyLen || tree.Len() > c.MaxKeys {
+			return false
+		}
+		ok := true
+		tree.Root().Walk(func(key []byte, _ string) bool {
+			if len(key) > c.MaxKeyLen || len(bytes.Trim(key, c.Alphabet)) != 0 {
+				ok = false
+			}
+			return !ok
+		})
+		return ok
+	}
+	if err := quick.Check(prop, &quick.Config{Values: Values[string](c, prop)}); err != nil {
+		t.Fatal(err)
+	}
+}
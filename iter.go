@@ -5,6 +5,8 @@ package iradix
 
 import (
 	"bytes"
+	"context"
+	"runtime"
 )
 
 // Iterator is used to iterate over a set of nodes
@@ -12,11 +14,53 @@ import (
 type Iterator[T any] struct {
 	node  *Node[T]
 	stack []edges[T]
+
+	// collate is the collation table of the tree the iterator was created
+	// from, if any, which seek keys are translated through.
+	collate *[256]byte
+
+	// transform is the KeyTransformer of the tree the iterator was created
+	// from, if any, which seek keys are transformed by first.
+	transform KeyTransformer
+
+	// start backs the initial stack entry set up by Reset, so that it
+	// doesn't need to be allocated.
+	start [1]edge[T]
+
+	// ctxCalls counts the calls to NextCtx, which checks its context every
+	// ctxCheckInterval calls.
+	ctxCalls int
+}
+
+// Reset re-targets the iterator at the given node, as if it had been newly
+// created by calling Iterator on it. The iterator's stack is kept so that
+// pooled iterators can be reused without allocating.
+func (i *Iterator[T]) Reset(n *Node[T]) {
+	// Clear out the old entries so they don't keep old nodes alive.
+	for j := range i.stack {
+		i.stack[j] = nil
+	}
+	i.stack = i.stack[:0]
+	i.node = n
+	i.start[0] = edge[T]{node: n}
+	if n != nil {
+		i.stack = append(i.stack, i.start[:])
+	}
+}
+
+// path returns the path in the tree of a key given to a seek method.
+func (i *Iterator[T]) path(k []byte) []byte {
+	return collateKey(i.collate, transformKey(i.transform, k))
 }
 
 // SeekPrefixWatch is used to seek the iterator to a given prefix
 // and returns the watch channel of the finest granularity
 func (i *Iterator[T]) SeekPrefixWatch(prefix []byte) (watch <-chan struct{}) {
+	return i.seekPrefixWatch(i.path(prefix))
+}
+
+// seekPrefixWatch implements SeekPrefixWatch for an already collated prefix.
+func (i *Iterator[T]) seekPrefixWatch(prefix []byte) (watch <-chan struct{}) {
 	// Wipe the stack
 	i.stack = nil
 	n := i.node
@@ -63,14 +107,14 @@ func (i *Iterator[T]) recurseMin(n *Node[T]) *Node[T] {
 	if n.leaf != nil {
 		return n
 	}
-	nEdges := len(n.edges)
+	nEdges := len(n.edges())
 	if nEdges > 1 {
 		// Add all the other edges to the stack (the min node will be added as
 		// we recurse)
-		i.stack = append(i.stack, n.edges[1:])
+		i.stack = append(i.stack, n.edges()[1:])
 	}
 	if nEdges > 0 {
-		return i.recurseMin(n.edges[0].node)
+		return i.recurseMin(n.edges()[0].node)
 	}
 	// Shouldn't be possible
 	return nil
@@ -81,6 +125,28 @@ func (i *Iterator[T]) recurseMin(n *Node[T]) *Node[T] {
 // predict based on the radix structure which node(s) changes might affect the
 // result.
 func (i *Iterator[T]) SeekLowerBound(key []byte) {
+	i.seekLowerBound(i.path(key))
+}
+
+// SeekAfter is used to seek the iterator to the smallest key that is strictly
+// greater than the given key. This is how to resume an iteration from the last
+// key that was returned, for example to serve the next page of a paginated
+// list, and it works on any version of the tree, not just the one the key came
+// from. Resuming on a newer version returns exactly the keys greater than the
+// given one that exist in that version: keys deleted in between are skipped,
+// keys inserted in between are returned if they sort after the given key, and
+// keys at or before it are never returned again, so no key is seen twice.
+func (i *Iterator[T]) SeekAfter(key []byte) {
+	// The smallest key greater than the given one is the same key followed
+	// by a zero byte.
+	path := i.path(key)
+	after := make([]byte, len(path)+1)
+	copy(after, path)
+	i.seekLowerBound(after)
+}
+
+// seekLowerBound implements SeekLowerBound for an already collated key.
+func (i *Iterator[T]) seekLowerBound(key []byte) {
 	// Wipe the stack. Unlike Prefix iteration, we need to build the stack as we
 	// go because we need only a subset of edges of many nodes in the path to the
 	// leaf with the lower bound. Note that the iterator will still recurse into
@@ -137,8 +203,10 @@ func (i *Iterator[T]) SeekLowerBound(key []byte) {
 		}
 
 		// Prefix is equal, we are still heading for an exact match. If this is a
-		// leaf and an exact match we're done.
-		if n.leaf != nil && bytes.Equal(n.leaf.key, key) {
+		// leaf and an exact match we're done. The prefix can only be as long as
+		// the remaining search if the two are equal, since prefixCmp would have
+		// been > 0 otherwise.
+		if n.leaf != nil && len(n.prefix) == len(search) {
 			found(n)
 			return
 		}
@@ -164,8 +232,8 @@ func (i *Iterator[T]) SeekLowerBound(key []byte) {
 		}
 
 		// Create stack edges for the all strictly higher edges in this node.
-		if idx+1 < len(n.edges) {
-			i.stack = append(i.stack, n.edges[idx+1:])
+		if idx+1 < len(n.edges()) {
+			i.stack = append(i.stack, n.edges()[idx+1:])
 		}
 
 		// Recurse
@@ -173,6 +241,53 @@ func (i *Iterator[T]) SeekLowerBound(key []byte) {
 	}
 }
 
+// SeekIndex is used to seek the iterator to the i-th smallest key under the
+// iterator's node, counting from zero, so that the next call to Next returns
+// that key. If i is out of range the iterator is exhausted. This uses the leaf
+// counts maintained on every node, so it only visits the nodes on the path to
+// the key.
+func (i *Iterator[T]) SeekIndex(idx int) {
+	// Wipe the stack and build it as we go, as in SeekLowerBound.
+	i.stack = []edges[T]{}
+	n := i.node
+	i.node = nil
+	if n == nil || idx < 0 || idx >= n.count() {
+		return
+	}
+
+	for {
+		// If this node has a leaf it comes before all the children.
+		if n.leaf != nil {
+			if idx == 0 {
+				i.stack = append(i.stack, edges[T]{edge[T]{node: n}})
+				return
+			}
+			idx--
+		}
+
+		// Find the child that holds the index, skipping over the counts of
+		// all the children before it.
+		var next *Node[T]
+		for j, e := range n.edges() {
+			if idx < e.node.count() {
+				if j+1 < len(n.edges()) {
+					i.stack = append(i.stack, n.edges()[j+1:])
+				}
+				next = e.node
+				break
+			}
+			idx -= e.node.count()
+		}
+
+		// This shouldn't be possible since we checked the range, so the
+		// counts must be corrupt.
+		if next == nil {
+			panic("iradix: leaf counts are inconsistent")
+		}
+		n = next
+	}
+}
+
 // Next returns the next node in order
 func (i *Iterator[T]) Next() ([]byte, T, bool) {
 	var zero T
@@ -195,8 +310,8 @@ func (i *Iterator[T]) Next() ([]byte, T, bool) {
 		}
 
 		// Push the edges onto the frontier
-		if len(elem.edges) > 0 {
-			i.stack = append(i.stack, elem.edges)
+		if len(elem.edges()) > 0 {
+			i.stack = append(i.stack, elem.edges())
 		}
 
 		// Return the leaf values if any
@@ -206,3 +321,76 @@ func (i *Iterator[T]) Next() ([]byte, T, bool) {
 	}
 	return nil, zero, false
 }
+
+// NextCtx is like Next, but every few hundred calls it checks ctx, returning
+// ctx.Err() if it's done, so that long scans can be cancelled. Once it returns
+// an error, the iterator is left where it was and can still be used.
+func (i *Iterator[T]) NextCtx(ctx context.Context) ([]byte, T, bool, error) {
+	if i.ctxCalls%ctxCheckInterval == 0 {
+		if err := ctx.Err(); err != nil {
+			var zero T
+			return nil, zero, false, err
+		}
+	}
+	i.ctxCalls++
+	k, v, ok := i.Next()
+	return k, v, ok, nil
+}
+
+// Prefetch starts a goroutine that reads ahead the next n leaves the iterator
+// will return, along with the nodes leading to them, so that their memory is
+// already in cache when they're consumed. This can speed up iteration over
+// large trees that are cold, such as ones just decoded from a snapshot, when
+// there's work done between calls to Next. It doesn't change the position of
+// the iterator and returns immediately.
+//
+// This is safe since the nodes of a tree are never modified, but the iterator
+// must not be over the root of a transaction that's still being written to.
+func (i *Iterator[T]) Prefetch(n int) {
+	if n <= 0 {
+		return
+	}
+	var ahead Iterator[T]
+	if i.stack == nil {
+		if i.node == nil {
+			return
+		}
+		ahead.stack = []edges[T]{{edge[T]{node: i.node}}}
+	} else if len(i.stack) > 0 {
+		// The edges are shared with the iterator, which only modifies its
+		// own copy of the stack.
+		ahead.stack = append([]edges[T](nil), i.stack...)
+	} else {
+		return
+	}
+	go ahead.prefetch(n)
+}
+
+// prefetch reads the next n leaves of the iterator and the nodes leading to
+// them, touching every cache line of their keys.
+func (i *Iterator[T]) prefetch(n int) {
+	var sum byte
+	touch := func(b []byte) {
+		for j := 0; j < len(b); j += 64 {
+			sum += b[j]
+		}
+	}
+	for len(i.stack) > 0 && n > 0 {
+		last := i.stack[len(i.stack)-1]
+		elem := last[0].node
+		if len(last) > 1 {
+			i.stack[len(i.stack)-1] = last[1:]
+		} else {
+			i.stack = i.stack[:len(i.stack)-1]
+		}
+		if len(elem.edges()) > 0 {
+			i.stack = append(i.stack, elem.edges())
+		}
+		touch(elem.prefix)
+		if elem.leaf != nil {
+			touch(elem.leaf.key)
+			n--
+		}
+	}
+	runtime.KeepAlive(sum)
+}
@@ -0,0 +1,250 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestIterator_SeekIndex(t *testing.T) {
+	keys := []string{"", "a", "ab", "abc", "abd", "b", "ba", "foo", "foo/bar", "foo/baz", "zip"}
+	r := New[int]()
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	for idx := -1; idx <= len(keys); idx++ {
+		t.Run(fmt.Sprintf("index%d", idx), func(t *testing.T) {
+			it := r.Root().Iterator()
+			it.SeekIndex(idx)
+			var got []string
+			for k, _, ok := it.Next(); ok; k, _, ok = it.Next() {
+				got = append(got, string(k))
+			}
+
+			var want []string
+			if idx >= 0 && idx < len(keys) {
+				want = keys[idx:]
+			}
+			if !slices.Equal(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		})
+	}
+
+	// Indexes are relative to the node the iterator was seeked to.
+	it := r.Root().Iterator()
+	it.SeekPrefix([]byte("foo"))
+	it.SeekIndex(1)
+	if k, _, ok := it.Next(); !ok || string(k) != "foo/bar" {
+		t.Fatalf("bad: %q %v", k, ok)
+	}
+}
+
+func TestIterator_Reset(t *testing.T) {
+	r1, r2 := New[int](), New[int]()
+	for i, k := range []string{"a", "b", "c"} {
+		r1, _, _ = r1.Insert([]byte(k), i)
+	}
+	for i, k := range []string{"x", "y"} {
+		r2, _, _ = r2.Insert([]byte(k), i)
+	}
+
+	collect := func(it *Iterator[int]) []string {
+		var out []string
+		for k, _, ok := it.Next(); ok; k, _, ok = it.Next() {
+			out = append(out, string(k))
+		}
+		return out
+	}
+
+	it := r1.Root().Iterator()
+	it.SeekLowerBound([]byte("b"))
+	if got := collect(it); !slices.Equal(got, []string{"b", "c"}) {
+		t.Fatalf("bad: %v", got)
+	}
+
+	it.Reset(r2.Root())
+	if got := collect(it); !slices.Equal(got, []string{"x", "y"}) {
+		t.Fatalf("bad: %v", got)
+	}
+
+	// Seeks work as usual after a reset.
+	it.Reset(r1.Root())
+	it.SeekPrefix([]byte("c"))
+	if got := collect(it); !slices.Equal(got, []string{"c"}) {
+		t.Fatalf("bad: %v", got)
+	}
+
+	it.Reset(nil)
+	if got := collect(it); len(got) != 0 {
+		t.Fatalf("bad: %v", got)
+	}
+
+	ri := r1.Root().ReverseIterator()
+	ri.SeekReverseLowerBound([]byte("b"))
+	if k, _, ok := ri.Previous(); !ok || string(k) != "b" {
+		t.Fatalf("bad: %q", k)
+	}
+	ri.Reset(r2.Root())
+	var got []string
+	for k, _, ok := ri.Previous(); ok; k, _, ok = ri.Previous() {
+		got = append(got, string(k))
+	}
+	if !slices.Equal(got, []string{"y", "x"}) {
+		t.Fatalf("bad: %v", got)
+	}
+}
+
+func BenchmarkIterator_Reset(b *testing.B) {
+	r := New[int]()
+	for i := 0; i < 1000; i++ {
+		r, _, _ = r.Insert([]byte(fmt.Sprintf("key-%04d", i)), i)
+	}
+	root := r.Root()
+	it := root.Iterator()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		it.Reset(root)
+		for _, _, ok := it.Next(); ok; _, _, ok = it.Next() {
+		}
+	}
+}
+
+func TestIterator_SeekAfter(t *testing.T) {
+	r := New[int]()
+	for i, k := range []string{"", "a", "a\x00", "a\x00\x00", "ab", "b", "c"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	collect := func(it *Iterator[int]) []string {
+		var out []string
+		for k, _, ok := it.Next(); ok; k, _, ok = it.Next() {
+			out = append(out, string(k))
+		}
+		return out
+	}
+	cases := []struct {
+		after  string
+		expect []string
+	}{
+		{"", []string{"a", "a\x00", "a\x00\x00", "ab", "b", "c"}},
+		{"a", []string{"a\x00", "a\x00\x00", "ab", "b", "c"}},
+		{"a\x00", []string{"a\x00\x00", "ab", "b", "c"}},
+		{"aa", []string{"ab", "b", "c"}},
+		{"b", []string{"c"}},
+		{"c", nil},
+		{"d", nil},
+	}
+	for _, c := range cases {
+		it := r.Root().Iterator()
+		it.SeekAfter([]byte(c.after))
+		if got := collect(it); !reflect.DeepEqual(got, c.expect) {
+			t.Fatalf("after %q: got %q, expected %q", c.after, got, c.expect)
+		}
+	}
+
+	// Paginate over a tree that changes between pages.
+	page := func(r *Tree[int], after []byte, n int) []string {
+		it := r.Root().Iterator()
+		if after != nil {
+			it.SeekAfter(after)
+		}
+		var out []string
+		for k, _, ok := it.Next(); ok && len(out) < n; k, _, ok = it.Next() {
+			out = append(out, string(k))
+		}
+		return out
+	}
+	first := page(r, nil, 3)
+	if !reflect.DeepEqual(first, []string{"", "a", "a\x00"}) {
+		t.Fatalf("bad: %q", first)
+	}
+	r, _, _ = r.Delete([]byte("a\x00\x00"))
+	r, _, _ = r.Insert([]byte("0"), 0)
+	r, _, _ = r.Insert([]byte("aa"), 0)
+	second := page(r, []byte(first[len(first)-1]), 3)
+	if !reflect.DeepEqual(second, []string{"aa", "ab", "b"}) {
+		t.Fatalf("bad: %q", second)
+	}
+}
+
+func TestIterator_Prefetch(t *testing.T) {
+	r := New[int]()
+	var keys []string
+	for i := 0; i < 1000; i++ {
+		k := fmt.Sprintf("key/%04d", i)
+		keys = append(keys, k)
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	// Prefetching doesn't move the iterator, wherever it is, and runs
+	// concurrently with iteration.
+	it := r.Root().Iterator()
+	it.Prefetch(100)
+	var got []string
+	for k, _, ok := it.Next(); ok; k, _, ok = it.Next() {
+		got = append(got, string(k))
+		if len(got)%100 == 0 {
+			it.Prefetch(200)
+		}
+	}
+	it.Prefetch(10)
+	if !reflect.DeepEqual(got, keys) {
+		t.Fatalf("bad: %v", got)
+	}
+
+	it = r.Root().Iterator()
+	it.SeekLowerBound([]byte("key/0500"))
+	it.Prefetch(1000)
+	if k, _, _ := it.Next(); string(k) != "key/0500" {
+		t.Fatalf("bad: %s", k)
+	}
+}
+
+func TestIterator_NextCtx(t *testing.T) {
+	txn := New[int]().Txn()
+	for i := 0; i < 1000; i++ {
+		txn.Insert([]byte(fmt.Sprintf("%04d", i)), i)
+	}
+	r := txn.Commit()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	it := r.Root().Iterator()
+	count := 0
+	for {
+		_, v, ok, err := it.NextCtx(ctx)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) || count != 2*ctxCheckInterval {
+				t.Fatalf("bad error after %d keys: %v", count, err)
+			}
+			break
+		}
+		if !ok || v != count {
+			t.Fatalf("bad value: %v %v", v, ok)
+		}
+		count++
+		if count == 300 {
+			cancel()
+		}
+	}
+
+	// The iterator can still be used without the context.
+	if _, v, ok := it.Next(); !ok || v != count {
+		t.Fatalf("bad value: %v %v", v, ok)
+	}
+	_, _, ok, err := r.Root().Iterator().NextCtx(context.Background())
+	if !ok || err != nil {
+		t.Fatalf("bad next: %v %v", ok, err)
+	}
+}
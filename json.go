@@ -0,0 +1,89 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// MarshalJSON implements json.Marshaler, encoding the tree as a JSON object
+// with a member for each key, in the order of the tree, whose value is the
+// key's value encoded by encoding/json. It returns an error if a key isn't
+// valid UTF-8, since it can't be written as a JSON string without changing it.
+func (t *Tree[T]) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	var err error
+	t.root.Walk(func(k []byte, v T) bool {
+		if !utf8.Valid(k) {
+			err = fmt.Errorf("iradix: key %q isn't valid UTF-8", k)
+			return true
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		var b []byte
+		if b, err = json.Marshal(string(k)); err != nil {
+			return true
+		}
+		buf.Write(b)
+		buf.WriteByte(':')
+		if b, err = json.Marshal(v); err != nil {
+			return true
+		}
+		buf.Write(b)
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, replacing the contents of the
+// tree with the members of a JSON object as encoded by MarshalJSON. The keys
+// are loaded through a single transaction, so an object in the order of the
+// tree builds it bottom-up. If a key appears more than once, the last value
+// wins. The tree keeps the options it was created with by New, and a zero Tree
+// gets the default options. It returns ErrBudgetExceeded if the tree has a
+// memory budget that the object doesn't fit in, leaving the tree unchanged.
+func (t *Tree[T]) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('{') {
+		return fmt.Errorf("iradix: can't unmarshal %v into a tree", tok)
+	}
+	var kvs []KV[T]
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var kv KV[T]
+		kv.Key = []byte(tok.(string))
+		if err := dec.Decode(&kv.Val); err != nil {
+			return err
+		}
+		kvs = append(kvs, kv)
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	txn := t.emptyBase().Txn()
+	txn.InsertMany(kvs)
+	if err := txn.Err(); err != nil {
+		return err
+	}
+	*t = *txn.derive()
+	return nil
+}
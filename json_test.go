@@ -0,0 +1,79 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestTree_JSON(t *testing.T) {
+	r := New[[]int]()
+	for i, k := range []string{"foo/bar", "foo", "", "b\"az"} {
+		r, _, _ = r.Insert([]byte(k), []int{i})
+	}
+
+	type doc struct {
+		Tree *Tree[[]int] `json:"tree"`
+	}
+	b, err := json.Marshal(doc{Tree: r})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expect := `{"tree":{"":[2],"b\"az":[3],"foo":[1],"foo/bar":[0]}}`
+	if string(b) != expect {
+		t.Fatalf("bad json: %s", b)
+	}
+
+	var out doc
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out.Tree.Len() != 4 {
+		t.Fatalf("bad len: %d", out.Tree.Len())
+	}
+	if v, ok := out.Tree.Get([]byte("b\"az")); !ok || v[0] != 3 {
+		t.Fatalf("bad value: %v %v", v, ok)
+	}
+	if err := CheckOrdered(out.Tree); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if b2, _ := json.Marshal(out); string(b2) != expect {
+		t.Fatalf("bad round trip: %s", b2)
+	}
+
+	// Unmarshaling into a tree keeps its options, and the last of repeated
+	// keys wins.
+	r2 := New[[]int](WithCollation(FoldCaseCollation()))
+	if err := json.Unmarshal([]byte(`{"b":[1],"a":[2],"A":[3],"a":[4]}`), r2); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if v, ok := r2.Get([]byte("a")); !ok || v[0] != 4 || r2.Len() != 3 {
+		t.Fatalf("bad value: %v %v %d", v, ok, r2.Len())
+	}
+	if b, _ := json.Marshal(r2); string(b) != `{"A":[3],"a":[4],"b":[1]}` {
+		t.Fatalf("bad json: %s", b)
+	}
+
+	r3 := New[[]int](WithMemoryBudget(8, func(v []int) int { return 8 * len(v) }))
+	if err := json.Unmarshal([]byte(`{"a":[1],"b":[2]}`), r3); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected budget error, got %v", err)
+	}
+	if r3.Len() != 0 {
+		t.Fatalf("tree was changed")
+	}
+
+	for _, bad := range []string{`[]`, `{"a":}`, `{"a":"b"}`, `{"a":[1]`} {
+		var bt Tree[[]int]
+		if err := json.Unmarshal([]byte(bad), &bt); err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+
+	r, _, _ = r.Insert([]byte("\xff"), nil)
+	if _, err := json.Marshal(r); err == nil {
+		t.Fatalf("expected error for invalid UTF-8 key")
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+// MappedIterator wraps an iterator, transforming every value it returns with a
+// function. The wrapped iterator can still be seeked before calling Next, which
+// then starts from its new position.
+type MappedIterator[T, U any] struct {
+	next func() ([]byte, T, bool)
+	f    func([]byte, T) U
+}
+
+// KeyValueIterator is implemented by the iterators that return their elements
+// in order from Next, such as Iterator, PathIterator, IndexIterator and
+// TokenIterator.
+type KeyValueIterator[T any] interface {
+	Next() ([]byte, T, bool)
+}
+
+// ReverseKeyValueIterator is implemented by the iterators that return their
+// elements in reverse order from Previous, such as ReverseIterator and
+// ReverseIndexIterator.
+type ReverseKeyValueIterator[T any] interface {
+	Previous() ([]byte, T, bool)
+}
+
+// MapIterator returns an iterator that returns the keys of it in order, with
+// their values transformed by f.
+func MapIterator[I KeyValueIterator[T], T, U any](it I, f func([]byte, T) U) *MappedIterator[T, U] {
+	return &MappedIterator[T, U]{next: it.Next, f: f}
+}
+
+// MapReverseIterator is like MapIterator, but for a ReverseKeyValueIterator,
+// so Next returns the elements in reverse order.
+func MapReverseIterator[I ReverseKeyValueIterator[T], T, U any](it I, f func([]byte, T) U) *MappedIterator[T, U] {
+	return &MappedIterator[T, U]{next: it.Previous, f: f}
+}
+
+// Next returns the next key and its transformed value. The function is only
+// called for the elements that are returned.
+func (i *MappedIterator[T, U]) Next() ([]byte, U, bool) {
+	k, v, ok := i.next()
+	if !ok {
+		var zero U
+		return nil, zero, false
+	}
+	return k, i.f(k, v), true
+}
@@ -0,0 +1,70 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestMapIterator(t *testing.T) {
+	r := New[int]()
+	for i, k := range []string{"a", "ab", "abc", "b", "foo"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	f := func(k []byte, v int) string {
+		return fmt.Sprintf("%s=%d", k, v)
+	}
+	collect := func(it KeyValueIterator[string]) []string {
+		var out []string
+		for _, v, ok := it.Next(); ok; _, v, ok = it.Next() {
+			out = append(out, v)
+		}
+		return out
+	}
+
+	it := r.Root().Iterator()
+	if got, want := collect(MapIterator(it, f)), []string{"a=0", "ab=1", "abc=2", "b=3", "foo=4"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// Seeking the wrapped iterator moves the mapped one.
+	it = r.Root().Iterator()
+	m := MapIterator(it, f)
+	it.SeekLowerBound([]byte("abc"))
+	if got, want := collect(m), []string{"abc=2", "b=3", "foo=4"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	ri := r.Root().ReverseIterator()
+	ri.SeekReverseLowerBound([]byte("b"))
+	if got, want := collect(MapReverseIterator(ri, f)), []string{"b=3", "abc=2", "ab=1", "a=0"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	pi := r.Root().PathIterator([]byte("abc"))
+	if got, want := collect(MapIterator(pi, f)), []string{"a=0", "ab=1", "abc=2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// Any iterator with a Next or Previous method can be mapped, including
+	// mapped ones.
+	double := MapIterator(MapIterator(r.Root().Iterator(), f), func(_ []byte, v string) string {
+		return v + v
+	})
+	if got, want := collect(double), []string{"a=0a=0", "ab=1ab=1", "abc=2abc=2", "b=3b=3", "foo=4foo=4"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	ix := NewIndexedTree(func(v int) []byte { return []byte{byte(10 - v)} })
+	for i, k := range []string{"a", "b", "c"} {
+		ix, _, _ = ix.Insert([]byte(k), i)
+	}
+	if got, want := collect(MapIterator(ix.ByValue(), f)), []string{"c=2", "b=1", "a=0"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := collect(MapReverseIterator(ix.ReverseByValue(), f)), []string{"a=0", "b=1", "c=2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+// MapValues returns a tree with the same keys as t, and values that are the
+// results of calling fn on each key and value of t, in order. The nodes are
+// rebuilt in a single pass with the same shape as t, so no keys are split or
+// looked up again, and the keys themselves are shared with t.
+//
+// The new tree keeps the options of t that don't depend on the value type,
+// such as its collation and misuse policy, and is also given opts, which may
+// add options for the new value type like WithIntern or WithContentHash. The
+// collation can't be changed, since the shape of the tree depends on it. A
+// memory budget given in opts isn't enforced for the mapped values.
+func MapValues[T, U any](t *Tree[T], fn func(k []byte, v T) U, opts ...Option) *Tree[U] {
+	src := t.conf.options
+	inherit := func(o *options) {
+		o.leafMeta = src.leafMeta
+		o.misuse = src.misuse
+		o.collate = src.collate
+		o.fold = src.fold
+		o.transform = src.transform
+		o.noMerge = src.noMerge
+		o.copyKeys = src.copyKeys
+		o.writableCache = src.writableCache
+	}
+	nt := New[U](append([]Option{inherit}, opts...)...)
+	if !sameCollation(nt.conf.collate, src.collate) {
+		panic("iradix: MapValues can't change the collation of a tree")
+	}
+
+	txn := nt.Txn()
+	txn.root = mapNode(txn, t.root, fn)
+	txn.size = t.size
+	txn.generation = t.generation
+	txn.recount()
+	return txn.derive()
+}
+
+// mapNode returns a copy of the subtree under n with its values mapped by fn,
+// allocated by txn.
+func mapNode[T, U any](txn *Txn[U], n *Node[T], fn func(k []byte, v T) U) *Node[U] {
+	nn := txn.allocNode(Node[U]{prefix: n.prefix})
+	if n.leaf != nil {
+		v := fn(n.leaf.key, n.leaf.val)
+		if txn.conf.intern != nil {
+			v = txn.conf.intern(v)
+		}
+		nn.leaf = txn.allocLeaf(leafNode[U]{
+			key:  n.leaf.key,
+			val:  v,
+			meta: n.leaf.meta,
+		})
+	}
+	if len(n.edges()) != 0 {
+		nn.inner = &innerNode[U]{
+			edges: make(edges[U], len(n.edges())),
+			count: n.count(),
+		}
+		for i, e := range n.edges() {
+			nn.inner.edges[i] = edge[U]{
+				label: e.label,
+				node:  mapNode(txn, e.node, fn),
+			}
+		}
+	}
+	return nn
+}
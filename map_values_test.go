@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMapValues(t *testing.T) {
+	r := New[[]byte](WithCollation(FoldCaseCollation()), WithoutDeleteMerge())
+	for _, k := range []string{"B/1", "a/2", "A/3", "a/22"} {
+		r, _, _ = r.Insert([]byte(k), []byte(k[2:]))
+	}
+	r, _, _ = r.Delete([]byte("a/22"))
+
+	hash := func(v int) uint64 { return uint64(v) }
+	out := MapValues(r, func(k []byte, v []byte) int {
+		n, err := strconv.Atoi(string(v))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return n
+	}, WithContentHash(hash))
+
+	// The shape is the same, down to the node left behind by the delete.
+	layout := func(lines []string) string {
+		var out []string
+		for _, l := range lines {
+			out = append(out, strings.Split(l, "=")[0])
+		}
+		return strings.Join(out, "\n")
+	}
+	if layout(nodeLayout(out.Root())) != layout(nodeLayout(r.Root())) {
+		t.Fatalf("bad layout:\n%s", strings.Join(nodeLayout(out.Root()), "\n"))
+	}
+	if v, ok := out.Get([]byte("A/3")); !ok || v != 3 || out.Len() != 3 {
+		t.Fatalf("bad value: %v %v", v, ok)
+	}
+	if out.Hash() != out.Root().Hash(hash) {
+		t.Fatalf("bad hash")
+	}
+	if err := CheckOrdered(out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic")
+		}
+	}()
+	MapValues(r, func([]byte, []byte) int { return 0 }, WithCollation(identityCollation()))
+}
+
+// identityCollation returns a collation table that keeps the usual byte order.
+func identityCollation() [256]byte {
+	var table [256]byte
+	for i := range table {
+		table[i] = byte(i)
+	}
+	return table
+}
@@ -0,0 +1,330 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+// Merge returns the union of this tree and other. Keys that are in both trees
+// get the value returned by resolve, which is given the key and the values
+// from this tree and from other, in that order. A nil resolve keeps the value
+// from other.
+//
+// The trees are merged node by node, so subtrees that only one of the trees
+// has are shared with the result rather than copied, and so are subtrees that
+// both trees share, such as when they're versions of the same tree. This makes
+// merging trees with mostly distinct keys, like shards of a keyspace, much
+// cheaper than inserting every key of one into the other. Keys in subtrees
+// that both trees share keep their value without calling resolve.
+//
+// The result has this tree's options. If other orders its keys differently
+// because of its collation, its keys are inserted one at a time instead.
+func (t *Tree[T]) Merge(other *Tree[T], resolve func(k []byte, a, b T) T) *Tree[T] {
+	txn := t.Txn()
+	txn.Merge(other, resolve)
+	return txn.Commit()
+}
+
+// Merge adds the keys of other to the transaction's tree, in the same way as
+// Tree.Merge, so subtrees that only other has are grafted in rather than
+// inserted key by key. With mutation tracking, the watches on the nodes and
+// leaves that change fire on commit. If changes are tracked, the keys of other
+// are inserted one at a time so that each of them is recorded.
+func (t *Txn[T]) Merge(other *Tree[T], resolve func(k []byte, a, b T) T) {
+	if !t.checkUse("Merge") {
+		return
+	}
+	if resolve == nil {
+		resolve = func(_ []byte, _, b T) T { return b }
+	}
+	if t.trackChanges || !sameCollation(t.conf.collate, other.conf.collate) {
+		other.root.Walk(func(k []byte, v T) bool {
+			if old, ok := t.Get(k); ok {
+				v = resolve(k, old, v)
+			}
+			t.Insert(k, v)
+			return false
+		})
+		return
+	}
+
+	// The accounting of the other tree can be reused if it was done the same
+	// way, and otherwise it's redone over the whole result.
+	shared := t.conf == other.conf
+	if shared {
+		t.bytes += other.bytes
+		t.hash += other.hash
+	}
+	m := treeMerger[T]{txn: t, resolve: resolve, account: shared}
+	t.root = m.merge(t.root, other.root)
+	t.size = t.root.count()
+	if !shared {
+		t.recount()
+	}
+}
+
+// recount recomputes the size and content hash of the tree from scratch, if
+// they're tracked, after its root was replaced.
+func (t *Txn[T]) recount() {
+	if t.conf.sizer == nil && t.conf.hash == nil {
+		return
+	}
+	t.bytes, t.hash = 0, 0
+	t.root.Walk(func(k []byte, v T) bool {
+		if t.conf.sizer != nil {
+			t.bytes += t.entrySize(k, v)
+		}
+		if t.conf.hash != nil {
+			t.hash += t.entryHash(k, v)
+		}
+		return false
+	})
+}
+
+// sameCollation returns true if two collation tables are the same.
+func sameCollation(a, b *[256]byte) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// treeMerger merges the nodes of two trees.
+type treeMerger[T any] struct {
+	txn     *Txn[T]
+	resolve func(k []byte, a, b T) T
+
+	// account is set if the accounting of the transaction has to be
+	// adjusted for entries that are in both trees.
+	account bool
+}
+
+// merge returns the union of the subtrees under a and b, whose prefixes start
+// at the same point in the path.
+func (m *treeMerger[T]) merge(a, b *Node[T]) *Node[T] {
+	if a == b {
+		// The subtree was counted for both trees.
+		if m.account {
+			m.txn.unaccount(a)
+		}
+		return a
+	}
+	if m.txn.trackMutate {
+		m.txn.trackChannel(a.mutateCh)
+	}
+
+	// Unless the prefixes are the same, put what's below the shared part of
+	// them under temporary nodes that have the same prefix.
+	common := longestPrefix(a.prefix, b.prefix)
+	if common < len(a.prefix) {
+		a = m.txn.under(a, common)
+	}
+	if common < len(b.prefix) {
+		b = m.txn.under(b, common)
+	}
+
+	n := Node[T]{prefix: a.prefix}
+	switch {
+	case a.leaf != nil && b.leaf != nil:
+		n.leaf = m.mergeLeaves(a.leaf, b.leaf)
+	case a.leaf != nil:
+		n.leaf = a.leaf
+	default:
+		n.leaf = b.leaf
+	}
+
+	// Merge the sorted edges.
+	ae, be := a.edges(), b.edges()
+	var es edges[T]
+	if len(ae)+len(be) != 0 {
+		es = make(edges[T], 0, len(ae)+len(be))
+	}
+	count := n.count()
+	i, j := 0, 0
+	for i < len(ae) || j < len(be) {
+		var e edge[T]
+		switch {
+		case j == len(be) || (i < len(ae) && ae[i].label < be[j].label):
+			e = ae[i]
+			i++
+		case i == len(ae) || be[j].label < ae[i].label:
+			e = be[j]
+			j++
+		default:
+			e = edge[T]{
+				label: ae[i].label,
+				node:  m.merge(ae[i].node, be[j].node),
+			}
+			i++
+			j++
+		}
+		es = append(es, e)
+		count += e.node.count()
+	}
+	n.setEdges(es)
+	n.setCount(count)
+
+	nn := m.txn.allocNode(n)
+	if m.txn.aliasLeafPrefix(nn) {
+		nn.prefix = leafPrefix(nn.leaf, len(nn.prefix))
+	}
+	return nn
+}
+
+// under returns a temporary node with the first common bytes of n's prefix,
+// whose only child is a copy of n with the rest of its prefix.
+func (t *Txn[T]) under(n *Node[T], common int) *Node[T] {
+	child := t.allocNode(Node[T]{
+		prefix: n.prefix[common:],
+		leaf:   n.leaf,
+		inner:  n.sharedInner(),
+	})
+	if t.aliasLeafPrefix(child) {
+		child.prefix = leafPrefix(child.leaf, len(child.prefix))
+	}
+	return &Node[T]{
+		prefix: n.prefix[:common],
+		inner: &innerNode[T]{
+			edges: edges[T]{{label: child.prefix[0], node: child}},
+			count: n.count(),
+		},
+	}
+}
+
+// mergeLeaves returns the leaf for a key that's in both trees.
+func (m *treeMerger[T]) mergeLeaves(a, b *leafNode[T]) *leafNode[T] {
+	t := m.txn
+	if t.trackMutate {
+		t.trackChannel(a.mutateCh)
+	}
+	v := m.resolve(a.key, a.val, b.val)
+	if t.conf.intern != nil {
+		v = t.conf.intern(v)
+	}
+	if m.account {
+		if t.conf.sizer != nil {
+			t.bytes += t.entrySize(a.key, v) - t.entrySize(a.key, a.val) - t.entrySize(b.key, b.val)
+		}
+		if t.conf.hash != nil {
+			t.hash += t.entryHash(a.key, v) - t.entryHash(a.key, a.val) - t.entryHash(b.key, b.val)
+		}
+	}
+	return t.newLeaf(a.key, v, a)
+}
+
+// Intersect returns a tree with the keys of this tree that are also in other,
+// with their values from this tree. The trees are walked together, so subtrees
+// are skipped as soon as their prefixes diverge, and subtrees that both trees
+// share are kept whole.
+//
+// The result has this tree's options. If other orders its keys differently
+// because of its collation, the keys of this tree are looked up in it one at a
+// time instead.
+func (t *Tree[T]) Intersect(other *Tree[T]) *Tree[T] {
+	txn := t.Txn()
+	if !sameCollation(t.conf.collate, other.conf.collate) {
+		t.root.Walk(func(k []byte, _ T) bool {
+			if _, ok := other.Get(k); !ok {
+				txn.Delete(k)
+			}
+			return false
+		})
+		return txn.Commit()
+	}
+
+	root := txn.intersect(t.root, other.root)
+	if root == nil {
+		root = txn.allocNode(Node[T]{})
+	} else if len(root.prefix) != 0 {
+		// The root was collapsed with its only child.
+		root = txn.allocNode(Node[T]{
+			inner: &innerNode[T]{
+				edges: edges[T]{{label: root.prefix[0], node: root}},
+				count: root.count(),
+			},
+		})
+	}
+	txn.root = root
+	txn.size = root.count()
+	txn.recount()
+	return txn.Commit()
+}
+
+// intersect returns the subtree with the keys under a that are also under b,
+// whose prefixes start at the same point in the path, or nil if there are
+// none. The result may have a longer prefix than a, if it's left with a single
+// child and no leaf.
+func (t *Txn[T]) intersect(a, b *Node[T]) *Node[T] {
+	if a == b {
+		return a
+	}
+
+	// Line up the prefixes as for merge, unless they diverge.
+	orig := a
+	common := longestPrefix(a.prefix, b.prefix)
+	if common < len(a.prefix) && common < len(b.prefix) {
+		return nil
+	}
+	if common < len(a.prefix) {
+		a = t.under(a, common)
+	}
+	if common < len(b.prefix) {
+		b = t.under(b, common)
+	}
+
+	n := Node[T]{prefix: a.prefix}
+	if a.leaf != nil && b.leaf != nil {
+		n.leaf = a.leaf
+	}
+	same := n.leaf == a.leaf
+	ae, be := a.edges(), b.edges()
+	var es edges[T]
+	count := n.count()
+	i, j := 0, 0
+	for i < len(ae) && j < len(be) {
+		switch ea, eb := ae[i], be[j]; {
+		case ea.label < eb.label:
+			same = false
+			i++
+		case eb.label < ea.label:
+			j++
+		default:
+			child := t.intersect(ea.node, eb.node)
+			if child != ea.node {
+				same = false
+			}
+			if child != nil {
+				es = append(es, edge[T]{label: ea.label, node: child})
+				count += child.count()
+			}
+			i++
+			j++
+		}
+	}
+	if i < len(ae) {
+		same = false
+	}
+
+	switch {
+	case same:
+		return orig
+	case count == 0:
+		return nil
+	case n.leaf == nil && len(es) == 1:
+		// Collapse the node with its only child.
+		child := es[0].node
+		if a != orig && child == ae[0].node {
+			return orig
+		}
+		n = Node[T]{
+			prefix: concat(n.prefix, child.prefix),
+			leaf:   child.leaf,
+		}
+		es, count = child.edges(), child.count()
+	}
+	n.setEdges(es)
+	n.setCount(count)
+	nn := t.allocNode(n)
+	if t.aliasLeafPrefix(nn) {
+		nn.prefix = leafPrefix(nn.leaf, len(nn.prefix))
+	}
+	return nn
+}
@@ -0,0 +1,219 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	hash := func(v int) uint64 { return uint64(v) * 31 }
+	size := func(v int) int { return v % 7 }
+	opts := []Option{WithContentHash(hash), WithMemoryBudget(1<<30, size)}
+	sum := func(_ []byte, a, b int) int { return a + b }
+
+	randomTree := func(r *Tree[int], n int, prefix string) *Tree[int] {
+		txn := r.Txn()
+		for i := 0; i < n; i++ {
+			k := fmt.Sprintf("%s%x", prefix, rand.Intn(1000))
+			txn.Insert([]byte(k), rand.Intn(100))
+		}
+		return txn.Commit()
+	}
+	expectMerge := func(a, b *Tree[int]) map[string]int {
+		out := make(map[string]int)
+		a.Root().Walk(func(k []byte, v int) bool {
+			out[string(k)] = v
+			return false
+		})
+		b.Root().Walk(func(k []byte, v int) bool {
+			if old, ok := out[string(k)]; ok {
+				v = sum(k, old, v)
+			}
+			out[string(k)] = v
+			return false
+		})
+		return out
+	}
+	check := func(r *Tree[int], expect map[string]int) {
+		t.Helper()
+		got := make(map[string]int)
+		r.Root().Walk(func(k []byte, v int) bool {
+			got[string(k)] = v
+			return false
+		})
+		if !reflect.DeepEqual(got, expect) {
+			t.Fatalf("bad merge")
+		}
+		if r.Len() != len(expect) {
+			t.Fatalf("bad len %d", r.Len())
+		}
+		fresh := New[int](opts...)
+		for k, v := range expect {
+			fresh, _, _ = fresh.Insert([]byte(k), v)
+		}
+		if h1, _ := r.ContentHash(); r.Bytes() != fresh.Bytes() || h1 != fresh.hash {
+			t.Fatalf("bad accounting")
+		}
+		checkCounts(t, r.Root())
+		checkLeafPrefix(t, r.Root())
+	}
+
+	base := New[int](opts...)
+	for i := 0; i < 20; i++ {
+		a := randomTree(base, 200, "a/")
+		b := randomTree(base, 200, "")
+		check(a.Merge(b, sum), expectMerge(a, b))
+		check(b.Merge(a, sum), expectMerge(b, a))
+
+		// Trees with separate options redo the accounting.
+		c := randomTree(New[int](opts...), 100, "a/1")
+		check(a.Merge(c, sum), expectMerge(a, c))
+
+		// Versions of the same tree share subtrees, which aren't resolved.
+		d := randomTree(a, 10, "a/f")
+		m := a.Merge(d, func(_ []byte, _, b int) int { return b })
+		check(m, expectMerge(d, New[int]()))
+	}
+
+	// Disjoint subtrees are shared with the result.
+	a := randomTree(base, 100, "a/")
+	b := randomTree(base, 100, "b/")
+	m := a.Merge(b, nil)
+	_, aa := a.Root().getEdge('a')
+	_, mb := m.Root().getEdge('b')
+	_, bb := b.Root().getEdge('b')
+	if aa == nil || bb == nil || m.Root().edges()[0].node != aa || mb != bb {
+		t.Fatalf("expected subtrees to be shared")
+	}
+	if m.Len() != a.Len()+b.Len() {
+		t.Fatalf("bad len")
+	}
+
+	// Trees with different collations are merged key by key.
+	c := New[int](WithCollation(FoldCaseCollation()))
+	for _, k := range []string{"B", "a", "b/1"} {
+		c, _, _ = c.Insert([]byte(k), 1)
+	}
+	m = c.Merge(New[int]().Merge(a, nil), nil)
+	if m.Len() != c.Len()+a.Len() {
+		t.Fatalf("bad len")
+	}
+	if k, _, _ := m.Iterator().Next(); string(k) != "a" {
+		t.Fatalf("bad first key %q", k)
+	}
+	checkCounts(t, m.Root())
+}
+
+func TestTxn_Merge(t *testing.T) {
+	r := New[int]()
+	for i, k := range []string{"a/1", "a/2", "b/1"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	other := New[int]()
+	for i, k := range []string{"a/2", "c/1", "c/2"} {
+		other, _, _ = other.Insert([]byte(k), 10+i)
+	}
+	leafWatch, _, _ := r.Root().GetWatch([]byte("a/2"))
+	otherWatch, _, _ := r.Root().GetWatch([]byte("b/1"))
+
+	// Writes before and after the merge are kept.
+	txn := r.Txn()
+	txn.TrackMutate(true)
+	txn.Insert([]byte("a/3"), 3)
+	txn.Merge(other, func(_ []byte, a, b int) int { return a + b })
+	txn.Delete([]byte("a/1"))
+	m := txn.Commit()
+	verifyTree(t, []string{"a/2", "a/3", "b/1", "c/1", "c/2"}, m)
+	if v, _ := m.Get([]byte("a/2")); v != 11 {
+		t.Fatalf("bad value: %d", v)
+	}
+	checkCounts(t, m.Root())
+	checkLeafPrefix(t, m.Root())
+	if _, c := m.Root().getEdge('c'); c != other.Root().edges()[1].node {
+		t.Fatalf("expected the subtree to be grafted")
+	}
+	if !isClosedRecv(leafWatch) {
+		t.Fatalf("expected the watch on the merged key to fire")
+	}
+	if isClosedRecv(otherWatch) {
+		t.Fatalf("expected other watch not to fire")
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	opts := []Option{WithContentHash(func(v int) uint64 { return uint64(v) }), WithMemoryBudget(1<<30, func(int) int { return 1 })}
+	randomTree := func(r *Tree[int], n int) *Tree[int] {
+		txn := r.Txn()
+		for i := 0; i < n; i++ {
+			k := fmt.Sprintf("%c/%x", "abc"[rand.Intn(3)], rand.Intn(300))
+			txn.Insert([]byte(k), rand.Intn(100))
+		}
+		return txn.Commit()
+	}
+	check := func(a, b *Tree[int]) *Tree[int] {
+		t.Helper()
+		r := a.Intersect(b)
+		expect := New[int](opts...)
+		a.Root().Walk(func(k []byte, v int) bool {
+			if _, ok := b.Get(k); ok {
+				expect, _, _ = expect.Insert(k, v)
+			}
+			return false
+		})
+		if got, want := nodeLayout(r.Root()), nodeLayout(expect.Root()); !reflect.DeepEqual(got, want) {
+			t.Fatalf("layouts differ:\n%v\n%v", got, want)
+		}
+		if h, _ := r.ContentHash(); r.Len() != expect.Len() || r.Bytes() != expect.Bytes() || h != expect.hash {
+			t.Fatalf("bad accounting")
+		}
+		checkLeafPrefix(t, r.Root())
+		return r
+	}
+
+	base := New[int](opts...)
+	for i := 0; i < 20; i++ {
+		a := randomTree(base, 1+rand.Intn(300))
+		b := randomTree(base, 1+rand.Intn(300))
+		check(a, b)
+		check(b, a)
+		check(a, New[int]())
+		check(New[int](opts...), a)
+
+		// Versions of the same tree keep shared subtrees.
+		c := randomTree(a, 5)
+		check(check(a, c), a)
+		if r := check(a, a); r.Root() != a.Root() {
+			t.Fatalf("expected the root to be shared")
+		}
+	}
+
+	// Disjoint prefixes give an empty tree, and shared subtrees are kept.
+	a := randomTree(base, 100)
+	b, _, _ := a.Insert([]byte("d/1"), 1)
+	r := check(b, a)
+	for i, e := range r.Root().edges() {
+		if e.node != a.Root().edges()[i].node {
+			t.Fatalf("expected subtrees to be shared")
+		}
+	}
+	d, _, _ := base.Insert([]byte("d/2"), 2)
+	if r := check(b, d); r.Len() != 0 {
+		t.Fatalf("bad len %d", r.Len())
+	}
+
+	// Trees with different collations are intersected key by key.
+	c := New[int](WithCollation(FoldCaseCollation()))
+	for _, k := range []string{"B", "a/1", "b/2"} {
+		c, _, _ = c.Insert([]byte(k), 1)
+	}
+	o := New[int]()
+	for _, k := range []string{"a/1", "b/2", "b/3"} {
+		o, _, _ = o.Insert([]byte(k), 2)
+	}
+	verifyTree(t, []string{"a/1", "b/2"}, c.Intersect(o))
+}
@@ -5,6 +5,7 @@ package iradix
 
 import (
 	"bytes"
+	"context"
 	"sort"
 )
 
@@ -18,6 +19,19 @@ type leafNode[T any] struct {
 	mutateCh chan struct{}
 	key      []byte
 	val      T
+
+	// meta is only set for trees created with WithLeafMeta.
+	meta *LeafMeta
+}
+
+// LeafMeta holds the generations at which a leaf was created and last
+// modified. See Tree.Generation.
+type LeafMeta struct {
+	// Created is the generation of the commit that first inserted the key.
+	Created uint64
+
+	// Modified is the generation of the commit that last updated the key.
+	Modified uint64
 }
 
 // edge is used to represent an edge node
@@ -37,72 +51,188 @@ type Node[T any] struct {
 	// prefix is the common prefix we ignore
 	prefix []byte
 
+	// inner holds the edges and the leaf count of a node with children. Most
+	// nodes are leaf-only ones, so they leave it nil and count their own
+	// leaf instead of paying for an edge slice and a count of their own.
+	inner *innerNode[T]
+}
+
+// innerNode is the part of a Node that only nodes with children need. It's
+// owned by a single node, and only changed while that node is writable.
+type innerNode[T any] struct {
 	// Edges should be stored in-order for iteration.
 	// We avoid a fully materialized slice to save memory,
 	// since in most cases we expect to be sparse
 	edges edges[T]
+
+	// count is the number of leaves in the subtree rooted at the node,
+	// including the leaf of the node, if any.
+	count int
+}
+
+// edges returns the edges of n, in order of their labels.
+func (n *Node[T]) edges() edges[T] {
+	if n.inner == nil {
+		return nil
+	}
+	return n.inner.edges
+}
+
+// count returns the number of leaves in the subtree rooted at n, including the
+// leaf of n, if any.
+func (n *Node[T]) count() int {
+	if n.inner != nil {
+		return n.inner.count
+	}
+	if n.leaf != nil {
+		return 1
+	}
+	return 0
+}
+
+// sharedInner returns a new inner part with the same edges and count as n's,
+// for a node that takes over the children of n, or nil if n has no children.
+func (n *Node[T]) sharedInner() *innerNode[T] {
+	if len(n.edges()) == 0 {
+		return nil
+	}
+	return &innerNode[T]{edges: n.inner.edges, count: n.inner.count}
+}
+
+// setEdges replaces the edges of n, which must be writable. The count is left
+// for the caller to update.
+func (n *Node[T]) setEdges(e edges[T]) {
+	if n.inner == nil {
+		if len(e) == 0 {
+			return
+		}
+		n.inner = &innerNode[T]{count: n.count()}
+	}
+	n.inner.edges = e
+	n.compact()
+}
+
+// setCount sets the number of leaves under n, which must be writable.
+func (n *Node[T]) setCount(c int) {
+	if n.inner == nil {
+		if c == n.count() {
+			return
+		}
+		n.inner = &innerNode[T]{}
+	}
+	n.inner.count = c
+	n.compact()
+}
+
+// setLeaf replaces the leaf of n, which must be writable. The count is left for
+// the caller to update, like it would be if it were stored for every node.
+func (n *Node[T]) setLeaf(l *leafNode[T]) {
+	if n.inner == nil && (l == nil) != (n.leaf == nil) {
+		n.inner = &innerNode[T]{count: n.count()}
+	}
+	n.leaf = l
+	n.compact()
+}
+
+// compact drops the inner part of n once it has no edges and its count is the
+// one n implies by itself.
+func (n *Node[T]) compact() {
+	if n.inner == nil || len(n.inner.edges) != 0 {
+		return
+	}
+	if (n.leaf != nil && n.inner.count == 1) || (n.leaf == nil && n.inner.count == 0) {
+		n.inner = nil
+	}
 }
 
 func (n *Node[T]) isLeaf() bool {
 	return n.leaf != nil
 }
 
-func (n *Node[T]) addEdge(e edge[T]) {
-	num := len(n.edges)
-	idx := sort.Search(num, func(i int) bool {
-		return n.edges[i].label >= e.label
+// isLeafOnly returns true if n holds a leaf and has no edges. The prefix of such
+// a node is always a suffix of its leaf's key, so it's stored as a subslice of
+// the key rather than in memory of its own, see leafPrefix.
+func (n *Node[T]) isLeafOnly() bool {
+	return n.leaf != nil && len(n.edges()) == 0
+}
+
+// leafPrefix returns the last n bytes of the key of l, for use as the prefix of
+// a leaf-only node. The capacity is capped so the key can't be appended to.
+func leafPrefix[T any](l *leafNode[T], n int) []byte {
+	if n == 0 {
+		return nil
+	}
+	return l.key[len(l.key)-n : len(l.key) : len(l.key)]
+}
+
+// searchEdges returns the index of the first edge with a label greater than or
+// equal to label. Labels are unique, so at most label edges can come before it
+// and at least label-(256-len(n.edges)) must, which narrows the search for dense
+// nodes. A node with all 256 edges needs no search at all.
+func (n *Node[T]) searchEdges(label byte) int {
+	es := n.edges()
+	num := len(es)
+	lo, hi := int(label)-(256-num), int(label)
+	if lo < 0 {
+		lo = 0
+	}
+	if hi > num {
+		hi = num
+	}
+	return lo + sort.Search(hi-lo, func(i int) bool {
+		return es[lo+i].label >= label
 	})
-	n.edges = append(n.edges, e)
+}
+
+func (n *Node[T]) addEdge(e edge[T]) {
+	es := n.edges()
+	num := len(es)
+	idx := n.searchEdges(e.label)
+	es = append(es, e)
 	if idx != num {
-		copy(n.edges[idx+1:], n.edges[idx:num])
-		n.edges[idx] = e
+		copy(es[idx+1:], es[idx:num])
+		es[idx] = e
 	}
+	n.setEdges(es)
 }
 
 func (n *Node[T]) replaceEdge(e edge[T]) {
-	num := len(n.edges)
-	idx := sort.Search(num, func(i int) bool {
-		return n.edges[i].label >= e.label
-	})
-	if idx < num && n.edges[idx].label == e.label {
-		n.edges[idx].node = e.node
+	es := n.edges()
+	idx := n.searchEdges(e.label)
+	if idx < len(es) && es[idx].label == e.label {
+		es[idx].node = e.node
 		return
 	}
 	panic("replacing missing edge")
 }
 
 func (n *Node[T]) getEdge(label byte) (int, *Node[T]) {
-	num := len(n.edges)
-	idx := sort.Search(num, func(i int) bool {
-		return n.edges[i].label >= label
-	})
-	if idx < num && n.edges[idx].label == label {
-		return idx, n.edges[idx].node
+	num := len(n.edges())
+	idx := n.searchEdges(label)
+	if idx < num && n.edges()[idx].label == label {
+		return idx, n.edges()[idx].node
 	}
 	return -1, nil
 }
 
 func (n *Node[T]) getLowerBoundEdge(label byte) (int, *Node[T]) {
-	num := len(n.edges)
-	idx := sort.Search(num, func(i int) bool {
-		return n.edges[i].label >= label
-	})
+	num := len(n.edges())
+	idx := n.searchEdges(label)
 	// we want lower bound behavior so return even if it's not an exact match
 	if idx < num {
-		return idx, n.edges[idx].node
+		return idx, n.edges()[idx].node
 	}
 	return -1, nil
 }
 
 func (n *Node[T]) delEdge(label byte) {
-	num := len(n.edges)
-	idx := sort.Search(num, func(i int) bool {
-		return n.edges[i].label >= label
-	})
-	if idx < num && n.edges[idx].label == label {
-		copy(n.edges[idx:], n.edges[idx+1:])
-		n.edges[len(n.edges)-1] = edge[T]{}
-		n.edges = n.edges[:len(n.edges)-1]
+	es := n.edges()
+	num := len(es)
+	idx := n.searchEdges(label)
+	if idx < num && es[idx].label == label {
+		copy(es[idx:], es[idx+1:])
+		es[num-1] = edge[T]{}
+		n.setEdges(es[:num-1])
 	}
 }
 
@@ -143,6 +273,40 @@ func (n *Node[T]) Get(k []byte) (T, bool) {
 	return val, ok
 }
 
+// GetMeta is used to lookup the metadata of a specific key, returning the
+// metadata and if it was found. If the key exists but no metadata was
+// recorded for it, this returns false.
+func (n *Node[T]) GetMeta(k []byte) (LeafMeta, bool) {
+	if leaf := n.getLeaf(k); leaf != nil && leaf.meta != nil {
+		return *leaf.meta, true
+	}
+	return LeafMeta{}, false
+}
+
+// getLeaf returns the leaf stored under the given key, or nil if there isn't
+// one.
+func (n *Node[T]) getLeaf(k []byte) *leafNode[T] {
+	search := k
+	for {
+		// Check for key exhaustion
+		if len(search) == 0 {
+			return n.leaf
+		}
+
+		// Look for an edge
+		_, n = n.getEdge(search[0])
+		if n == nil {
+			return nil
+		}
+
+		// Consume the search prefix
+		if !bytes.HasPrefix(search, n.prefix) {
+			return nil
+		}
+		search = search[len(n.prefix):]
+	}
+}
+
 // LongestPrefix is like Get, but instead of an
 // exact match, it will return the longest prefix match.
 func (n *Node[T]) LongestPrefix(k []byte) ([]byte, T, bool) {
@@ -179,14 +343,306 @@ func (n *Node[T]) LongestPrefix(k []byte) ([]byte, T, bool) {
 	return nil, zero, false
 }
 
+// LongestPrefixWithin is like LongestPrefix, but only considers matches of at
+// most maxLen bytes, so the descent stops there rather than following a long
+// key to its end when deeper matches are never relevant.
+func (n *Node[T]) LongestPrefixWithin(k []byte, maxLen int) ([]byte, T, bool) {
+	if maxLen < 0 {
+		maxLen = 0
+	}
+	if len(k) > maxLen {
+		k = k[:maxLen]
+	}
+	return n.LongestPrefix(k)
+}
+
+// LongestPrefixSegments is like LongestPrefixWithin, but bounds the match to the
+// first segments of k, where each segment ends with sep. For example, with sep
+// '/' and segments 2 the key "svc/web/1/x" can match "svc/" or "svc/web/", but
+// not "svc/web/1".
+func (n *Node[T]) LongestPrefixSegments(k []byte, sep byte, segments int) ([]byte, T, bool) {
+	end := 0
+	for ; segments > 0; segments-- {
+		i := bytes.IndexByte(k[end:], sep)
+		if i < 0 {
+			end = len(k)
+			break
+		}
+		end += i + 1
+	}
+	return n.LongestPrefix(k[:end])
+}
+
+// GetString is like Get, but takes the key as a string. It doesn't allocate.
+func (n *Node[T]) GetString(k string) (T, bool) {
+	search := k
+	for {
+		// Check for key exhaustion
+		if len(search) == 0 {
+			if n.isLeaf() {
+				return n.leaf.val, true
+			}
+			break
+		}
+
+		// Look for an edge
+		_, n = n.getEdge(search[0])
+		if n == nil {
+			break
+		}
+
+		// Consume the search prefix
+		if !hasPrefixString(search, n.prefix) {
+			break
+		}
+		search = search[len(n.prefix):]
+	}
+	var zero T
+	return zero, false
+}
+
+// LongestPrefixString is like LongestPrefix, but takes the key as a string and
+// returns the matched key as a prefix of k, so it doesn't allocate.
+func (n *Node[T]) LongestPrefixString(k string) (string, T, bool) {
+	var last *leafNode[T]
+	search := k
+	for {
+		// Look for a leaf node
+		if n.isLeaf() {
+			last = n.leaf
+		}
+
+		// Check for key exhaustion
+		if len(search) == 0 {
+			break
+		}
+
+		// Look for an edge
+		_, n = n.getEdge(search[0])
+		if n == nil {
+			break
+		}
+
+		// Consume the search prefix
+		if hasPrefixString(search, n.prefix) {
+			search = search[len(n.prefix):]
+		} else {
+			break
+		}
+	}
+	if last != nil {
+		return k[:len(last.key)], last.val, true
+	}
+	var zero T
+	return "", zero, false
+}
+
+// Match is the result of a longest prefix match for one key in a batch given to
+// ClassifyMany.
+type Match[T any] struct {
+	// Key is the longest key in the tree that is a prefix of the key being
+	// classified, and Value its value. Found is false, and the others are
+	// unset, if no key matched.
+	Key   []byte
+	Value T
+	Found bool
+}
+
+// ClassifyMany is like LongestPrefix for a batch of keys, returning the match
+// for each key at the same index. The keys are visited in sorted order, and
+// each one resumes the descent of the previous key from the deepest node on the
+// part of the path they share, so batches with many common prefixes visit far
+// fewer nodes than separate lookups.
+func (n *Node[T]) ClassifyMany(keys [][]byte) []Match[T] {
+	out := make([]Match[T], len(keys))
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return bytes.Compare(keys[order[i]], keys[order[j]]) < 0
+	})
+
+	// Each frame holds a node on the path of the previous key, the number
+	// of key bytes consumed to reach it, and the last leaf seen down to it.
+	type frame struct {
+		n     *Node[T]
+		depth int
+		last  *leafNode[T]
+	}
+	stack := []frame{{n: n, last: n.leaf}}
+	var prev []byte
+	for _, idx := range order {
+		k := keys[idx]
+
+		// Resume from the deepest node whose path is shared with the
+		// previous key.
+		common := longestPrefix(prev, k)
+		for len(stack) > 1 && stack[len(stack)-1].depth > common {
+			stack = stack[:len(stack)-1]
+		}
+		f := stack[len(stack)-1]
+		for f.depth < len(k) {
+			_, child := f.n.getEdge(k[f.depth])
+			if child == nil || !bytes.HasPrefix(k[f.depth:], child.prefix) {
+				break
+			}
+			f.n = child
+			f.depth += len(child.prefix)
+			if child.leaf != nil {
+				f.last = child.leaf
+			}
+			stack = append(stack, f)
+		}
+
+		if f.last != nil {
+			out[idx] = Match[T]{Key: f.last.key, Value: f.last.val, Found: true}
+		}
+		prev = k
+	}
+	return out
+}
+
+// hasPrefixString is like bytes.HasPrefix for a string. The conversion in the
+// comparison doesn't allocate.
+func hasPrefixString(s string, prefix []byte) bool {
+	return len(s) >= len(prefix) && s[:len(prefix)] == string(prefix)
+}
+
+// leafAt returns the leaf with the idx-th smallest key under n, counting from
+// zero, or nil if idx is out of range. This uses the leaf counts so it only
+// visits the nodes on the path to the leaf.
+func (n *Node[T]) leafAt(idx int) *leafNode[T] {
+	if idx < 0 || idx >= n.count() {
+		return nil
+	}
+	for {
+		if n.leaf != nil {
+			if idx == 0 {
+				return n.leaf
+			}
+			idx--
+		}
+		var next *Node[T]
+		for _, e := range n.edges() {
+			if idx < e.node.count() {
+				next = e.node
+				break
+			}
+			idx -= e.node.count()
+		}
+		if next == nil {
+			panic("iradix: leaf counts are inconsistent")
+		}
+		n = next
+	}
+}
+
+// Select returns the idx-th smallest key under this node, counting from zero,
+// with its value, or false if idx is out of range. Like Rank, this uses the
+// leaf counts kept by the nodes, so it only visits the nodes on the path to the
+// key, which makes it suitable for paging through keys by offset.
+func (n *Node[T]) Select(idx int) ([]byte, T, bool) {
+	leaf := n.leafAt(idx)
+	if leaf == nil {
+		var zero T
+		return nil, zero, false
+	}
+	return leaf.key, leaf.val, true
+}
+
+// Rank returns the number of keys under this node that are smaller than k,
+// which is the index that Select would return k at, and whether k itself is
+// present.
+func (n *Node[T]) Rank(k []byte) (int, bool) {
+	rank := 0
+	search := k
+	for {
+		if len(search) == 0 {
+			return rank, n.leaf != nil
+		}
+
+		// The leaf and the subtrees under smaller labels all come first.
+		if n.leaf != nil {
+			rank++
+		}
+		idx := n.searchEdges(search[0])
+		for _, e := range n.edges()[:idx] {
+			rank += e.node.count()
+		}
+		if idx == len(n.edges()) || n.edges()[idx].label != search[0] {
+			return rank, false
+		}
+
+		child := n.edges()[idx].node
+		if !bytes.HasPrefix(search, child.prefix) {
+			if comparePrefix(child.prefix, search) < 0 {
+				rank += child.count()
+			}
+			return rank, false
+		}
+		search = search[len(child.prefix):]
+		n = child
+	}
+}
+
+// ChunkBoundaries splits the keys under this node into parts contiguous chunks
+// of as equal size as possible, and returns the first key of each chunk in
+// order. Each chunk runs from its boundary key up to, but not including, the
+// next one, and the last chunk runs to the end. If there are fewer keys than
+// parts, every key is its own chunk. The boundaries only depend on the keys,
+// not on the shape of the tree, so the same contents always give the same
+// chunks. The returned keys must not be modified.
+func (n *Node[T]) ChunkBoundaries(parts int) [][]byte {
+	if parts <= 0 || n.count() == 0 {
+		return nil
+	}
+	if parts > n.count() {
+		parts = n.count()
+	}
+	bounds := make([][]byte, parts)
+	for i := range bounds {
+		bounds[i] = n.leafAt(i * n.count() / parts).key
+	}
+	return bounds
+}
+
+// Prefix returns a copy of the part of the path that this node adds to its
+// parent's. The root has an empty prefix.
+func (n *Node[T]) Prefix() []byte {
+	return append([]byte{}, n.prefix...)
+}
+
+// Fanout returns the number of children of this node.
+func (n *Node[T]) Fanout() int {
+	return len(n.edges())
+}
+
+// ChildLabels returns the first byte of the prefix of each child of this node,
+// in order. The result is a new slice that the caller may modify.
+func (n *Node[T]) ChildLabels() []byte {
+	labels := make([]byte, len(n.edges()))
+	for i, e := range n.edges() {
+		labels[i] = e.label
+	}
+	return labels
+}
+
+// Child returns the child of this node whose prefix starts with label, or nil
+// if there isn't one.
+func (n *Node[T]) Child(label byte) *Node[T] {
+	_, child := n.getEdge(label)
+	return child
+}
+
 // Minimum is used to return the minimum value in the tree
 func (n *Node[T]) Minimum() ([]byte, T, bool) {
 	for {
 		if n.isLeaf() {
 			return n.leaf.key, n.leaf.val, true
 		}
-		if len(n.edges) > 0 {
-			n = n.edges[0].node
+		if len(n.edges()) > 0 {
+			n = n.edges()[0].node
 		} else {
 			break
 		}
@@ -198,8 +654,8 @@ func (n *Node[T]) Minimum() ([]byte, T, bool) {
 // Maximum is used to return the maximum value in the tree
 func (n *Node[T]) Maximum() ([]byte, T, bool) {
 	for {
-		if num := len(n.edges); num > 0 {
-			n = n.edges[num-1].node // bug?
+		if num := len(n.edges()); num > 0 {
+			n = n.edges()[num-1].node // bug?
 			continue
 		}
 		if n.isLeaf() {
@@ -212,6 +668,30 @@ func (n *Node[T]) Maximum() ([]byte, T, bool) {
 	return nil, zero, false
 }
 
+// LongestCommonPrefix returns the longest prefix shared by all the keys under
+// this node, or nil if there are none. It's read off the chain of nodes at the
+// top of the subtree, down to the first one that holds a leaf or has more than
+// one child, so only that chain and one path below it are visited. The result
+// is part of a stored key, so it must not be modified.
+func (n *Node[T]) LongestCommonPrefix() []byte {
+	for n.leaf == nil && len(n.edges()) == 1 {
+		n = n.edges()[0].node
+	}
+	if n.leaf == nil && len(n.edges()) == 0 {
+		return nil
+	}
+
+	// Every key under n shares the path to the end of its prefix, so cut the
+	// rest of the path off the smallest key.
+	below, m := 0, n
+	for m.leaf == nil {
+		m = m.edges()[0].node
+		below += len(m.prefix)
+	}
+	end := len(m.leaf.key) - below
+	return m.leaf.key[:end:end]
+}
+
 // Iterator is used to return an iterator at
 // the given node to walk the tree
 func (n *Node[T]) Iterator() *Iterator[T] {
@@ -278,6 +758,207 @@ func (n *Node[T]) WalkPrefix(prefix []byte, fn WalkFn[T]) {
 	}
 }
 
+// ctxCheckInterval is the number of keys visited between checks of whether the
+// context of WalkCtx or Iterator.NextCtx is done, which amortizes the cost of
+// the check over long scans. Txn.NotifyCtx checks as often, counting the
+// channels it closes.
+const ctxCheckInterval = 256
+
+// WalkCtx is like Walk, but checks ctx every few hundred keys and stops the walk
+// if it's done, returning ctx.Err(). It returns nil if the walk finished or was
+// stopped by fn.
+func (n *Node[T]) WalkCtx(ctx context.Context, fn WalkFn[T]) error {
+	var err error
+	visited := 0
+	recursiveWalk(n, func(k []byte, v T) bool {
+		if visited%ctxCheckInterval == 0 {
+			if err = ctx.Err(); err != nil {
+				return true
+			}
+		}
+		visited++
+		return fn(k, v)
+	})
+	return err
+}
+
+// WalkGlob walks the keys under this node that match the given pattern, in
+// which '*' matches any sequence of bytes, including an empty one, and '?'
+// matches any single byte. Every other byte matches itself, and unlike with
+// path.Match the '/' byte isn't special, so "service/*/leader" also matches
+// "service/a/b/leader". Only the keys with the literal prefix of the pattern
+// before its first wildcard are visited.
+func (n *Node[T]) WalkGlob(pattern []byte, fn WalkFn[T]) {
+	walkGlob(n, globPrefix(pattern), pattern, fn)
+}
+
+// globPrefix returns the part of pattern before its first wildcard.
+func globPrefix(pattern []byte) []byte {
+	if i := bytes.IndexAny(pattern, "*?"); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+// walkGlob walks the keys under n whose paths start with prefix and that match
+// pattern.
+func walkGlob[T any](n *Node[T], prefix, pattern []byte, fn WalkFn[T]) {
+	n.WalkPrefix(prefix, func(k []byte, v T) bool {
+		if globMatch(pattern, k) {
+			return fn(k, v)
+		}
+		return false
+	})
+}
+
+// globMatch reports whether k matches the pattern as described by WalkGlob.
+func globMatch(pattern, k []byte) bool {
+	// On a mismatch, backtrack to the last '*' and have it match one more
+	// byte, which is enough since a later '*' can match anything an earlier
+	// one could.
+	p, i := 0, 0
+	star, starI := -1, 0
+	for i < len(k) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, starI = p, i
+			p++
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == k[i]):
+			p++
+			i++
+		case star >= 0:
+			starI++
+			p, i = star+1, starI
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// IsEmptyPrefix returns true if there are no keys under this node with the
+// given prefix. This only visits the nodes along the prefix.
+func (n *Node[T]) IsEmptyPrefix(prefix []byte) bool {
+	pn := n.prefixNode(prefix)
+	return pn == nil || pn.count() == 0
+}
+
+// CountPrefix returns the number of keys under this node with the given prefix.
+// The nodes keep a count of the leaves under them, so this only visits the
+// nodes along the prefix, rather than walking the keys.
+func (n *Node[T]) CountPrefix(prefix []byte) int {
+	pn := n.prefixNode(prefix)
+	if pn == nil {
+		return 0
+	}
+	return pn.count()
+}
+
+// ListPrefix lists the keys under this node with the given prefix like a
+// directory, as S3's ListObjects does with a delimiter. The keys that don't
+// contain the delimiter after the prefix are returned in order with their
+// values, and the others are grouped by the part of their key up to and
+// including the first delimiter after the prefix, which are returned in order
+// as the common prefixes. Only the nodes down to the first delimiter of each
+// group are visited, so the keys inside the groups aren't walked.
+func (n *Node[T]) ListPrefix(prefix []byte, delimiter byte) (entries []KV[T], commonPrefixes [][]byte) {
+	l := lister[T]{prefix: prefix, delimiter: delimiter}
+	l.node(n, nil, 0)
+	return l.entries, l.commonPrefixes
+}
+
+// lister collects the results of ListPrefix.
+type lister[T any] struct {
+	prefix         []byte
+	delimiter      byte
+	entries        []KV[T]
+	commonPrefixes [][]byte
+}
+
+// node lists the keys under n, whose path is path, of which the first checked
+// bytes are known not to contain the delimiter after the prefix.
+func (l *lister[T]) node(n *Node[T], path []byte, checked int) {
+	if n.count() == 0 {
+		return
+	}
+	if len(path) < len(l.prefix) {
+		if !bytes.HasPrefix(l.prefix, path) {
+			return
+		}
+	} else if !bytes.HasPrefix(path, l.prefix) {
+		return
+	}
+	if checked < len(l.prefix) {
+		checked = len(l.prefix)
+	}
+	if checked < len(path) {
+		if i := bytes.IndexByte(path[checked:], l.delimiter); i >= 0 {
+			end := checked + i + 1
+			l.commonPrefixes = append(l.commonPrefixes, n.leafAt(0).key[:end:end])
+			return
+		}
+		checked = len(path)
+	}
+
+	if n.leaf != nil && len(path) >= len(l.prefix) {
+		l.entries = append(l.entries, KV[T]{Key: n.leaf.key, Val: n.leaf.val})
+	}
+	if len(path) < len(l.prefix) {
+		if _, child := n.getEdge(l.prefix[len(path)]); child != nil {
+			l.node(child, append(path, child.prefix...), checked)
+		}
+		return
+	}
+	for _, e := range n.edges() {
+		l.node(e.node, append(path, e.node.prefix...), checked)
+	}
+}
+
+// SinglePrefix returns the key and value under this node with the given
+// prefix if there's exactly one, or false if there are none or several. This
+// uses the leaf counts, so it only visits the nodes along the prefix and down
+// to the key.
+func (n *Node[T]) SinglePrefix(prefix []byte) (KV[T], bool) {
+	pn := n.prefixNode(prefix)
+	if pn == nil || pn.count() != 1 {
+		return KV[T]{}, false
+	}
+	leaf, _ := minLeaf(pn, nil)
+	return KV[T]{Key: leaf.key, Val: leaf.val}, true
+}
+
+// prefixNode returns the node holding exactly the keys under n with the given
+// prefix, or nil if there are none.
+func (n *Node[T]) prefixNode(prefix []byte) *Node[T] {
+	pn, _ := n.findPrefix(prefix)
+	return pn
+}
+
+// findPrefix is like prefixNode, but also returns where the prefix of the node
+// starts in the given prefix.
+func (n *Node[T]) findPrefix(prefix []byte) (*Node[T], int) {
+	start := 0
+	for consumed := 0; consumed < len(prefix); {
+		_, n = n.getEdge(prefix[consumed])
+		if n == nil {
+			return nil, 0
+		}
+		start = consumed
+		rest := prefix[consumed:]
+		if bytes.HasPrefix(rest, n.prefix) {
+			consumed += len(n.prefix)
+		} else if bytes.HasPrefix(n.prefix, rest) {
+			break
+		} else {
+			return nil, 0
+		}
+	}
+	return n, start
+}
+
 // WalkPath is used to walk the tree, but only visiting nodes
 // from the root down to a given leaf. Where WalkPrefix walks
 // all the entries *under* the given prefix, this walks the
@@ -292,6 +973,43 @@ func (n *Node[T]) WalkPath(path []byte, fn WalkFn[T]) {
 	}
 }
 
+// GetPath returns every key under this node that's a prefix of k, including k
+// itself, with their values, in order from the shortest, as WalkPath visits
+// them. The result is allocated once, at its exact size, and nil is returned
+// if there are no such keys.
+func (n *Node[T]) GetPath(k []byte) []KV[T] {
+	count := n.visitPath(k, nil)
+	if count == 0 {
+		return nil
+	}
+	out := make([]KV[T], count)
+	n.visitPath(k, out)
+	return out
+}
+
+// visitPath returns the number of leaves on the path to k, storing them in out
+// if it isn't nil.
+func (n *Node[T]) visitPath(k []byte, out []KV[T]) int {
+	count := 0
+	search := k
+	for {
+		if n.leaf != nil {
+			if out != nil {
+				out[count] = KV[T]{Key: n.leaf.key, Val: n.leaf.val}
+			}
+			count++
+		}
+		if len(search) == 0 {
+			return count
+		}
+		_, n = n.getEdge(search[0])
+		if n == nil || !bytes.HasPrefix(search, n.prefix) {
+			return count
+		}
+		search = search[len(n.prefix):]
+	}
+}
+
 // recursiveWalk is used to do a pre-order walk of a node
 // recursively. Returns true if the walk should be aborted
 func recursiveWalk[T any](n *Node[T], fn WalkFn[T]) bool {
@@ -301,7 +1019,7 @@ func recursiveWalk[T any](n *Node[T], fn WalkFn[T]) bool {
 	}
 
 	// Recurse on the children
-	for _, e := range n.edges {
+	for _, e := range n.edges() {
 		if recursiveWalk(e.node, fn) {
 			return true
 		}
@@ -319,8 +1037,8 @@ func reverseRecursiveWalk[T any](n *Node[T], fn WalkFn[T]) bool {
 	}
 
 	// Recurse on the children in reverse order
-	for i := len(n.edges) - 1; i >= 0; i-- {
-		e := n.edges[i]
+	for i := len(n.edges()) - 1; i >= 0; i-- {
+		e := n.edges()[i]
 		if reverseRecursiveWalk(e.node, fn) {
 			return true
 		}
@@ -4,6 +4,12 @@
 package iradix
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
 	"testing"
 )
 
@@ -32,6 +38,50 @@ func TestNodeWalk(t *testing.T) {
 	})
 }
 
+func TestNodeWalkCtx(t *testing.T) {
+	txn := New[int]().Txn()
+	for i := 0; i < 1000; i++ {
+		txn.Insert([]byte(fmt.Sprintf("%04d", i)), i)
+	}
+	r := txn.Commit()
+
+	visited := 0
+	if err := r.Root().WalkCtx(context.Background(), func([]byte, int) bool {
+		visited++
+		return false
+	}); err != nil || visited != 1000 {
+		t.Fatalf("bad walk: %v %d", err, visited)
+	}
+
+	// The walk stops at the first check after the context is cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	visited = 0
+	err := r.Root().WalkCtx(ctx, func([]byte, int) bool {
+		visited++
+		if visited == 300 {
+			cancel()
+		}
+		return false
+	})
+	if !errors.Is(err, context.Canceled) || visited != 2*ctxCheckInterval {
+		t.Fatalf("bad walk: %v %d", err, visited)
+	}
+	if err := r.Root().WalkCtx(ctx, func([]byte, int) bool {
+		t.Fatalf("unexpected key")
+		return false
+	}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("bad walk: %v", err)
+	}
+
+	// Stopping the walk early isn't an error.
+	if err := r.Root().WalkCtx(context.Background(), func([]byte, int) bool {
+		return true
+	}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
 func TestNodeWalkBackwards(t *testing.T) {
 	r := New[any]()
 	keys := []string{"001", "002", "005", "010", "100"}
@@ -56,3 +106,451 @@ func TestNodeWalkBackwards(t *testing.T) {
 		return false
 	})
 }
+
+func TestNode_SearchEdges(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, num := range []int{0, 1, 2, 16, 128, 200, 250, 255, 256} {
+		for trial := 0; trial < 20; trial++ {
+			n := &Node[any]{}
+			for _, l := range rng.Perm(256)[:num] {
+				n.addEdge(edge[any]{label: byte(l)})
+			}
+			for l := 0; l < 256; l++ {
+				expect := 0
+				for expect < num && n.edges()[expect].label < byte(l) {
+					expect++
+				}
+				if got := n.searchEdges(byte(l)); got != expect {
+					t.Fatalf("%d edges, label %d: got %d, expected %d", num, l, got, expect)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkGet_Dense(b *testing.B) {
+	// Two levels of 256 way fanout.
+	r := New[int]()
+	txn := r.Txn()
+	var keys [][]byte
+	for i := 0; i < 256; i++ {
+		for j := 0; j < 256; j++ {
+			k := []byte{byte(i), byte(j)}
+			keys = append(keys, k)
+			txn.Insert(k, 0)
+		}
+	}
+	r = txn.Commit()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Get(keys[i%len(keys)])
+	}
+}
+
+func TestNode_ChunkBoundaries(t *testing.T) {
+	r := New[int]()
+	var keys []string
+	for i := 0; i < 10; i++ {
+		k := fmt.Sprintf("%02d", i)
+		keys = append(keys, k)
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	cases := []struct {
+		parts  int
+		expect []string
+	}{
+		{0, nil},
+		{1, []string{"00"}},
+		{2, []string{"00", "05"}},
+		{3, []string{"00", "03", "06"}},
+		{4, []string{"00", "02", "05", "07"}},
+		{10, keys},
+		{20, keys},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, b := range r.Root().ChunkBoundaries(c.parts) {
+			got = append(got, string(b))
+		}
+		if !reflect.DeepEqual(got, c.expect) {
+			t.Fatalf("%d parts: got %v, expected %v", c.parts, got, c.expect)
+		}
+	}
+
+	// The same keys inserted in a different order give the same chunks.
+	r2 := New[int]()
+	for i := len(keys) - 1; i >= 0; i-- {
+		r2, _, _ = r2.Insert([]byte(keys[i]), i)
+	}
+	r2, _, _ = r2.Insert([]byte("0"), 0)
+	r2, _, _ = r2.Delete([]byte("0"))
+	if !reflect.DeepEqual(r.Root().ChunkBoundaries(3), r2.Root().ChunkBoundaries(3)) {
+		t.Fatalf("chunks depend on insert order")
+	}
+
+	if b := New[int]().Root().ChunkBoundaries(3); b != nil {
+		t.Fatalf("bad: %v", b)
+	}
+}
+
+func TestNode_ClassifyMany(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randKey := func() []byte {
+		k := make([]byte, rng.Intn(8))
+		for i := range k {
+			k[i] = "abc/"[rng.Intn(4)]
+		}
+		return k
+	}
+
+	for trial := 0; trial < 50; trial++ {
+		r := New[int]()
+		for i := 0; i < rng.Intn(50); i++ {
+			r, _, _ = r.Insert(randKey(), i)
+		}
+		keys := make([][]byte, rng.Intn(100))
+		for i := range keys {
+			keys[i] = randKey()
+		}
+
+		matches := r.Root().ClassifyMany(keys)
+		if len(matches) != len(keys) {
+			t.Fatalf("bad len: %d", len(matches))
+		}
+		for i, k := range keys {
+			mk, mv, ok := r.Root().LongestPrefix(k)
+			expect := Match[int]{Key: mk, Value: mv, Found: ok}
+			if !reflect.DeepEqual(matches[i], expect) {
+				t.Fatalf("%q: got %+v, expected %+v", k, matches[i], expect)
+			}
+		}
+	}
+}
+
+func BenchmarkNode_ClassifyMany(b *testing.B) {
+	r := New[int]()
+	for i := 0; i < 1000; i++ {
+		r, _, _ = r.Insert([]byte(fmt.Sprintf("/api/v1/service/%03d", i)), i)
+	}
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("/api/v1/service/%03d/check/%d", i%1000, i))
+	}
+
+	b.Run("single", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, k := range keys {
+				r.Root().LongestPrefix(k)
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r.Root().ClassifyMany(keys)
+		}
+	})
+}
+
+func TestNode_Structure(t *testing.T) {
+	r := New[int]()
+	for i, k := range []string{"foo", "foobar", "foobaz", "zip"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	root := r.Root()
+	if len(root.Prefix()) != 0 || root.Fanout() != 2 {
+		t.Fatalf("bad root: %q %d", root.Prefix(), root.Fanout())
+	}
+	if labels := root.ChildLabels(); string(labels) != "fz" {
+		t.Fatalf("bad labels: %q", labels)
+	}
+	if root.Child('x') != nil {
+		t.Fatalf("unexpected child")
+	}
+
+	foo := root.Child('f')
+	if string(foo.Prefix()) != "foo" || foo.Fanout() != 1 {
+		t.Fatalf("bad node: %q %d", foo.Prefix(), foo.Fanout())
+	}
+	ba := foo.Child('b')
+	if string(ba.Prefix()) != "ba" || string(ba.ChildLabels()) != "rz" {
+		t.Fatalf("bad node: %q %q", ba.Prefix(), ba.ChildLabels())
+	}
+
+	// The accessors return copies.
+	foo.Prefix()[0] = 'x'
+	foo.ChildLabels()[0] = 'x'
+	if string(foo.Prefix()) != "foo" || string(foo.ChildLabels()) != "b" {
+		t.Fatalf("node modified")
+	}
+}
+
+func TestNode_PrefixChecks(t *testing.T) {
+	r := New[int]()
+	for i, k := range []string{"", "foo", "foo/bar", "foo/baz", "zip/1", "zipper/2"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	cases := []struct {
+		prefix string
+		empty  bool
+		single string
+		count  int
+	}{
+		{"", false, "", 6},
+		{"f", false, "", 3},
+		{"foo/", false, "", 2},
+		{"foo/bar", false, "foo/bar", 1},
+		{"foo/bar/", true, "", 0},
+		{"foo/c", true, "", 0},
+		{"zip/", false, "zip/1", 1},
+		{"zipp", false, "zipper/2", 1},
+		{"zip", false, "", 2},
+		{"x", true, "", 0},
+	}
+	root := r.Root()
+	for _, c := range cases {
+		if got := root.IsEmptyPrefix([]byte(c.prefix)); got != c.empty {
+			t.Fatalf("%q: got empty %v", c.prefix, got)
+		}
+		if got := root.CountPrefix([]byte(c.prefix)); got != c.count || r.LenPrefix([]byte(c.prefix)) != got {
+			t.Fatalf("%q: got count %d", c.prefix, got)
+		}
+		kv, ok := root.SinglePrefix([]byte(c.prefix))
+		if ok != (c.single != "") || string(kv.Key) != c.single {
+			t.Fatalf("%q: got single %q %v", c.prefix, kv.Key, ok)
+		}
+		if ok {
+			if v, _ := r.Get(kv.Key); v != kv.Val {
+				t.Fatalf("%q: bad value %v", c.prefix, kv.Val)
+			}
+		}
+	}
+
+	if !New[int]().Root().IsEmptyPrefix(nil) {
+		t.Fatalf("expected empty")
+	}
+	r, _, _ = New[int]().Insert([]byte(""), 1)
+	if kv, ok := r.Root().SinglePrefix(nil); !ok || kv.Key == nil || len(kv.Key) != 0 {
+		t.Fatalf("bad: %v %v", kv, ok)
+	}
+}
+
+func TestNode_SelectRank(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	r := New[int]()
+	seen := make(map[string]bool)
+	for i := 0; i < 500; i++ {
+		b := make([]byte, rnd.Intn(6))
+		for j := range b {
+			b[j] = "ab/"[rnd.Intn(3)]
+		}
+		r, _, _ = r.Insert(b, i)
+		seen[string(b)] = true
+	}
+	var keys []string
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for i, k := range keys {
+		got, _, ok := r.Select(i)
+		if !ok || string(got) != k {
+			t.Fatalf("bad select %d: %q", i, got)
+		}
+		if rank, ok := r.Rank([]byte(k)); !ok || rank != i {
+			t.Fatalf("bad rank of %q: %d %v", k, rank, ok)
+		}
+
+		// Keys that aren't in the tree rank where they'd be inserted.
+		for _, probe := range []string{k + "0", k + "c"} {
+			if seen[probe] {
+				continue
+			}
+			expect := sort.SearchStrings(keys, probe)
+			if rank, ok := r.Rank([]byte(probe)); ok || rank != expect {
+				t.Fatalf("bad rank of %q: %d %v, expected %d", probe, rank, ok, expect)
+			}
+		}
+	}
+	for _, idx := range []int{-1, len(keys)} {
+		if _, _, ok := r.Select(idx); ok {
+			t.Fatalf("expected no key at %d", idx)
+		}
+	}
+}
+
+func TestNode_LongestCommonPrefix(t *testing.T) {
+	cases := []struct {
+		keys   []string
+		expect string
+		found  bool
+	}{
+		{nil, "", false},
+		{[]string{""}, "", true},
+		{[]string{"foo"}, "foo", true},
+		{[]string{"foo/bar", "foo/baz"}, "foo/ba", true},
+		{[]string{"foo", "foo/bar", "foo/baz"}, "foo", true},
+		{[]string{"foo/bar", "foo/baz", "zip"}, "", true},
+		{[]string{"svc/web/1", "svc/web/2", "svc/web/3/x"}, "svc/web/", true},
+	}
+	for _, c := range cases {
+		for _, opts := range [][]Option{nil, {WithoutDeleteMerge()}, {WithCollation(FoldCaseCollation())}} {
+			r := New[int](opts...)
+			for i, k := range c.keys {
+				r, _, _ = r.Insert([]byte(k), i)
+			}
+
+			// Leave a chain of single-child nodes behind without merging.
+			r, _, _ = r.Insert([]byte(c.expect+"\x00"), 0)
+			r, _, _ = r.Delete([]byte(c.expect + "\x00"))
+			got := r.LongestCommonPrefix()
+			if string(got) != c.expect || (got != nil) != c.found {
+				t.Fatalf("%q: got %q", c.keys, got)
+			}
+		}
+	}
+	r := New[int]()
+	r, _, _ = r.Insert([]byte("ab/1"), 1)
+	r, _, _ = r.Insert([]byte("ab/2"), 1)
+	if got := r.Root().Child('a').LongestCommonPrefix(); string(got) != "ab/" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestNode_ListPrefix(t *testing.T) {
+	r := New[int](WithoutDeleteMerge())
+	keys := []string{"a", "a/", "a/b", "a/b/c", "a/bc", "a/d/e", "a/d/f", "a//g", "ab", "b/c"}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	r, _, _ = r.Delete([]byte("a/bc"))
+
+	list := func(r *Tree[int], prefix string) string {
+		entries, prefixes := r.ListPrefix([]byte(prefix), '/')
+		var out []string
+		for _, e := range entries {
+			out = append(out, fmt.Sprintf("%s=%d", e.Key, e.Val))
+		}
+		for _, p := range prefixes {
+			out = append(out, string(p)+"...")
+		}
+		return fmt.Sprint(out)
+	}
+	cases := []struct {
+		prefix string
+		expect string
+	}{
+		{"", "[a=0 ab=8 a/... b/...]"},
+		{"a", "[a=0 ab=8 a/...]"},
+		{"a/", "[a/=1 a/b=2 a//... a/b/... a/d/...]"},
+		{"a/b", "[a/b=2 a/b/...]"},
+		{"a/d", "[a/d/...]"},
+		{"a/d/", "[a/d/e=5 a/d/f=6]"},
+		{"a/x", "[]"},
+		{"b/c/", "[]"},
+	}
+	for _, c := range cases {
+		if got := list(r, c.prefix); got != c.expect {
+			t.Fatalf("bad listing of %q: %s", c.prefix, got)
+		}
+	}
+
+	f := New[int](WithCollation(FoldCaseCollation()))
+	for i, k := range []string{"b/x", "B/y", "a", "B"} {
+		f, _, _ = f.Insert([]byte(k), i)
+	}
+	if got := list(f, ""); got != "[a=2 B=3 B/... b/...]" {
+		t.Fatalf("bad collated listing: %s", got)
+	}
+}
+
+func TestNode_WalkGlob(t *testing.T) {
+	r := New[int]()
+	keys := []string{"service/a/leader", "service/a/follower", "service/b/leader", "service/a/b/leader", "service/leader", "services/c/leader", "other/a/leader", ""}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+
+	glob := func(pattern string) []string {
+		var out []string
+		r.WalkGlob([]byte(pattern), func(k []byte, _ int) bool {
+			out = append(out, string(k))
+			return false
+		})
+		return out
+	}
+	cases := []struct {
+		pattern string
+		expect  []string
+	}{
+		{"service/*/leader", []string{"service/a/b/leader", "service/a/leader", "service/b/leader"}},
+		{"service/?/leader", []string{"service/a/leader", "service/b/leader"}},
+		{"service*/leader", []string{"service/a/b/leader", "service/a/leader", "service/b/leader", "service/leader", "services/c/leader"}},
+		{"*a*", []string{"other/a/leader", "service/a/b/leader", "service/a/follower", "service/a/leader", "service/b/leader", "service/leader", "services/c/leader"}},
+		{"service/leader", []string{"service/leader"}},
+		{"service/lead", nil},
+		{"*", sorted},
+		{"", []string{""}},
+		{"?*", sorted[1:]},
+		{"s*r", []string{"service/a/b/leader", "service/a/follower", "service/a/leader", "service/b/leader", "service/leader", "services/c/leader"}},
+	}
+	for _, c := range cases {
+		got := glob(c.pattern)
+		if !reflect.DeepEqual(got, c.expect) {
+			t.Fatalf("bad glob %q: %q", c.pattern, got)
+		}
+	}
+}
+
+func TestNode_GetPath(t *testing.T) {
+	r := New[int]()
+	for i, k := range []string{"", "10.", "10.0.", "10.0.0.1", "10.1.", "192.168."} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	cases := []struct {
+		k      string
+		expect []string
+	}{
+		{"10.0.0.1", []string{"", "10.", "10.0.", "10.0.0.1"}},
+		{"10.0.0.2", []string{"", "10.", "10.0."}},
+		{"10.1.2.3", []string{"", "10.", "10.1."}},
+		{"10", []string{""}},
+		{"", []string{""}},
+	}
+	for _, c := range cases {
+		var got, walked []string
+		for _, kv := range r.GetPath([]byte(c.k)) {
+			if expect, _ := r.Get(kv.Key); expect != kv.Val {
+				t.Fatalf("bad value for %q: %d", kv.Key, kv.Val)
+			}
+			got = append(got, string(kv.Key))
+		}
+		r.Root().WalkPath([]byte(c.k), func(k []byte, _ int) bool {
+			walked = append(walked, string(k))
+			return false
+		})
+		if !reflect.DeepEqual(got, c.expect) || !reflect.DeepEqual(got, walked) {
+			t.Fatalf("bad path to %q: %q", c.k, got)
+		}
+	}
+
+	r, _, _ = r.Delete([]byte(""))
+	if got := r.GetPath([]byte("172.16.0.1")); got != nil {
+		t.Fatalf("expected no keys, got %v", got)
+	}
+	k := []byte("10.0.0.1")
+	allocs := testing.AllocsPerRun(10, func() {
+		r.GetPath(k)
+	})
+	if allocs != 1 {
+		t.Fatalf("expected one allocation, got %v", allocs)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+// segmentChunkSize is the size of the chunks of memory that a segmentDict
+// packs segments into.
+const segmentChunkSize = 64 * 1024
+
+// segmentDict is a dictionary of key segments. Every distinct segment is stored
+// once in a chunk of memory shared with other segments, and all nodes with that
+// segment as their prefix reference the same bytes. Keyspaces with a small
+// vocabulary of repeated segments, like "service/<id>/check/", can then store
+// the prefixes of many nodes in very little memory.
+type segmentDict struct {
+	segments map[string][]byte
+	chunk    []byte
+}
+
+func newSegmentDict() *segmentDict {
+	return &segmentDict{
+		segments: make(map[string][]byte),
+	}
+}
+
+// intern returns a slice with the same contents as b from the dictionary,
+// adding it if needed. The returned slice has its capacity capped at its
+// length so that appending to it can't overwrite another segment.
+func (d *segmentDict) intern(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	if seg, ok := d.segments[string(b)]; ok {
+		return seg
+	}
+
+	// Large segments get their own allocation rather than wasting the rest
+	// of a chunk.
+	var seg []byte
+	if len(b) > segmentChunkSize/8 {
+		seg = make([]byte, len(b))
+	} else {
+		if len(d.chunk) < len(b) {
+			d.chunk = make([]byte, segmentChunkSize)
+		}
+		seg, d.chunk = d.chunk[:len(b):len(b)], d.chunk[len(b):]
+	}
+	copy(seg, b)
+	d.segments[string(seg)] = seg
+	return seg
+}
+
+// Optimize returns a tree with the same contents, rebuilt to use less memory.
+// The prefixes of all the inner nodes are stored in a dictionary shared by the
+// new tree, so each distinct prefix is only stored once, the prefixes of nodes
+// without children share memory with their leaf's key, and all the edge slices
+// are sized exactly. The leaves are shared with this tree. Nodes that are later
+// modified by a transaction get their own copy of their prefix as usual, so the
+// savings erode as the tree is written to and Optimize can be called again.
+//
+// The inner nodes of the returned tree have new watch channels, so watches on
+// prefixes or missing keys obtained from this tree won't fire for changes made
+// to the optimized tree. Since the leaves are shared, though, a watch on a key
+// that exists fires when that key is modified or deleted in either tree.
+func (t *Tree[T]) Optimize() *Tree[T] {
+	d := newSegmentDict()
+	return &Tree[T]{
+		root:       optimizeNode(t.root, d, t.conf.collate == nil),
+		size:       t.size,
+		bytes:      t.bytes,
+		hash:       t.hash,
+		generation: t.generation,
+		version:    nextVersion(),
+		conf:       t.conf,
+	}
+}
+
+// optimizeNode returns a copy of the subtree under n with its prefixes stored
+// in the given dictionary. If aliasLeaves is set the prefixes of leaf-only
+// nodes are stored in their leaf's key instead, which isn't possible if the
+// tree has a collation.
+func optimizeNode[T any](n *Node[T], d *segmentDict, aliasLeaves bool) *Node[T] {
+	nn := &Node[T]{
+		mutateCh: make(chan struct{}),
+		leaf:     n.leaf,
+	}
+	if aliasLeaves && n.isLeafOnly() {
+		nn.prefix = leafPrefix(n.leaf, len(n.prefix))
+	} else {
+		nn.prefix = d.intern(n.prefix)
+	}
+	if len(n.edges()) != 0 {
+		nn.inner = &innerNode[T]{
+			edges: make(edges[T], len(n.edges())),
+			count: n.count(),
+		}
+		for i, e := range n.edges() {
+			nn.inner.edges[i] = edge[T]{
+				label: e.label,
+				node:  optimizeNode(e.node, d, aliasLeaves),
+			}
+		}
+	}
+	return nn
+}
+
+// Canonicalize returns a copy of the tree in a canonical physical form, that
+// depends only on its contents and not on the history of writes that produced
+// it. Chains of nodes that have a single child and no leaf, which are left
+// behind by deletes when the tree was created with WithoutDeleteMerge, are
+// merged, every prefix and edge slice is sized exactly, and the prefixes of
+// leaf-only nodes share memory with their leaf's key like they do when they're
+// inserted. Two trees with equal contents and options therefore have identical
+// layouts once canonicalized, so walking them node by node gives the same
+// result. The leaves are shared with this tree.
+//
+// Like Optimize, the returned tree has new watch channels for its inner nodes,
+// but watches on existing keys fire for changes to them in either tree.
+func (t *Tree[T]) Canonicalize() *Tree[T] {
+	aliasLeaves := t.conf.collate == nil
+	root := &Node[T]{
+		mutateCh: make(chan struct{}),
+		leaf:     t.root.leaf,
+	}
+	root.setEdges(canonicalEdges(t.root, aliasLeaves))
+	root.setCount(t.root.count())
+	return &Tree[T]{
+		root:       root,
+		size:       t.size,
+		bytes:      t.bytes,
+		hash:       t.hash,
+		generation: t.generation,
+		version:    nextVersion(),
+		conf:       t.conf,
+	}
+}
+
+// canonicalEdges returns the canonical copies of the children of n.
+func canonicalEdges[T any](n *Node[T], aliasLeaves bool) edges[T] {
+	if len(n.edges()) == 0 {
+		return nil
+	}
+	es := make(edges[T], len(n.edges()))
+	for i, e := range n.edges() {
+		es[i] = edge[T]{
+			label: e.label,
+			node:  canonicalNode(e.node, nil, aliasLeaves),
+		}
+	}
+	return es
+}
+
+// canonicalNode returns the canonical copy of the subtree under n, a child
+// whose prefix follows the given one from merged ancestors.
+func canonicalNode[T any](n *Node[T], prefix []byte, aliasLeaves bool) *Node[T] {
+	for n.leaf == nil && len(n.edges()) == 1 {
+		prefix = append(prefix, n.prefix...)
+		n = n.edges()[0].node
+	}
+	nn := &Node[T]{
+		mutateCh: make(chan struct{}),
+		leaf:     n.leaf,
+	}
+	size := len(prefix) + len(n.prefix)
+	if aliasLeaves && n.isLeafOnly() {
+		nn.prefix = leafPrefix(n.leaf, size)
+	} else {
+		nn.prefix = make([]byte, 0, size)
+		nn.prefix = append(append(nn.prefix, prefix...), n.prefix...)
+	}
+	nn.setEdges(canonicalEdges(n, aliasLeaves))
+	nn.setCount(n.count())
+	return nn
+}
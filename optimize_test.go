@@ -0,0 +1,141 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	r := New[int]()
+	var keys []string
+	for svc := 0; svc < 20; svc++ {
+		for chk := 0; chk < 5; chk++ {
+			k := fmt.Sprintf("service/%02d/check/%02d", svc, chk)
+			keys = append(keys, k)
+			r, _, _ = r.Insert([]byte(k), svc*100+chk)
+		}
+	}
+
+	o := r.Optimize()
+	if o.Len() != r.Len() {
+		t.Fatalf("bad len: %d", o.Len())
+	}
+	verifyTree(t, keys, o)
+	checkCounts(t, o.Root())
+
+	// Every inner node with the same prefix should share the same bytes.
+	seen := make(map[string]*byte)
+	var shared int
+	var walk func(n *Node[int])
+	walk = func(n *Node[int]) {
+		if n.isLeafOnly() {
+			checkLeafPrefix(t, n)
+		} else if len(n.prefix) > 0 {
+			if p, ok := seen[string(n.prefix)]; ok {
+				if p != &n.prefix[0] {
+					t.Fatalf("prefix %q not shared", n.prefix)
+				}
+				shared++
+			}
+			seen[string(n.prefix)] = &n.prefix[0]
+		}
+		for _, e := range n.edges() {
+			walk(e.node)
+		}
+	}
+	walk(o.Root())
+	if shared == 0 {
+		t.Fatalf("expected some prefixes to be shared")
+	}
+
+	// The trees are independent afterwards.
+	o2, _, _ := o.Insert([]byte("service/00/check/99"), 0)
+	o2, _, _ = o2.Delete([]byte("service/01/check/00"))
+	if _, ok := r.Get([]byte("service/00/check/99")); ok {
+		t.Fatalf("original tree modified")
+	}
+	if _, ok := o.Get([]byte("service/00/check/99")); ok {
+		t.Fatalf("optimized tree modified")
+	}
+	checkCounts(t, o2.Root())
+	verifyTree(t, keys, o)
+
+	// Watches on missing keys are independent, but the leaves are shared.
+	for i, tree := range []*Tree[int]{r.Optimize(), r.Canonicalize()} {
+		k := fmt.Sprintf("service/02/check/%02d", i)
+		leafCh, _, _ := r.Root().GetWatch([]byte(k))
+		missingCh, _, _ := r.Root().GetWatch([]byte("service/03/check/zz"))
+		txn := tree.Txn()
+		txn.TrackMutate(true)
+		txn.Delete([]byte(k))
+		txn.Delete([]byte(fmt.Sprintf("service/03/check/%02d", i)))
+		txn.Commit()
+		if !isClosedRecv(leafCh) || isClosedRecv(missingCh) {
+			t.Fatalf("%d: bad watches", i)
+		}
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	var keys []string
+	for i := 0; i < 200; i++ {
+		keys = append(keys, fmt.Sprintf("k/%03d/%d", rand.Intn(100), rand.Intn(10)))
+	}
+
+	// Build trees with the same contents through different histories.
+	build := func(opts ...Option) *Tree[int] {
+		r := New[int](opts...)
+		txn := r.Txn()
+		for _, i := range rand.Perm(len(keys)) {
+			txn.Insert([]byte(keys[i]), len(keys[i]))
+			txn.Insert([]byte(keys[i]+"#1"), 1)
+			txn.Insert([]byte(keys[i]+"#2"), 2)
+		}
+		for _, k := range keys {
+			txn.Delete([]byte(k + "#1"))
+		}
+		return txn.Commit()
+	}
+	layout := func(r *Tree[int]) []string {
+		var out []string
+		var walk func(n *Node[int], depth int)
+		walk = func(n *Node[int], depth int) {
+			if cap(n.prefix) != len(n.prefix) || cap(n.edges()) != len(n.edges()) {
+				t.Fatalf("%q: slices aren't sized exactly", n.prefix)
+			}
+			s := fmt.Sprintf("%d %q %d", depth, n.prefix, n.count())
+			if n.leaf != nil {
+				s += fmt.Sprintf(" %q=%d", n.leaf.key, n.leaf.val)
+			}
+			out = append(out, s)
+			for _, e := range n.edges() {
+				walk(e.node, depth+1)
+			}
+		}
+		walk(r.Root(), 0)
+		return out
+	}
+
+	for _, opts := range [][]Option{nil, {WithoutDeleteMerge()}} {
+		r := build(opts...)
+		expect := New[int]()
+		r.Root().Walk(func(k []byte, v int) bool {
+			expect, _, _ = expect.Insert(k, v)
+			return false
+		})
+		want := layout(expect.Canonicalize())
+		c := r.Canonicalize()
+		if got := layout(c); !reflect.DeepEqual(got, want) {
+			t.Fatalf("layouts differ:\n%v\n%v", got, want)
+		}
+		if c.Len() != r.Len() || c.Root().mutateCh == r.Root().mutateCh {
+			t.Fatalf("bad copy")
+		}
+		checkLeafPrefix(t, c.Root())
+	}
+}
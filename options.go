@@ -0,0 +1,299 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Option is used to configure optional behavior of a Tree when it is created
+// with New. The configuration is inherited by every tree derived from it via
+// transactions.
+type Option func(*options)
+
+// options holds the settings collected from the Options passed to New.
+type options struct {
+	// leafMeta enables recording of LeafMeta for every leaf.
+	leafMeta bool
+
+	// misuse is the policy for invalid use of transactions.
+	misuse MisusePolicy
+
+	// intern holds a func(T) T given to WithIntern. It's stored untyped since
+	// Option isn't generic, and is resolved by newConfig.
+	intern any
+
+	// budget is the limit given to WithMemoryBudget, and sizer the untyped
+	// func(T) int given along with it, resolved by newConfig.
+	budget int
+	sizer  any
+
+	// hash holds the func(T) uint64 given to WithContentHash, resolved by
+	// newConfig.
+	hash any
+
+	// collate is the table given to WithCollation. If fold is set, newConfig
+	// replaces it with one that also folds the case of keys.
+	collate *[256]byte
+
+	// fold normalizes the case of keys, set by WithKeyFold.
+	fold bool
+
+	// transform is the KeyTransformer given to WithKeyTransformer.
+	transform KeyTransformer
+
+	// noMerge disables collapsing of nodes on delete.
+	noMerge bool
+
+	// copyKeys makes Insert copy the keys it's given.
+	copyKeys bool
+
+	// writableCache is the size given to WithWritableCacheSize, or zero for
+	// the default.
+	writableCache int
+
+	// stats is the collector created by WithAccessStats.
+	stats *accessStats
+
+	// hooks holds the CommitHooks[T] given to WithCommitHooks, resolved by
+	// newConfig.
+	hooks any
+
+	// codec holds the Codec[T] given to WithCodec, resolved by newConfig.
+	codec any
+}
+
+// config is the resolved configuration of a tree. It is shared, read-only, by
+// a tree, all the trees derived from it, and their transactions.
+type config[T any] struct {
+	options
+
+	// intern is applied to every value passed to Insert, if set.
+	intern func(T) T
+
+	// sizer returns the approximate size of a value, if a memory budget is
+	// set.
+	sizer func(T) int
+
+	// hash returns the hash of a value, if content hashing is enabled.
+	hash func(T) uint64
+
+	// hooks are run by every commit.
+	hooks CommitHooks[T]
+
+	// codec encodes values for MarshalBinary and UnmarshalBinary, if set.
+	codec Codec[T]
+}
+
+// key returns a key given to one of the methods of Tree or Txn in the form
+// it's stored in the tree, which is transformed and folded.
+func (c *config[T]) key(k []byte) []byte {
+	k = transformKey(c.transform, k)
+	if c.fold {
+		k = foldKey(k)
+	}
+	return k
+}
+
+// writableCacheSize returns the size of the cache of writable nodes of a
+// transaction.
+func (c *config[T]) writableCacheSize() int {
+	if c.writableCache > 0 {
+		return c.writableCache
+	}
+	return defaultModifiedCache
+}
+
+// path returns the path in the tree of a key given to one of the methods of
+// Tree or Txn, which is the key transformed and then collated.
+func (c *config[T]) path(k []byte) []byte {
+	return collateKey(c.collate, transformKey(c.transform, k))
+}
+
+// newConfig applies the given options and returns the resulting config. This
+// panics if an option was built for a different value type than T.
+func newConfig[T any](opts []Option) *config[T] {
+	c := &config[T]{}
+	for _, opt := range opts {
+		opt(&c.options)
+	}
+	if c.options.fold {
+		// Folding twice is the same as folding once, so this can be applied
+		// to a table that already folds, as for options copied from another
+		// tree.
+		table := foldCollation()
+		if c.options.collate != nil {
+			for b := range table {
+				table[b] = c.options.collate[table[b]]
+			}
+		}
+		c.options.collate = &table
+	}
+	if c.options.intern != nil {
+		fn, ok := c.options.intern.(func(T) T)
+		if !ok {
+			panic(fmt.Sprintf("iradix: WithIntern given %T, expected %T", c.options.intern, fn))
+		}
+		c.intern = fn
+	}
+	if c.options.sizer != nil {
+		fn, ok := c.options.sizer.(func(T) int)
+		if !ok {
+			panic(fmt.Sprintf("iradix: WithMemoryBudget given %T, expected %T", c.options.sizer, fn))
+		}
+		c.sizer = fn
+	}
+	if c.options.hash != nil {
+		fn, ok := c.options.hash.(func(T) uint64)
+		if !ok {
+			panic(fmt.Sprintf("iradix: WithContentHash given %T, expected %T", c.options.hash, fn))
+		}
+		c.hash = fn
+	}
+	if c.options.hooks != nil {
+		hooks, ok := c.options.hooks.(CommitHooks[T])
+		if !ok {
+			panic(fmt.Sprintf("iradix: WithCommitHooks given %T, expected %T", c.options.hooks, hooks))
+		}
+		c.hooks = hooks
+	}
+	if c.options.codec != nil {
+		codec, ok := c.options.codec.(Codec[T])
+		if !ok {
+			panic(fmt.Sprintf("iradix: WithCodec given %T, expected a %v", c.options.codec, reflect.TypeOf((*Codec[T])(nil)).Elem()))
+		}
+		c.codec = codec
+	}
+	return c
+}
+
+// WithLeafMeta enables recording of the commit generation at which every leaf
+// was created and last modified. The metadata can be retrieved with GetMeta.
+// This costs an extra allocation per inserted leaf, so it's off by default.
+func WithLeafMeta() Option {
+	return func(o *options) {
+		o.leafMeta = true
+	}
+}
+
+// WithoutDeleteMerge disables collapsing a node with its only remaining child
+// when a delete leaves it with one edge and no leaf. The structure of the tree
+// then stays stable while the same keys are repeatedly deleted and re-inserted,
+// so they don't cause a merge and then a split of the same nodes, which copies
+// more nodes and fires more watch channels than needed. The price is that
+// paths through the tree may be longer than necessary, making reads slightly
+// slower, until all the keys below such a node are deleted.
+func WithoutDeleteMerge() Option {
+	return func(o *options) {
+		o.noMerge = true
+	}
+}
+
+// WithCopyKeys makes Insert store a copy of every key it's given. By default
+// the tree keeps a reference to the caller's key, which saves an allocation
+// per insert, but means that the key must never be modified afterwards. This
+// option is useful when keys are built in reused buffers.
+func WithCopyKeys() Option {
+	return func(o *options) {
+		o.copyKeys = true
+	}
+}
+
+// WithWritableCacheSize sets the number of nodes a transaction remembers having
+// copied, which it can then modify in place until it's committed rather than
+// copying them again. The default of 8192 covers the nodes near the root that
+// most writes go through. Large transactions whose writes are spread over many
+// more nodes than that can use a bigger cache to avoid copying the same nodes
+// repeatedly, and small embedded users can use a smaller one to save memory. A
+// size of zero or less keeps the default.
+func WithWritableCacheSize(size int) Option {
+	return func(o *options) {
+		o.writableCache = size
+	}
+}
+
+// WithIntern sets a function that is applied to every value passed to Insert
+// before it is stored. This can be used to deduplicate equal values so that
+// leaves holding them share the same underlying memory, for example by
+// returning a canonical instance from a map. The function must return a value
+// that is equal to its argument, and must be safe to call from every goroutine
+// that writes to trees derived from this one.
+func WithIntern[T any](fn func(T) T) Option {
+	return func(o *options) {
+		o.intern = fn
+	}
+}
+
+// WithMemoryBudget limits the approximate number of bytes retained by the keys
+// and values of the tree. The size of an entry is the length of its key plus
+// the result of calling size on its value, which should account for any memory
+// the value references. An Insert that would take the total over limit is not
+// applied and ErrBudgetExceeded is recorded in Txn.Err. Updates that shrink an
+// entry and deletes are always allowed. Tree.Insert has no way to report the
+// error, so a Txn should be used to write to a tree with a budget.
+//
+// The total does not include the nodes of the tree, so limit should leave some
+// headroom for them. The current total is returned by Tree.Bytes.
+func WithMemoryBudget[T any](limit int, size func(T) int) Option {
+	return func(o *options) {
+		o.budget = limit
+		o.sizer = size
+	}
+}
+
+// WithContentHash enables a hash of the contents of the tree that is kept up to
+// date on every write, using fn to hash values. Equal values must have equal
+// hashes. The hash of a tree is returned by Tree.ContentHash, and is used by
+// Tree.EqualFunc to quickly tell trees apart.
+func WithContentHash[T any](fn func(T) uint64) Option {
+	return func(o *options) {
+		o.hash = fn
+	}
+}
+
+// CommitHooks are functions run by every commit of a transaction, given to
+// WithCommitHooks. Either may be nil.
+type CommitHooks[T any] struct {
+	// OnBeforePublish is called with the new tree when a transaction is
+	// committed, before it's returned to the caller and before any watch
+	// channels are closed.
+	OnBeforePublish func(new *Tree[T])
+
+	// OnAfterPublish is called with the tree the transaction was started
+	// from and the new tree after the watch channels of the commit are
+	// closed, either by Commit, or by the first call to Notify after
+	// CommitOnly.
+	OnAfterPublish func(old, new *Tree[T])
+}
+
+// WithCommitHooks sets hooks that are run exactly once by every commit of a
+// transaction on the tree or any tree derived from it, including the ones made
+// by the write methods on Tree. This lets an embedding system serialize
+// snapshots or update metrics for every new version of a tree without wrapping
+// each place that commits. The hooks run on the committing goroutine, so they
+// should be quick.
+//
+// Methods that add to or remove from the contents of a tree commit a new
+// version of it, so Merge, Intersect, BulkLoad and Overlay.Compact run the
+// hooks like Insert does. Copying a tree or taking part of it doesn't, so
+// DeepCopy, Optimize, Canonicalize, Filter, MapValues and SubtreeAt don't run
+// them, nor does decoding a tree into an empty one, and their results keep
+// the generation of the tree they were made from. Committing transactions on
+// those results does run the hooks.
+func WithCommitHooks[T any](hooks CommitHooks[T]) Option {
+	return func(o *options) {
+		o.hooks = hooks
+	}
+}
+
+// WithCodec sets the Codec used to encode values by Tree.MarshalBinary and
+// decode them by Tree.UnmarshalBinary, which can't be given one directly since
+// they implement the interfaces of package encoding. Trees of []byte or string
+// values use BytesCodec or StringCodec if no codec is set.
+func WithCodec[T any](c Codec[T]) Option {
+	return func(o *options) {
+		o.codec = c
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import "bytes"
+
+// Overlay is an immutable set of pending writes layered over a base tree.
+// Reads see the writes of the overlay, including deletes, which are recorded as
+// tombstones, and fall through to the base tree for keys the overlay hasn't
+// touched. The writes are folded into the base tree with Compact or ApplyTo.
+//
+// Like Tree, every write returns a new Overlay and leaves the original as it
+// was, so it's safe to read an Overlay concurrently.
+type Overlay[T any] struct {
+	base *Tree[T]
+	ops  *Tree[overlayOp[T]]
+}
+
+// overlayOp is a pending write in an overlay: either a value or a tombstone.
+type overlayOp[T any] struct {
+	val     T
+	deleted bool
+}
+
+// NewOverlay returns an empty overlay on top of the given base tree.
+func NewOverlay[T any](base *Tree[T]) *Overlay[T] {
+	// The pending writes are kept in the same order as the base so that
+	// they can be merged by OverlayIterator.
+	inherit := func(o *options) {
+		o.collate = base.conf.collate
+		o.fold = base.conf.fold
+		o.transform = base.conf.transform
+	}
+	return &Overlay[T]{
+		base: base,
+		ops:  New[overlayOp[T]](inherit),
+	}
+}
+
+// Base returns the tree the overlay is layered over.
+func (o *Overlay[T]) Base() *Tree[T] {
+	return o.base
+}
+
+// Pending returns the number of keys written to the overlay, including
+// tombstones.
+func (o *Overlay[T]) Pending() int {
+	return o.ops.Len()
+}
+
+// Get is used to lookup a specific key, returning the value and if it was
+// found, as it would be after compaction.
+func (o *Overlay[T]) Get(k []byte) (T, bool) {
+	if op, ok := o.ops.Get(k); ok {
+		if op.deleted {
+			var zero T
+			return zero, false
+		}
+		return op.val, true
+	}
+	return o.base.Get(k)
+}
+
+// Insert returns a new overlay with the given key set to v.
+func (o *Overlay[T]) Insert(k []byte, v T) *Overlay[T] {
+	ops, _, _ := o.ops.Insert(k, overlayOp[T]{val: v})
+	return &Overlay[T]{base: o.base, ops: ops}
+}
+
+// Delete returns a new overlay with a tombstone for the given key, so that it
+// is deleted from the base tree on compaction.
+func (o *Overlay[T]) Delete(k []byte) *Overlay[T] {
+	ops, _, _ := o.ops.Insert(k, overlayOp[T]{deleted: true})
+	return &Overlay[T]{base: o.base, ops: ops}
+}
+
+// ApplyTo makes all the writes in the overlay to the given transaction, which
+// would normally be on the base tree. This lets the caller turn on mutation
+// tracking before folding the overlay in. The writes are made in key order,
+// so nodes along shared paths are copied once and then modified in place for
+// the rest of the transaction.
+func (o *Overlay[T]) ApplyTo(txn *Txn[T]) {
+	o.ops.Root().Walk(func(k []byte, op overlayOp[T]) bool {
+		if op.deleted {
+			txn.Delete(k)
+		} else {
+			txn.Insert(k, op.val)
+		}
+		return false
+	})
+}
+
+// Compact folds the overlay into the base tree in a single transaction and
+// returns the resulting tree. Subtrees of the base that the overlay didn't
+// touch are shared with the result.
+func (o *Overlay[T]) Compact() *Tree[T] {
+	txn := o.base.Txn()
+	o.ApplyTo(txn)
+	return txn.Commit()
+}
+
+// Iterator returns an iterator over the keys and values of the overlay as they
+// would be after compaction.
+func (o *Overlay[T]) Iterator() *OverlayIterator[T] {
+	return &OverlayIterator[T]{
+		base:    o.base.Iterator(),
+		ops:     o.ops.Iterator(),
+		collate: o.base.conf.collate,
+	}
+}
+
+// OverlayIterator iterates over an Overlay in key order, merging the base tree
+// with the pending writes: inserted values shadow the ones in the base tree,
+// and deleted keys are skipped. This lets the writes collected so far be read
+// back in order, for example to validate them, before they're compacted.
+type OverlayIterator[T any] struct {
+	base    *Iterator[T]
+	ops     *Iterator[overlayOp[T]]
+	collate *[256]byte
+
+	// The next element of each iterator, once primed.
+	primed  bool
+	baseKey []byte
+	baseVal T
+	baseOK  bool
+	opKey   []byte
+	op      overlayOp[T]
+	opOK    bool
+}
+
+// SeekPrefix is used to seek the iterator to the keys with the given prefix.
+func (i *OverlayIterator[T]) SeekPrefix(prefix []byte) {
+	i.base.SeekPrefix(prefix)
+	i.ops.SeekPrefix(prefix)
+	i.primed = false
+}
+
+// SeekLowerBound is used to seek the iterator to the smallest key that is
+// greater or equal to the given key.
+func (i *OverlayIterator[T]) SeekLowerBound(key []byte) {
+	i.base.SeekLowerBound(key)
+	i.ops.SeekLowerBound(key)
+	i.primed = false
+}
+
+// Next returns the next key and value, merged from the base and the pending
+// writes.
+func (i *OverlayIterator[T]) Next() ([]byte, T, bool) {
+	if !i.primed {
+		i.baseKey, i.baseVal, i.baseOK = i.base.Next()
+		i.opKey, i.op, i.opOK = i.ops.Next()
+		i.primed = true
+	}
+	for i.baseOK || i.opOK {
+		cmp := -1
+		if !i.opOK {
+			cmp = 1
+		} else if i.baseOK {
+			cmp = i.compare(i.opKey, i.baseKey)
+		}
+		if cmp > 0 {
+			k, v := i.baseKey, i.baseVal
+			i.baseKey, i.baseVal, i.baseOK = i.base.Next()
+			return k, v, true
+		}
+		if cmp == 0 {
+			// The base value is shadowed.
+			i.baseKey, i.baseVal, i.baseOK = i.base.Next()
+		}
+		k, op := i.opKey, i.op
+		i.opKey, i.op, i.opOK = i.ops.Next()
+		if !op.deleted {
+			return k, op.val, true
+		}
+	}
+	var zero T
+	return nil, zero, false
+}
+
+// compare compares two keys in the order of the tree.
+func (i *OverlayIterator[T]) compare(a, b []byte) int {
+	if i.collate == nil {
+		return bytes.Compare(a, b)
+	}
+	for j := 0; j < len(a) && j < len(b); j++ {
+		if ca, cb := i.collate[a[j]], i.collate[b[j]]; ca != cb {
+			if ca < cb {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,144 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestOverlay(t *testing.T) {
+	base := New[int]()
+	for i := 0; i < 10; i++ {
+		base, _, _ = base.Insert([]byte(fmt.Sprintf("base/%d", i)), i)
+	}
+	base, _, _ = base.Insert([]byte("other"), 0)
+
+	o := NewOverlay(base)
+	o1 := o.Insert([]byte("base/1"), 100)
+	o2 := o1.Insert([]byte("new"), 1).Delete([]byte("base/2")).Delete([]byte("missing"))
+
+	// Earlier overlays are unchanged.
+	if o.Pending() != 0 || o1.Pending() != 1 || o2.Pending() != 4 {
+		t.Fatalf("bad pending: %d %d %d", o.Pending(), o1.Pending(), o2.Pending())
+	}
+	if v, _ := o1.Get([]byte("base/1")); v != 100 {
+		t.Fatalf("bad: %v", v)
+	}
+	if _, ok := o1.Get([]byte("new")); ok {
+		t.Fatalf("unexpected key")
+	}
+
+	// Reads see through to the base, except for tombstones.
+	cases := []struct {
+		key string
+		val int
+		ok  bool
+	}{
+		{"base/0", 0, true},
+		{"base/1", 100, true},
+		{"base/2", 0, false},
+		{"new", 1, true},
+		{"missing", 0, false},
+	}
+	for _, c := range cases {
+		v, ok := o2.Get([]byte(c.key))
+		if v != c.val || ok != c.ok {
+			t.Fatalf("%s: got %v %v", c.key, v, ok)
+		}
+	}
+
+	// Compacting folds everything into the base.
+	r := o2.Compact()
+	if r.Len() != base.Len() {
+		t.Fatalf("bad len: %d", r.Len())
+	}
+	for _, c := range cases {
+		v, ok := r.Get([]byte(c.key))
+		if v != c.val || ok != c.ok {
+			t.Fatalf("%s: got %v %v", c.key, v, ok)
+		}
+	}
+	checkCounts(t, r.Root())
+	if _, ok := base.Get([]byte("new")); ok {
+		t.Fatalf("base modified")
+	}
+
+	// Untouched subtrees are shared with the base.
+	_, baseOther := base.Root().getEdge('o')
+	_, rOther := r.Root().getEdge('o')
+	if baseOther == nil || baseOther != rOther {
+		t.Fatalf("expected untouched subtree to be shared")
+	}
+
+	// ApplyTo lets the caller track the changes.
+	ch, _, _ := base.Root().GetWatch([]byte("base/2"))
+	txn := base.Txn()
+	txn.TrackMutate(true)
+	o2.ApplyTo(txn)
+	txn.Commit()
+	select {
+	case <-ch:
+	default:
+		t.Fatalf("expected notification")
+	}
+}
+
+func TestOverlayIterator(t *testing.T) {
+	for _, collate := range []bool{false, true} {
+		var opts []Option
+		if collate {
+			opts = append(opts, WithCollation(FoldCaseCollation()))
+		}
+		base := New[int](opts...)
+		o := NewOverlay(base)
+		for i := 0; i < 500; i++ {
+			k := []byte(fmt.Sprintf("%c/%d", "aAbB"[rand.Intn(4)], rand.Intn(100)))
+			switch rand.Intn(3) {
+			case 0:
+				base, _, _ = base.Insert(k, i)
+			case 1:
+				o = o.Insert(k, i)
+			default:
+				o = o.Delete(k)
+			}
+		}
+		// Layer the writes over the final base.
+		o = &Overlay[int]{base: base, ops: o.ops}
+		r := o.Compact()
+
+		collect := func(next func() ([]byte, int, bool)) []string {
+			var out []string
+			for k, v, ok := next(); ok; k, v, ok = next() {
+				out = append(out, fmt.Sprintf("%s=%d", k, v))
+			}
+			return out
+		}
+		it := o.Iterator()
+		expect := r.Iterator()
+		if got, want := collect(it.Next), collect(expect.Next); !reflect.DeepEqual(got, want) {
+			t.Fatalf("collate %v: got %v, want %v", collate, got, want)
+		}
+
+		for _, seek := range []string{"", "a", "A/5", "b/50", "c"} {
+			it = o.Iterator()
+			it.SeekLowerBound([]byte(seek))
+			expect = r.Iterator()
+			expect.SeekLowerBound([]byte(seek))
+			if got, want := collect(it.Next), collect(expect.Next); !reflect.DeepEqual(got, want) {
+				t.Fatalf("collate %v: lower bound %q: got %v, want %v", collate, seek, got, want)
+			}
+
+			it = o.Iterator()
+			it.SeekPrefix([]byte(seek))
+			expect = r.Iterator()
+			expect.SeekPrefix([]byte(seek))
+			if got, want := collect(it.Next), collect(expect.Next); !reflect.DeepEqual(got, want) {
+				t.Fatalf("collate %v: prefix %q: got %v, want %v", collate, seek, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// parallelUnitsPerWorker is the number of pieces of work WalkParallel aims to
+// split the tree into for each worker, so that workers that get small subtrees
+// can pick up more work instead of waiting on the ones with large subtrees.
+const parallelUnitsPerWorker = 4
+
+// WalkParallel walks the whole tree, calling fn for every key and value with up
+// to parallelism goroutines, which is useful for full scans of large trees
+// since they're safe for concurrent reads. A parallelism of zero or less uses
+// GOMAXPROCS goroutines. The tree is split into subtrees from the root down
+// until there are a few for each goroutine, and each subtree is walked in
+// order by a single goroutine, but the subtrees are walked in no particular
+// order, so fn must be safe to call concurrently. If fn returns true, the walk
+// is stopped, though calls that are already running on other goroutines still
+// finish. WalkParallel returns once all the calls to fn have returned.
+func (t *Tree[T]) WalkParallel(fn WalkFn[T], parallelism int) {
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	units := splitWalk(t.root, parallelism*parallelUnitsPerWorker)
+
+	var stop int32
+	visit := func(k []byte, v T) bool {
+		if atomic.LoadInt32(&stop) != 0 {
+			return true
+		}
+		if fn(k, v) {
+			atomic.StoreInt32(&stop, 1)
+			return true
+		}
+		return false
+	}
+
+	work := make(chan walkUnit[T])
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism && i < len(units); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range work {
+				if u.node != nil {
+					recursiveWalk(u.node, visit)
+				} else {
+					visit(u.leaf.key, u.leaf.val)
+				}
+			}
+		}()
+	}
+	for _, u := range units {
+		if atomic.LoadInt32(&stop) != 0 {
+			break
+		}
+		work <- u
+	}
+	close(work)
+	wg.Wait()
+}
+
+// walkUnit is a piece of the work of WalkParallel, which is either a subtree
+// or the leaf of a node whose children are walked separately.
+type walkUnit[T any] struct {
+	node *Node[T]
+	leaf *leafNode[T]
+}
+
+// splitWalk splits the tree under n a level at a time until there are at least
+// target pieces, or every piece is a single leaf.
+func splitWalk[T any](n *Node[T], target int) []walkUnit[T] {
+	units := []walkUnit[T]{{node: n}}
+	for len(units) < target {
+		var next []walkUnit[T]
+		split := false
+		for _, u := range units {
+			if u.node == nil || len(u.node.edges()) == 0 {
+				next = append(next, u)
+				continue
+			}
+			split = true
+			if u.node.leaf != nil {
+				next = append(next, walkUnit[T]{leaf: u.node.leaf})
+			}
+			for _, e := range u.node.edges() {
+				next = append(next, walkUnit[T]{node: e.node})
+			}
+		}
+		units = next
+		if !split {
+			break
+		}
+	}
+	return units
+}
@@ -0,0 +1,79 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTree_WalkParallel(t *testing.T) {
+	txn := New[int]().Txn()
+	for i := 0; i < 5000; i++ {
+		txn.Insert([]byte(fmt.Sprintf("%d", i)), i)
+	}
+	txn.Insert([]byte{}, -1)
+	r := txn.Commit()
+
+	for _, parallelism := range []int{0, 1, 3, 64} {
+		var mu sync.Mutex
+		seen := make(map[string]int)
+		r.WalkParallel(func(k []byte, v int) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			seen[string(k)]++
+			if want, _ := r.Get(k); want != v {
+				t.Errorf("bad value for %q: %d", k, v)
+			}
+			return false
+		}, parallelism)
+		if len(seen) != r.Len() {
+			t.Fatalf("parallelism %d: saw %d keys, expected %d", parallelism, len(seen), r.Len())
+		}
+		for k, n := range seen {
+			if n != 1 {
+				t.Fatalf("parallelism %d: saw %q %d times", parallelism, k, n)
+			}
+		}
+	}
+
+	// Stopping the walk stops the other workers too.
+	var calls int32
+	r.WalkParallel(func([]byte, int) bool {
+		atomic.AddInt32(&calls, 1)
+		return true
+	}, 4)
+	if calls < 1 || calls > 4 {
+		t.Fatalf("bad number of calls: %d", calls)
+	}
+
+	New[int]().WalkParallel(func([]byte, int) bool {
+		t.Fatalf("unexpected key")
+		return false
+	}, 4)
+
+	// Subtrees are walked in order.
+	units := splitWalk(r.Root(), 8)
+	if len(units) < 8 || units[0].leaf == nil || string(units[0].leaf.key) != "" {
+		t.Fatalf("bad split: %d units", len(units))
+	}
+	var keys []string
+	for _, u := range units {
+		if u.node == nil {
+			keys = append(keys, string(u.leaf.key))
+			continue
+		}
+		u.node.Walk(func(k []byte, _ int) bool {
+			keys = append(keys, string(k))
+			return false
+		})
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i-1] >= keys[i] {
+			t.Fatalf("units out of order at %q", keys[i])
+		}
+	}
+}
@@ -0,0 +1,371 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+// ChangeType is the kind of change made to a key.
+type ChangeType uint8
+
+const (
+	// ChangeAdded means the key was inserted. Only Change.New is set.
+	ChangeAdded ChangeType = iota + 1
+
+	// ChangeUpdated means the value of an existing key was replaced. Both
+	// Change.Old and Change.New are set.
+	ChangeUpdated
+
+	// ChangeDeleted means the key was deleted. Only Change.Old is set.
+	ChangeDeleted
+)
+
+// String returns the name of the change type, which is also its JSON encoding.
+func (c ChangeType) String() string {
+	switch c {
+	case ChangeAdded:
+		return "added"
+	case ChangeUpdated:
+		return "updated"
+	case ChangeDeleted:
+		return "deleted"
+	default:
+		return fmt.Sprintf("ChangeType(%d)", uint8(c))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c ChangeType) MarshalText() ([]byte, error) {
+	if !c.valid() {
+		return nil, fmt.Errorf("iradix: invalid change type %d", uint8(c))
+	}
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *ChangeType) UnmarshalText(b []byte) error {
+	for t := ChangeAdded; t <= ChangeDeleted; t++ {
+		if string(b) == t.String() {
+			*c = t
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unknown change type %q", ErrInvalidEncoding, b)
+}
+
+func (c ChangeType) valid() bool {
+	return c >= ChangeAdded && c <= ChangeDeleted
+}
+
+func (c ChangeType) hasOld() bool {
+	return c == ChangeUpdated || c == ChangeDeleted
+}
+
+func (c ChangeType) hasNew() bool {
+	return c == ChangeAdded || c == ChangeUpdated
+}
+
+// Change describes a change to the value of a single key.
+type Change[T any] struct {
+	Type ChangeType
+	Key  []byte
+
+	// Old is the value before the change, and New the value after it. Each
+	// is only set if the type of change calls for it.
+	Old T
+	New T
+}
+
+// Patch is a list of changes, ordered by key, that turns one version of a tree
+// into another.
+//
+// Patches have two stable encodings for exchanging them between processes. The
+// binary one, written by AppendBinary and read by DecodePatch, encodes values
+// with a Codec. The JSON one, from MarshalJSON and UnmarshalJSON, encodes
+// values with encoding/json, so they can customize it by implementing
+// json.Marshaler and json.Unmarshaler. Both start with a version number so the
+// formats can be extended.
+type Patch[T any] []Change[T]
+
+// Apply makes the changes in the patch to the transaction. Only the new state
+// of each key is used: added and updated keys are inserted, and deleted keys
+// are deleted.
+func (p Patch[T]) Apply(txn *Txn[T]) {
+	for _, c := range p {
+		switch c.Type {
+		case ChangeAdded, ChangeUpdated:
+			txn.Insert(c.Key, c.New)
+		case ChangeDeleted:
+			txn.Delete(c.Key)
+		}
+	}
+}
+
+// Diff returns the changes that turn old into this tree. Committed trees share
+// the nodes that weren't modified, so only the subtrees that differ between
+// the two versions are visited, as with ChangedIterator. A key whose leaf was
+// replaced is reported as updated even if the new value is equal to the old
+// one, since values can't be compared in general.
+func (t *Tree[T]) Diff(old *Tree[T]) Patch[T] {
+	var p Patch[T]
+	t.DiffFunc(old, func(c Change[T]) bool {
+		p = append(p, c)
+		return false
+	})
+	return p
+}
+
+// DiffFunc is like Diff, but calls fn with each change in key order instead of
+// returning them all. Returning true from fn stops the diff.
+func (t *Tree[T]) DiffFunc(old *Tree[T], fn func(Change[T]) bool) {
+	it := NewChangedIterator(old.root, t.root)
+	for {
+		oldLeaf, newLeaf, ok := it.next()
+		if !ok {
+			return
+		}
+		var c Change[T]
+		switch {
+		case oldLeaf == nil:
+			c = Change[T]{Type: ChangeAdded, Key: newLeaf.key, New: newLeaf.val}
+		case newLeaf == nil:
+			c = Change[T]{Type: ChangeDeleted, Key: oldLeaf.key, Old: oldLeaf.val}
+		default:
+			c = Change[T]{Type: ChangeUpdated, Key: newLeaf.key, Old: oldLeaf.val, New: newLeaf.val}
+		}
+		if fn(c) {
+			return
+		}
+	}
+}
+
+const (
+	// patchMagic starts every binary encoded patch.
+	patchMagic = "IRXP"
+
+	// patchVersion is the version of the patch encodings.
+	patchVersion = 1
+)
+
+// AppendBinary appends the binary encoding of the patch to b, using c to encode
+// the values, and returns the result. The encoding is the magic string "IRXP",
+// a version byte and a uvarint count of changes. Each change is a type byte,
+// then its key, its old value if it has one, and its new value if it has one,
+// each as a uvarint length followed by that many bytes.
+func (p Patch[T]) AppendBinary(b []byte, c Codec[T]) ([]byte, error) {
+	b = append(b, patchMagic...)
+	b = append(b, patchVersion)
+	b = appendUvarint(b, uint64(len(p)))
+	var err error
+	for _, ch := range p {
+		if !ch.Type.valid() {
+			return nil, fmt.Errorf("iradix: invalid change type %d", uint8(ch.Type))
+		}
+		b = append(b, byte(ch.Type))
+		b = appendUvarint(b, uint64(len(ch.Key)))
+		b = append(b, ch.Key...)
+		if ch.Type.hasOld() {
+			if b, err = appendValue(b, ch.Old, c); err != nil {
+				return nil, err
+			}
+		}
+		if ch.Type.hasNew() {
+			if b, err = appendValue(b, ch.New, c); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b, nil
+}
+
+// DecodePatch decodes a patch written by AppendBinary, using c to decode the
+// values. It returns an error wrapping ErrInvalidEncoding if b isn't a
+// complete patch in a supported version.
+func DecodePatch[T any](b []byte, c Codec[T]) (Patch[T], error) {
+	d := &decoder{b: b}
+	if string(d.next(len(patchMagic))) != patchMagic {
+		return nil, fmt.Errorf("%w: not a patch", ErrInvalidEncoding)
+	}
+	if v := d.next(1); len(v) == 1 && v[0] != patchVersion {
+		return nil, fmt.Errorf("%w: unsupported patch version %d", ErrInvalidEncoding, v[0])
+	}
+	n := d.uvarint()
+
+	// Every change takes at least two bytes, which bounds the allocation
+	// for corrupt counts.
+	if n > uint64(len(d.b)/2) {
+		return nil, fmt.Errorf("%w: truncated patch", ErrInvalidEncoding)
+	}
+	p := make(Patch[T], 0, n)
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		var ch Change[T]
+		if t := d.next(1); len(t) == 1 {
+			ch.Type = ChangeType(t[0])
+		}
+		if d.err == nil && !ch.Type.valid() {
+			return nil, fmt.Errorf("%w: unknown change type %d", ErrInvalidEncoding, uint8(ch.Type))
+		}
+		ch.Key = append([]byte{}, d.bytes()...)
+		if ch.Type.hasOld() {
+			ch.Old = decodeValue(d, c)
+		}
+		if ch.Type.hasNew() {
+			ch.New = decodeValue(d, c)
+		}
+		p = append(p, ch)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(d.b) != 0 {
+		return nil, fmt.Errorf("%w: trailing data after patch", ErrInvalidEncoding)
+	}
+	return p, nil
+}
+
+// jsonPatch and jsonChange are the JSON encodings of Patch and Change. Keys are
+// encoded as base64 strings, like any []byte.
+type jsonPatch[T any] struct {
+	Version int             `json:"version"`
+	Changes []jsonChange[T] `json:"changes"`
+}
+
+type jsonChange[T any] struct {
+	Type ChangeType `json:"type"`
+	Key  []byte     `json:"key"`
+	Old  *T         `json:"old,omitempty"`
+	New  *T         `json:"new,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p Patch[T]) MarshalJSON() ([]byte, error) {
+	jp := jsonPatch[T]{
+		Version: patchVersion,
+		Changes: make([]jsonChange[T], len(p)),
+	}
+	for i := range p {
+		ch := &p[i]
+		jc := jsonChange[T]{Type: ch.Type, Key: ch.Key}
+		if ch.Key == nil {
+			jc.Key = []byte{}
+		}
+		if ch.Type.hasOld() {
+			jc.Old = &ch.Old
+		}
+		if ch.Type.hasNew() {
+			jc.New = &ch.New
+		}
+		jp.Changes[i] = jc
+	}
+	return json.Marshal(jp)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *Patch[T]) UnmarshalJSON(b []byte) error {
+	var jp jsonPatch[T]
+	if err := json.Unmarshal(b, &jp); err != nil {
+		return err
+	}
+	if jp.Version != patchVersion {
+		return fmt.Errorf("%w: unsupported patch version %d", ErrInvalidEncoding, jp.Version)
+	}
+	out := make(Patch[T], len(jp.Changes))
+	for i, jc := range jp.Changes {
+		if jc.Key == nil {
+			return fmt.Errorf("%w: change %d has no key", ErrInvalidEncoding, i)
+		}
+		if (jc.Old != nil) != jc.Type.hasOld() || (jc.New != nil) != jc.Type.hasNew() {
+			return fmt.Errorf("%w: change %d has the wrong values for %q", ErrInvalidEncoding, i, jc.Type)
+		}
+		ch := Change[T]{Type: jc.Type, Key: jc.Key}
+		if jc.Old != nil {
+			ch.Old = *jc.Old
+		}
+		if jc.New != nil {
+			ch.New = *jc.New
+		}
+		out[i] = ch
+	}
+	*p = out
+	return nil
+}
+
+// appendValue appends v encoded with c, prefixed by its length.
+func appendValue[T any](b []byte, v T, c Codec[T]) ([]byte, error) {
+	enc, err := c.AppendValue(nil, v)
+	if err != nil {
+		return nil, err
+	}
+	b = appendUvarint(b, uint64(len(enc)))
+	return append(b, enc...), nil
+}
+
+// decodeValue decodes a value written by appendValue, recording any error in
+// d.
+func decodeValue[T any](d *decoder, c Codec[T]) T {
+	var v T
+	b := d.bytes()
+	if d.err != nil {
+		return v
+	}
+	v, err := c.DecodeValue(b)
+	if err != nil {
+		d.err = err
+	}
+	return v
+}
+
+func appendUvarint(b []byte, x uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], x)
+	return append(b, buf[:n]...)
+}
+
+// decoder reads from a byte slice, recording the first error it hits so
+// callers can check it once at the end. Once it has failed all reads return
+// zero values.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+// next consumes and returns the next n bytes.
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.b) {
+		d.err = fmt.Errorf("%w: unexpected end of data", ErrInvalidEncoding)
+		return nil
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+// uvarint consumes a uvarint.
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = fmt.Errorf("%w: bad length", ErrInvalidEncoding)
+		return 0
+	}
+	d.b = d.b[n:]
+	return x
+}
+
+// bytes consumes a length prefixed byte string.
+func (d *decoder) bytes() []byte {
+	n := d.uvarint()
+	if d.err == nil && n > uint64(len(d.b)) {
+		d.err = fmt.Errorf("%w: unexpected end of data", ErrInvalidEncoding)
+	}
+	return d.next(int(n))
+}
@@ -0,0 +1,191 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func testPatch() Patch[string] {
+	return Patch[string]{
+		{Type: ChangeAdded, Key: []byte("a"), New: "1"},
+		{Type: ChangeUpdated, Key: []byte("b"), Old: "2", New: "3"},
+		{Type: ChangeDeleted, Key: []byte(""), Old: "4"},
+	}
+}
+
+func TestPatch_Binary(t *testing.T) {
+	p := testPatch()
+	b, err := p.AppendBinary(nil, StringCodec{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The encoding is stable, so check it byte for byte.
+	expect := "IRXP\x01\x03" +
+		"\x01\x01a\x011" +
+		"\x02\x01b\x012\x013" +
+		"\x03\x00\x014"
+	if string(b) != expect {
+		t.Fatalf("bad encoding: %q", b)
+	}
+
+	out, err := DecodePatch[string](b, StringCodec{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(out, p) {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	// Every truncation, and trailing data, is an error.
+	for i := 0; i < len(b); i++ {
+		if _, err := DecodePatch[string](b[:i], StringCodec{}); !errors.Is(err, ErrInvalidEncoding) {
+			t.Fatalf("%d: expected error, got %v", i, err)
+		}
+	}
+	if _, err := DecodePatch[string](append(b, 0), StringCodec{}); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("expected error, got %v", err)
+	}
+
+	bad := []string{
+		"IRXP\x02\x00",
+		"IRXP\x01\x01\x04\x00",
+		"IRXP\x01\xff\xff\xff\xff\x0f",
+	}
+	for _, in := range bad {
+		if _, err := DecodePatch[string]([]byte(in), StringCodec{}); !errors.Is(err, ErrInvalidEncoding) {
+			t.Fatalf("%q: expected error, got %v", in, err)
+		}
+	}
+
+	if _, err := (Patch[string]{{Key: []byte("a")}}).AppendBinary(nil, StringCodec{}); err == nil {
+		t.Fatalf("expected error for zero change type")
+	}
+}
+
+func TestPatch_BinaryJSONCodec(t *testing.T) {
+	type value struct {
+		A int
+		B []string
+	}
+	p := Patch[value]{
+		{Type: ChangeAdded, Key: []byte("x"), New: value{A: 1, B: []string{"a"}}},
+		{Type: ChangeUpdated, Key: []byte("y"), Old: value{A: 2}, New: value{A: 3}},
+	}
+	b, err := p.AppendBinary(nil, JSONCodec[value]{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	out, err := DecodePatch[value](b, JSONCodec[value]{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(out, p) {
+		t.Fatalf("bad: %#v", out)
+	}
+}
+
+func TestPatch_JSON(t *testing.T) {
+	p := testPatch()
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expect := `{"version":1,"changes":[` +
+		`{"type":"added","key":"YQ==","new":"1"},` +
+		`{"type":"updated","key":"Yg==","old":"2","new":"3"},` +
+		`{"type":"deleted","key":"","old":"4"}]}`
+	if string(b) != expect {
+		t.Fatalf("bad encoding: %s", b)
+	}
+
+	var out Patch[string]
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(out, p) {
+		t.Fatalf("bad: %#v", out)
+	}
+
+	bad := []string{
+		`{"version":2,"changes":[]}`,
+		`{"version":1,"changes":[{"type":"moved","key":""}]}`,
+		`{"version":1,"changes":[{"type":"added","new":"1"}]}`,
+		`{"version":1,"changes":[{"type":"added","key":"","old":"1"}]}`,
+		`{"version":1,"changes":[{"type":"deleted","key":""}]}`,
+	}
+	for _, in := range bad {
+		if err := json.Unmarshal([]byte(in), &out); !errors.Is(err, ErrInvalidEncoding) {
+			t.Fatalf("%s: expected error, got %v", in, err)
+		}
+	}
+}
+
+func TestPatch_Apply(t *testing.T) {
+	r := New[string]()
+	r, _, _ = r.Insert([]byte("b"), "2")
+	r, _, _ = r.Insert([]byte(""), "4")
+
+	txn := r.Txn()
+	testPatch().Apply(txn)
+	r = txn.Commit()
+
+	verifyTree(t, []string{"a", "b"}, r)
+	if v, _ := r.Get([]byte("b")); v != "3" {
+		t.Fatalf("bad: %v", v)
+	}
+}
+
+func TestTree_Diff(t *testing.T) {
+	r := New[string]()
+	for _, k := range []string{"a", "b", "foo/1", "foo/2", "zip"} {
+		r, _, _ = r.Insert([]byte(k), k)
+	}
+
+	txn := r.Txn()
+	txn.Insert([]byte("b"), "B")
+	txn.Insert([]byte("c"), "c")
+	txn.Delete([]byte("foo/1"))
+	txn.Delete([]byte("zip"))
+	txn.Insert([]byte("foo/3"), "foo/3")
+	nr := txn.Commit()
+
+	expect := Patch[string]{
+		{Type: ChangeUpdated, Key: []byte("b"), Old: "b", New: "B"},
+		{Type: ChangeAdded, Key: []byte("c"), New: "c"},
+		{Type: ChangeDeleted, Key: []byte("foo/1"), Old: "foo/1"},
+		{Type: ChangeAdded, Key: []byte("foo/3"), New: "foo/3"},
+		{Type: ChangeDeleted, Key: []byte("zip"), Old: "zip"},
+	}
+	if got := nr.Diff(r); !reflect.DeepEqual(got, expect) {
+		t.Fatalf("got %v, want %v", got, expect)
+	}
+	if got := r.Diff(r); got != nil {
+		t.Fatalf("bad: %v", got)
+	}
+
+	// Applying the diff to the old tree gives the new one.
+	txn = r.Txn()
+	nr.Diff(r).Apply(txn)
+	for _, c := range txn.Commit().Diff(nr) {
+		// The rebuilt leaves differ, but hold the same values.
+		if c.Type != ChangeUpdated || c.Old != c.New {
+			t.Fatalf("bad: %v", c)
+		}
+	}
+
+	// DiffFunc stops when asked.
+	var n int
+	nr.DiffFunc(r, func(c Change[string]) bool {
+		n++
+		return c.Type == ChangeAdded
+	})
+	if n != 2 {
+		t.Fatalf("bad: %d", n)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTreePool(t *testing.T) {
+	p := NewTreePool[int](WithLeafMeta())
+	for round := 0; round < 3; round++ {
+		r := p.AcquireTree()
+		if r.Len() != 0 {
+			t.Fatalf("bad len: %d", r.Len())
+		}
+
+		var keys []string
+		txn := r.Txn()
+		txn.TrackMutate(true)
+		for i := 0; i < 500; i++ {
+			k := fmt.Sprintf("%03d/%d", i, round)
+			keys = append(keys, k)
+			txn.Insert([]byte(k), i)
+		}
+		r = txn.Commit()
+
+		// Derived trees share the arena.
+		r, _, _ = r.Delete([]byte("000/" + fmt.Sprint(round)))
+		keys = keys[1:]
+		if r.arena == nil {
+			t.Fatalf("derived tree lost its arena")
+		}
+		verifyTree(t, keys, r)
+		checkCounts(t, r.Root())
+		if meta, ok := r.GetMeta([]byte(keys[0])); !ok || meta.Created != 1 {
+			t.Fatalf("bad meta: %v %v", meta, ok)
+		}
+		p.ReleaseTree(r)
+	}
+}
+
+func TestTreePool_ReleaseUnpooled(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected a panic")
+		}
+	}()
+	NewTreePool[int]().ReleaseTree(New[int]())
+}
+
+func TestArena_Reset(t *testing.T) {
+	a := &arena[int]{}
+	var nodes []*Node[int]
+	for i := 0; i < 2*arenaChunkSize+1; i++ {
+		n := a.node()
+		n.setCount(i)
+		nodes = append(nodes, n)
+	}
+	l := a.leaf()
+	l.val = 1
+	openCh, closedCh := nodes[0].mutateCh, nodes[1].mutateCh
+	close(closedCh)
+
+	a.reset()
+	if a.numNodes != 0 || a.numLeaves != 0 {
+		t.Fatalf("bad: %d %d", a.numNodes, a.numLeaves)
+	}
+	for _, n := range nodes {
+		if n.count() != 0 {
+			t.Fatalf("node not cleared")
+		}
+	}
+	if l.val != 0 {
+		t.Fatalf("leaf not cleared")
+	}
+
+	// Open channels are reused, closed ones replaced.
+	if n := a.node(); n != nodes[0] || n.mutateCh != openCh {
+		t.Fatalf("expected slot and channel to be reused")
+	}
+	if n := a.node(); n.mutateCh == closedCh || n.mutateCh == nil {
+		t.Fatalf("closed channel reused")
+	}
+}
+
+func BenchmarkTreePool(b *testing.B) {
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("key/%06d", i))
+	}
+	build := func(r *Tree[int]) *Tree[int] {
+		txn := r.Txn()
+		for i, k := range keys {
+			txn.Insert(k, i)
+		}
+		return txn.Commit()
+	}
+
+	b.Run("new", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			build(New[int]())
+		}
+	})
+	b.Run("pool", func(b *testing.B) {
+		b.ReportAllocs()
+		p := NewTreePool[int]()
+		for i := 0; i < b.N; i++ {
+			p.ReleaseTree(build(p.AcquireTree()))
+		}
+	})
+}
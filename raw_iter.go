@@ -66,9 +66,9 @@ func (i *rawIterator[T]) Next() {
 		}
 
 		// Push the edges onto the frontier.
-		if len(elem.edges) > 0 {
+		if len(elem.edges()) > 0 {
 			path := last.path + string(elem.prefix)
-			i.stack = append(i.stack, rawStackEntry[T]{path, elem.edges})
+			i.stack = append(i.stack, rawStackEntry[T]{path, elem.edges()})
 		}
 
 		i.pos = elem
@@ -79,3 +79,13 @@ func (i *rawIterator[T]) Next() {
 	i.pos = nil
 	i.path = ""
 }
+
+// skip advances the iterator past the current node and everything under it.
+func (i *rawIterator[T]) skip() {
+	// Next pushes the edges of the node it lands on, so if there were any
+	// they are on the top of the stack and can be dropped.
+	if i.pos != nil && len(i.pos.edges()) > 0 {
+		i.stack = i.stack[:len(i.stack)-1]
+	}
+	i.Next()
+}
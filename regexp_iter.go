@@ -0,0 +1,74 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"regexp"
+	"regexp/syntax"
+)
+
+// RegexpIterator iterates over the keys that match a regular expression, in
+// order. If the expression is anchored at the start of the key, with ^ or \A,
+// the iterator is first seeked to the literal prefix that follows the anchor,
+// so only the keys with that prefix are visited, and otherwise every key is
+// tested. Keys are matched with Regexp.Match, so the expression has to be
+// anchored at both ends to match whole keys. The expression is assumed to have
+// been compiled with regexp.Compile; with regexp.CompilePOSIX, a ^ that can
+// match after a newline in a key must be avoided.
+type RegexpIterator[T any] struct {
+	it *Iterator[T]
+	re *regexp.Regexp
+}
+
+// RegexpIterator returns an iterator over the keys under this node that match
+// re.
+func (n *Node[T]) RegexpIterator(re *regexp.Regexp) *RegexpIterator[T] {
+	return newRegexpIterator(n.Iterator(), re)
+}
+
+// RegexpIterator returns an iterator over the keys in the tree that match re.
+// The literal prefix is translated if the tree was created with WithCollation,
+// but the expression is matched against the keys as they were inserted.
+func (t *Tree[T]) RegexpIterator(re *regexp.Regexp) *RegexpIterator[T] {
+	return newRegexpIterator(t.Iterator(), re)
+}
+
+func newRegexpIterator[T any](it *Iterator[T], re *regexp.Regexp) *RegexpIterator[T] {
+	if prefix := regexpPrefix(re); len(prefix) != 0 {
+		it.SeekPrefix(prefix)
+	}
+	return &RegexpIterator[T]{it: it, re: re}
+}
+
+// regexpPrefix returns the literal prefix every key matching re must start
+// with, which is empty unless re is anchored at the start. Regexp.LiteralPrefix
+// can't be used since it gives the prefix of the match, which only starts the
+// key if the expression is anchored, and misses the prefix in many anchored
+// expressions that aren't one-pass.
+func regexpPrefix(re *regexp.Regexp) []byte {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	parsed = parsed.Simplify()
+	if parsed.Op != syntax.OpConcat || len(parsed.Sub) < 2 || parsed.Sub[0].Op != syntax.OpBeginText {
+		return nil
+	}
+	lit := parsed.Sub[1]
+	if lit.Op != syntax.OpLiteral || lit.Flags&syntax.FoldCase != 0 {
+		return nil
+	}
+	return []byte(string(lit.Rune))
+}
+
+// Next returns the next key that matches, and its value, or false once the
+// keys are exhausted.
+func (i *RegexpIterator[T]) Next() ([]byte, T, bool) {
+	for {
+		k, v, ok := i.it.Next()
+		if !ok || i.re.Match(k) {
+			return k, v, ok
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestRegexpIterator(t *testing.T) {
+	r := New[int]()
+	keys := []string{"service/a/leader", "service/b/follower", "service/b/leader", "services/leader", "other/service/a/leader", "leader"}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	cases := []struct {
+		expr   string
+		prefix string
+		expect []string
+	}{
+		{`^service/[^/]+/leader$`, "service/", []string{"service/a/leader", "service/b/leader"}},
+		{`service/[^/]+/leader$`, "", []string{"other/service/a/leader", "service/a/leader", "service/b/leader"}},
+		{`^services?/.*leader`, "service", []string{"service/a/leader", "service/b/leader", "services/leader"}},
+		{`\Aleader`, "leader", []string{"leader"}},
+		{`(?i)^SERVICES`, "", []string{"services/leader"}},
+		{`^other|^leader`, "", []string{"leader", "other/service/a/leader"}},
+		{`^nope`, "nope", nil},
+	}
+	for _, c := range cases {
+		re := regexp.MustCompile(c.expr)
+		if got := string(regexpPrefix(re)); got != c.prefix {
+			t.Fatalf("bad prefix for %q: %q", c.expr, got)
+		}
+		var got []string
+		it := r.RegexpIterator(re)
+		for k, v, ok := it.Next(); ok; k, v, ok = it.Next() {
+			if keys[v] != string(k) {
+				t.Fatalf("bad value for %q: %d", k, v)
+			}
+			got = append(got, string(k))
+		}
+		if !reflect.DeepEqual(got, c.expect) {
+			t.Fatalf("bad keys for %q: %q", c.expr, got)
+		}
+	}
+
+	// The prefix is translated through the collation.
+	f := New[int](WithCollation(FoldCaseCollation()))
+	for i, k := range []string{"Ab", "ab", "b", "a"} {
+		f, _, _ = f.Insert([]byte(k), i)
+	}
+	var got []string
+	it := f.RegexpIterator(regexp.MustCompile(`^a`))
+	for k, _, ok := it.Next(); ok; k, _, ok = it.Next() {
+		got = append(got, string(k))
+	}
+	if !reflect.DeepEqual(got, []string{"a", "ab"}) {
+		t.Fatalf("bad keys: %q", got)
+	}
+}
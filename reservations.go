@@ -0,0 +1,195 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// Lease is a claim on a range of keys held by a holder until it expires.
+type Lease struct {
+	// Start and End are the range of keys claimed, [Start, End). A nil End
+	// means the range has no upper bound.
+	Start, End []byte
+
+	// Holder identifies who claimed the range.
+	Holder string
+
+	// Expires is when the lease lapses, unless it's renewed.
+	Expires time.Time
+}
+
+// Reservations hands out leases on keys and ranges of keys, so that each part
+// of a keyspace is claimed by at most one holder at a time, like slots being
+// allocated to workers. Leases that aren't renewed lapse after their time to
+// live, and their ranges can then be claimed again. The leases are kept in
+// trees, so snapshots of them are cheap and can be read without locking.
+//
+// Reservations is safe for concurrent use.
+type Reservations struct {
+	l   sync.Mutex
+	now func() time.Time
+
+	// leases holds the leases by their start key, and expiry holds their
+	// start keys by the TimeKey of their expiry, followed by the start key.
+	leases *Tree[Lease]
+	expiry *Tree[[]byte]
+}
+
+// NewReservations returns an empty set of reservations, using now to tell the
+// time, or time.Now if it's nil.
+func NewReservations(now func() time.Time) *Reservations {
+	if now == nil {
+		now = time.Now
+	}
+	return &Reservations{
+		now:    now,
+		leases: New[Lease](),
+		expiry: New[[]byte](),
+	}
+}
+
+// Claim claims a single key for holder for the given time to live, returning
+// true if it was claimed, or false if it's covered by another live lease.
+func (r *Reservations) Claim(key []byte, holder string, ttl time.Duration) bool {
+	return r.ClaimRange(key, append(append([]byte{}, key...), 0), holder, ttl)
+}
+
+// ClaimRange claims the keys in [start, end) for holder for the given time to
+// live, returning true if they were claimed, or false if any of them is
+// covered by another live lease, including one held by the same holder. A nil
+// end claims every key from start onwards.
+func (r *Reservations) ClaimRange(start, end []byte, holder string, ttl time.Duration) bool {
+	if start == nil || (end != nil && bytes.Compare(start, end) >= 0) {
+		return false
+	}
+
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	now := r.now()
+	r.expire(now)
+	if _, ok := r.overlap(start, end); ok {
+		return false
+	}
+	lease := Lease{
+		Start:   append([]byte{}, start...),
+		Holder:  holder,
+		Expires: now.Add(ttl),
+	}
+	if end != nil {
+		lease.End = append([]byte{}, end...)
+	}
+	r.put(lease)
+	return true
+}
+
+// Renew extends the lease starting at start held by holder to expire after the
+// given time to live from now, returning false if there's no such live lease.
+func (r *Reservations) Renew(start []byte, holder string, ttl time.Duration) bool {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	now := r.now()
+	r.expire(now)
+	lease, ok := r.leases.Get(start)
+	if !ok || lease.Holder != holder {
+		return false
+	}
+	r.remove(lease)
+	lease.Expires = now.Add(ttl)
+	r.put(lease)
+	return true
+}
+
+// Release gives up the lease starting at start held by holder, returning false
+// if there's no such live lease.
+func (r *Reservations) Release(start []byte, holder string) bool {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	r.expire(r.now())
+	lease, ok := r.leases.Get(start)
+	if !ok || lease.Holder != holder {
+		return false
+	}
+	r.remove(lease)
+	return true
+}
+
+// Get returns the live lease covering the given key, if any.
+func (r *Reservations) Get(key []byte) (Lease, bool) {
+	r.l.Lock()
+	leases, now := r.leases, r.now()
+	r.l.Unlock()
+
+	lease, ok := leaseBefore(leases, key)
+	if !ok || !endAfter(lease.End, key) || !lease.Expires.After(now) {
+		return Lease{}, false
+	}
+	return lease, true
+}
+
+// Snapshot returns a tree of the leases by their start key, as of the last
+// change. It may include leases that have expired since.
+func (r *Reservations) Snapshot() *Tree[Lease] {
+	r.l.Lock()
+	defer r.l.Unlock()
+	return r.leases
+}
+
+// overlap returns a lease that overlaps [start, end), if any.
+func (r *Reservations) overlap(start, end []byte) (Lease, bool) {
+	if lease, ok := leaseBefore(r.leases, start); ok && endAfter(lease.End, start) {
+		return lease, true
+	}
+	if _, lease, ok := r.leases.Root().FirstInRange(start, end); ok {
+		return lease, true
+	}
+	return Lease{}, false
+}
+
+// expire removes the leases that have expired by now.
+func (r *Reservations) expire(now time.Time) {
+	limit := TimeKey(now)
+	var expired []Lease
+	it := r.expiry.Root().Iterator()
+	for k, start, ok := it.Next(); ok && bytes.Compare(k[:TimeKeyLen], limit) <= 0; k, start, ok = it.Next() {
+		lease, _ := r.leases.Get(start)
+		expired = append(expired, lease)
+	}
+	for _, lease := range expired {
+		r.remove(lease)
+	}
+}
+
+// put adds a lease.
+func (r *Reservations) put(lease Lease) {
+	r.leases, _, _ = r.leases.Insert(lease.Start, lease)
+	r.expiry, _, _ = r.expiry.Insert(expiryKey(lease), lease.Start)
+}
+
+// remove deletes a lease.
+func (r *Reservations) remove(lease Lease) {
+	r.leases, _, _ = r.leases.Delete(lease.Start)
+	r.expiry, _, _ = r.expiry.Delete(expiryKey(lease))
+}
+
+// expiryKey returns the key of a lease in the expiry index.
+func expiryKey(lease Lease) []byte {
+	return append(AppendTimeKey(nil, lease.Expires), lease.Start...)
+}
+
+// leaseBefore returns the lease with the largest start key at or before key.
+func leaseBefore(leases *Tree[Lease], key []byte) (Lease, bool) {
+	_, lease, ok := leases.Root().LastInRange([]byte{}, append(append([]byte{}, key...), 0))
+	return lease, ok
+}
+
+// endAfter returns true if the range end is after key.
+func endAfter(end, key []byte) bool {
+	return end == nil || bytes.Compare(end, key) > 0
+}
@@ -0,0 +1,88 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReservations(t *testing.T) {
+	now := time.Unix(1000, 0)
+	r := NewReservations(func() time.Time { return now })
+
+	if !r.Claim([]byte("slot/1"), "a", time.Minute) {
+		t.Fatalf("expected claim")
+	}
+	if r.Claim([]byte("slot/1"), "b", time.Minute) || r.Claim([]byte("slot/1"), "a", time.Minute) {
+		t.Fatalf("unexpected claim")
+	}
+	if !r.Claim([]byte("slot/10"), "b", time.Minute) || !r.Claim([]byte("slot/"), "b", time.Minute) {
+		t.Fatalf("expected claim")
+	}
+
+	// Ranges can't overlap any live lease.
+	cases := []struct {
+		start, end string
+		ok         bool
+	}{
+		{"slot/0", "slot/1", true},
+		{"slot/0", "slot/10", false},
+		{"slot/1\x00", "slot/10", true},
+		{"slot/2", "slot/4", true},
+		{"slot/3", "slot/5", false},
+		{"slot/4", "", true},
+		{"slot/9", "slot/99", false},
+		{"slot/5", "slot/5", false},
+	}
+	for _, c := range cases {
+		var end []byte
+		if c.end != "" {
+			end = []byte(c.end)
+		}
+		if ok := r.ClaimRange([]byte(c.start), end, "c", time.Hour); ok != c.ok {
+			t.Fatalf("%q-%q: got %v", c.start, c.end, ok)
+		}
+	}
+
+	lease, ok := r.Get([]byte("slot/33"))
+	if !ok || string(lease.Start) != "slot/2" || string(lease.End) != "slot/4" || lease.Holder != "c" {
+		t.Fatalf("bad: %v %v", lease, ok)
+	}
+	if _, ok := r.Get([]byte("slot/10\x01")); ok {
+		t.Fatalf("unexpected lease")
+	}
+
+	// Only the holder can renew or release.
+	if r.Renew([]byte("slot/1"), "b", time.Hour) || r.Release([]byte("slot/1"), "b") {
+		t.Fatalf("unexpected renew or release")
+	}
+	if !r.Renew([]byte("slot/1"), "a", time.Hour) {
+		t.Fatalf("expected renew")
+	}
+	if !r.Release([]byte("slot/10"), "b") || r.Release([]byte("slot/10"), "b") {
+		t.Fatalf("bad release")
+	}
+	if !r.Claim([]byte("slot/10"), "c", time.Minute) {
+		t.Fatalf("expected claim")
+	}
+
+	// Leases that aren't renewed lapse.
+	now = now.Add(time.Minute)
+	if _, ok := r.Get([]byte("slot/")); ok {
+		t.Fatalf("expected lease to lapse")
+	}
+	if _, ok := r.Get([]byte("slot/1")); !ok {
+		t.Fatalf("expected renewed lease")
+	}
+	if !r.Claim([]byte("slot/"), "d", time.Minute) {
+		t.Fatalf("expected claim")
+	}
+	if n := r.Snapshot().Len(); n != 6 {
+		t.Fatalf("bad len %d", n)
+	}
+	if n := r.expiry.Len(); n != 6 {
+		t.Fatalf("bad index len %d", n)
+	}
+}
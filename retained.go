@@ -0,0 +1,101 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"bytes"
+	"unsafe"
+)
+
+// chanSize is the approximate size of an unbuffered channel, which every node
+// and leaf holds for its watches.
+const chanSize = 96
+
+// Retained is the memory held only by one version of a tree, as estimated by
+// RetainedBy.
+type Retained struct {
+	// Nodes and Leaves are the numbers of nodes and leaves that aren't
+	// shared.
+	Nodes  int
+	Leaves int
+
+	// Bytes is the approximate size of the nodes and leaves, including their
+	// edges, prefixes, keys and watch channels, plus the sizes of the values
+	// if the tree has a memory budget.
+	Bytes int
+}
+
+// RetainedBy estimates the memory that would be freed by dropping old while new
+// is kept, which is the part of old that isn't shared with new. Trees derived
+// from one another through transactions share every node that wasn't written
+// in between, so this can drive a policy for how many versions of a tree to
+// keep by their actual cost. Both trees should be kept alive by the caller
+// for the duration of the call, and nothing else should retain old.
+//
+// Only the nodes of old that were copied or removed since new diverged from
+// it are visited, each with a lookup into new, so this is cheap for versions
+// that are close. Values are assumed to be owned by their leaves, and memory
+// held by other versions of the tree isn't considered.
+func RetainedBy[T any](old, new *Tree[T]) Retained {
+	e := retainEstimator[T]{
+		new:      new.root,
+		sizer:    old.conf.sizer,
+		aliasing: old.conf.collate == nil,
+	}
+	e.walk(old.root, nil)
+	return e.out
+}
+
+// retainEstimator walks the nodes of a tree that aren't shared with another.
+type retainEstimator[T any] struct {
+	new      *Node[T]
+	sizer    func(T) int
+	aliasing bool
+	out      Retained
+}
+
+// walk counts n, whose path is path, and everything under it that isn't
+// shared with the new tree. A shared node or leaf can only be found at the
+// same path in both trees.
+func (e *retainEstimator[T]) walk(n *Node[T], path []byte) {
+	m := e.lookup(path)
+	if m == n {
+		return
+	}
+	e.out.Nodes++
+	e.out.Bytes += int(unsafe.Sizeof(*n)) + chanSize
+	if n.inner != nil {
+		e.out.Bytes += int(unsafe.Sizeof(*n.inner)) + cap(n.inner.edges)*int(unsafe.Sizeof(edge[T]{}))
+	}
+	if !e.aliasing || !n.isLeafOnly() {
+		e.out.Bytes += cap(n.prefix)
+	}
+	if l := n.leaf; l != nil && (m == nil || m.leaf != l) {
+		e.out.Leaves++
+		e.out.Bytes += int(unsafe.Sizeof(*l)) + chanSize + len(l.key)
+		if l.meta != nil {
+			e.out.Bytes += int(unsafe.Sizeof(*l.meta))
+		}
+		if e.sizer != nil {
+			e.out.Bytes += e.sizer(l.val)
+		}
+	}
+	for _, ed := range n.edges() {
+		e.walk(ed.node, append(path, ed.node.prefix...))
+	}
+}
+
+// lookup returns the node of the new tree whose path is exactly path, if there
+// is one.
+func (e *retainEstimator[T]) lookup(path []byte) *Node[T] {
+	n := e.new
+	for len(path) != 0 {
+		_, n = n.getEdge(path[0])
+		if n == nil || !bytes.HasPrefix(path, n.prefix) {
+			return nil
+		}
+		path = path[len(n.prefix):]
+	}
+	return n
+}
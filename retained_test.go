@@ -0,0 +1,61 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRetainedBy(t *testing.T) {
+	r := New[int]()
+	for i := 0; i < 1000; i++ {
+		r, _, _ = r.Insert([]byte(fmt.Sprintf("key/%03d", i)), i)
+	}
+	nodes := 0
+	it := r.Root().rawIterator()
+	for it.Front() != nil {
+		nodes++
+		it.Next()
+	}
+
+	// A tree shares everything with itself.
+	if got := RetainedBy(r, r); got != (Retained{}) {
+		t.Fatalf("bad retention: %+v", got)
+	}
+
+	// Dropping a tree in favour of an empty one frees all of it.
+	all := RetainedBy(r, New[int]())
+	if all.Nodes != nodes || all.Leaves != r.Len() || all.Bytes <= 0 {
+		t.Fatalf("bad retention: %+v, expected %d nodes", all, nodes)
+	}
+
+	// Updating a key only frees the old path to it and its leaf. There are
+	// five nodes on it: the root, "key/", and one for each digit.
+	up, _, _ := r.Insert([]byte("key/055"), -1)
+	got := RetainedBy(r, up)
+	if got.Nodes != 5 || got.Leaves != 1 {
+		t.Fatalf("bad retention: %+v", got)
+	}
+
+	// Adding a key frees no leaves, and the new tree holds all of the old
+	// one.
+	add, _, _ := r.Insert([]byte("key/1000"), 1000)
+	if got := RetainedBy(r, add); got.Leaves != 0 || got.Nodes == 0 || got.Bytes >= all.Bytes {
+		t.Fatalf("bad retention: %+v", got)
+	}
+	if got := RetainedBy(up, r); got.Leaves != 1 {
+		t.Fatalf("bad retention: %+v", got)
+	}
+
+	// Values are counted with a memory budget.
+	sized := New[int](WithMemoryBudget(1<<20, func(int) int { return 100 }))
+	sized, _, _ = sized.Insert([]byte("a"), 1)
+	withValues := RetainedBy(sized, New[int]())
+	sized2 := New[int]()
+	sized2, _, _ = sized2.Insert([]byte("a"), 1)
+	if withoutValues := RetainedBy(sized2, New[int]()); withValues.Bytes != withoutValues.Bytes+100 {
+		t.Fatalf("bad sizes: %+v %+v", withValues, withoutValues)
+	}
+}
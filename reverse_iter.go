@@ -30,6 +30,16 @@ func NewReverseIterator[T any](n *Node[T]) *ReverseIterator[T] {
 	}
 }
 
+// Reset re-targets the iterator at the given node, as if it had been newly
+// created by calling ReverseIterator on it. The iterator's internal state is
+// kept so that pooled iterators can be reused without allocating.
+func (ri *ReverseIterator[T]) Reset(n *Node[T]) {
+	ri.i.Reset(n)
+	for p := range ri.expandedParents {
+		delete(ri.expandedParents, p)
+	}
+}
+
 // SeekPrefixWatch is used to seek the iterator to a given prefix
 // and returns the watch channel of the finest granularity
 func (ri *ReverseIterator[T]) SeekPrefixWatch(prefix []byte) (watch <-chan struct{}) {
@@ -60,8 +70,61 @@ func (ri *ReverseIterator[T]) SeekReverseLowerBound(key []byte) {
 	// up as nil so just set it here.
 	n := ri.i.node
 	ri.i.node = nil
-	search := key
+	if n == nil {
+		return
+	}
+	ri.seekReverseLowerBound(n, ri.i.path(key))
+}
+
+// SeekReversePrefixLowerBound is used to seek the iterator to the largest key
+// under the given prefix that is lower or equal to the given key, so that the
+// iterator only visits keys under the prefix in descending order. This is the
+// query needed to find the latest entry at or before some point in a
+// namespace. There is no watch variant for the same reasons as
+// SeekReverseLowerBound.
+func (ri *ReverseIterator[T]) SeekReversePrefixLowerBound(prefix, key []byte) {
+	prefix = ri.i.path(prefix)
+	key = ri.i.path(key)
+
+	// If the key isn't under the prefix then either every key under the prefix
+	// is lower than it, or none of them are.
+	if !bytes.HasPrefix(key, prefix) {
+		if bytes.Compare(key, prefix) > 0 {
+			ri.i.seekPrefixWatch(prefix)
+			return
+		}
+		ri.i.stack = []edges[T]{}
+		ri.i.node = nil
+		return
+	}
+
+	// Wipe the stack, as in SeekReverseLowerBound.
+	ri.i.stack = []edges[T]{}
+	n := ri.i.node
+	ri.i.node = nil
+
+	// Walk down to the node that holds the prefix. Since the key starts with
+	// the prefix we consume the same amount of both as we go, which leaves us
+	// with the remainder of the key to search for under that node.
+	search, rest := prefix, key
+	for n != nil {
+		if bytes.HasPrefix(n.prefix, search) {
+			ri.seekReverseLowerBound(n, rest)
+			return
+		}
+		if !bytes.HasPrefix(search, n.prefix) {
+			return
+		}
+		search = search[len(n.prefix):]
+		rest = rest[len(n.prefix):]
+		_, n = n.getEdge(search[0])
+	}
+}
 
+// seekReverseLowerBound finds the reverse lower bound of a key in the subtree
+// under n, setting up the stack as it goes. The search is the remainder of the
+// key that still needs to be matched, starting at n's prefix.
+func (ri *ReverseIterator[T]) seekReverseLowerBound(n *Node[T], search []byte) {
 	if ri.expandedParents == nil {
 		ri.expandedParents = make(map[*Node[T]]struct{})
 	}
@@ -113,8 +176,9 @@ func (ri *ReverseIterator[T]) SeekReverseLowerBound(key []byte) {
 		// greater.
 		if n.isLeaf() {
 
-			// Firstly, if it's an exact match, we're done!
-			if bytes.Equal(n.leaf.key, key) {
+			// Firstly, if it's an exact match, we're done! The prefix can only
+			// be as long as the remaining search if the two are equal.
+			if len(n.prefix) == len(search) {
 				found(n)
 				return
 			}
@@ -123,7 +187,7 @@ func (ri *ReverseIterator[T]) SeekReverseLowerBound(key []byte) {
 			// valid contender for reverse lower bound.
 
 			// If it has no children then we are also done.
-			if len(n.edges) == 0 {
+			if len(n.edges()) == 0 {
 				// This leaf is the lower bound.
 				found(n)
 				return
@@ -164,12 +228,12 @@ func (ri *ReverseIterator[T]) SeekReverseLowerBound(key []byte) {
 		// last edge index so they can all be place in the stack, since they
 		// come before our search prefix.
 		if idx == -1 {
-			idx = len(n.edges)
+			idx = len(n.edges())
 		}
 
 		// Create stack edges for the all strictly lower edges in this node.
-		if len(n.edges[:idx]) > 0 {
-			ri.i.stack = append(ri.i.stack, n.edges[:idx])
+		if len(n.edges()[:idx]) > 0 {
+			ri.i.stack = append(ri.i.stack, n.edges()[:idx])
 		}
 
 		// Exit if there's no lower bound edge. The stack will have the previous
@@ -210,12 +274,12 @@ func (ri *ReverseIterator[T]) Previous() ([]byte, T, bool) {
 		// If this is an internal node and we've not seen it already, we need to
 		// leave it in the stack so we can return its possible leaf value _after_
 		// we've recursed through all its children.
-		if len(elem.edges) > 0 && !alreadyExpanded {
+		if len(elem.edges()) > 0 && !alreadyExpanded {
 			// record that we've seen this node!
 			ri.expandedParents[elem] = struct{}{}
 			// push child edges onto stack and skip the rest of the loop to recurse
 			// into the largest one.
-			ri.i.stack = append(ri.i.stack, elem.edges)
+			ri.i.stack = append(ri.i.stack, elem.edges())
 			continue
 		}
 
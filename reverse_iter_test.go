@@ -6,6 +6,7 @@ package iradix
 import (
 	"fmt"
 	"sort"
+	"strings"
 	"testing"
 	"testing/quick"
 
@@ -70,6 +71,89 @@ func TestReverseIterator_SeekReverseLowerBoundFuzz(t *testing.T) {
 	}
 }
 
+func TestReverseIterator_SeekReversePrefixLowerBoundFuzz(t *testing.T) {
+	r := New[any]()
+	set := make(map[string]struct{})
+
+	// Like TestReverseIterator_SeekReverseLowerBoundFuzz, but the result is also
+	// restricted to keys under a random prefix.
+	radixAddAndScan := func(newKey, prefix, searchKey readableString) []string {
+		r, _, _ = r.Insert([]byte(newKey), nil)
+
+		it := r.Root().ReverseIterator()
+		var result []string
+		it.SeekReversePrefixLowerBound([]byte(prefix), []byte(searchKey))
+		for {
+			key, _, ok := it.Previous()
+			if !ok {
+				break
+			}
+			result = append(result, string(key))
+		}
+		return result
+	}
+
+	sliceAddSortAndFilter := func(newKey, prefix, searchKey readableString) []string {
+		set[string(newKey)] = struct{}{}
+
+		var result []string
+		for k := range set {
+			if strings.HasPrefix(k, string(prefix)) && k <= string(searchKey) {
+				result = append(result, k)
+			}
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(result)))
+		return result
+	}
+
+	if err := quick.CheckEqual(radixAddAndScan, sliceAddSortAndFilter, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReverseIterator_SeekReversePrefixLowerBound(t *testing.T) {
+	r := New[any]()
+	keys := []string{
+		"metrics/cpu/0001",
+		"metrics/cpu/0005",
+		"metrics/cpu/0010",
+		"metrics/mem/0002",
+		"metrics/mem/0007",
+		"other",
+	}
+	for _, k := range keys {
+		r, _, _ = r.Insert([]byte(k), nil)
+	}
+
+	cases := []struct {
+		prefix string
+		key    string
+		want   []string
+	}{
+		{"metrics/cpu/", "metrics/cpu/0006", []string{"metrics/cpu/0005", "metrics/cpu/0001"}},
+		{"metrics/cpu/", "metrics/cpu/0005", []string{"metrics/cpu/0005", "metrics/cpu/0001"}},
+		{"metrics/cpu/", "metrics/cpu/0000", nil},
+		{"metrics/cpu/", "metrics/mem/0003", []string{"metrics/cpu/0010", "metrics/cpu/0005", "metrics/cpu/0001"}},
+		{"metrics/mem/", "metrics/cpu/9999", nil},
+		{"metrics/m", "metrics/mem/0008", []string{"metrics/mem/0007", "metrics/mem/0002"}},
+		{"nope/", "zzz", nil},
+		{"", "metrics/mem/0002", []string{"metrics/mem/0002", "metrics/cpu/0010", "metrics/cpu/0005", "metrics/cpu/0001"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.prefix+"|"+tc.key, func(t *testing.T) {
+			it := r.Root().ReverseIterator()
+			it.SeekReversePrefixLowerBound([]byte(tc.prefix), []byte(tc.key))
+			var got []string
+			for k, _, ok := it.Previous(); ok; k, _, ok = it.Previous() {
+				got = append(got, string(k))
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestReverseIterator_SeekLowerBound(t *testing.T) {
 
 	// these should be defined in order
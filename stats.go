@@ -0,0 +1,142 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// maxStatsPrefixes bounds the number of distinct prefixes an access stats
+// collector keeps counts for.
+const maxStatsPrefixes = 1024
+
+// PrefixStats holds the access counts of the keys sharing a prefix.
+type PrefixStats struct {
+	Prefix  []byte
+	Gets    uint64
+	Inserts uint64
+	Deletes uint64
+}
+
+// Total returns the total number of accesses.
+func (s PrefixStats) Total() uint64 {
+	return s.Gets + s.Inserts + s.Deletes
+}
+
+// accessOp is a kind of access counted by the stats collector.
+type accessOp int
+
+const (
+	accessGet accessOp = iota
+	accessInsert
+	accessDelete
+)
+
+// accessStats collects the access counts of a tree and all the trees derived
+// from it.
+type accessStats struct {
+	// tick counts the accesses to pick the ones that are sampled. It's
+	// first so that it's aligned for atomic access on 32-bit platforms.
+	tick uint64
+
+	prefixLen int
+	rate      uint64
+
+	l       sync.Mutex
+	counts  map[string]*PrefixStats
+	dropped uint64
+}
+
+// WithAccessStats enables collection of statistics of which key prefixes are
+// accessed the most by Get, Insert and Delete, across the tree and all the
+// trees and transactions derived from it. Keys are grouped by their first
+// prefixLen bytes. Only one in every rate accesses is recorded, to keep the
+// overhead low on hot paths, and the counts are scaled up accordingly, so
+// they're estimates unless rate is 1. At most 1024 distinct prefixes are
+// tracked; accesses to further prefixes are only counted by StatsDropped.
+//
+// The statistics are returned by Tree.Stats.
+func WithAccessStats(prefixLen, rate int) Option {
+	if rate < 1 {
+		rate = 1
+	}
+	return func(o *options) {
+		o.stats = &accessStats{
+			prefixLen: prefixLen,
+			rate:      uint64(rate),
+			counts:    make(map[string]*PrefixStats),
+		}
+	}
+}
+
+// record records an access to k if it's sampled.
+func (s *accessStats) record(op accessOp, k []byte) {
+	if s.rate > 1 && atomic.AddUint64(&s.tick, 1)%s.rate != 0 {
+		return
+	}
+	if len(k) > s.prefixLen {
+		k = k[:s.prefixLen]
+	}
+
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	ps, ok := s.counts[string(k)]
+	if !ok {
+		if len(s.counts) >= maxStatsPrefixes {
+			s.dropped += s.rate
+			return
+		}
+		ps = &PrefixStats{Prefix: append([]byte{}, k...)}
+		s.counts[string(ps.Prefix)] = ps
+	}
+	switch op {
+	case accessGet:
+		ps.Gets += s.rate
+	case accessInsert:
+		ps.Inserts += s.rate
+	case accessDelete:
+		ps.Deletes += s.rate
+	}
+}
+
+// Stats returns the access statistics collected for the tree, with the most
+// accessed prefixes first, or nil if the tree wasn't created with
+// WithAccessStats. The statistics are shared by every tree derived from the one
+// created with New.
+func (t *Tree[T]) Stats() []PrefixStats {
+	s := t.conf.stats
+	if s == nil {
+		return nil
+	}
+
+	s.l.Lock()
+	out := make([]PrefixStats, 0, len(s.counts))
+	for _, ps := range s.counts {
+		out = append(out, *ps)
+	}
+	s.l.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if ti, tj := out[i].Total(), out[j].Total(); ti != tj {
+			return ti > tj
+		}
+		return string(out[i].Prefix) < string(out[j].Prefix)
+	})
+	return out
+}
+
+// StatsDropped returns the estimated number of accesses that weren't counted by
+// Stats because too many distinct prefixes were already tracked.
+func (t *Tree[T]) StatsDropped() uint64 {
+	s := t.conf.stats
+	if s == nil {
+		return 0
+	}
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.dropped
+}
@@ -0,0 +1,77 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestAccessStats(t *testing.T) {
+	if s := New[int]().Stats(); s != nil {
+		t.Fatalf("bad: %v", s)
+	}
+
+	r := New[int](WithAccessStats(2, 1))
+	txn := r.Txn()
+	for i := 0; i < 3; i++ {
+		txn.Insert([]byte(fmt.Sprintf("aa/%d", i)), i)
+	}
+	txn.Insert([]byte("b"), 0)
+	txn.Delete([]byte("aa/0"))
+	txn.Get([]byte("bb"))
+	r = txn.Commit()
+	for i := 0; i < 5; i++ {
+		r.Get([]byte("bb/x"))
+	}
+	r.GetString("b")
+
+	type stat struct {
+		Prefix                 string
+		Gets, Inserts, Deletes uint64
+	}
+	var got []stat
+	for _, s := range r.Stats() {
+		got = append(got, stat{string(s.Prefix), s.Gets, s.Inserts, s.Deletes})
+	}
+	expect := []stat{{"bb", 6, 0, 0}, {"aa", 0, 3, 1}, {"b", 1, 1, 0}}
+	if !reflect.DeepEqual(got, expect) {
+		t.Fatalf("got %v, want %v", got, expect)
+	}
+
+	// Accesses are counted under the stored form of their keys.
+	r = New[int](WithAccessStats(2, 1), WithKeyFold()).BulkLoad([]KV[int]{{Key: []byte("AA/0"), Val: 0}})
+	txn = r.Txn()
+	txn.Insert([]byte("Aa/1"), 1)
+	txn.Delete([]byte("aA/0"))
+	txn.Get([]byte("AA/1"))
+	r = txn.Commit()
+	r.Get([]byte("aA/1"))
+	got = nil
+	for _, s := range r.Stats() {
+		got = append(got, stat{string(s.Prefix), s.Gets, s.Inserts, s.Deletes})
+	}
+	if expect := []stat{{"aa", 2, 2, 1}}; !reflect.DeepEqual(got, expect) {
+		t.Fatalf("got %v, want %v", got, expect)
+	}
+
+	// Sampled counts are scaled, and the number of prefixes is bounded.
+	r = New[int](WithAccessStats(8, 4))
+	for i := 0; i < 4*(maxStatsPrefixes+10); i++ {
+		r.Get([]byte(fmt.Sprintf("%08d", i/4)))
+	}
+	stats := r.Stats()
+	if len(stats) != maxStatsPrefixes {
+		t.Fatalf("bad len %d", len(stats))
+	}
+	for _, s := range stats {
+		if s.Gets != 4 {
+			t.Fatalf("%s: bad gets %d", s.Prefix, s.Gets)
+		}
+	}
+	if d := r.StatsDropped(); d != 40 {
+		t.Fatalf("bad dropped %d", d)
+	}
+}
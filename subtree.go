@@ -0,0 +1,104 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+// SubtreeAt returns a tree holding only the keys of this tree with the given
+// prefix, and whether there are any. The keys keep their prefix. The nodes
+// under the prefix are shared with this tree, so this only allocates the two
+// nodes above them, plus a walk over the keys if the tree tracks their size or
+// content hash. The returned tree has the same options as this one, and can be
+// handed off and written to independently.
+func (t *Tree[T]) SubtreeAt(prefix []byte) (*Tree[T], bool) {
+	return t.subtreeAt(prefix, false)
+}
+
+// StrippedSubtreeAt is like SubtreeAt, but removes the prefix from the keys of
+// the returned tree. The leaves have to be replaced to change their keys, so
+// this copies every node under the prefix, but the memory of the keys and the
+// values is still shared with this tree.
+func (t *Tree[T]) StrippedSubtreeAt(prefix []byte) (*Tree[T], bool) {
+	return t.subtreeAt(prefix, true)
+}
+
+// subtreeAt implements SubtreeAt and StrippedSubtreeAt.
+func (t *Tree[T]) subtreeAt(prefix []byte, strip bool) (*Tree[T], bool) {
+	search := t.conf.path(prefix)
+	if len(search) == 0 {
+		return t, t.size != 0
+	}
+
+	// Find the node holding the keys under the prefix, and where its prefix
+	// starts in the path.
+	n, start := t.root.findPrefix(search)
+
+	txn := t.Txn()
+	if n == nil || n.count() == 0 {
+		txn.root = txn.allocNode(Node[T]{})
+		txn.size = 0
+		txn.recount()
+		return txn.derive(), false
+	}
+
+	// Build the path to the node, less the prefix if it's stripped, and hang
+	// the node from a new root under it.
+	cut := 0
+	if strip {
+		cut = len(search)
+	}
+	path := make([]byte, 0, start+len(n.prefix))
+	path = append(append(path, search[:start]...), n.prefix...)
+	path = path[cut:]
+	var sub *Node[T]
+	if strip {
+		sub = txn.stripNode(n, path, cut)
+	} else {
+		sub = txn.allocNode(Node[T]{
+			leaf:   n.leaf,
+			prefix: path,
+			inner:  n.sharedInner(),
+		})
+	}
+	if txn.aliasLeafPrefix(sub) {
+		sub.prefix = leafPrefix(sub.leaf, len(sub.prefix))
+	}
+	if len(path) == 0 {
+		txn.root = sub
+	} else {
+		txn.root = txn.allocNode(Node[T]{
+			inner: &innerNode[T]{
+				edges: edges[T]{{label: path[0], node: sub}},
+				count: sub.count(),
+			},
+		})
+	}
+	txn.size = sub.count()
+	txn.recount()
+	return txn.derive(), true
+}
+
+// stripNode returns a copy of the subtree under n with the given prefix, where
+// the first cut bytes of every key are removed.
+func (t *Txn[T]) stripNode(n *Node[T], prefix []byte, cut int) *Node[T] {
+	nn := t.allocNode(Node[T]{prefix: prefix})
+	if n.leaf != nil {
+		nn.leaf = t.allocLeaf(leafNode[T]{
+			key:  n.leaf.key[cut:],
+			val:  n.leaf.val,
+			meta: n.leaf.meta,
+		})
+	}
+	if len(n.edges()) != 0 {
+		nn.inner = &innerNode[T]{
+			edges: make(edges[T], len(n.edges())),
+			count: n.count(),
+		}
+		for i, e := range n.edges() {
+			nn.inner.edges[i] = edge[T]{
+				label: e.label,
+				node:  t.stripNode(e.node, e.node.prefix, cut),
+			}
+		}
+	}
+	return nn
+}
@@ -0,0 +1,91 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSubtreeAt(t *testing.T) {
+	r := New[int](WithContentHash(func(v int) uint64 { return uint64(v) }))
+	keys := []string{"app/a", "app/b/1", "app/b/2", "apple", "b", "svc"}
+	for i, k := range keys {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+
+	treeKeys := func(r *Tree[int]) string {
+		var out []string
+		r.Root().Walk(func(k []byte, _ int) bool {
+			out = append(out, string(k))
+			return false
+		})
+		return strings.Join(out, ",")
+	}
+	cases := []struct {
+		prefix   string
+		keys     string
+		stripped string
+	}{
+		{"", "app/a,app/b/1,app/b/2,apple,b,svc", "app/a,app/b/1,app/b/2,apple,b,svc"},
+		{"app", "app/a,app/b/1,app/b/2,apple", "/a,/b/1,/b/2,le"},
+		{"app/", "app/a,app/b/1,app/b/2", "a,b/1,b/2"},
+		{"app/b", "app/b/1,app/b/2", "/1,/2"},
+		{"app/b/", "app/b/1,app/b/2", "1,2"},
+		{"ap", "app/a,app/b/1,app/b/2,apple", "p/a,p/b/1,p/b/2,ple"},
+		{"sv", "svc", "c"},
+		{"svc", "svc", ""},
+		{"svcs", "", ""},
+		{"x", "", ""},
+	}
+	for _, c := range cases {
+		sub, ok := r.SubtreeAt([]byte(c.prefix))
+		if ok != (c.keys != "") || treeKeys(sub) != c.keys || sub.Len() != sub.Root().count() {
+			t.Fatalf("bad subtree at %q: %v %q", c.prefix, ok, treeKeys(sub))
+		}
+		if err := CheckOrdered(sub); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if sub.Hash() != sub.Root().Hash(func(v int) uint64 { return uint64(v) }) {
+			t.Fatalf("bad hash at %q", c.prefix)
+		}
+
+		stripped, ok := r.StrippedSubtreeAt([]byte(c.prefix))
+		if ok != (c.keys != "") || treeKeys(stripped) != c.stripped || stripped.Len() != sub.Len() {
+			t.Fatalf("bad stripped subtree at %q: %v %q", c.prefix, ok, treeKeys(stripped))
+		}
+		if err := CheckOrdered(stripped); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	// The subtree can be written to without affecting the original.
+	sub, _ := r.SubtreeAt([]byte("app/b"))
+	sub, _, _ = sub.Insert([]byte("app/b/3"), 10)
+	sub, _, _ = sub.Delete([]byte("app/b/1"))
+	if treeKeys(sub) != "app/b/2,app/b/3" || treeKeys(r) != "app/a,app/b/1,app/b/2,apple,b,svc" {
+		t.Fatalf("bad trees: %q %q", treeKeys(sub), treeKeys(r))
+	}
+	stripped, _ := r.StrippedSubtreeAt([]byte("app/"))
+	if v, ok := stripped.Get([]byte("b/2")); !ok || v != 2 {
+		t.Fatalf("bad get: %v %v", v, ok)
+	}
+}
+
+func TestSubtreeAt_Collation(t *testing.T) {
+	r := New[int](WithCollation(FoldCaseCollation()))
+	for i, k := range []string{"Ab", "aB", "ab", "b"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	sub, ok := r.StrippedSubtreeAt([]byte("a"))
+	if !ok || sub.Len() != 2 {
+		t.Fatalf("bad subtree: %v %d", ok, sub.Len())
+	}
+	if v, ok := sub.Get([]byte("B")); !ok || v != 1 {
+		t.Fatalf("bad get: %v %v", v, ok)
+	}
+	if err := CheckOrdered(sub); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+// SuffixTree is a tree along with a companion index of its keys reversed, so
+// that keys can be looked up by their suffixes as well as their prefixes, as
+// for domain names. Both are updated together by a SuffixTxn, so the index is
+// always in step with the tree.
+type SuffixTree[T any] struct {
+	companion[T]
+}
+
+// NewSuffixTree returns an empty suffix tree. The options apply to the tree,
+// and the index holds the keys in the form they're stored in the tree.
+func NewSuffixTree[T any](opts ...Option) *SuffixTree[T] {
+	return &SuffixTree[T]{companion: newCompanion[T](opts)}
+}
+
+// LongestSuffix is like Get, but instead of an exact match, it returns the
+// longest key in the tree that's a suffix of k, so looking up
+// "www.example.com" finds "example.com" if that's the closest key.
+func (t *SuffixTree[T]) LongestSuffix(k []byte) ([]byte, T, bool) {
+	_, e, ok := t.index.Root().LongestPrefix(reverseKey(t.tree.conf.key(k)))
+	return e.key, e.val, ok
+}
+
+// WalkSuffix walks the keys in the tree that end with the given suffix. They're
+// walked in the order of the reversed keys, so keys sharing a longer suffix
+// are walked together.
+func (t *SuffixTree[T]) WalkSuffix(suffix []byte, fn WalkFn[T]) {
+	t.index.Root().WalkPrefix(reverseKey(t.tree.conf.key(suffix)), func(_ []byte, e indexEntry[T]) bool {
+		return fn(e.key, e.val)
+	})
+}
+
+// Txn starts a new transaction that updates the tree and its index.
+func (t *SuffixTree[T]) Txn() *SuffixTxn[T] {
+	return &SuffixTxn[T]{companionTxn: t.txn()}
+}
+
+// Insert is used to add or update a given key in a copy of the tree, as with
+// SuffixTxn.Insert.
+func (t *SuffixTree[T]) Insert(k []byte, v T) (*SuffixTree[T], T, bool) {
+	txn := t.Txn()
+	old, ok := txn.Insert(k, v)
+	return txn.Commit(), old, ok
+}
+
+// Delete is used to delete a given key from a copy of the tree, as with
+// SuffixTxn.Delete.
+func (t *SuffixTree[T]) Delete(k []byte) (*SuffixTree[T], T, bool) {
+	txn := t.Txn()
+	old, ok := txn.Delete(k)
+	return txn.Commit(), old, ok
+}
+
+// SuffixTxn is a transaction on a SuffixTree.
+type SuffixTxn[T any] struct {
+	companionTxn[T]
+}
+
+// Insert is used to add or update a given key, along with its entry in the
+// index. The return provides the previous value and a bool indicating if any
+// was set.
+func (t *SuffixTxn[T]) Insert(k []byte, v T) (T, bool) {
+	k, old, ok, applied := t.insert(k, v)
+	if applied {
+		t.index.Insert(reverseKey(k), indexEntry[T]{key: k, val: v})
+	}
+	return old, ok
+}
+
+// Delete is used to delete a given key, along with its entry in the index.
+// Returns the old value if any, and a bool indicating if the key was set.
+func (t *SuffixTxn[T]) Delete(k []byte) (T, bool) {
+	k, old, ok := t.delete(k)
+	if ok {
+		t.index.Delete(reverseKey(k))
+	}
+	return old, ok
+}
+
+// Commit is used to finalize the transaction and return the new tree along
+// with its index.
+func (t *SuffixTxn[T]) Commit() *SuffixTree[T] {
+	return &SuffixTree[T]{companion: t.commit()}
+}
+
+// reverseKey returns a copy of k with its bytes in reverse order.
+func reverseKey(k []byte) []byte {
+	out := make([]byte, len(k))
+	for i, b := range k {
+		out[len(k)-1-i] = b
+	}
+	return out
+}
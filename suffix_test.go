@@ -0,0 +1,79 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSuffixTree(t *testing.T) {
+	r := NewSuffixTree[int](WithKeyFold())
+	txn := r.Txn()
+	txn.Insert([]byte("example.com"), 1)
+	txn.Insert([]byte(".Example.com"), 2)
+	txn.Insert([]byte("api.example.com"), 3)
+	txn.Insert([]byte("example.org"), 4)
+	txn.Insert([]byte("com"), 5)
+	txn.Delete([]byte("missing"))
+	r = txn.Commit()
+
+	// Updates replace the index entry, and deletes remove it.
+	r2, old, ok := r.Insert([]byte("EXAMPLE.com"), 10)
+	if !ok || old != 1 {
+		t.Fatalf("bad: %v %v", old, ok)
+	}
+	r2, _, _ = r2.Delete([]byte("com"))
+
+	longest := func(r *SuffixTree[int], k string) string {
+		lk, v, ok := r.LongestSuffix([]byte(k))
+		if !ok {
+			return ""
+		}
+		if want, _ := r.Get(lk); want != v {
+			t.Fatalf("bad value for %q: %d", lk, v)
+		}
+		return string(lk)
+	}
+	walk := func(r *SuffixTree[int], suffix string) []string {
+		var out []string
+		r.WalkSuffix([]byte(suffix), func(k []byte, _ int) bool {
+			out = append(out, string(k))
+			return false
+		})
+		return out
+	}
+
+	cases := []struct {
+		got, want interface{}
+	}{
+		{longest(r, "www.example.com"), ".example.com"},
+		{longest(r, "API.example.com"), "api.example.com"},
+		{longest(r, "other.com"), "com"},
+		{longest(r, "example.net"), ""},
+		{longest(r2, "other.com"), ""},
+		{walk(r, ".example.com"), []string{".example.com", "api.example.com"}},
+		{walk(r, "EXAMPLE.com"), []string{"example.com", ".example.com", "api.example.com"}},
+		{walk(r, ""), []string{"example.org", "com", "example.com", ".example.com", "api.example.com"}},
+		{walk(r2, "com"), []string{"example.com", ".example.com", "api.example.com"}},
+	}
+	for i, c := range cases {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Fatalf("%d: got %q, want %q", i, c.got, c.want)
+		}
+	}
+	if v, _ := r2.Get([]byte("example.com")); v != 10 || r2.Len() != 4 || r2.Tree().Len() != 4 {
+		t.Fatalf("bad tree: %d %d", v, r2.Len())
+	}
+	if _, v, _ := r2.LongestSuffix([]byte("example.com")); v != 10 {
+		t.Fatalf("bad index value: %d", v)
+	}
+
+	// Inserts rejected by the tree don't reach the index.
+	b := NewSuffixTree[int](WithMemoryBudget(4, func(int) int { return 0 }))
+	b, _, _ = b.Insert([]byte("toolong"), 1)
+	if _, _, ok := b.LongestSuffix([]byte("toolong")); ok || b.Len() != 0 {
+		t.Fatalf("expected the insert to be rejected")
+	}
+}
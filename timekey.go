@@ -0,0 +1,76 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// TimeKeyLen is the length of the keys produced by TimeKey and ReverseTimeKey.
+const TimeKeyLen = 8
+
+// timeKeyBias flips the sign bit of the nanosecond timestamp so that times
+// before the Unix epoch sort before times after it when compared as unsigned
+// big-endian bytes.
+const timeKeyBias = 1 << 63
+
+// TimeKey encodes t as a fixed length key whose byte order is chronological, so
+// that iterating forwards visits the oldest times first. For example, seeking an
+// iterator to the lower bound of TimeKey(t) will visit every time at or after t
+// in ascending order. The encoding is based on t.UnixNano, so it only supports
+// the same range of times (roughly the years 1678 to 2262), and doesn't retain
+// the location or monotonic clock reading.
+func TimeKey(t time.Time) []byte {
+	return AppendTimeKey(make([]byte, 0, TimeKeyLen), t)
+}
+
+// AppendTimeKey appends the TimeKey encoding of t to dst and returns the
+// extended slice. This is useful for building keys made up of a prefix
+// followed by a time.
+func AppendTimeKey(dst []byte, t time.Time) []byte {
+	return appendUint64(dst, uint64(t.UnixNano())^timeKeyBias)
+}
+
+// ReverseTimeKey encodes t as a fixed length key whose byte order is reverse
+// chronological, so that iterating forwards visits the newest times first. For
+// example, seeking an iterator to the lower bound of ReverseTimeKey(t) will
+// visit every time at or before t in descending order. The same restrictions
+// as TimeKey apply.
+func ReverseTimeKey(t time.Time) []byte {
+	return AppendReverseTimeKey(make([]byte, 0, TimeKeyLen), t)
+}
+
+// AppendReverseTimeKey appends the ReverseTimeKey encoding of t to dst and
+// returns the extended slice.
+func AppendReverseTimeKey(dst []byte, t time.Time) []byte {
+	return appendUint64(dst, ^(uint64(t.UnixNano()) ^ timeKeyBias))
+}
+
+// ParseTimeKey decodes a key produced by TimeKey. The time is returned in UTC.
+func ParseTimeKey(k []byte) (time.Time, error) {
+	if len(k) != TimeKeyLen {
+		return time.Time{}, fmt.Errorf("invalid time key length %d", len(k))
+	}
+	nanos := int64(binary.BigEndian.Uint64(k) ^ timeKeyBias)
+	return time.Unix(0, nanos).UTC(), nil
+}
+
+// ParseReverseTimeKey decodes a key produced by ReverseTimeKey. The time is
+// returned in UTC.
+func ParseReverseTimeKey(k []byte) (time.Time, error) {
+	if len(k) != TimeKeyLen {
+		return time.Time{}, fmt.Errorf("invalid time key length %d", len(k))
+	}
+	nanos := int64(^binary.BigEndian.Uint64(k) ^ timeKeyBias)
+	return time.Unix(0, nanos).UTC(), nil
+}
+
+// appendUint64 appends the big-endian encoding of v to dst.
+func appendUint64(dst []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(dst, buf[:]...)
+}
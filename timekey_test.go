@@ -0,0 +1,103 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeKey_RoundTrip(t *testing.T) {
+	times := []time.Time{
+		time.Unix(0, 0),
+		time.Unix(-1, 0),
+		time.Unix(0, -1),
+		time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 6, 1, 12, 30, 0, 123456789, time.UTC),
+		time.Date(2200, 12, 31, 23, 59, 59, 999999999, time.UTC),
+	}
+	for _, tm := range times {
+		got, err := ParseTimeKey(TimeKey(tm))
+		if err != nil || !got.Equal(tm) {
+			t.Fatalf("bad: %v %v %v", tm, got, err)
+		}
+		got, err = ParseReverseTimeKey(ReverseTimeKey(tm))
+		if err != nil || !got.Equal(tm) {
+			t.Fatalf("bad: %v %v %v", tm, got, err)
+		}
+	}
+
+	if _, err := ParseTimeKey([]byte("short")); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if _, err := ParseReverseTimeKey([]byte("short")); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestTimeKey_SeekLowerBound(t *testing.T) {
+	base := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	var times []time.Time
+	for i := -5; i <= 5; i++ {
+		// Include times before the epoch to check the sign handling.
+		times = append(times, base.Add(time.Duration(i)*time.Hour))
+		times = append(times, time.Unix(0, 0).Add(time.Duration(i)*time.Second))
+	}
+
+	prefix := []byte("events/")
+	fwd, rev := New[time.Time](), New[time.Time]()
+	for _, tm := range times {
+		fwd, _, _ = fwd.Insert(AppendTimeKey(append([]byte{}, prefix...), tm), tm)
+		rev, _, _ = rev.Insert(AppendReverseTimeKey(append([]byte{}, prefix...), tm), tm)
+	}
+
+	for _, seek := range append(times, base.Add(90*time.Minute), time.Unix(-3600, 0)) {
+		// Forward encoding: every time at or after seek, oldest first.
+		it := fwd.Root().Iterator()
+		it.SeekLowerBound(AppendTimeKey(append([]byte{}, prefix...), seek))
+		var last time.Time
+		var n int
+		for _, tm, ok := it.Next(); ok; _, tm, ok = it.Next() {
+			if tm.Before(seek) {
+				t.Fatalf("seek %v: got earlier time %v", seek, tm)
+			}
+			if n > 0 && !tm.After(last) {
+				t.Fatalf("seek %v: not ascending at %v", seek, tm)
+			}
+			last = tm
+			n++
+		}
+		if want := countTimes(times, func(tm time.Time) bool { return !tm.Before(seek) }); n != want {
+			t.Fatalf("seek %v: got %d times, want %d", seek, n, want)
+		}
+
+		// Reverse encoding: every time at or before seek, newest first.
+		it = rev.Root().Iterator()
+		it.SeekLowerBound(AppendReverseTimeKey(append([]byte{}, prefix...), seek))
+		n = 0
+		for _, tm, ok := it.Next(); ok; _, tm, ok = it.Next() {
+			if tm.After(seek) {
+				t.Fatalf("seek %v: got later time %v", seek, tm)
+			}
+			if n > 0 && !tm.Before(last) {
+				t.Fatalf("seek %v: not descending at %v", seek, tm)
+			}
+			last = tm
+			n++
+		}
+		if want := countTimes(times, func(tm time.Time) bool { return !tm.After(seek) }); n != want {
+			t.Fatalf("seek %v: got %d times, want %d", seek, n, want)
+		}
+	}
+}
+
+func countTimes(times []time.Time, fn func(time.Time) bool) int {
+	var n int
+	for _, tm := range times {
+		if fn(tm) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,83 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import "bytes"
+
+// WatchedIterator iterates over the keys under a prefix while watching them
+// for changes, for long reads over live data such as paginated listings. Once
+// a newer version of the tree changes any key under the prefix, Next stops
+// returning keys and Err returns ErrInvalidated, and the iteration can be
+// carried on over the newer tree with Restart, picking up after the last key
+// that was returned.
+//
+// Changes are only noticed if they're committed by transactions that track
+// mutations, as for the channels returned by SeekPrefixWatch. The keys seen
+// before a restart come from the older tree, so the iteration as a whole
+// isn't a consistent snapshot; restart from scratch on the newer tree if
+// that's needed.
+type WatchedIterator[T any] struct {
+	prefix []byte
+	it     *Iterator[T]
+	watch  <-chan struct{}
+	last   []byte
+	err    error
+}
+
+// WatchedPrefixIterator returns an iterator over the keys with the given
+// prefix that watches them for changes.
+func (t *Tree[T]) WatchedPrefixIterator(prefix []byte) *WatchedIterator[T] {
+	i := &WatchedIterator[T]{prefix: t.conf.key(prefix)}
+	i.seek(t)
+	return i
+}
+
+// Next returns the next key and value, or false once the keys are exhausted or
+// they've been changed by a newer tree, which can be told apart with Err.
+func (i *WatchedIterator[T]) Next() ([]byte, T, bool) {
+	var zero T
+	if i.err != nil {
+		return nil, zero, false
+	}
+	select {
+	case <-i.watch:
+		i.err = ErrInvalidated
+		return nil, zero, false
+	default:
+	}
+
+	k, v, ok := i.it.Next()
+	if !ok || !bytes.HasPrefix(k, i.prefix) {
+		return nil, zero, false
+	}
+	i.last = k
+	return k, v, true
+}
+
+// Err returns ErrInvalidated if the iteration stopped because the keys under
+// the prefix were changed, or nil otherwise.
+func (i *WatchedIterator[T]) Err() error {
+	return i.err
+}
+
+// Restart carries on the iteration over the given tree, which would normally
+// be the newer version that invalidated it, after the last key returned by
+// Next.
+func (i *WatchedIterator[T]) Restart(t *Tree[T]) {
+	i.err = nil
+	i.seek(t)
+}
+
+// seek positions the iterator on the given tree and gets a new watch channel.
+func (i *WatchedIterator[T]) seek(t *Tree[T]) {
+	i.it = t.Iterator()
+	i.watch = i.it.SeekPrefixWatch(i.prefix)
+	if i.last != nil {
+		// Resume with a separate iterator, since the one holding the
+		// watch has already been seeked. Next stops at the end of the
+		// prefix.
+		i.it = t.Iterator()
+		i.it.SeekAfter(i.last)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWatchedIterator(t *testing.T) {
+	r := New[int]()
+	for i, k := range []string{"a", "svc/1", "svc/2", "svc/3", "svc/4", "z"} {
+		r, _, _ = r.Insert([]byte(k), i)
+	}
+	commit := func(r *Tree[int], fn func(txn *Txn[int])) *Tree[int] {
+		txn := r.Txn()
+		txn.TrackMutate(true)
+		fn(txn)
+		return txn.Commit()
+	}
+
+	it := r.WatchedPrefixIterator([]byte("svc/"))
+	var got []string
+	for i := 0; i < 2; i++ {
+		k, _, ok := it.Next()
+		if !ok {
+			t.Fatalf("expected key")
+		}
+		got = append(got, string(k))
+	}
+
+	// Changes outside the prefix don't invalidate the iterator.
+	r = commit(r, func(txn *Txn[int]) { txn.Insert([]byte("b"), 0) })
+	if k, _, ok := it.Next(); !ok {
+		t.Fatalf("expected key")
+	} else {
+		got = append(got, string(k))
+	}
+
+	// Changes under it do, and the iteration carries on over the new tree.
+	r = commit(r, func(txn *Txn[int]) {
+		txn.Delete([]byte("svc/4"))
+		txn.Insert([]byte("svc/35"), 0)
+		txn.Insert([]byte("svc/0"), 0)
+	})
+	if _, _, ok := it.Next(); ok || it.Err() != ErrInvalidated {
+		t.Fatalf("expected invalidation, got %v", it.Err())
+	}
+	if _, _, ok := it.Next(); ok {
+		t.Fatalf("expected no key")
+	}
+	it.Restart(r)
+	for k, _, ok := it.Next(); ok; k, _, ok = it.Next() {
+		got = append(got, string(k))
+	}
+	if it.Err() != nil {
+		t.Fatalf("err: %v", it.Err())
+	}
+	if expect := []string{"svc/1", "svc/2", "svc/3", "svc/35"}; !reflect.DeepEqual(got, expect) {
+		t.Fatalf("got %v, want %v", got, expect)
+	}
+
+	// A prefix without keys gives an empty iteration.
+	it = r.WatchedPrefixIterator([]byte("missing/"))
+	if _, _, ok := it.Next(); ok || it.Err() != nil {
+		t.Fatalf("expected no keys")
+	}
+}
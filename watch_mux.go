@@ -0,0 +1,223 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"bytes"
+	"sync"
+)
+
+// WatchMux fans out changes between versions of a tree to many subscribers,
+// each interested in some keys or prefixes. Unlike watch channels, which are
+// closed by Notify, a WatchMux never blocks on a subscriber: every subscriber
+// has its own queue of changed keys and is told when it's non-empty, so one slow
+// consumer can't delay delivery to the others, and the cost of publishing is
+// proportional to the changes and the subscribers they concern, not to the
+// total number of watchers.
+//
+// A WatchMux is safe for concurrent use.
+type WatchMux[T any] struct {
+	l    sync.Mutex
+	root *Node[T]
+
+	// interests holds the subscribers interested in each key or prefix.
+	// It's only accessed under the lock, so the sets are modified in place.
+	interests *Tree[*interestSet[T]]
+}
+
+// interestSet holds the subscribers to a single key or prefix.
+type interestSet[T any] struct {
+	exact  map[*Subscription[T]]struct{}
+	prefix map[*Subscription[T]]struct{}
+}
+
+// NewWatchMux returns a WatchMux that will report changes relative to the given
+// tree.
+func NewWatchMux[T any](t *Tree[T]) *WatchMux[T] {
+	return &WatchMux[T]{
+		root:      t.root,
+		interests: New[*interestSet[T]](),
+	}
+}
+
+// Subscribe returns a new subscriber with no interests.
+func (m *WatchMux[T]) Subscribe() *Subscription[T] {
+	return &Subscription[T]{
+		mux:     m,
+		ready:   make(chan struct{}, 1),
+		pending: make(map[string]struct{}),
+	}
+}
+
+// Publish reports the changes between the last published tree, or the one the
+// mux was created with, and t to the interested subscribers. Only the parts of
+// the trees that differ are visited.
+func (m *WatchMux[T]) Publish(t *Tree[T]) {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	it := NewChangedIterator(m.root, t.root)
+	m.root = t.root
+	interests := m.interests.Root()
+	for k, ok := it.Next(); ok; k, ok = it.Next() {
+		interests.WalkPath(k, func(ik []byte, set *interestSet[T]) bool {
+			for s := range set.prefix {
+				s.deliver(k)
+			}
+			if bytes.Equal(ik, k) {
+				for s := range set.exact {
+					s.deliver(k)
+				}
+			}
+			return false
+		})
+	}
+}
+
+// add registers the subscriber's interest in the key or prefix, unless it has
+// been closed. The interest is recorded on the subscription while the mux is
+// locked, so a concurrent Close either sees it and removes it afterwards, or
+// has already closed the subscription and it isn't added.
+func (m *WatchMux[T]) add(s *Subscription[T], k []byte, prefix bool) {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	s.l.Lock()
+	closed := s.closed
+	if !closed {
+		s.interests = append(s.interests, interest{k, prefix})
+	}
+	s.l.Unlock()
+	if closed {
+		return
+	}
+
+	set, ok := m.interests.Get(k)
+	if !ok {
+		set = &interestSet[T]{
+			exact:  make(map[*Subscription[T]]struct{}),
+			prefix: make(map[*Subscription[T]]struct{}),
+		}
+		m.interests, _, _ = m.interests.Insert(k, set)
+	}
+	if prefix {
+		set.prefix[s] = struct{}{}
+	} else {
+		set.exact[s] = struct{}{}
+	}
+}
+
+// remove drops all the interests of the subscriber.
+func (m *WatchMux[T]) remove(s *Subscription[T], keys []interest) {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	txn := m.interests.Txn()
+	for _, i := range keys {
+		set, ok := txn.Get(i.key)
+		if !ok {
+			continue
+		}
+		delete(set.exact, s)
+		delete(set.prefix, s)
+		if len(set.exact) == 0 && len(set.prefix) == 0 {
+			txn.Delete(i.key)
+		}
+	}
+	m.interests = txn.Commit()
+}
+
+// interest is a key or prefix a subscriber has registered.
+type interest struct {
+	key    []byte
+	prefix bool
+}
+
+// Subscription receives the changes published to a WatchMux for the keys and
+// prefixes it's interested in. Changed keys are queued, coalescing repeated
+// changes to the same key, until they are collected with Changes. A
+// subscription should be used by a single goroutine, typically in a loop like:
+//
+//	for range s.Ready() {
+//		for _, k := range s.Changes() {
+//			...
+//		}
+//	}
+type Subscription[T any] struct {
+	mux   *WatchMux[T]
+	ready chan struct{}
+
+	l         sync.Mutex
+	pending   map[string]struct{}
+	interests []interest
+	closed    bool
+}
+
+// Watch registers interest in changes to the given key.
+func (s *Subscription[T]) Watch(k []byte) {
+	s.watch(k, false)
+}
+
+// WatchPrefix registers interest in changes to any key with the given prefix.
+func (s *Subscription[T]) WatchPrefix(prefix []byte) {
+	s.watch(prefix, true)
+}
+
+func (s *Subscription[T]) watch(k []byte, prefix bool) {
+	s.mux.add(s, append([]byte{}, k...), prefix)
+}
+
+// Ready returns a channel that receives a value when changes are waiting to be
+// collected with Changes, and is closed when the subscription is closed.
+func (s *Subscription[T]) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Changes returns the keys that have changed since the last call, in no
+// particular order.
+func (s *Subscription[T]) Changes() [][]byte {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if len(s.pending) == 0 {
+		return nil
+	}
+	keys := make([][]byte, 0, len(s.pending))
+	for k := range s.pending {
+		keys = append(keys, []byte(k))
+	}
+	s.pending = make(map[string]struct{})
+	return keys
+}
+
+// Close drops all the subscription's interests and closes its Ready channel.
+func (s *Subscription[T]) Close() {
+	s.l.Lock()
+	if s.closed {
+		s.l.Unlock()
+		return
+	}
+	s.closed = true
+	interests := s.interests
+	s.interests = nil
+	s.pending = nil
+	close(s.ready)
+	s.l.Unlock()
+	s.mux.remove(s, interests)
+}
+
+// deliver queues a changed key, without ever blocking.
+func (s *Subscription[T]) deliver(k []byte) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.pending[string(k)] = struct{}{}
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
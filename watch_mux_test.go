@@ -0,0 +1,128 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iradix
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestWatchMux(t *testing.T) {
+	r := New[int]()
+	r, _, _ = r.Insert([]byte("foo/a"), 1)
+	r, _, _ = r.Insert([]byte("bar"), 2)
+
+	m := NewWatchMux(r)
+	exact := m.Subscribe()
+	exact.Watch([]byte("bar"))
+	prefix := m.Subscribe()
+	prefix.WatchPrefix([]byte("foo/"))
+	slow := m.Subscribe()
+	slow.WatchPrefix([]byte(""))
+
+	changes := func(s *Subscription[int]) []string {
+		var keys []string
+		for _, k := range s.Changes() {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	ready := func(s *Subscription[int]) bool {
+		select {
+		case <-s.Ready():
+			return true
+		default:
+			return false
+		}
+	}
+
+	txn := r.Txn()
+	txn.Insert([]byte("foo/a"), 10)
+	txn.Insert([]byte("foo/b"), 11)
+	txn.Insert([]byte("barbaz"), 12)
+	r = txn.Commit()
+	m.Publish(r)
+
+	if ready(exact) {
+		t.Fatalf("exact subscriber shouldn't be ready")
+	}
+	if !ready(prefix) {
+		t.Fatalf("prefix subscriber should be ready")
+	}
+	if got, want := changes(prefix), []string{"foo/a", "foo/b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// The slow subscriber hasn't drained anything, and later changes are
+	// coalesced without blocking the publisher.
+	r, _, _ = r.Insert([]byte("bar"), 20)
+	m.Publish(r)
+	r, _, _ = r.Delete([]byte("foo/a"))
+	m.Publish(r)
+
+	if !ready(exact) {
+		t.Fatalf("exact subscriber should be ready")
+	}
+	if got, want := changes(exact), []string{"bar"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := changes(prefix), []string{"foo/a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if !ready(slow) || ready(slow) {
+		t.Fatalf("slow subscriber should be ready exactly once")
+	}
+	if got, want := changes(slow), []string{"bar", "barbaz", "foo/a", "foo/b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got := changes(slow); got != nil {
+		t.Fatalf("bad: %v", got)
+	}
+
+	// Closed subscribers are unregistered.
+	exact.Close()
+	prefix.Close()
+	if _, ok := <-exact.Ready(); ok {
+		t.Fatalf("ready channel should be closed")
+	}
+	if n := m.interests.Len(); n != 1 {
+		t.Fatalf("bad: %d", n)
+	}
+	r, _, _ = r.Insert([]byte("bar"), 30)
+	m.Publish(r)
+	if exact.Changes() != nil {
+		t.Fatalf("closed subscriber got changes")
+	}
+	if got, want := changes(slow), []string{"bar"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestWatchMux_CloseWhileWatching(t *testing.T) {
+	m := NewWatchMux(New[int]())
+	for i := 0; i < 1000; i++ {
+		s := m.Subscribe()
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				s.Watch([]byte{byte(j)})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			s.Close()
+		}()
+		wg.Wait()
+	}
+
+	// Interests registered concurrently with Close must not outlive it.
+	if n := m.interests.Len(); n != 0 {
+		t.Fatalf("leaked %d interests", n)
+	}
+}